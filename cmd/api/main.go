@@ -43,6 +43,11 @@ func main() {
 	} else {
 		log.Printf("Storage using %s provider", storageConfig.Provider)
 	}
+	if storageConfig.ScanProvider == "clamav" {
+		log.Printf("Upload scanning configured with ClamAV (%s)", storageConfig.ClamAVAddress)
+	} else {
+		log.Println("Warning: Upload scanning disabled")
+	}
 	paymentConfig := cfg.Payment
 	if paymentConfig.PaystackSecretKey != "" {
 		log.Println("Payment configured with Paystack")
@@ -55,6 +60,24 @@ func main() {
 	} else {
 		log.Println("Warning: ChatGPT API key not configured, using mock responses")
 	}
+	if cfg.External.OCRProvider == "tesseract" {
+		log.Printf("Prescription OCR configured with Tesseract (%s)", cfg.External.OCRLanguages)
+	} else {
+		log.Println("Prescription OCR disabled, prescriptions are read by the vision model only")
+	}
+
+	if err := api.InitDrugDatabase(); err != nil {
+		log.Printf("Warning: Medication safety checks unavailable: %v", err)
+	} else {
+		log.Println("Medication safety checks enabled")
+	}
+
+	api.StartTestKitAnalysisWorkers(db)
+	api.StartPrescriptionExtractionWorkers(db)
+	api.StartFileCollector(db)
+	api.StartDoseReminders(db)
+	api.StartRefillReminders(db)
+	api.StartDataRequests(db)
 
 	router := gin.Default()
 
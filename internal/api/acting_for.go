@@ -0,0 +1,231 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+	"gorm.io/gorm"
+)
+
+// ActingForHeader names the patient a caregiver is acting for
+const ActingForHeader = "X-Acting-For"
+
+// Access a caregiver needs beyond the link scopes
+const (
+	caregiverScopeDependant = "dependant" // any caregiver of a dependant profile, which has no one else to read its notifications
+	caregiverScopeManage    = "manage"    // only the caregiver managing a dependant profile
+)
+
+// actingForRoutes maps routes, by method and path, to the scope a caregiver
+// needs to use them on a patient's behalf. Routes not listed, such as account
+// settings, prescriptions and staff status updates, can only be used by the
+// patient themselves.
+var actingForRoutes = map[string]string{
+	"POST /api/v1/orders":            models.CaregiverScopeOrders,
+	"GET /api/v1/orders":             models.CaregiverScopeOrders,
+	"GET /api/v1/orders/:id":         models.CaregiverScopeOrders,
+	"POST /api/v1/payments/paystack": models.CaregiverScopeOrders, // test kit orders only
+	"GET /api/v1/payments":           models.CaregiverScopeOrders,
+	"GET /api/v1/payments/:id":       models.CaregiverScopeOrders,
+
+	"POST /api/v1/test-kits/results/analyze": models.CaregiverScopeTestKitResults,
+	"GET /api/v1/test-kits/results":          models.CaregiverScopeTestKitResults,
+	"GET /api/v1/test-kits/results/:id":      models.CaregiverScopeTestKitResults,
+	"DELETE /api/v1/test-kits/results/:id":   models.CaregiverScopeTestKitResults,
+	"POST /api/v1/test-results":              models.CaregiverScopeTestKitResults,
+	"GET /api/v1/test-results":               models.CaregiverScopeTestKitResults,
+	"GET /api/v1/test-results/:id":           models.CaregiverScopeTestKitResults,
+
+	"POST /api/v1/symptoms/check":  models.CaregiverScopeSymptomChecks,
+	"GET /api/v1/symptoms/history": models.CaregiverScopeSymptomChecks,
+
+	"POST /api/v1/lab-bookings":   models.CaregiverScopeLabBookings,
+	"GET /api/v1/lab-bookings":    models.CaregiverScopeLabBookings,
+	"GET /api/v1/lab-results":     models.CaregiverScopeLabBookings,
+	"GET /api/v1/lab-results/:id": models.CaregiverScopeLabBookings,
+
+	"POST /api/v1/telehealth/sessions": models.CaregiverScopeTelehealth,
+	"GET /api/v1/telehealth/sessions":  models.CaregiverScopeTelehealth,
+
+	"GET /api/v1/medical-records":                                      models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medical-records":                                     models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medical-records/me":                                   models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medical-records/me":                                  models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medical-records/:id":                                  models.CaregiverScopeMedicalRecords,
+	"PUT /api/v1/medical-records/:id":                                  models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medical-records/:id/medications":                      models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medical-records/:id/medications":                     models.CaregiverScopeMedicalRecords,
+	"PUT /api/v1/medical-records/:id/medications/:medicationId":        models.CaregiverScopeMedicalRecords,
+	"DELETE /api/v1/medical-records/:id/medications/:medicationId":     models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medical-records/:id/test-results":                     models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medical-records/:id/test-results":                    models.CaregiverScopeMedicalRecords,
+	"PUT /api/v1/medical-records/:id/test-results/:resultId":           models.CaregiverScopeMedicalRecords,
+	"DELETE /api/v1/medical-records/:id/test-results/:resultId":        models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medical-records/:id/consultations":                    models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medical-records/:id/consultations":                   models.CaregiverScopeMedicalRecords,
+	"PUT /api/v1/medical-records/:id/consultations/:consultationId":    models.CaregiverScopeMedicalRecords,
+	"DELETE /api/v1/medical-records/:id/consultations/:consultationId": models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/medications/:id/doses":                                models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/medications/:id/doses":                               models.CaregiverScopeMedicalRecords,
+	"GET /api/v1/fhir/Patient/:id/$everything":                         models.CaregiverScopeMedicalRecords,
+	"POST /api/v1/fhir/Bundle":                                         models.CaregiverScopeMedicalRecords,
+
+	// Reading notifications only; sending them is not something a caregiver does for a patient
+	"GET /api/v1/notifications":              caregiverScopeDependant,
+	"PUT /api/v1/notifications/:id/read":     caregiverScopeDependant,
+	"PUT /api/v1/notifications/read-all":     caregiverScopeDependant,
+	"GET /api/v1/notifications/unread/count": caregiverScopeDependant,
+
+	"GET /api/v1/caregivers":          caregiverScopeManage,
+	"POST /api/v1/caregivers":         caregiverScopeManage,
+	"GET /api/v1/caregivers/activity": caregiverScopeManage,
+	"PUT /api/v1/caregivers/:id":      caregiverScopeManage,
+	"DELETE /api/v1/caregivers/:id":   caregiverScopeManage,
+	"GET /api/v1/consents/documents":  caregiverScopeManage,
+	"GET /api/v1/consents":            caregiverScopeManage,
+	"POST /api/v1/consents":           caregiverScopeManage,
+	"DELETE /api/v1/consents/:id":     caregiverScopeManage,
+}
+
+// actingForScope returns the scope a route needs, and false when caregivers cannot use it
+func actingForScope(method, route string) (string, bool) {
+	scope, ok := actingForRoutes[method+" "+route]
+	return scope, ok
+}
+
+// actingUserID returns the caregiver making the request when it is made on a patient's behalf
+func actingUserID(c *gin.Context) (uuid.UUID, bool) {
+	id, ok := c.Get("acting_user_id")
+	if !ok {
+		return uuid.Nil, false
+	}
+	return id.(uuid.UUID), true
+}
+
+// activeCaregiverLink finds the link letting a caregiver act for a patient
+func activeCaregiverLink(db *gorm.DB, caregiverID, patientID uuid.UUID) (*models.CaregiverLink, error) {
+	var link models.CaregiverLink
+	err := db.Where("caregiver_id = ? AND patient_id = ? AND status = ?", caregiverID, patientID, models.CaregiverLinkActive).
+		First(&link).Error
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// authorizeActingFor checks that a caregiver may use a route for a patient,
+// returning the reason when they may not
+func authorizeActingFor(db *gorm.DB, caregiverID uuid.UUID, patient *models.User, method, route string) (*models.CaregiverLink, string, string, error) {
+	scope, ok := actingForScope(method, route)
+	if !ok {
+		return nil, "", "This action cannot be taken on behalf of someone else", nil
+	}
+	if patient.Role != "patient" {
+		// Acting for staff would hand the caregiver the staff member's role
+		return nil, scope, "You can only act on behalf of patients", nil
+	}
+	link, err := activeCaregiverLink(db, caregiverID, patient.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, scope, "You are not a caregiver for this patient", nil
+	}
+	if err != nil {
+		return nil, scope, "", err
+	}
+
+	var allowed bool
+	switch scope {
+	case caregiverScopeDependant:
+		allowed = patient.ManagedByID != nil
+	case caregiverScopeManage:
+		allowed = patient.ManagedByID != nil && *patient.ManagedByID == caregiverID
+	default:
+		allowed = link.HasScope(scope)
+	}
+	if !allowed {
+		return link, scope, "Your caregiver access does not include this", nil
+	}
+	return link, scope, "", nil
+}
+
+// recordCaregiverAudit saves an audit entry; a failure is logged rather than
+// failing a request that has already been handled
+func recordCaregiverAudit(db *gorm.DB, entry *models.CaregiverAuditEntry) {
+	if err := db.Create(entry).Error; err != nil {
+		fmt.Printf("Failed to record caregiver audit entry for %s %s by %s: %v\n", entry.Method, entry.Path, entry.CaregiverID, err)
+	}
+}
+
+// ActingFor lets a caregiver act on behalf of a patient by naming them in the
+// X-Acting-For header. Handlers then see the patient as the signed-in user, with
+// the patient role, so existing ownership checks apply unchanged; the caregiver
+// is available as acting_user_id. Every request, allowed or not, is audited.
+func ActingFor(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := strings.TrimSpace(c.GetHeader(ActingForHeader))
+		if header == "" {
+			c.Next()
+			return
+		}
+		caregiverID := c.MustGet("user_id").(uuid.UUID)
+		patientID, err := uuid.Parse(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid X-Acting-For patient ID")})
+			c.Abort()
+			return
+		}
+		if patientID == caregiverID {
+			c.Next()
+			return
+		}
+
+		entry := models.CaregiverAuditEntry{
+			CaregiverID: caregiverID,
+			PatientID:   patientID,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			IPAddress:   c.ClientIP(),
+			UserAgent:   c.Request.UserAgent(),
+		}
+
+		var patient models.User
+		if err := db.First(&patient, "id = ?", patientID).Error; err != nil {
+			entry.StatusCode = http.StatusForbidden
+			recordCaregiverAudit(db, &entry)
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "You are not a caregiver for this patient")})
+			c.Abort()
+			return
+		}
+		link, scope, reason, err := authorizeActingFor(db, caregiverID, &patient, c.Request.Method, c.FullPath())
+		if err != nil {
+			fmt.Printf("Failed to check caregiver %s acting for %s: %v\n", caregiverID, patientID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to check caregiver access")})
+			c.Abort()
+			return
+		}
+		entry.Scope = scope
+		if link != nil {
+			entry.LinkID = &link.ID
+		}
+		if reason != "" {
+			entry.StatusCode = http.StatusForbidden
+			recordCaregiverAudit(db, &entry)
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, reason)})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", patientID)
+		c.Set("role", "patient")
+		c.Set("acting_user_id", caregiverID)
+		c.Next()
+
+		entry.Allowed = true
+		entry.StatusCode = c.Writer.Status()
+		recordCaregiverAudit(db, &entry)
+	}
+}
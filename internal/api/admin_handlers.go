@@ -5,7 +5,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
 	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
 	"gorm.io/gorm"
 )
 
@@ -18,7 +20,7 @@ func CreateLabTest(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&labTest).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lab test"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create lab test")})
 			return
 		}
 
@@ -31,7 +33,7 @@ func UpdateLabTest(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var labTest models.LabTest
 		if err := db.First(&labTest, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Lab test not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab test not found")})
 			return
 		}
 
@@ -41,7 +43,7 @@ func UpdateLabTest(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&labTest).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update lab test"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update lab test")})
 			return
 		}
 
@@ -53,7 +55,7 @@ func DeleteLabTest(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		if err := db.Delete(&models.LabTest{}, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete lab test"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete lab test")})
 			return
 		}
 
@@ -65,7 +67,7 @@ func CreateHealthArticle(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -78,9 +80,10 @@ func CreateHealthArticle(db *gorm.DB) gin.HandlerFunc {
 		article.AuthorID = userID.(uuid.UUID)
 
 		if err := db.Create(&article).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create health article"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create health article")})
 			return
 		}
+		attachFiles(db, models.FileResourceHealthArticle, article.ID, article.AuthorID, article.ImageURL, article.VideoURL)
 
 		c.JSON(http.StatusCreated, article)
 	}
@@ -91,7 +94,7 @@ func UpdateHealthArticle(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var article models.HealthArticle
 		if err := db.First(&article, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Health article not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Health article not found")})
 			return
 		}
 
@@ -101,9 +104,12 @@ func UpdateHealthArticle(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&article).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update health article"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update health article")})
 			return
 		}
+		if userID, exists := c.Get("user_id"); exists {
+			attachFiles(db, models.FileResourceHealthArticle, article.ID, userID.(uuid.UUID), article.ImageURL, article.VideoURL)
+		}
 
 		c.JSON(http.StatusOK, article)
 	}
@@ -111,12 +117,106 @@ func UpdateHealthArticle(db *gorm.DB) gin.HandlerFunc {
 
 func DeleteHealthArticle(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid health article ID")})
+			return
+		}
 		if err := db.Delete(&models.HealthArticle{}, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete health article"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete health article")})
 			return
 		}
+		deleteResourceFiles(db, models.FileResourceHealthArticle, id)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Health article deleted successfully"})
 	}
 }
+
+func ListEmailTemplates(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry, err := services.EmailTemplates()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load email templates")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"templates": registry.Names(),
+			"locales":   services.SupportedLocales,
+		})
+	}
+}
+
+// @Summary Preview an email template
+// @Description Render an email template with sample data in the requested locale and format
+// @Tags Admin
+// @Produce html
+// @Param name path string true "Template name"
+// @Param lang query string false "Locale (en, sw)"
+// @Param format query string false "html, text or json"
+// @Success 200 {string} string "Rendered template"
+// @Failure 404 {object} map[string]string "Template not found"
+// @Router /api/v1/admin/email-templates/{name}/preview [get]
+// @Security Bearer
+func PreviewEmailTemplate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry, err := services.EmailTemplates()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load email templates")})
+			return
+		}
+
+		name := c.Param("name")
+		if !registry.Has(name) {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Email template not found")})
+			return
+		}
+
+		rendered, err := registry.RenderPreview(name, c.DefaultQuery("lang", services.DefaultLocale))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to render email template: ") + err.Error()})
+			return
+		}
+
+		switch c.DefaultQuery("format", "html") {
+		case "text":
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+		case "json":
+			c.JSON(http.StatusOK, rendered)
+		default:
+			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+		}
+	}
+}
+
+// @Summary AI usage
+// @Description Token, cost and failure totals per AI feature, provider and model since the server started
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/v1/admin/ai/usage [get]
+// @Security Bearer
+func GetAIUsage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.GetConfig().External
+		aiService := services.NewAIService(&cfg)
+
+		usage := services.LLMUsageSummary()
+		var totalCost float64
+		for _, totals := range usage {
+			totalCost += totals.CostUSD
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"provider": aiService.ProviderName(),
+			"models": gin.H{
+				services.LLMFeatureSymptoms:     cfg.SymptomsModel,
+				services.LLMFeatureAnalytics:    cfg.AnalyticsModel,
+				services.LLMFeatureVision:       cfg.VisionModel,
+				services.LLMFeaturePrescription: cfg.VisionModel,
+			},
+			"usage":          usage,
+			"total_cost_usd": totalCost,
+		})
+	}
+}
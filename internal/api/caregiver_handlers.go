@@ -0,0 +1,477 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+	"gorm.io/gorm"
+)
+
+// validCaregiverScopes checks that every requested scope exists
+func validCaregiverScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.CaregiverScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicUser limits a user to what caregivers and patients see of each other
+func publicUser(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "first_name", "last_name", "email", "date_of_birth", "gender", "managed_by_id")
+}
+
+// dependantProfile is someone the signed-in user can act for
+type dependantProfile struct {
+	models.CaregiverLink
+	Managed bool `json:"managed"` // A dependant profile the user manages, rather than a patient who delegated access
+}
+
+// @Summary Add a dependant
+// @Description Create a profile for a dependant who does not have an account of their own, such as a child or an elderly relative. The signed-in user manages the profile and can act for the dependant in every scope by sending the dependant's ID in the X-Acting-For header.
+// @Tags Caregivers
+// @Accept json
+// @Produce json
+// @Success 201 {object} map[string]interface{} "Dependant and caregiver link"
+// @Router /api/v1/dependants [post]
+// @Security Bearer
+func CreateDependant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caregiverID := c.MustGet("user_id").(uuid.UUID)
+
+		var req struct {
+			FirstName    string `json:"first_name" binding:"required"`
+			LastName     string `json:"last_name" binding:"required"`
+			DateOfBirth  string `json:"date_of_birth" binding:"required"`
+			Gender       string `json:"gender"`
+			Relationship string `json:"relationship" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dateOfBirth, err := time.Parse("2006-01-02", req.DateOfBirth)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid date of birth format. Use YYYY-MM-DD")})
+			return
+		}
+
+		var caregiver models.User
+		if err := db.First(&caregiver, "id = ?", caregiverID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "User not found")})
+			return
+		}
+		if caregiver.ManagedByID != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "A dependant cannot add dependants")})
+			return
+		}
+
+		// Dependants cannot sign in: they have no password, and placeholder contact
+		// details keep the unique email and phone indexes satisfied
+		dependantID := uuid.New()
+		dependant := models.User{
+			ID:                dependantID,
+			Email:             fmt.Sprintf("dependant-%s@dependants.invalid", dependantID),
+			PhoneNumber:       fmt.Sprintf("dependant-%s", dependantID),
+			FirstName:         req.FirstName,
+			LastName:          req.LastName,
+			DateOfBirth:       &dateOfBirth,
+			Gender:            req.Gender,
+			Role:              "patient",
+			PreferredLanguage: caregiver.PreferredLanguage,
+			ManagedByID:       &caregiverID,
+		}
+		link := models.CaregiverLink{
+			CaregiverID:  caregiverID,
+			PatientID:    dependantID,
+			Relationship: req.Relationship,
+			Scopes:       models.CaregiverScopes,
+			Status:       models.CaregiverLinkActive,
+			GrantedByID:  caregiverID,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&dependant).Error; err != nil {
+				return err
+			}
+			return tx.Create(&link).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to add dependant")})
+			return
+		}
+		fmt.Printf("User %s added dependant %s\n", caregiverID, dependantID)
+		c.JSON(http.StatusCreated, gin.H{"dependant": dependant, "link": link})
+	}
+}
+
+// @Summary List dependants
+// @Description List everyone the signed-in user can act for: dependant profiles they manage or help care for, and patients who delegated access to them, with the scopes of each
+// @Tags Caregivers
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Dependants"
+// @Router /api/v1/dependants [get]
+// @Security Bearer
+func ListDependants(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caregiverID := c.MustGet("user_id").(uuid.UUID)
+
+		var links []models.CaregiverLink
+		if err := db.Preload("Patient", publicUser).
+			Where("caregiver_id = ? AND status = ?", caregiverID, models.CaregiverLinkActive).
+			Order("created_at").
+			Find(&links).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch dependants")})
+			return
+		}
+		dependants := make([]dependantProfile, 0, len(links))
+		for _, link := range links {
+			managed := link.Patient != nil && link.Patient.ManagedByID != nil && *link.Patient.ManagedByID == caregiverID
+			dependants = append(dependants, dependantProfile{CaregiverLink: link, Managed: managed})
+		}
+		c.JSON(http.StatusOK, gin.H{"dependants": dependants})
+	}
+}
+
+// @Summary Update a dependant
+// @Description Update the profile of a dependant the signed-in user manages
+// @Tags Caregivers
+// @Accept json
+// @Produce json
+// @Param id path string true "Dependant (user) ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string "Dependant not found"
+// @Router /api/v1/dependants/{id} [put]
+// @Security Bearer
+func UpdateDependant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var dependant models.User
+		if err := db.Where("id = ? AND managed_by_id = ?", c.Param("id"), c.MustGet("user_id")).First(&dependant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Dependant not found")})
+			return
+		}
+
+		var req struct {
+			FirstName   *string `json:"first_name"`
+			LastName    *string `json:"last_name"`
+			DateOfBirth *string `json:"date_of_birth"`
+			Gender      *string `json:"gender"`
+			Address     *string `json:"address"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		updates := map[string]interface{}{}
+		if req.FirstName != nil {
+			updates["first_name"] = *req.FirstName
+		}
+		if req.LastName != nil {
+			updates["last_name"] = *req.LastName
+		}
+		if req.DateOfBirth != nil {
+			dateOfBirth, err := time.Parse("2006-01-02", *req.DateOfBirth)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid date of birth format. Use YYYY-MM-DD")})
+				return
+			}
+			updates["date_of_birth"] = dateOfBirth
+		}
+		if req.Gender != nil {
+			updates["gender"] = *req.Gender
+		}
+		if req.Address != nil {
+			updates["address"] = *req.Address
+		}
+		if len(updates) > 0 {
+			if err := db.Model(&dependant).Updates(updates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update dependant")})
+				return
+			}
+		}
+		if err := db.First(&dependant, "id = ?", dependant.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update dependant")})
+			return
+		}
+		c.JSON(http.StatusOK, dependant)
+	}
+}
+
+// @Summary Stop caring for someone
+// @Description Give up the signed-in user's caregiver access to a patient or dependant. The caregiver who manages a dependant profile cannot leave it.
+// @Tags Caregivers
+// @Produce json
+// @Param id path string true "Patient or dependant (user) ID"
+// @Success 200 {object} models.CaregiverLink
+// @Failure 404 {object} map[string]string "Not a caregiver for this patient"
+// @Failure 409 {object} map[string]string "The managing caregiver cannot leave"
+// @Router /api/v1/dependants/{id} [delete]
+// @Security Bearer
+func LeaveDependant(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caregiverID := c.MustGet("user_id").(uuid.UUID)
+		patientID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid patient ID")})
+			return
+		}
+		link, err := activeCaregiverLink(db, caregiverID, patientID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "You are not a caregiver for this patient")})
+			return
+		}
+		var managed int64
+		if err := db.Model(&models.User{}).Where("id = ? AND managed_by_id = ?", patientID, caregiverID).Count(&managed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update caregiver access")})
+			return
+		}
+		if managed > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The caregiver who manages a dependant profile cannot leave it")})
+			return
+		}
+		if err := revokeCaregiverLink(db, link, caregiverID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update caregiver access")})
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// revokeCaregiverLink ends a caregiver's access
+func revokeCaregiverLink(db *gorm.DB, link *models.CaregiverLink, revokedByID uuid.UUID) error {
+	now := time.Now()
+	err := db.Model(link).Updates(map[string]interface{}{
+		"status":        models.CaregiverLinkRevoked,
+		"revoked_at":    now,
+		"revoked_by_id": revokedByID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	link.Status = models.CaregiverLinkRevoked
+	link.RevokedAt = &now
+	link.RevokedByID = &revokedByID
+	fmt.Printf("Caregiver link %s of %s for patient %s revoked by %s\n", link.ID, link.CaregiverID, link.PatientID, revokedByID)
+	return nil
+}
+
+// loadPatientCaregiverLink finds the caregiver link in the :id path parameter
+// of the signed-in patient. The link of the caregiver managing a dependant
+// profile cannot be changed through it.
+func loadPatientCaregiverLink(c *gin.Context, db *gorm.DB) (*models.CaregiverLink, bool) {
+	patientID := c.MustGet("user_id").(uuid.UUID)
+	var link models.CaregiverLink
+	if err := db.Where("id = ? AND patient_id = ? AND status = ?", c.Param("id"), patientID, models.CaregiverLinkActive).
+		First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Caregiver not found")})
+		return nil, false
+	}
+	var managed int64
+	if err := db.Model(&models.User{}).Where("id = ? AND managed_by_id = ?", patientID, link.CaregiverID).Count(&managed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update caregiver access")})
+		return nil, false
+	}
+	if managed > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The access of the caregiver managing this dependant cannot be changed")})
+		return nil, false
+	}
+	return &link, true
+}
+
+// @Summary List caregivers
+// @Description List the caregivers who can act for the signed-in patient, with their scopes. The caregiver managing a dependant can list the dependant's caregivers by acting for them.
+// @Tags Caregivers
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Caregivers"
+// @Router /api/v1/caregivers [get]
+// @Security Bearer
+func ListCaregivers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var links []models.CaregiverLink
+		if err := db.Preload("Caregiver", publicUser).
+			Where("patient_id = ? AND status = ?", c.MustGet("user_id"), models.CaregiverLinkActive).
+			Order("created_at").
+			Find(&links).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch caregivers")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"caregivers": links})
+	}
+}
+
+// @Summary Add a caregiver
+// @Description Let another registered user act for the signed-in patient within the given scopes: orders, test_kit_results, symptom_checks, lab_bookings, telehealth and medical_records. The caregiver managing a dependant can share the dependant with another caregiver by acting for them.
+// @Tags Caregivers
+// @Accept json
+// @Produce json
+// @Success 201 {object} models.CaregiverLink
+// @Failure 404 {object} map[string]string "No user with that email"
+// @Failure 409 {object} map[string]interface{} "Already a caregiver"
+// @Router /api/v1/caregivers [post]
+// @Security Bearer
+func AddCaregiver(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID := c.MustGet("user_id").(uuid.UUID)
+
+		var req struct {
+			Email        string   `json:"email" binding:"required,email"`
+			Relationship string   `json:"relationship" binding:"required"`
+			Scopes       []string `json:"scopes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validCaregiverScopes(req.Scopes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid caregiver scopes")})
+			return
+		}
+
+		var caregiver models.User
+		if err := db.Where("LOWER(email) = LOWER(?) AND managed_by_id IS NULL", strings.TrimSpace(req.Email)).First(&caregiver).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No user with that email")})
+			return
+		}
+		if caregiver.ID == patientID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "You cannot be your own caregiver")})
+			return
+		}
+
+		existing, err := activeCaregiverLink(db, caregiver.ID, patientID)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Already a caregiver"), "caregiver": existing})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to add caregiver")})
+			return
+		}
+
+		grantedByID := patientID
+		if actingID, ok := actingUserID(c); ok {
+			grantedByID = actingID
+		}
+		link := models.CaregiverLink{
+			CaregiverID:  caregiver.ID,
+			PatientID:    patientID,
+			Relationship: req.Relationship,
+			Scopes:       req.Scopes,
+			Status:       models.CaregiverLinkActive,
+			GrantedByID:  grantedByID,
+		}
+		if err := db.Create(&link).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to add caregiver")})
+			return
+		}
+
+		var patient models.User
+		if err := db.First(&patient, "id = ?", patientID).Error; err == nil {
+			if err := NotifyCaregiverAdded(db, &caregiver, &patient, &link); err != nil {
+				fmt.Printf("Failed to notify caregiver %s: %v\n", caregiver.ID, err)
+			}
+		}
+		fmt.Printf("User %s made %s a caregiver of %s\n", grantedByID, caregiver.ID, patientID)
+		c.JSON(http.StatusCreated, link)
+	}
+}
+
+// @Summary Update a caregiver's scopes
+// @Description Change what a caregiver can do on the signed-in patient's behalf
+// @Tags Caregivers
+// @Accept json
+// @Produce json
+// @Param id path string true "Caregiver link ID"
+// @Success 200 {object} models.CaregiverLink
+// @Failure 404 {object} map[string]string "Caregiver not found"
+// @Router /api/v1/caregivers/{id} [put]
+// @Security Bearer
+func UpdateCaregiver(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, ok := loadPatientCaregiverLink(c, db)
+		if !ok {
+			return
+		}
+		var req struct {
+			Scopes []string `json:"scopes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validCaregiverScopes(req.Scopes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid caregiver scopes")})
+			return
+		}
+		link.Scopes = req.Scopes
+		if err := db.Model(link).Update("scopes", link.Scopes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update caregiver access")})
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// @Summary Remove a caregiver
+// @Description End a caregiver's access to the signed-in patient. Their past actions stay in the activity log.
+// @Tags Caregivers
+// @Produce json
+// @Param id path string true "Caregiver link ID"
+// @Success 200 {object} models.CaregiverLink
+// @Failure 404 {object} map[string]string "Caregiver not found"
+// @Router /api/v1/caregivers/{id} [delete]
+// @Security Bearer
+func RemoveCaregiver(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		link, ok := loadPatientCaregiverLink(c, db)
+		if !ok {
+			return
+		}
+		revokedByID := c.MustGet("user_id").(uuid.UUID)
+		if actingID, ok := actingUserID(c); ok {
+			revokedByID = actingID
+		}
+		if err := revokeCaregiverLink(db, link, revokedByID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update caregiver access")})
+			return
+		}
+		c.JSON(http.StatusOK, link)
+	}
+}
+
+// @Summary Caregiver activity
+// @Description List every request caregivers made, or were refused, on the signed-in patient's behalf, newest first
+// @Tags Caregivers
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {object} map[string]interface{} "Activity"
+// @Router /api/v1/caregivers/activity [get]
+// @Security Bearer
+func ListCaregiverActivity(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.CaregiverAuditEntry{}).Where("patient_id = ?", c.MustGet("user_id"))
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch caregiver activity")})
+			return
+		}
+		var entries []models.CaregiverAuditEntry
+		if err := query.Order("created_at DESC").Scopes(Paginate(c)).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch caregiver activity")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"activity": entries, "total": total})
+	}
+}
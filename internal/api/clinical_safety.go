@@ -0,0 +1,245 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// recentDispenseWindow is how long dispensed prescriptions count as current
+// medication; their durations are free text, so they cannot be relied on
+const recentDispenseWindow = 30 * 24 * time.Hour
+
+var (
+	drugDB     *services.DrugDatabase
+	drugDBErr  error
+	drugDBOnce sync.Once
+)
+
+// drugDatabase returns the drug database used for safety checks, loading it on first use
+func drugDatabase() (*services.DrugDatabase, error) {
+	drugDBOnce.Do(func() {
+		drugDB, drugDBErr = services.LoadDrugDatabase(config.GetConfig().External.DrugDatabasePath)
+	})
+	return drugDB, drugDBErr
+}
+
+// InitDrugDatabase loads the drug database at startup so a broken file is reported early
+func InitDrugDatabase() error {
+	_, err := drugDatabase()
+	return err
+}
+
+// patientSafetyProfile gathers the allergies, chronic conditions and current
+// medications from a patient's medical records and other open prescriptions.
+// excludePrescriptionID is the prescription being checked, if any, and its refills;
+// excludeMedicationID is the medication being checked, if any.
+func patientSafetyProfile(db *gorm.DB, patientID uuid.UUID, excludePrescriptionID, excludeMedicationID uuid.UUID) (services.SafetyProfile, error) {
+	profile := services.SafetyProfile{}
+
+	var records []models.MedicalRecord
+	if err := db.Where("user_id = ?", patientID).Find(&records).Error; err != nil {
+		return profile, err
+	}
+	recordIDs := make([]uuid.UUID, 0, len(records))
+	for _, record := range records {
+		recordIDs = append(recordIDs, record.ID)
+		profile.Allergies = appendUnique(profile.Allergies, record.Allergies...)
+		profile.Conditions = appendUnique(profile.Conditions, record.ChronicConditions...)
+	}
+
+	if len(recordIDs) > 0 {
+		var medications []string
+		if err := db.Model(&models.Medication{}).
+			Where("medical_record_id IN ? AND id <> ? AND (end_date IS NULL OR end_date > ?)", recordIDs, excludeMedicationID, time.Now()).
+			Pluck("name", &medications).Error; err != nil {
+			return profile, err
+		}
+		profile.Medications = appendUnique(profile.Medications, medications...)
+	}
+
+	// Refills repeat the same medication, so the whole refill family is excluded
+	family := excludePrescriptionID
+	if excludePrescriptionID != uuid.Nil {
+		var excluded models.Prescription
+		if err := db.Select("id", "original_prescription_id").First(&excluded, "id = ?", excludePrescriptionID).Error; err == nil && excluded.OriginalPrescriptionID != nil {
+			family = *excluded.OriginalPrescriptionID
+		}
+	}
+
+	var prescribed []string
+	if err := db.Model(&models.PrescriptionMedication{}).
+		Joins("JOIN prescriptions ON prescriptions.id = prescription_medications.prescription_id AND prescriptions.deleted_at IS NULL").
+		Where("prescriptions.user_id = ? AND prescription_medications.available", patientID).
+		Where("prescriptions.id NOT IN ? AND (prescriptions.original_prescription_id IS NULL OR prescriptions.original_prescription_id <> ?)",
+			[]uuid.UUID{excludePrescriptionID, family}, family).
+		Where("(prescriptions.status IN ? OR (prescriptions.status = ? AND prescriptions.dispensed_at > ?))",
+			[]string{
+				models.PrescriptionStatusApproved,
+				models.PrescriptionStatusAccepted,
+				models.PrescriptionStatusPaid,
+				models.PrescriptionStatusOutForDelivery,
+			},
+			models.PrescriptionStatusDispensed, time.Now().Add(-recentDispenseWindow)).
+		Pluck("prescription_medications.name", &prescribed).Error; err != nil {
+		return profile, err
+	}
+	profile.Medications = appendUnique(profile.Medications, prescribed...)
+
+	return profile, nil
+}
+
+// appendUnique appends the non-empty values not already present, ignoring case
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range list {
+			if strings.EqualFold(existing, value) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// safetyCheckInput describes medications about to be added for a patient
+type safetyCheckInput struct {
+	PatientID      uuid.UUID
+	Context        string
+	PrescriptionID *uuid.UUID
+	MedicationID   *uuid.UUID
+	Medications    []string
+	OverrideReason string
+	WarnOnly       bool // record alerts without blocking, for lines that still need confirming
+}
+
+// checkMedicationSafety checks medications against the patient's record and
+// records any alerts for audit. Major and contraindicated alerts block the change
+// with a conflict unless an override reason is given, or the same alerts were
+// already overridden for the prescription. It returns false when it has responded.
+func checkMedicationSafety(c *gin.Context, db *gorm.DB, input safetyCheckInput) (*services.SafetyCheck, bool) {
+	drugs, err := drugDatabase()
+	if err != nil {
+		fmt.Printf("Drug database unavailable: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Medication safety checks are unavailable")})
+		return nil, false
+	}
+
+	exclude, excludeMedication := uuid.Nil, uuid.Nil
+	if input.PrescriptionID != nil {
+		exclude = *input.PrescriptionID
+	}
+	if input.MedicationID != nil {
+		excludeMedication = *input.MedicationID
+	}
+	profile, err := patientSafetyProfile(db, input.PatientID, exclude, excludeMedication)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load the patient's medical record")})
+		return nil, false
+	}
+
+	check := drugs.Check(input.Medications, profile)
+	if len(check.Alerts) == 0 {
+		return check, true
+	}
+
+	audit := models.MedicationSafetyCheck{
+		PatientID:      input.PatientID,
+		CheckedByID:    c.MustGet("user_id").(uuid.UUID),
+		Role:           c.GetString("role"),
+		Context:        input.Context,
+		PrescriptionID: input.PrescriptionID,
+		MedicationID:   input.MedicationID,
+		Medications:    input.Medications,
+		Alerts:         check.Alerts,
+		Outcome:        models.SafetyOutcomeWarned,
+	}
+	if check.Blocking && !input.WarnOnly {
+		reason := strings.TrimSpace(input.OverrideReason)
+		if reason == "" && input.PrescriptionID != nil {
+			reason = overriddenForPrescription(db, *input.PrescriptionID, check.Alerts)
+		}
+		if reason != "" {
+			audit.Outcome = models.SafetyOutcomeOverridden
+			audit.OverrideReason = reason
+		} else {
+			audit.Outcome = models.SafetyOutcomeBlocked
+		}
+	}
+
+	if err := db.Create(&audit).Error; err != nil {
+		fmt.Printf("Failed to record medication safety check: %v\n", err)
+		// An override must be on record before it takes effect
+		if audit.Outcome == models.SafetyOutcomeOverridden {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to record the safety override")})
+			return nil, false
+		}
+	}
+
+	if audit.Outcome == models.SafetyOutcomeBlocked {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        tr(c, "The medication safety check found serious problems. Review the alerts and provide an override_reason to continue."),
+			"alerts":       check.Alerts,
+			"unrecognized": check.Unrecognized,
+		})
+		return nil, false
+	}
+	return check, true
+}
+
+// overriddenForPrescription returns the recorded override reason when every
+// blocking alert was already overridden for the prescription, so approving lines
+// that were overridden when they were added does not need a second override
+func overriddenForPrescription(db *gorm.DB, prescriptionID uuid.UUID, alerts []models.SafetyAlert) string {
+	var overrides []models.MedicationSafetyCheck
+	if err := db.Where("prescription_id = ? AND outcome = ?", prescriptionID, models.SafetyOutcomeOverridden).
+		Order("created_at DESC").
+		Find(&overrides).Error; err != nil || len(overrides) == 0 {
+		return ""
+	}
+
+	key := func(alert models.SafetyAlert) string {
+		return strings.ToLower(alert.Type + "|" + alert.Medication + "|" + alert.Against)
+	}
+	acknowledged := map[string]int{}
+	for _, override := range overrides {
+		for _, alert := range override.Alerts {
+			if rank := services.SafetySeverityRank(alert.Severity); rank > acknowledged[key(alert)] {
+				acknowledged[key(alert)] = rank
+			}
+		}
+	}
+
+	for _, alert := range alerts {
+		if services.IsBlockingSeverity(alert.Severity) && acknowledged[key(alert)] < services.SafetySeverityRank(alert.Severity) {
+			return ""
+		}
+	}
+	return overrides[0].OverrideReason
+}
+
+// saveSafetyAlerts stores the latest safety check on a prescription for the pharmacist to see
+func saveSafetyAlerts(db *gorm.DB, prescriptionID uuid.UUID, check *services.SafetyCheck) {
+	if err := db.Model(&models.Prescription{ID: prescriptionID}).
+		Select("SafetyAlerts").
+		Updates(&models.Prescription{SafetyAlerts: check.Alerts}).Error; err != nil {
+		fmt.Printf("Failed to save safety alerts for prescription %s: %v\n", prescriptionID, err)
+	}
+}
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/middleware"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// consentDocuments returns the current document of each purpose, and the oldest
+// version of each whose grants are still valid
+func consentDocuments(db *gorm.DB) (map[string]models.ConsentDocument, map[string]int, error) {
+	var documents []models.ConsentDocument
+	if err := db.Order("purpose, version").Find(&documents).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load consent documents: %w", err)
+	}
+	current := map[string]models.ConsentDocument{}
+	minimum := map[string]int{}
+	for _, document := range documents {
+		current[document.Purpose] = document
+		if document.RequiresReconsent {
+			minimum[document.Purpose] = document.Version
+		}
+	}
+	return current, minimum, nil
+}
+
+// activeConsentGrants selects a patient's grants that have not been revoked or expired
+func activeConsentGrants(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Model(&models.ConsentGrant{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now())
+}
+
+// hasConsent reports whether a patient currently consents to a purpose. Consent
+// given to a version that has since been superseded by one requiring fresh
+// consent no longer counts.
+func hasConsent(db *gorm.DB, userID uuid.UUID, purpose string) (bool, error) {
+	_, minimum, err := consentDocuments(db)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = activeConsentGrants(db, userID).
+		Where("purpose = ? AND document_version >= ?", purpose, minimum[purpose]).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// recordSharedWith reports whether a patient has shared their medical record with a clinician
+func recordSharedWith(db *gorm.DB, patientID, providerID uuid.UUID) (bool, error) {
+	_, minimum, err := consentDocuments(db)
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = activeConsentGrants(db, patientID).
+		Where("purpose = ? AND provider_id = ? AND document_version >= ?",
+			models.ConsentPurposeProviderSharing, providerID, minimum[models.ConsentPurposeProviderSharing]).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// clinicianMayAccessRecord reports whether the signed-in user may read or write a
+// patient's medical record: the patient themselves, or a clinician they have
+// shared it with
+func clinicianMayAccessRecord(c *gin.Context, db *gorm.DB, patientID uuid.UUID) bool {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	if userID == patientID {
+		return true
+	}
+	role, _ := c.Get("role")
+	if !middleware.IsClinicianRole(role) {
+		return false
+	}
+	shared, err := recordSharedWith(db, patientID, userID)
+	if err != nil {
+		// Fail closed: without a confirmed grant the record stays private
+		fmt.Printf("Failed to check record sharing of patient %s with %s: %v\n", patientID, userID, err)
+		return false
+	}
+	return shared
+}
+
+// aiServiceFor returns the AI service to use on a patient's data, which only
+// sends it to a model when the patient consents to AI analysis
+func aiServiceFor(db *gorm.DB, userID uuid.UUID) *services.AIService {
+	consented, err := hasConsent(db, userID, models.ConsentPurposeAIAnalysis)
+	if err != nil {
+		fmt.Printf("Failed to check AI consent of user %s, analysing without AI: %v\n", userID, err)
+	}
+	return services.NewAIService(&config.GetConfig().External).ForPatient(err == nil && consented)
+}
+
+// purposeConsent is the consent state of one purpose
+type purposeConsent struct {
+	Purpose           string     `json:"purpose"`
+	Granted           bool       `json:"granted"`
+	GrantID           *uuid.UUID `json:"grant_id,omitempty"`
+	GrantedVersion    int        `json:"granted_version,omitempty"`
+	GrantedAt         *time.Time `json:"granted_at,omitempty"`
+	CurrentVersion    int        `json:"current_version"`
+	CurrentDocumentID *uuid.UUID `json:"current_document_id,omitempty"`
+	ReconsentRequired bool       `json:"reconsent_required"` // Consent was given to a version that has lapsed
+}
+
+// recordShare is a clinician a patient has shared their medical record with
+type recordShare struct {
+	GrantID      uuid.UUID  `json:"grant_id"`
+	ProviderID   uuid.UUID  `json:"provider_id"`
+	ProviderName string     `json:"provider_name"`
+	GrantedAt    time.Time  `json:"granted_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// consentState summarises a patient's consents
+type consentState struct {
+	Purposes   []purposeConsent `json:"purposes"`
+	SharedWith []recordShare    `json:"shared_with"`
+}
+
+// loadConsentState works out a patient's current consent to every purpose and
+// who their medical record is shared with
+func loadConsentState(db *gorm.DB, userID uuid.UUID) (*consentState, error) {
+	current, minimum, err := consentDocuments(db)
+	if err != nil {
+		return nil, err
+	}
+	var grants []models.ConsentGrant
+	if err := activeConsentGrants(db, userID).Order("granted_at DESC").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to load consent grants: %w", err)
+	}
+
+	state := &consentState{Purposes: []purposeConsent{}, SharedWith: []recordShare{}}
+	var providerIDs []uuid.UUID
+	for _, purpose := range models.ConsentPurposes {
+		consent := purposeConsent{Purpose: purpose}
+		if document, ok := current[purpose]; ok {
+			consent.CurrentVersion = document.Version
+			consent.CurrentDocumentID = &document.ID
+		}
+		for i := range grants {
+			grant := &grants[i]
+			if grant.Purpose != purpose {
+				continue
+			}
+			if grant.DocumentVersion < minimum[purpose] {
+				consent.ReconsentRequired = !consent.Granted
+				continue
+			}
+			if purpose == models.ConsentPurposeProviderSharing && grant.ProviderID != nil {
+				state.SharedWith = append(state.SharedWith, recordShare{
+					GrantID:    grant.ID,
+					ProviderID: *grant.ProviderID,
+					GrantedAt:  grant.GrantedAt,
+					ExpiresAt:  grant.ExpiresAt,
+				})
+				providerIDs = append(providerIDs, *grant.ProviderID)
+			}
+			if !consent.Granted {
+				consent.Granted = true
+				consent.ReconsentRequired = false
+				consent.GrantID = &grant.ID
+				consent.GrantedVersion = grant.DocumentVersion
+				consent.GrantedAt = &grant.GrantedAt
+			}
+		}
+		state.Purposes = append(state.Purposes, consent)
+	}
+
+	if len(providerIDs) > 0 {
+		var providers []models.User
+		if err := db.Select("id", "first_name", "last_name").Where("id IN ?", providerIDs).Find(&providers).Error; err != nil {
+			return nil, fmt.Errorf("failed to load clinicians: %w", err)
+		}
+		names := map[uuid.UUID]string{}
+		for _, provider := range providers {
+			names[provider.ID] = strings.TrimSpace(provider.FirstName + " " + provider.LastName)
+		}
+		for i := range state.SharedWith {
+			state.SharedWith[i].ProviderName = names[state.SharedWith[i].ProviderID]
+		}
+	}
+	return state, nil
+}
+
+// @Summary List consent documents
+// @Description List the current version of the consent document for each purpose: ai_analysis, provider_sharing, research and marketing
+// @Tags Consent
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Consent documents"
+// @Router /api/v1/consents/documents [get]
+// @Security Bearer
+func ListConsentDocuments(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current, _, err := consentDocuments(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consent documents")})
+			return
+		}
+		documents := make([]models.ConsentDocument, 0, len(current))
+		for _, purpose := range models.ConsentPurposes {
+			if document, ok := current[purpose]; ok {
+				documents = append(documents, document)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"documents": documents})
+	}
+}
+
+// @Summary List consents
+// @Description Get the current user's consent to each purpose, who their medical record is shared with, and the history of every consent given and withdrawn
+// @Tags Consent
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Consent state and history"
+// @Router /api/v1/consents [get]
+// @Security Bearer
+func ListConsents(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+		state, err := loadConsentState(db, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consents")})
+			return
+		}
+		var history []models.ConsentGrant
+		if err := db.Where("user_id = ?", userID).Order("granted_at DESC").Find(&history).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consents")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"consents": state, "history": history})
+	}
+}
+
+// @Summary Give consent
+// @Description Consent to the current version of a purpose's consent document. The document ID confirms which version the patient was shown. Sharing the medical record needs the clinician to share it with, and can be given an end date.
+// @Tags Consent
+// @Accept json
+// @Produce json
+// @Success 201 {object} models.ConsentGrant
+// @Failure 409 {object} map[string]interface{} "The document is not the current version, or consent is already given"
+// @Router /api/v1/consents [post]
+// @Security Bearer
+func GrantConsent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+
+		var req struct {
+			DocumentID uuid.UUID  `json:"document_id" binding:"required"`
+			ProviderID *uuid.UUID `json:"provider_id"` // required for provider_sharing
+			ExpiresAt  *time.Time `json:"expires_at"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var document models.ConsentDocument
+		if err := db.First(&document, "id = ?", req.DocumentID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Consent document not found")})
+			return
+		}
+		current, minimum, err := consentDocuments(db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to record consent")})
+			return
+		}
+		if current[document.Purpose].ID != document.ID {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":               tr(c, "A newer version of this consent document has been published"),
+				"current_document_id": current[document.Purpose].ID,
+			})
+			return
+		}
+
+		grant := models.ConsentGrant{
+			UserID:          userID,
+			Purpose:         document.Purpose,
+			DocumentID:      document.ID,
+			DocumentVersion: document.Version,
+			GrantedAt:       time.Now(),
+			IPAddress:       c.ClientIP(),
+			UserAgent:       c.Request.UserAgent(),
+		}
+		existing := activeConsentGrants(db, userID).Where("purpose = ? AND document_version >= ?", document.Purpose, minimum[document.Purpose])
+
+		if document.Purpose == models.ConsentPurposeProviderSharing {
+			if req.ProviderID == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Choose the clinician to share your record with")})
+				return
+			}
+			var provider models.User
+			if err := db.First(&provider, "id = ?", *req.ProviderID).Error; err != nil || !middleware.IsClinicianRole(provider.Role) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Clinician not found")})
+				return
+			}
+			if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "The end of sharing must be in the future")})
+				return
+			}
+			grant.ProviderID = req.ProviderID
+			grant.ExpiresAt = req.ExpiresAt
+			existing = existing.Where("provider_id = ?", *req.ProviderID)
+		}
+
+		var active models.ConsentGrant
+		err = existing.First(&active).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Consent already given"), "grant": active})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to record consent")})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Consent to an earlier, lapsed version is replaced by this one
+			lapsed := tx.Model(&models.ConsentGrant{}).
+				Where("user_id = ? AND purpose = ? AND revoked_at IS NULL AND document_version < ?", userID, document.Purpose, minimum[document.Purpose])
+			if grant.ProviderID != nil {
+				lapsed = lapsed.Where("provider_id = ?", *grant.ProviderID)
+			}
+			if err := lapsed.Update("revoked_at", grant.GrantedAt).Error; err != nil {
+				return err
+			}
+			return tx.Create(&grant).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to record consent")})
+			return
+		}
+		fmt.Printf("User %s gave %s consent (version %d)\n", userID, grant.Purpose, grant.DocumentVersion)
+		c.JSON(http.StatusCreated, grant)
+	}
+}
+
+// @Summary Withdraw consent
+// @Description Withdraw a consent the current user gave. Withdrawal takes effect immediately; the consent stays in the history.
+// @Tags Consent
+// @Produce json
+// @Param id path string true "Consent grant ID"
+// @Success 200 {object} models.ConsentGrant
+// @Failure 404 {object} map[string]string "Consent not found"
+// @Router /api/v1/consents/{id} [delete]
+// @Security Bearer
+func RevokeConsent(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		grantID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid consent ID")})
+			return
+		}
+		var grant models.ConsentGrant
+		if err := db.Where("id = ? AND user_id = ?", grantID, c.MustGet("user_id")).First(&grant).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Consent not found")})
+			return
+		}
+		if grant.RevokedAt != nil {
+			c.JSON(http.StatusOK, grant)
+			return
+		}
+
+		now := time.Now()
+		if err := db.Model(&grant).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to withdraw consent")})
+			return
+		}
+		grant.RevokedAt = &now
+		fmt.Printf("User %s withdrew %s consent %s\n", grant.UserID, grant.Purpose, grant.ID)
+		c.JSON(http.StatusOK, grant)
+	}
+}
+
+// @Summary Publish a consent document
+// @Description Publish the next version of a purpose's consent document. When fresh consent is required, consent given to earlier versions lapses and patients are asked again.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 201 {object} models.ConsentDocument
+// @Failure 400 {object} map[string]string "Unknown purpose"
+// @Router /api/v1/admin/consent-documents [post]
+// @Security Bearer
+func PublishConsentDocument(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Purpose           string `json:"purpose" binding:"required"`
+			Title             string `json:"title" binding:"required"`
+			Body              string `json:"body" binding:"required"`
+			RequiresReconsent bool   `json:"requires_reconsent"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !slices.Contains(models.ConsentPurposes, req.Purpose) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unknown consent purpose")})
+			return
+		}
+
+		adminID := c.MustGet("user_id").(uuid.UUID)
+		document := models.ConsentDocument{
+			Purpose:           req.Purpose,
+			Title:             req.Title,
+			Body:              req.Body,
+			RequiresReconsent: req.RequiresReconsent,
+			PublishedByID:     &adminID,
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var latest int
+			if err := tx.Model(&models.ConsentDocument{}).Where("purpose = ?", req.Purpose).
+				Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+				return err
+			}
+			document.Version = latest + 1
+			return tx.Create(&document).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to publish consent document")})
+			return
+		}
+		c.JSON(http.StatusCreated, document)
+	}
+}
+
+// @Summary Get a shared medical record
+// @Description Get a patient's medical record with their merged history. Only clinicians the patient has shared their record with may read it.
+// @Tags Review
+// @Produce json
+// @Param id path string true "Patient (user) ID"
+// @Success 200 {object} models.MedicalRecord
+// @Failure 403 {object} map[string]string "The patient has not shared their record"
+// @Failure 404 {object} map[string]string "Medical record not found"
+// @Router /api/v1/review/patients/{id}/medical-record [get]
+// @Security Bearer
+func GetSharedMedicalRecord(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid patient ID")})
+			return
+		}
+		if !clinicianMayAccessRecord(c, db, patientID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "The patient has not shared their medical record with you")})
+			return
+		}
+
+		var record models.MedicalRecord
+		if err := db.Where("user_id = ?", patientID).First(&record).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medical record not found")})
+			return
+		}
+		fmt.Printf("Medical record of patient %s read by clinician %s\n", patientID, c.MustGet("user_id"))
+		respondWithMedicalRecord(c, db, &record)
+	}
+}
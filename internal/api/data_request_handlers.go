@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/utils"
+	"gorm.io/gorm"
+)
+
+// dataExportResponse adds where to download a completed export
+func dataExportResponse(export *models.DataExport) gin.H {
+	response := gin.H{"export": export}
+	if export.Status == models.DataExportCompleted && export.FileID != nil {
+		response["download_url"] = fileURL(*export.FileID)
+	}
+	return response
+}
+
+// @Summary Request a data export
+// @Description Start building an archive of everything held about the current user: JSON for every record, a FHIR bundle of the medical record, a PDF summary and their uploaded files. The archive is built in the background; poll the export until it completes, then download it through the files route. Only one export runs at a time.
+// @Tags Users
+// @Produce json
+// @Success 202 {object} map[string]interface{} "Export queued, or the export already in progress"
+// @Router /api/v1/users/me/export [post]
+// @Security Bearer
+func RequestDataExport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+
+		var export models.DataExport
+		err := db.Where("user_id = ? AND status IN ?", userID,
+			[]string{models.DataExportPending, models.DataExportProcessing}).
+			First(&export).Error
+		if err == nil {
+			c.JSON(http.StatusAccepted, dataExportResponse(&export))
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to request data export")})
+			return
+		}
+
+		export = models.DataExport{UserID: userID, Status: models.DataExportPending}
+		if err := db.Create(&export).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to request data export")})
+			return
+		}
+		c.JSON(http.StatusAccepted, dataExportResponse(&export))
+	}
+}
+
+// @Summary List data exports
+// @Description List the current user's data exports, newest first
+// @Tags Users
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Data exports"
+// @Router /api/v1/users/me/exports [get]
+// @Security Bearer
+func ListDataExports(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var exports []models.DataExport
+		if err := db.Where("user_id = ?", c.MustGet("user_id")).Order("created_at DESC").Find(&exports).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch data exports")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exports": exports})
+	}
+}
+
+// @Summary Get a data export
+// @Description Get the status of one of the current user's data exports, with its download URL once completed
+// @Tags Users
+// @Produce json
+// @Param id path string true "Data export ID"
+// @Success 200 {object} map[string]interface{} "Data export"
+// @Failure 404 {object} map[string]string "Data export not found"
+// @Router /api/v1/users/me/exports/{id} [get]
+// @Security Bearer
+func GetDataExport(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exportID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid data export ID")})
+			return
+		}
+		var export models.DataExport
+		if err := db.Where("id = ? AND user_id = ?", exportID, c.MustGet("user_id")).First(&export).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Data export not found")})
+			return
+		}
+		c.JSON(http.StatusOK, dataExportResponse(&export))
+	}
+}
+
+// @Summary Request account deletion
+// @Description Schedule the current user's account to be erased after the grace period, during which the request can be cancelled. Erasure deletes their health records, orders, bookings, notifications and uploaded files, and anonymises the account. Payment records are kept for financial record keeping, without contact details. Dependant profiles the user manages are handed to another of their caregivers, or erased with the account. Requires the account password.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Success 202 {object} models.AccountDeletion
+// @Failure 401 {object} map[string]string "Incorrect password"
+// @Failure 409 {object} map[string]interface{} "Deletion already scheduled"
+// @Router /api/v1/users/me/deletion [post]
+// @Security Bearer
+func RequestAccountDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.MustGet("user_id").(uuid.UUID)
+
+		var req struct {
+			Password string `json:"password" binding:"required"`
+			Reason   string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, "id = ?", userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "User not found")})
+			return
+		}
+		if !utils.CheckPasswordHash(req.Password, user.Password) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "Incorrect password")})
+			return
+		}
+
+		var existing models.AccountDeletion
+		err := db.Where("user_id = ? AND status IN ?", userID,
+			[]string{models.AccountDeletionScheduled, models.AccountDeletionProcessing}).
+			First(&existing).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Account deletion already scheduled"), "deletion": existing})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to schedule account deletion")})
+			return
+		}
+
+		graceDays := config.GetConfig().External.AccountDeletionGraceDays
+		deletion := models.AccountDeletion{
+			UserID:       userID,
+			Status:       models.AccountDeletionScheduled,
+			Reason:       req.Reason,
+			ScheduledFor: time.Now().AddDate(0, 0, graceDays),
+		}
+		if err := db.Create(&deletion).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to schedule account deletion")})
+			return
+		}
+
+		if err := NotifyAccountDeletionScheduled(db, &user, &deletion); err != nil {
+			fmt.Printf("Failed to notify user %s of scheduled deletion: %v\n", userID, err)
+		}
+		c.JSON(http.StatusAccepted, deletion)
+	}
+}
+
+// @Summary Get account deletion
+// @Description Get the current user's latest account deletion request
+// @Tags Users
+// @Produce json
+// @Success 200 {object} models.AccountDeletion
+// @Failure 404 {object} map[string]string "No deletion requested"
+// @Router /api/v1/users/me/deletion [get]
+// @Security Bearer
+func GetAccountDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var deletion models.AccountDeletion
+		if err := db.Where("user_id = ?", c.MustGet("user_id")).Order("created_at DESC").First(&deletion).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No account deletion requested")})
+			return
+		}
+		c.JSON(http.StatusOK, deletion)
+	}
+}
+
+// @Summary Cancel account deletion
+// @Description Cancel the current user's scheduled account deletion during the grace period
+// @Tags Users
+// @Produce json
+// @Success 200 {object} models.AccountDeletion
+// @Failure 404 {object} map[string]string "No deletion scheduled"
+// @Router /api/v1/users/me/deletion [delete]
+// @Security Bearer
+func CancelAccountDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var deletion models.AccountDeletion
+		if err := db.Where("user_id = ? AND status IN ?", c.MustGet("user_id"),
+			[]string{models.AccountDeletionScheduled, models.AccountDeletionProcessing}).
+			First(&deletion).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No account deletion scheduled")})
+			return
+		}
+
+		now := time.Now()
+		// Conditional on the status so a deletion the worker has already started is not cancelled
+		result := db.Model(&deletion).Where("status = ?", models.AccountDeletionScheduled).
+			Updates(map[string]interface{}{"status": models.AccountDeletionCancelled, "cancelled_at": now})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to cancel account deletion")})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Account deletion is already in progress")})
+			return
+		}
+		deletion.Status = models.AccountDeletionCancelled
+		deletion.CancelledAt = &now
+		c.JSON(http.StatusOK, deletion)
+	}
+}
@@ -0,0 +1,651 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// dataRequestInterval is how often data exports and account deletions are processed
+	dataRequestInterval = time.Minute
+	// dataRequestBatch caps how many exports and deletions are processed per run
+	dataRequestBatch = 10
+	// dataExportMaxAttempts is how many times an export is tried before it fails
+	dataExportMaxAttempts = 3
+	// dataExportStaleAfter is when an export still processing is assumed interrupted
+	dataExportStaleAfter = time.Hour
+	// accountDeletionMaxAttempts is how many times an erasure is tried before it is
+	// left failed for an operator to look at
+	accountDeletionMaxAttempts = 5
+)
+
+// StartDataRequests periodically builds requested data exports, expires old ones
+// and erases accounts whose deletion grace period has ended
+func StartDataRequests(db *gorm.DB) {
+	go func() {
+		for {
+			if err := RunDataRequests(db); err != nil {
+				fmt.Printf("Data requests failed: %v\n", err)
+			}
+			time.Sleep(dataRequestInterval)
+		}
+	}()
+}
+
+// RunDataRequests processes pending data exports, expires downloaded ones and runs
+// due account deletions
+func RunDataRequests(db *gorm.DB) error {
+	cfg := config.GetConfig()
+	storageSvc, err := services.NewStorageService(&cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+
+	if err := runDataExports(db, storageSvc, time.Duration(cfg.External.DataExportTTLHours)*time.Hour); err != nil {
+		return err
+	}
+	if err := expireDataExports(db); err != nil {
+		return err
+	}
+	return runAccountDeletions(db, storageSvc)
+}
+
+func runDataExports(db *gorm.DB, storageSvc *services.StorageService, ttl time.Duration) error {
+	stale := time.Now().Add(-dataExportStaleAfter)
+	due := "status = ? OR (status = ? AND started_at < ?)"
+
+	var exports []models.DataExport
+	if err := db.Where(due, models.DataExportPending, models.DataExportProcessing, stale).
+		Order("created_at").
+		Limit(dataRequestBatch).
+		Find(&exports).Error; err != nil {
+		return fmt.Errorf("failed to load data exports: %w", err)
+	}
+
+	for i := range exports {
+		export := &exports[i]
+
+		// Claim the export so another instance does not build it too
+		now := time.Now()
+		claim := db.Model(&models.DataExport{}).
+			Where("id = ?", export.ID).
+			Where(due, models.DataExportPending, models.DataExportProcessing, stale).
+			Updates(map[string]interface{}{
+				"status":     models.DataExportProcessing,
+				"started_at": now,
+				"attempts":   gorm.Expr("attempts + 1"),
+			})
+		if claim.Error != nil {
+			return fmt.Errorf("failed to claim data export %s: %w", export.ID, claim.Error)
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+		export.Attempts++
+
+		file, fileCount, err := buildDataExport(db, storageSvc, export)
+		if err != nil {
+			fmt.Printf("Data export %s failed (attempt %d): %v\n", export.ID, export.Attempts, err)
+			status := models.DataExportPending
+			if export.Attempts >= dataExportMaxAttempts {
+				status = models.DataExportFailed
+			}
+			if err := db.Model(export).Updates(map[string]interface{}{"status": status, "error": err.Error()}).Error; err != nil {
+				fmt.Printf("Failed to record data export %s failure: %v\n", export.ID, err)
+			}
+			if status == models.DataExportFailed {
+				if err := NotifyDataExportFailed(db, export); err != nil {
+					fmt.Printf("Failed to notify user %s of failed data export: %v\n", export.UserID, err)
+				}
+			}
+			continue
+		}
+
+		completedAt := time.Now()
+		expiresAt := completedAt.Add(ttl)
+		export.Status = models.DataExportCompleted
+		export.FileID = &file.ID
+		export.FileCount = fileCount
+		export.Size = file.Size
+		export.Error = ""
+		export.CompletedAt = &completedAt
+		export.ExpiresAt = &expiresAt
+		if err := db.Model(export).
+			Select("status", "file_id", "file_count", "size", "error", "completed_at", "expires_at").
+			Updates(export).Error; err != nil {
+			return fmt.Errorf("failed to complete data export %s: %w", export.ID, err)
+		}
+		if err := NotifyDataExportReady(db, export); err != nil {
+			fmt.Printf("Failed to notify user %s of data export: %v\n", export.UserID, err)
+		}
+	}
+	return nil
+}
+
+// expireDataExports deletes archives past their download window. The file
+// collector then removes them from storage.
+func expireDataExports(db *gorm.DB) error {
+	var expired []models.DataExport
+	if err := db.Where("status = ? AND expires_at < ?", models.DataExportCompleted, time.Now()).
+		Limit(dataRequestBatch).
+		Find(&expired).Error; err != nil {
+		return fmt.Errorf("failed to load expired data exports: %w", err)
+	}
+
+	for i := range expired {
+		export := &expired[i]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if export.FileID != nil {
+				if err := tx.Delete(&models.File{}, "id = ?", *export.FileID).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(export).Update("status", models.DataExportExpired).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to expire data export %s: %w", export.ID, err)
+		}
+	}
+	return nil
+}
+
+// dataSubjectFiles selects the files held about a user: their uploads not attached
+// to anyone's records, their data exports, and files attached to their own records
+// whoever uploaded them. Files a clinician attached to other patients' records or
+// to the catalogue are not theirs.
+func dataSubjectFiles(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	subject := db.Where("owner_id = ? AND (resource_id IS NULL OR resource_type = ?)", userID, models.FileResourceDataExport)
+	for resourceType, table := range fileResourceTables {
+		subject = subject.Or("resource_type = ? AND resource_id IN (?)", resourceType,
+			db.Table(table).Select("id").Where("user_id = ?", userID))
+	}
+	return db.Model(&models.File{}).Where(subject)
+}
+
+// dataExportSection is one JSON file of a data export
+type dataExportSection struct {
+	name string
+	rows interface{}
+}
+
+// loadDataExportSections loads every record held about a user, one section per table
+func loadDataExportSections(db *gorm.DB, userID uuid.UUID, files []models.File) ([]dataExportSection, error) {
+	var (
+		doses              []models.MedicationDose
+		safetyChecks       []models.MedicationSafetyCheck
+		prescriptions      []models.Prescription
+		extractions        []models.PrescriptionExtraction
+		refillRequests     []models.RefillRequest
+		testKitOrders      []models.TestKitOrder
+		testKitResults     []models.TestKitResult
+		labBookings        []models.LabBooking
+		labResults         []models.LabResult
+		telehealthSessions []models.TelehealthSession
+		symptomChecks      []models.SymptomCheck
+		analytics          []models.CareSenseAnalytics
+		payments           []models.Payment
+		notifications      []models.Notification
+		fileAccess         []models.FileAccessLog
+		deletions          []models.AccountDeletion
+		consents           []models.ConsentGrant
+		caregiverLinks     []models.CaregiverLink
+		caregiverActivity  []models.CaregiverAuditEntry
+	)
+
+	fileIDs := make([]uuid.UUID, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	prescriptionIDs := db.Model(&models.Prescription{}).Select("id").Where("user_id = ?", userID)
+
+	queries := []struct {
+		name  string
+		rows  interface{}
+		query *gorm.DB
+	}{
+		{"medication_doses", &doses, db.Where("user_id = ?", userID)},
+		{"medication_safety_checks", &safetyChecks, db.Where("patient_id = ?", userID)},
+		{"prescriptions", &prescriptions, db.Preload("Medications").Where("user_id = ?", userID)},
+		{"prescription_extractions", &extractions, db.Where("prescription_id IN (?)", prescriptionIDs)},
+		{"refill_requests", &refillRequests, db.Where("user_id = ?", userID)},
+		{"test_kit_orders", &testKitOrders, db.Where("user_id = ?", userID)},
+		{"test_kit_results", &testKitResults, db.Where("user_id = ?", userID)},
+		{"lab_bookings", &labBookings, db.Preload("LabTest").Where("user_id = ?", userID)},
+		{"lab_results", &labResults, db.Preload("Values", labValuesInOrder).Where("user_id = ? AND status = ?", userID, models.LabResultStatusVerified)},
+		{"telehealth_sessions", &telehealthSessions, db.Where("patient_id = ?", userID)},
+		{"symptom_checks", &symptomChecks, db.Where("user_id = ?", userID)},
+		{"care_sense_analytics", &analytics, db.Where("user_id = ?", userID)},
+		{"payments", &payments, db.Where("user_id = ?", userID)},
+		{"notifications", &notifications, db.Where("user_id = ?", userID)},
+		{"file_access_log", &fileAccess, db.Where("file_id IN ?", fileIDs)},
+		{"account_deletions", &deletions, db.Where("user_id = ?", userID)},
+		{"consents", &consents, db.Where("user_id = ?", userID)},
+		{"caregiver_links", &caregiverLinks, db.Where("? IN (patient_id, caregiver_id)", userID)},
+		{"caregiver_activity", &caregiverActivity, db.Where("? IN (patient_id, caregiver_id)", userID)},
+	}
+
+	sections := make([]dataExportSection, 0, len(queries)+1)
+	sections = append(sections, dataExportSection{"files", files})
+	for _, q := range queries {
+		if err := q.query.Order("created_at").Find(q.rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", q.name, err)
+		}
+		sections = append(sections, dataExportSection{q.name, q.rows})
+	}
+	return sections, nil
+}
+
+// buildDataExport writes a user's data to a ZIP archive and stores it as a
+// private file only they can download. It returns the archive and how many
+// uploaded files it includes.
+func buildDataExport(db *gorm.DB, storageSvc *services.StorageService, export *models.DataExport) (*models.File, int, error) {
+	var user models.User
+	if err := db.First(&user, "id = ?", export.UserID).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var files []models.File
+	if err := dataSubjectFiles(db, user.ID).
+		Where("resource_type IS NULL OR resource_type <> ?", models.FileResourceDataExport).
+		Order("created_at").
+		Find(&files).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load files: %w", err)
+	}
+	sections, err := loadDataExportSections(db, user.ID, files)
+	if err != nil {
+		return nil, 0, err
+	}
+	patient, err := fhirPatientData(db, &user)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tmp, err := os.CreateTemp("", "nyumbanicare-export-*.zip")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	archive := zip.NewWriter(tmp)
+	if err := writeZipJSON(archive, "data/profile.json", user); err != nil {
+		return nil, 0, err
+	}
+	if err := writeZipJSON(archive, "data/medical_record.json", patient.Record); err != nil {
+		return nil, 0, err
+	}
+	for _, section := range sections {
+		if err := writeZipJSON(archive, "data/"+section.name+".json", section.rows); err != nil {
+			return nil, 0, err
+		}
+	}
+	if err := writeZipJSON(archive, "fhir/patient-everything.json", services.FHIRPatientBundle(patient)); err != nil {
+		return nil, 0, err
+	}
+
+	summary := dataExportSummary(&user, patient, sections)
+	w, err := archive.Create("summary.pdf")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to add summary: %w", err)
+	}
+	if _, err := w.Write(summary.Bytes()); err != nil {
+		return nil, 0, fmt.Errorf("failed to add summary: %w", err)
+	}
+
+	// Uploaded files go under files/, named by ID so identical filenames do not collide.
+	// A file that cannot be read is listed in the index instead of failing the export.
+	type archivedFile struct {
+		ID       uuid.UUID `json:"id"`
+		Filename string    `json:"filename"`
+		Path     string    `json:"path,omitempty"`
+		Missing  string    `json:"missing,omitempty"`
+	}
+	index := make([]archivedFile, 0, len(files))
+	included := 0
+	for _, file := range files {
+		entry := archivedFile{ID: file.ID, Filename: file.Filename}
+		if file.Provider != storageSvc.Provider() {
+			entry.Missing = "stored with a previous storage provider"
+			index = append(index, entry)
+			continue
+		}
+		src, err := storageSvc.Open(file.StorageKey)
+		if err != nil {
+			fmt.Printf("Data export %s could not open file %s: %v\n", export.ID, file.ID, err)
+			entry.Missing = "could not be read from storage"
+			index = append(index, entry)
+			continue
+		}
+		entry.Path = fmt.Sprintf("files/%s-%s", file.ID, path.Base(file.Filename))
+		w, err := archive.Create(entry.Path)
+		if err == nil {
+			_, err = io.Copy(w, src)
+		}
+		src.Close()
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to add file %s: %w", file.ID, err)
+		}
+		index = append(index, entry)
+		included++
+	}
+	if err := writeZipJSON(archive, "files/index.json", index); err != nil {
+		return nil, 0, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to rewind archive: %w", err)
+	}
+
+	filename := fmt.Sprintf("nyumbanicare-data-%s.zip", time.Now().Format("2006-01-02"))
+	file, _, err := saveFile(db, storageSvc, tmp, filename, "exports", user.ID, true)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to store archive: %w", err)
+	}
+	if err := db.Model(file).Updates(map[string]interface{}{
+		"resource_type": models.FileResourceDataExport,
+		"resource_id":   export.ID,
+	}).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to attach archive: %w", err)
+	}
+	return file, included, nil
+}
+
+func writeZipJSON(archive *zip.Writer, name string, v interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// dataExportSummary is the human-readable part of a data export: the profile and
+// medical record in full, and how many records of every other kind are included
+func dataExportSummary(user *models.User, patient services.FHIRPatientData, sections []dataExportSection) *services.TextPDF {
+	const dateFormat = "2 Jan 2006"
+	pdf := services.NewTextPDF()
+	pdf.Heading("Nyumbani Care - Your Data")
+	pdf.Text(fmt.Sprintf("Prepared %s for %s %s. The JSON files in this archive hold every record in full.",
+		time.Now().Format(dateFormat), user.FirstName, user.LastName))
+
+	pdf.Subheading("Profile")
+	pdf.Text("Email: " + user.Email)
+	pdf.Text("Phone: " + user.PhoneNumber)
+	if user.DateOfBirth != nil {
+		pdf.Text("Date of birth: " + user.DateOfBirth.Format(dateFormat))
+	}
+	if user.Gender != "" {
+		pdf.Text("Gender: " + user.Gender)
+	}
+	if user.Address != "" {
+		pdf.Text("Address: " + user.Address)
+	}
+	pdf.Text("Member since: " + user.CreatedAt.Format(dateFormat))
+
+	if record := patient.Record; record != nil {
+		pdf.Subheading("Medical record")
+		if record.BloodType != "" {
+			pdf.Text("Blood type: " + record.BloodType)
+		}
+		if len(record.Allergies) > 0 {
+			pdf.Text("Allergies: " + strings.Join(record.Allergies, ", "))
+		}
+		if len(record.ChronicConditions) > 0 {
+			pdf.Text("Chronic conditions: " + strings.Join(record.ChronicConditions, ", "))
+		}
+		if record.FamilyHistory != "" {
+			pdf.Text("Family history: " + record.FamilyHistory)
+		}
+
+		if len(record.Medications) > 0 {
+			pdf.Subheading("Medications")
+			for _, medication := range record.Medications {
+				line := fmt.Sprintf("%s %s, %s, from %s", medication.Name, medication.Dosage, medication.Frequency, medication.StartDate.Format(dateFormat))
+				if medication.EndDate != nil {
+					line += " to " + medication.EndDate.Format(dateFormat)
+				}
+				pdf.Text(line)
+			}
+		}
+		if len(record.TestResults) > 0 {
+			pdf.Subheading("Test results")
+			for _, result := range record.TestResults {
+				pdf.Text(fmt.Sprintf("%s, %s: %s", result.TestDate.Format(dateFormat), result.TestType, result.Result))
+				if result.Interpretation != "" {
+					pdf.Text("  " + result.Interpretation)
+				}
+			}
+		}
+		if len(record.Consultations) > 0 {
+			pdf.Subheading("Consultations")
+			for _, consultation := range record.Consultations {
+				line := fmt.Sprintf("%s, %s", consultation.Date.Format(dateFormat), consultation.Type)
+				if consultation.DoctorName != "" {
+					line += " with " + consultation.DoctorName
+				}
+				pdf.Text(line)
+				if consultation.Diagnosis != "" {
+					pdf.Text("  Diagnosis: " + consultation.Diagnosis)
+				}
+				if consultation.Treatment != "" {
+					pdf.Text("  Treatment: " + consultation.Treatment)
+				}
+			}
+		}
+	}
+
+	pdf.Subheading("Records included")
+	for _, section := range sections {
+		count := reflect.Indirect(reflect.ValueOf(section.rows)).Len()
+		pdf.Text(fmt.Sprintf("%s: %d", strings.ReplaceAll(section.name, "_", " "), count))
+	}
+	return pdf
+}
+
+func runAccountDeletions(db *gorm.DB, storageSvc *services.StorageService) error {
+	var due []models.AccountDeletion
+	if err := db.Where("status = ? AND scheduled_for <= ?", models.AccountDeletionScheduled, time.Now()).
+		Order("scheduled_for").
+		Limit(dataRequestBatch).
+		Find(&due).Error; err != nil {
+		return fmt.Errorf("failed to load due account deletions: %w", err)
+	}
+
+	for i := range due {
+		deletion := &due[i]
+
+		// Claiming the deletion also stops it being cancelled from here on
+		claim := db.Model(&models.AccountDeletion{}).
+			Where("id = ? AND status = ?", deletion.ID, models.AccountDeletionScheduled).
+			Updates(map[string]interface{}{
+				"status":   models.AccountDeletionProcessing,
+				"attempts": gorm.Expr("attempts + 1"),
+			})
+		if claim.Error != nil {
+			return fmt.Errorf("failed to claim account deletion %s: %w", deletion.ID, claim.Error)
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+		deletion.Attempts++
+
+		if err := eraseAccount(db, storageSvc, deletion.UserID); err != nil {
+			fmt.Printf("Account deletion %s failed (attempt %d): %v\n", deletion.ID, deletion.Attempts, err)
+			status := models.AccountDeletionScheduled
+			if deletion.Attempts >= accountDeletionMaxAttempts {
+				status = models.AccountDeletionFailed
+			}
+			if err := db.Model(deletion).Updates(map[string]interface{}{"status": status, "error": err.Error()}).Error; err != nil {
+				fmt.Printf("Failed to record account deletion %s failure: %v\n", deletion.ID, err)
+			}
+			continue
+		}
+
+		if err := db.Model(deletion).Updates(map[string]interface{}{
+			"status":       models.AccountDeletionCompleted,
+			"completed_at": time.Now(),
+			"error":        "",
+		}).Error; err != nil {
+			return fmt.Errorf("failed to complete account deletion %s: %w", deletion.ID, err)
+		}
+		fmt.Printf("Erased account of user %s\n", deletion.UserID)
+	}
+	return nil
+}
+
+// eraseAccount permanently deletes a user's health records, orders, bookings,
+// notifications and files, and anonymises their account. Payments are kept for
+// financial record keeping without the payer's contact details. The account row
+// itself is anonymised and soft-deleted rather than removed, as records of other
+// patients it authored as a clinician still refer to it. Dependant profiles the
+// user manages are handed to another of their caregivers, or erased too.
+func eraseAccount(db *gorm.DB, storageSvc *services.StorageService, userID uuid.UUID) error {
+	if err := releaseDependants(db, storageSvc, userID); err != nil {
+		return err
+	}
+
+	var files []models.File
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := dataSubjectFiles(tx, userID).Unscoped().Find(&files).Error; err != nil {
+			return fmt.Errorf("failed to load files: %w", err)
+		}
+
+		records := tx.Unscoped().Model(&models.MedicalRecord{}).Select("id").Where("user_id = ?", userID)
+		prescriptions := tx.Unscoped().Model(&models.Prescription{}).Select("id").Where("user_id = ?", userID)
+		labResults := tx.Unscoped().Model(&models.LabResult{}).Select("id").Where("user_id = ?", userID)
+
+		// Children before the rows they refer to
+		erasures := []struct {
+			model interface{}
+			where string
+			arg   interface{}
+		}{
+			{&models.MedicationDose{}, "user_id = ?", userID},
+			{&models.MedicationSafetyCheck{}, "patient_id = ?", userID},
+			{&models.Medication{}, "medical_record_id IN (?)", records},
+			{&models.TestResult{}, "medical_record_id IN (?)", records},
+			{&models.Consultation{}, "medical_record_id IN (?)", records},
+			{&models.MedicalRecord{}, "user_id = ?", userID},
+			{&models.RefillRequest{}, "user_id = ?", userID},
+			{&models.PrescriptionExtraction{}, "prescription_id IN (?)", prescriptions},
+			{&models.PrescriptionMedication{}, "prescription_id IN (?)", prescriptions},
+			{&models.Prescription{}, "user_id = ?", userID},
+			{&models.TestKitResult{}, "user_id = ?", userID},
+			{&models.TestKitOrder{}, "user_id = ?", userID},
+			{&models.LabResultValue{}, "lab_result_id IN (?)", labResults},
+			{&models.LabResult{}, "user_id = ?", userID},
+			{&models.LabBooking{}, "user_id = ?", userID},
+			{&models.TelehealthSession{}, "patient_id = ?", userID},
+			{&models.SymptomCheck{}, "user_id = ?", userID},
+			{&models.CareSenseAnalytics{}, "user_id = ?", userID},
+			{&models.Notification{}, "user_id = ?", userID},
+			{&models.FileAccessLog{}, "user_id = ?", userID},
+			{&models.FileScanEvent{}, "user_id = ?", userID},
+			{&models.DataExport{}, "user_id = ?", userID},
+			{&models.ConsentGrant{}, "user_id = ?", userID},
+			{&models.CaregiverAuditEntry{}, "patient_id = ?", userID},
+			{&models.CaregiverLink{}, "? IN (patient_id, caregiver_id)", userID},
+		}
+		for _, erasure := range erasures {
+			if err := tx.Unscoped().Where(erasure.where, erasure.arg).Delete(erasure.model).Error; err != nil {
+				return fmt.Errorf("failed to delete %T: %w", erasure.model, err)
+			}
+		}
+
+		if len(files) > 0 {
+			ids := make([]uuid.UUID, 0, len(files))
+			for _, file := range files {
+				ids = append(ids, file.ID)
+			}
+			// Soft-deleted so they are purged from storage below, or by the file collector
+			if err := tx.Where("id IN ?", ids).Delete(&models.File{}).Error; err != nil {
+				return fmt.Errorf("failed to delete files: %w", err)
+			}
+		}
+
+		if err := tx.Unscoped().Model(&models.Payment{}).Where("user_id = ?", userID).
+			Updates(map[string]interface{}{"phone_number": "", "notes": ""}).Error; err != nil {
+			return fmt.Errorf("failed to anonymise payments: %w", err)
+		}
+
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":         fmt.Sprintf("deleted-%s@deleted.invalid", userID),
+			"phone_number":  fmt.Sprintf("deleted-%s", userID),
+			"password":      "",
+			"first_name":    "Deleted",
+			"last_name":     "User",
+			"date_of_birth": nil,
+			"gender":        "",
+			"address":       "",
+			"is_verified":   false,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymise user: %w", err)
+		}
+		return tx.Delete(&models.User{}, "id = ?", userID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		if files[i].Provider != storageSvc.Provider() {
+			continue
+		}
+		if _, err := purgeStoredFile(db, storageSvc, &files[i]); err != nil {
+			fmt.Printf("Failed to purge file %s of erased user %s: %v\n", files[i].ID, userID, err)
+		}
+	}
+	return nil
+}
+
+// releaseDependants hands each dependant profile a user manages to the longest
+// serving of its other caregivers. A dependant with no one else to care for them
+// is erased with the account.
+func releaseDependants(db *gorm.DB, storageSvc *services.StorageService, userID uuid.UUID) error {
+	var dependants []models.User
+	if err := db.Where("managed_by_id = ?", userID).Find(&dependants).Error; err != nil {
+		return fmt.Errorf("failed to load dependants: %w", err)
+	}
+	for _, dependant := range dependants {
+		var link models.CaregiverLink
+		err := db.Where("patient_id = ? AND caregiver_id <> ? AND status = ?", dependant.ID, userID, models.CaregiverLinkActive).
+			Order("created_at").
+			First(&link).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := eraseAccount(db, storageSvc, dependant.ID); err != nil {
+				return fmt.Errorf("failed to erase dependant %s: %w", dependant.ID, err)
+			}
+			fmt.Printf("Erased dependant %s of user %s\n", dependant.ID, userID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find caregiver of dependant %s: %w", dependant.ID, err)
+		}
+		if err := db.Model(&dependant).Update("managed_by_id", link.CaregiverID).Error; err != nil {
+			return fmt.Errorf("failed to hand over dependant %s: %w", dependant.ID, err)
+		}
+		fmt.Printf("Handed dependant %s of user %s to caregiver %s\n", dependant.ID, userID, link.CaregiverID)
+	}
+	return nil
+}
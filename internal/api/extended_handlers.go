@@ -6,10 +6,12 @@ import (
 	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/middleware"
 	"github.com/nyumbanicare/internal/models"
 	"gorm.io/gorm"
 )
@@ -18,13 +20,16 @@ func CreatePrescription(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
 		var req struct {
-			ImageURL string `json:"image_url" binding:"required"`
-			Notes    string `json:"notes"`
+			ImageURL               string     `json:"image_url" binding:"required"`
+			Notes                  string     `json:"notes"`
+			DoctorID               *uuid.UUID `json:"doctor_id"`
+			PrescriberName         string     `json:"prescriber_name"`
+			PrescriberRegistration string     `json:"prescriber_registration"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -32,17 +37,45 @@ func CreatePrescription(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Only images uploaded through /uploads/file have been type-checked and scanned
+		if !isOwnUpload(db, req.ImageURL, userID.(uuid.UUID)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Upload the prescription image first and use the returned file_url")})
+			return
+		}
+
+		// A prescriber on the platform must be a doctor
+		if req.DoctorID != nil {
+			var doctor models.User
+			if err := db.First(&doctor, "id = ? AND role = ?", *req.DoctorID, "doctor").Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Prescribing doctor not found")})
+				return
+			}
+			if req.PrescriberName == "" {
+				req.PrescriberName = strings.TrimSpace(doctor.FirstName + " " + doctor.LastName)
+			}
+		}
+
 		prescription := models.Prescription{
-			UserID:   userID.(uuid.UUID),
-			ImageURL: req.ImageURL,
-			Notes:    req.Notes,
-			Status:   "uploaded",
+			UserID:                 userID.(uuid.UUID),
+			DoctorID:               req.DoctorID,
+			PrescriberName:         req.PrescriberName,
+			PrescriberRegistration: req.PrescriberRegistration,
+			ImageURL:               req.ImageURL,
+			Notes:                  req.Notes,
+			Status:                 models.PrescriptionStatusUploaded,
+			PaymentStatus:          "pending",
 		}
 
 		if err := db.Create(&prescription).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create prescription"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create prescription")})
 			return
 		}
+		attachFiles(db, models.FileResourcePrescription, prescription.ID, prescription.UserID, prescription.ImageURL)
+
+		// The pharmacist can re-run the extraction if it could not be queued
+		if _, err := queuePrescriptionExtraction(db, prescription.ID, nil); err != nil {
+			fmt.Printf("Failed to queue extraction for prescription %s: %v\n", prescription.ID, err)
+		}
 
 		c.JSON(http.StatusCreated, prescription)
 	}
@@ -52,7 +85,7 @@ func ListPrescriptions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -60,12 +93,12 @@ func ListPrescriptions(db *gorm.DB) gin.HandlerFunc {
 		query := db.Preload("Medications")
 
 		role, _ := c.Get("role")
-		if role != "admin" {
+		if !middleware.IsPharmacistRole(role) {
 			query = query.Where("user_id = ?", userID)
 		}
 
-		if err := query.Find(&prescriptions).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch prescriptions"})
+		if err := query.Order("created_at DESC").Find(&prescriptions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch prescriptions")})
 			return
 		}
 
@@ -73,9 +106,17 @@ func ListPrescriptions(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// UpdatePrescriptionStatus lets a pharmacist take or cancel a prescription. The
+// other steps carry quote, payment or delivery details, so they go through their
+// own endpoints.
 func UpdatePrescriptionStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		role, _ := c.Get("role")
+		if !middleware.IsPharmacistRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Pharmacist access required")})
+			return
+		}
+
 		var req struct {
 			Status        string `json:"status" binding:"required"`
 			PharmacyNotes string `json:"pharmacy_notes"`
@@ -86,19 +127,26 @@ func UpdatePrescriptionStatus(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		var prescription models.Prescription
-		if err := db.First(&prescription, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Prescription not found"})
+		switch req.Status {
+		case models.PrescriptionStatusApproved, models.PrescriptionStatusAccepted, models.PrescriptionStatusPaid,
+			models.PrescriptionStatusRejected, models.PrescriptionStatusOutForDelivery, models.PrescriptionStatusDispensed:
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Use the prescription workflow endpoints for this status")})
 			return
 		}
 
-		prescription.Status = req.Status
-		if req.PharmacyNotes != "" {
-			prescription.PharmacyNotes = req.PharmacyNotes
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
 		}
 
-		if err := db.Save(&prescription).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update prescription"})
+		updates := map[string]interface{}{}
+		if req.PharmacyNotes != "" {
+			updates["pharmacy_notes"] = req.PharmacyNotes
+		}
+		if req.Status == models.PrescriptionStatusProcessing {
+			updates["pharmacist_id"] = c.MustGet("user_id")
+		}
+		if !transitionPrescription(c, db, prescription, req.Status, updates) {
 			return
 		}
 
@@ -110,10 +158,12 @@ func ListLabTests(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var labTests []models.LabTest
 		if err := db.Where("available = ?", true).Find(&labTests).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lab tests"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab tests")})
 			return
 		}
 
+		localizeLabTests(db, requestLanguage(c), labTests)
+
 		c.JSON(http.StatusOK, labTests)
 	}
 }
@@ -122,7 +172,7 @@ func CreateLabBooking(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -143,13 +193,13 @@ func CreateLabBooking(db *gorm.DB) gin.HandlerFunc {
 
 		bookingDate, err := time.Parse("2006-01-02", req.BookingDate)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid booking date format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid booking date format")})
 			return
 		}
 
 		var labTest models.LabTest
 		if err := db.First(&labTest, "id = ? AND available = ?", req.LabTestID, true).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Lab test not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab test not found")})
 			return
 		}
 
@@ -168,7 +218,7 @@ func CreateLabBooking(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&booking).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create lab booking"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create lab booking")})
 			return
 		}
 
@@ -180,7 +230,7 @@ func ListLabBookings(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -193,7 +243,7 @@ func ListLabBookings(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&bookings).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lab bookings"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab bookings")})
 			return
 		}
 
@@ -213,10 +263,14 @@ func UpdateLabBookingStatus(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if req.Status == "completed" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Bookings are completed by verifying their results")})
+			return
+		}
 
 		var booking models.LabBooking
 		if err := db.First(&booking, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Lab booking not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab booking not found")})
 			return
 		}
 
@@ -226,7 +280,7 @@ func UpdateLabBookingStatus(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&booking).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update booking status"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update booking status")})
 			return
 		}
 
@@ -251,10 +305,12 @@ func ListHealthArticles(db *gorm.DB) gin.HandlerFunc {
 
 		query.Count(&total)
 		if err := query.Preload("Author").Limit(limit).Offset(offset).Find(&articles).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch articles"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch articles")})
 			return
 		}
 
+		localizeHealthArticles(db, requestLanguage(c), articles)
+
 		c.JSON(http.StatusOK, gin.H{
 			"articles":    articles,
 			"total":       total,
@@ -269,14 +325,17 @@ func GetHealthArticle(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var article models.HealthArticle
 		if err := db.Preload("Author").First(&article, "id = ? AND published = ?", id, true).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Article not found")})
 			return
 		}
 
 		article.ViewCount++
 		db.Save(&article)
 
-		c.JSON(http.StatusOK, article)
+		articles := []models.HealthArticle{article}
+		localizeHealthArticles(db, requestLanguage(c), articles)
+
+		c.JSON(http.StatusOK, articles[0])
 	}
 }
 
@@ -284,7 +343,7 @@ func CreateTelehealthSession(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -303,7 +362,7 @@ func CreateTelehealthSession(db *gorm.DB) gin.HandlerFunc {
 
 		scheduledAt, err := time.Parse(time.RFC3339, req.ScheduledAt)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled time format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid scheduled time format")})
 			return
 		}
 
@@ -320,7 +379,7 @@ func CreateTelehealthSession(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&session).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create telehealth session"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create telehealth session")})
 			return
 		}
 
@@ -332,7 +391,7 @@ func ListTelehealthSessions(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -348,7 +407,7 @@ func ListTelehealthSessions(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&sessions).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch telehealth sessions"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch telehealth sessions")})
 			return
 		}
 
@@ -360,7 +419,7 @@ func CreateSymptomCheck(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -368,7 +427,7 @@ func CreateSymptomCheck(db *gorm.DB) gin.HandlerFunc {
 			Symptoms []string `json:"symptoms" binding:"required"`
 			Severity string   `json:"severity" binding:"required"`
 			Duration string   `json:"duration" binding:"required"`
-			Age      int      `json:"age" binding:"required"`
+			Age      *int     `json:"age" binding:"required,min=0"` // newborns are age 0
 			Gender   string   `json:"gender" binding:"required"`
 		}
 
@@ -378,20 +437,22 @@ func CreateSymptomCheck(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		symptomCheck := models.SymptomCheck{
-			UserID:           userID.(uuid.UUID),
-			Symptoms:         req.Symptoms,
-			Severity:         req.Severity,
-			Duration:         req.Duration,
-			Age:              req.Age,
-			Gender:           req.Gender,
-			Results:          `{"analysis": "Basic symptom analysis", "conditions": []}`,
-			Recommendations:  "Please consult with a healthcare provider for proper diagnosis",
-			UrgencyLevel:     "medium",
-			FollowUpRequired: true,
+			UserID:   userID.(uuid.UUID),
+			Symptoms: req.Symptoms,
+			Severity: req.Severity,
+			Duration: req.Duration,
+			Age:      *req.Age,
+			Gender:   req.Gender,
+		}
+
+		aiService := aiServiceFor(db, symptomCheck.UserID)
+		if _, err := aiService.ProcessSymptomCheck(&symptomCheck, requestLanguage(c)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to analyze symptoms")})
+			return
 		}
 
 		if err := db.Create(&symptomCheck).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create symptom check"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create symptom check")})
 			return
 		}
 
@@ -403,7 +464,7 @@ func ListSymptomChecks(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -416,7 +477,7 @@ func ListSymptomChecks(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&symptomChecks).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch symptom checks"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch symptom checks")})
 			return
 		}
 
@@ -428,7 +489,7 @@ func GenerateCareSenseAnalytics(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -475,17 +536,17 @@ func GenerateCareSenseAnalytics(db *gorm.DB) gin.HandlerFunc {
 		case "comprehensive":
 			analytics, err = generateComprehensiveAnalytics(db, userID.(uuid.UUID), fromDate, timeRange)
 		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid analysis type. Supported types: health_trends, risk_assessment, wellness_score, comprehensive"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid analysis type. Supported types: health_trends, risk_assessment, wellness_score, comprehensive")})
 			return
 		}
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate analytics: " + err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to generate analytics: ") + err.Error()})
 			return
 		}
 
 		if err := db.Create(&analytics).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save analytics"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save analytics")})
 			return
 		}
 
@@ -497,7 +558,7 @@ func GetCareSenseAnalytics(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -510,7 +571,7 @@ func GetCareSenseAnalytics(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Order("generated_at DESC").Find(&analytics).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch analytics"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch analytics")})
 			return
 		}
 
@@ -575,7 +636,10 @@ type PrescriptionHistory struct {
 	TotalPrescriptions int      `json:"total_prescriptions"`
 	ActiveMedications  int      `json:"active_medications"`
 	CommonMedications  []string `json:"common_medications"`
-	ComplianceRate     float64  `json:"compliance_rate"`
+	ComplianceRate     float64  `json:"compliance_rate"`   // percentage of due doses taken
+	AdherenceTracked   bool     `json:"adherence_tracked"` // whether any doses were due, so ComplianceRate is meaningful
+	DosesDue           int64    `json:"doses_due"`
+	DosesTaken         int64    `json:"doses_taken"`
 }
 
 func generateHealthTrendsAnalytics(db *gorm.DB, userID uuid.UUID, fromDate time.Time, timeRange string) (models.CareSenseAnalytics, error) {
@@ -679,11 +743,12 @@ func generateWellnessScoreAnalytics(db *gorm.DB, userID uuid.UUID, fromDate time
 	db.Where("patient_id = ? AND created_at >= ?", userID, fromDate).Find(&telehealthSessions)
 	db.Where("user_id = ? AND created_at >= ?", userID, fromDate).Find(&prescriptions)
 	db.Where("user_id = ? AND created_at >= ?", userID, fromDate).Find(&labBookings)
+	adherence, _ := doseAdherence(db, userID, nil, fromDate)
 
 	data.TestResults = convertTestResults(testResults)
 	data.SymptomPatterns = analyzeSymptomPatterns(symptomChecks)
 	data.ConsultationStats = analyzeConsultationStats(telehealthSessions)
-	data.PrescriptionHistory = analyzePrescriptionHistory(prescriptions)
+	data.PrescriptionHistory = analyzePrescriptionHistory(prescriptions, adherence)
 	data.LabResults = convertLabBookings(labBookings)
 
 	insights = generateWellnessInsights(data, timeRange)
@@ -722,13 +787,14 @@ func generateComprehensiveAnalytics(db *gorm.DB, userID uuid.UUID, fromDate time
 	db.Where("patient_id = ? AND created_at >= ?", userID, fromDate).Find(&telehealthSessions)
 	db.Where("user_id = ? AND created_at >= ?", userID, fromDate).Find(&prescriptions)
 	db.Where("user_id = ? AND created_at >= ?", userID, fromDate).Find(&labBookings)
+	adherence, _ := doseAdherence(db, userID, nil, fromDate)
 
 	data.HealthTrends = generateHealthTrendsFromData(testResults, symptomChecks)
 	data.RiskFactors = assessRiskFactors(testResults, symptomChecks, prescriptions)
 	data.TestResults = convertTestResults(testResults)
 	data.SymptomPatterns = analyzeSymptomPatterns(symptomChecks)
 	data.ConsultationStats = analyzeConsultationStats(telehealthSessions)
-	data.PrescriptionHistory = analyzePrescriptionHistory(prescriptions)
+	data.PrescriptionHistory = analyzePrescriptionHistory(prescriptions, adherence)
 	data.LabResults = convertLabBookings(labBookings)
 
 	insights = generateComprehensiveInsights(data, timeRange)
@@ -922,7 +988,9 @@ func analyzeConsultationStats(sessions []models.TelehealthSession) ConsultationS
 	return stats
 }
 
-func analyzePrescriptionHistory(prescriptions []models.Prescription) PrescriptionHistory {
+// analyzePrescriptionHistory summarises prescriptions and the adherence logged
+// against scheduled medication doses in the same period
+func analyzePrescriptionHistory(prescriptions []models.Prescription, adherence DoseAdherence) PrescriptionHistory {
 	history := PrescriptionHistory{
 		TotalPrescriptions: len(prescriptions),
 	}
@@ -935,7 +1003,12 @@ func analyzePrescriptionHistory(prescriptions []models.Prescription) Prescriptio
 	}
 
 	history.ActiveMedications = active
-	history.ComplianceRate = 85.0
+	if adherence.Rate != nil {
+		history.AdherenceTracked = true
+		history.ComplianceRate = *adherence.Rate
+		history.DosesDue = adherence.Due
+		history.DosesTaken = adherence.Taken
+	}
 	history.CommonMedications = []string{"Pain Relief", "Antibiotics"}
 
 	return history
@@ -1023,7 +1096,7 @@ func generateWellnessInsights(data AnalyticsData, timeRange string) []string {
 		insights = append(insights, "Consider increasing your health monitoring activities for better wellness tracking")
 	}
 
-	if data.PrescriptionHistory.ComplianceRate > 80 {
+	if data.PrescriptionHistory.AdherenceTracked && data.PrescriptionHistory.ComplianceRate > 80 {
 		insights = append(insights, "Excellent medication compliance rate supports your overall wellness")
 	}
 
@@ -1091,7 +1164,7 @@ func generateWellnessRecommendations(data AnalyticsData) []string {
 		recommendations = append(recommendations, "Annual lab work can provide valuable health insights")
 	}
 
-	if data.PrescriptionHistory.ComplianceRate < 80 {
+	if data.PrescriptionHistory.AdherenceTracked && data.PrescriptionHistory.ComplianceRate < 80 {
 		recommendations = append(recommendations, "Focus on improving medication compliance for better health outcomes")
 	}
 
@@ -1169,8 +1242,11 @@ func calculateWellnessScore(data AnalyticsData) float64 {
 		score += 20.0 * completionRate
 	}
 
-	maxScore += 20.0
-	score += 20.0 * (data.PrescriptionHistory.ComplianceRate / 100.0)
+	// Adherence only counts towards the score once doses have been tracked
+	if data.PrescriptionHistory.AdherenceTracked {
+		maxScore += 20.0
+		score += 20.0 * (data.PrescriptionHistory.ComplianceRate / 100.0)
+	}
 
 	maxScore += 15.0
 	if len(data.LabResults) > 0 {
@@ -0,0 +1,378 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/middleware"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxFHIRBundleSize bounds imported bundles, which carry records rather than files
+const maxFHIRBundleSize = 10 << 20
+
+// fhirJSON responds with a FHIR resource, which has its own content type
+func fhirJSON(c *gin.Context, status int, resource interface{}) {
+	body, err := json.Marshal(resource)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to encode the FHIR response")})
+		return
+	}
+	c.Data(status, services.FHIRContentType+"; charset=utf-8", body)
+}
+
+// fhirError responds with an OperationOutcome holding a single error
+func fhirError(c *gin.Context, status int, code, message string) {
+	fhirJSON(c, status, services.NewFHIROperationOutcome(services.FHIRIssue{
+		Severity:    "error",
+		Code:        code,
+		Diagnostics: tr(c, message),
+	}))
+}
+
+// @Summary Export a patient record as FHIR
+// @Description FHIR R4 Patient $everything. Returns a searchset Bundle with the Patient, AllergyIntolerance and Condition resources from the medical record, MedicationStatements, Observations and DiagnosticReports for test kit and lab results, and Encounters for consultations and telehealth sessions. Patients may export their own record; clinicians may export the record of a patient who has shared it with them.
+// @Tags FHIR
+// @Produce json
+// @Param id path string true "Patient (user) ID"
+// @Success 200 {object} services.FHIRBundle
+// @Failure 403 {object} services.FHIROperationOutcome
+// @Failure 404 {object} services.FHIROperationOutcome
+// @Router /api/v1/fhir/Patient/{id}/$everything [get]
+// @Security Bearer
+func ExportPatientEverything(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		patientID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			fhirError(c, http.StatusBadRequest, "value", "Invalid patient ID")
+			return
+		}
+		role, _ := c.Get("role")
+		if c.MustGet("user_id").(uuid.UUID) != patientID && !middleware.IsClinicianRole(role) {
+			fhirError(c, http.StatusForbidden, "forbidden", "You can only export your own record")
+			return
+		}
+		if !clinicianMayAccessRecord(c, db, patientID) {
+			fhirError(c, http.StatusForbidden, "forbidden", "The patient has not shared their medical record with you")
+			return
+		}
+
+		var user models.User
+		if err := db.First(&user, "id = ?", patientID).Error; err != nil {
+			fhirError(c, http.StatusNotFound, "not-found", "Patient not found")
+			return
+		}
+		data, err := fhirPatientData(db, &user)
+		if err != nil {
+			fmt.Printf("Failed to load FHIR export of patient %s: %v\n", patientID, err)
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch medical records")
+			return
+		}
+
+		fmt.Printf("FHIR export of patient %s by user %s\n", patientID, c.MustGet("user_id"))
+		fhirJSON(c, http.StatusOK, services.FHIRPatientBundle(data))
+	}
+}
+
+// fhirPatientData loads everything the FHIR export of a patient includes, merging
+// their reviewed results and completed consultations into the medical record first
+func fhirPatientData(db *gorm.DB, user *models.User) (services.FHIRPatientData, error) {
+	data := services.FHIRPatientData{User: *user}
+
+	var record models.MedicalRecord
+	err := db.Where("user_id = ?", user.ID).First(&record).Error
+	switch {
+	case err == nil:
+		if err := syncMedicalRecordHistory(db, &record); err != nil {
+			fmt.Printf("Failed to merge history into medical record %s: %v\n", record.ID, err)
+		}
+		if err := loadMedicalRecord(db, &record); err != nil {
+			return data, fmt.Errorf("failed to load medical record: %w", err)
+		}
+		data.Record = &record
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return data, fmt.Errorf("failed to load medical record: %w", err)
+	}
+
+	if err := db.Where("user_id = ? AND status NOT IN ?", user.ID,
+		[]string{models.TestKitResultStatusProcessing, models.TestKitResultStatusFailed}).
+		Order("created_at").Find(&data.KitResults).Error; err != nil {
+		return data, fmt.Errorf("failed to load test kit results: %w", err)
+	}
+	if data.KitNames, err = testKitNames(db, data.KitResults); err != nil {
+		return data, err
+	}
+	if err := db.Preload("LabBooking.LabTest").Preload("Values", labValuesInOrder).
+		Where("user_id = ? AND status = ?", user.ID, models.LabResultStatusVerified).
+		Order("result_date").Find(&data.LabResults).Error; err != nil {
+		return data, fmt.Errorf("failed to load lab results: %w", err)
+	}
+	if err := db.Where("patient_id = ?", user.ID).Order("scheduled_at").Find(&data.Sessions).Error; err != nil {
+		return data, fmt.Errorf("failed to load telehealth sessions: %w", err)
+	}
+	if data.ProviderNames, err = providerNames(db, data.Sessions); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+// fhirImportPatient finds the patient an imported bundle is for. Patients import
+// into their own record; clinicians must include a Patient identified by our
+// patient identifier, email or phone number.
+func fhirImportPatient(c *gin.Context, db *gorm.DB, patient *services.FHIRPatient) (uuid.UUID, bool) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	role, _ := c.Get("role")
+
+	var ownID string
+	if patient != nil {
+		for _, identifier := range patient.Identifier {
+			if identifier.System == services.FHIRPatientIdentifierSystem {
+				ownID = identifier.Value
+			}
+		}
+	}
+
+	if !middleware.IsClinicianRole(role) {
+		if ownID != "" && ownID != userID.String() {
+			fhirError(c, http.StatusForbidden, "forbidden", "The bundle describes a different patient")
+			return uuid.Nil, false
+		}
+		return userID, true
+	}
+
+	if patient == nil {
+		fhirError(c, http.StatusUnprocessableEntity, "required", "The bundle must include the Patient it describes")
+		return uuid.Nil, false
+	}
+
+	var user models.User
+	query := db.Where("role = ?", "patient")
+	switch {
+	case ownID != "":
+		id, err := uuid.Parse(ownID)
+		if err == nil && query.First(&user, "id = ?", id).Error == nil {
+			return fhirSharedPatient(c, db, user.ID)
+		}
+	default:
+		for _, telecom := range patient.Telecom {
+			value := strings.TrimSpace(telecom.Value)
+			if value == "" {
+				continue
+			}
+			var err error
+			switch telecom.System {
+			case "email":
+				err = db.Where("role = ? AND LOWER(email) = LOWER(?)", "patient", value).First(&user).Error
+			case "phone", "sms":
+				err = db.Where("role = ? AND phone_number = ?", "patient", value).First(&user).Error
+			default:
+				continue
+			}
+			if err == nil {
+				return fhirSharedPatient(c, db, user.ID)
+			}
+		}
+	}
+
+	fhirError(c, http.StatusUnprocessableEntity, "not-found", "No patient matches the bundle's Patient identifier, email or phone number")
+	return uuid.Nil, false
+}
+
+// fhirSharedPatient checks that the patient a clinician's import resolved to has
+// shared their medical record with the clinician
+func fhirSharedPatient(c *gin.Context, db *gorm.DB, patientID uuid.UUID) (uuid.UUID, bool) {
+	if !clinicianMayAccessRecord(c, db, patientID) {
+		fhirError(c, http.StatusForbidden, "forbidden", "The patient has not shared their medical record with you")
+		return uuid.Nil, false
+	}
+	return patientID, true
+}
+
+// @Summary Import a FHIR bundle
+// @Description Import a FHIR R4 Bundle (transaction, batch, collection or document) into a patient's medical record. AllergyIntolerance and Condition resources are added to the record's allergies and chronic conditions, MedicationStatements become medications, Observations and stand-alone DiagnosticReport conclusions become test results, and finished Encounters become consultations. Required elements are validated and nothing is imported if any resource is invalid. Resources already imported are not duplicated. Patients import into their own record; clinicians import into the record of the bundle's Patient, who must have shared their record with them.
+// @Tags FHIR
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.FHIRBundle "A transaction-response or batch-response Bundle with an entry per resource"
+// @Failure 400 {object} services.FHIROperationOutcome
+// @Failure 422 {object} services.FHIROperationOutcome "Invalid resources"
+// @Router /api/v1/fhir/Bundle [post]
+// @Security Bearer
+func ImportFHIRBundle(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFHIRBundleSize)
+		body, err := c.GetRawData()
+		if err != nil {
+			fhirError(c, http.StatusRequestEntityTooLarge, "too-costly", "The bundle is too large")
+			return
+		}
+
+		bundle, issues := services.ParseFHIRBundle(body)
+		if bundle == nil {
+			status := http.StatusUnprocessableEntity
+			if len(issues) > 0 && issues[0].Severity == "fatal" {
+				status = http.StatusBadRequest
+			}
+			fhirJSON(c, status, services.NewFHIROperationOutcome(issues...))
+			return
+		}
+
+		patientID, ok := fhirImportPatient(c, db, bundle.Patient)
+		if !ok {
+			return
+		}
+		record, err := medicalRecordFor(db, patientID)
+		if err != nil {
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch medical records")
+			return
+		}
+
+		responses := make([]services.FHIRBundleEntry, len(bundle.Entries))
+		var created []models.Medication
+		err = db.Transaction(func(tx *gorm.DB) error {
+			created = nil
+			allergies, conditions := record.Allergies, record.ChronicConditions
+			for i, entry := range bundle.Entries {
+				response, err := importFHIREntry(tx, record, &allergies, &conditions, entry, &created)
+				if err != nil {
+					return err
+				}
+				responses[i] = services.FHIRBundleEntry{Response: response}
+			}
+			record.Allergies, record.ChronicConditions = allergies, conditions
+			return tx.Model(record).Select("Allergies", "ChronicConditions").Updates(record).Error
+		})
+		if err != nil {
+			fmt.Printf("Failed to import FHIR bundle for patient %s: %v\n", patientID, err)
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to import the bundle")
+			return
+		}
+
+		for _, medication := range created {
+			if err := scheduleMedicationDoses(db, medication, patientID); err != nil {
+				fmt.Printf("Failed to schedule doses for medication %s: %v\n", medication.ID, err)
+			}
+		}
+
+		responseType := "transaction-response"
+		if bundle.BundleType == "batch" {
+			responseType = "batch-response"
+		}
+		fmt.Printf("FHIR import of %d entries into medical record %s by user %s\n", len(bundle.Entries), record.ID, c.MustGet("user_id"))
+		fhirJSON(c, http.StatusOK, services.FHIRBundle{
+			ResourceType: "Bundle",
+			ID:           uuid.New().String(),
+			Type:         responseType,
+			Entry:        responses,
+		})
+	}
+}
+
+// importFHIREntry adds one mapped bundle entry to the record, reporting 201 when it
+// is new and 200 when it was already there. Medications created are appended so
+// their doses can be scheduled once the import commits.
+func importFHIREntry(tx *gorm.DB, record *models.MedicalRecord, allergies, conditions *[]string, entry services.FHIRImportEntry, created *[]models.Medication) (*services.FHIRBundleResponse, error) {
+	status := func(isNew bool) string {
+		if isNew {
+			return "201 Created"
+		}
+		return "200 OK"
+	}
+	sourceID := services.FHIRSourceID(record.UserID, entry.Key)
+
+	switch {
+	case entry.Allergy != "":
+		before := len(*allergies)
+		*allergies = appendUnique(*allergies, entry.Allergy)
+		return &services.FHIRBundleResponse{
+			Status:   status(len(*allergies) > before),
+			Location: "AllergyIntolerance/" + services.FHIRAllergyID(record.ID, entry.Allergy).String(),
+		}, nil
+
+	case entry.Condition != "":
+		before := len(*conditions)
+		*conditions = appendUnique(*conditions, entry.Condition)
+		return &services.FHIRBundleResponse{
+			Status:   status(len(*conditions) > before),
+			Location: "Condition/" + services.FHIRConditionID(record.ID, entry.Condition).String(),
+		}, nil
+
+	case entry.Medication != nil:
+		medication := *entry.Medication
+		medication.MedicalRecordID = record.ID
+		medication.SourceType = models.RecordSourceFHIRImport
+		medication.SourceID = &sourceID
+		asNeeded := medication.AsNeeded
+		var doseTimes []string
+		if len(medication.DoseTimes) > 0 {
+			doseTimes = medication.DoseTimes
+		}
+		if err := applyDoseSchedule(&medication, doseTimes); err != nil {
+			medication.DoseTimes = []string{}
+		}
+		if asNeeded {
+			medication.AsNeeded, medication.DoseTimes = true, []string{}
+		}
+		if medication.DoseTimes == nil {
+			medication.DoseTimes = []string{}
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&medication)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			*created = append(*created, medication)
+		} else if err := tx.Unscoped().Where("source_type = ? AND source_id = ?", medication.SourceType, sourceID).First(&medication).Error; err != nil {
+			return nil, err
+		}
+		return &services.FHIRBundleResponse{Status: status(result.RowsAffected > 0), Location: "MedicationStatement/" + medication.ID.String()}, nil
+
+	case entry.TestResult != nil:
+		result := *entry.TestResult
+		result.MedicalRecordID = record.ID
+		result.SourceType = models.RecordSourceFHIRImport
+		result.SourceID = &sourceID
+		insert := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&result)
+		if insert.Error != nil {
+			return nil, insert.Error
+		}
+		if insert.RowsAffected == 0 {
+			if err := tx.Unscoped().Where("source_type = ? AND source_id = ?", result.SourceType, sourceID).First(&result).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &services.FHIRBundleResponse{Status: status(insert.RowsAffected > 0), Location: "Observation/" + result.ID.String()}, nil
+
+	case entry.Consultation != nil:
+		consultation := *entry.Consultation
+		consultation.MedicalRecordID = record.ID
+		consultation.SourceType = models.RecordSourceFHIRImport
+		consultation.SourceID = &sourceID
+		insert := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&consultation)
+		if insert.Error != nil {
+			return nil, insert.Error
+		}
+		if insert.RowsAffected == 0 {
+			if err := tx.Unscoped().Where("source_type = ? AND source_id = ?", consultation.SourceType, sourceID).First(&consultation).Error; err != nil {
+				return nil, err
+			}
+		}
+		return &services.FHIRBundleResponse{Status: status(insert.RowsAffected > 0), Location: "Encounter/" + consultation.ID.String()}, nil
+	}
+
+	outcome := services.NewFHIROperationOutcome(services.FHIRIssue{
+		Severity:    "information",
+		Code:        "informational",
+		Diagnostics: entry.Skipped,
+		Expression:  []string{fmt.Sprintf("Bundle.entry[%d]", entry.Index)},
+	})
+	return &services.FHIRBundleResponse{Status: "200 OK", Outcome: &outcome}, nil
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// fileCollectionInterval is how often unattached and deleted files are cleaned up
+	fileCollectionInterval = time.Hour
+	// fileCollectionBatch caps how many deleted files are purged per run
+	fileCollectionBatch = 500
+)
+
+// StartFileCollector periodically removes uploads that were never attached to a
+// record and purges the blobs of deleted files
+func StartFileCollector(db *gorm.DB) {
+	go func() {
+		for {
+			if removed, err := CollectFiles(db); err != nil {
+				fmt.Printf("File collection failed: %v\n", err)
+			} else if removed > 0 {
+				fmt.Printf("File collection removed %d stored files\n", removed)
+			}
+			time.Sleep(fileCollectionInterval)
+		}
+	}()
+}
+
+// CollectFiles deletes unattached uploads older than the configured age, then
+// removes deleted files from storage. Identical uploads share a blob, so a blob
+// is only deleted when no remaining file refers to it. It returns the number of
+// file records purged.
+func CollectFiles(db *gorm.DB) (int, error) {
+	cfg := config.GetConfig().Storage
+	cutoff := time.Now().Add(-time.Duration(cfg.UnattachedFileTTLHours) * time.Hour)
+	if err := db.Where("resource_id IS NULL AND created_at < ?", cutoff).Delete(&models.File{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to expire unattached files: %w", err)
+	}
+
+	storageSvc, err := services.NewStorageService(&cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+
+	// Files kept by a previously configured provider cannot be reached and are left alone.
+	// Files whose blob could not be deleted go to the back of the queue, so they
+	// cannot hold up newer ones.
+	var deleted []models.File
+	if err := db.Unscoped().
+		Where("deleted_at IS NOT NULL AND provider = ?", storageSvc.Provider()).
+		Order("purge_failed_at NULLS FIRST, deleted_at").
+		Limit(fileCollectionBatch).
+		Find(&deleted).Error; err != nil {
+		return 0, fmt.Errorf("failed to load deleted files: %w", err)
+	}
+
+	removed := 0
+	for i := range deleted {
+		purged, err := purgeStoredFile(db, storageSvc, &deleted[i])
+		if err != nil {
+			return removed, err
+		}
+		if purged {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// purgeStoredFile removes a deleted file's blob, unless another file still refers
+// to it, and then its record. It reports false when the blob could not be
+// deleted, keeping the record with the time of the failure so a later
+// collection tries again.
+func purgeStoredFile(db *gorm.DB, storageSvc *services.StorageService, file *models.File) (bool, error) {
+	var shared int64
+	if err := db.Model(&models.File{}).
+		Where("storage_key = ? AND provider = ?", file.StorageKey, file.Provider).
+		Count(&shared).Error; err != nil {
+		return false, fmt.Errorf("failed to check references to %s: %w", file.StorageKey, err)
+	}
+
+	if shared == 0 {
+		if err := storageSvc.DeleteKey(file.StorageKey); err != nil {
+			fmt.Printf("Failed to delete stored file %s: %v\n", file.ID, err)
+			if err := db.Unscoped().Model(file).UpdateColumn("purge_failed_at", time.Now()).Error; err != nil {
+				return false, fmt.Errorf("failed to record purge failure of %s: %w", file.ID, err)
+			}
+			return false, nil
+		}
+	}
+
+	if err := db.Unscoped().Delete(file).Error; err != nil {
+		return false, fmt.Errorf("failed to purge file %s: %w", file.ID, err)
+	}
+	return true, nil
+}
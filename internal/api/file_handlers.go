@@ -0,0 +1,411 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/middleware"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// filesRoute is the API path clients use to fetch private files
+const filesRoute = "/api/v1/files/"
+
+// uploadFolder is the policy for files uploaded to a folder
+type uploadFolder struct {
+	Private      bool     // holds health information, served only through GET /files/:id
+	ContentTypes []string // accepted content types, detected from the file's bytes
+}
+
+func (f uploadFolder) allows(contentType string) bool {
+	for _, allowed := range f.ContentTypes {
+		if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	imageContentTypes    = []string{"image/jpeg", "image/png", "image/webp"}
+	documentContentTypes = []string{"image/jpeg", "image/png", "image/webp", "application/pdf"}
+)
+
+// uploadFolders are the folders clients may upload to
+var uploadFolders = map[string]uploadFolder{
+	"general":         {ContentTypes: documentContentTypes},
+	"test_kits":       {ContentTypes: imageContentTypes},
+	"health_articles": {ContentTypes: imageContentTypes},
+	"test_results":    {Private: true, ContentTypes: imageContentTypes},
+	"prescriptions":   {Private: true, ContentTypes: documentContentTypes},
+	"lab_reports":     {Private: true, ContentTypes: documentContentTypes},
+	"medical_records": {Private: true, ContentTypes: documentContentTypes},
+}
+
+// fileResourceTables maps a file's resource type to the table whose user_id owns it
+var fileResourceTables = map[string]string{
+	models.FileResourceTestKitResult: "test_kit_results",
+	models.FileResourcePrescription:  "prescriptions",
+	models.FileResourceLabResult:     "lab_results",
+}
+
+// fileURL is the stable address recorded for a private file
+func fileURL(id uuid.UUID) string {
+	return filesRoute + id.String()
+}
+
+// saveFile stores an upload and records it as owned by ownerID. Medical files are
+// stored privately; the URL returned for them is the authorized files route.
+func saveFile(db *gorm.DB, storageSvc *services.StorageService, src io.Reader, filename, folder string, ownerID uuid.UUID, private bool) (*models.File, string, error) {
+	save := storageSvc.Save
+	if private {
+		save = storageSvc.SavePrivate
+	}
+	stored, err := save(src, filename, folder)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file := models.File{
+		OwnerID:     ownerID,
+		StorageKey:  stored.Key,
+		Provider:    storageSvc.Provider(),
+		Filename:    filename,
+		ContentType: stored.ContentType,
+		Purpose:     folder,
+		Size:        stored.Size,
+		Checksum:    stored.Checksum,
+		Private:     private,
+	}
+	if scanner := storageSvc.Scanner(); scanner != "" {
+		now := time.Now()
+		file.ScannedBy = scanner
+		file.ScannedAt = &now
+	}
+	if err := db.Create(&file).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	if private {
+		return &file, fileURL(file.ID), nil
+	}
+	return &file, stored.URL, nil
+}
+
+// respondUploadError answers a failed upload. Uploads the scanner rejected or
+// could not scan are recorded as audit events.
+func respondUploadError(c *gin.Context, db *gorm.DB, err error, event models.FileScanEvent) {
+	var infected *services.InfectedFileError
+	switch {
+	case errors.As(err, &infected):
+		event.Outcome = models.FileScanInfected
+		event.Scanner = infected.Scanner
+		event.Signature = infected.Signature
+		event.Checksum = infected.Checksum
+		event.Size = infected.Size
+	case errors.Is(err, services.ErrScanFailed):
+		event.Outcome = models.FileScanFailed
+		event.Scanner = config.GetConfig().Storage.ScanProvider
+		event.Detail = err.Error()
+	default:
+		fmt.Printf("Failed to store upload %q from user %s: %v\n", event.Filename, event.UserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to upload file")})
+		return
+	}
+
+	event.IPAddress = c.ClientIP()
+	if dbErr := db.Create(&event).Error; dbErr != nil {
+		fmt.Printf("Failed to record upload scan event for user %s: %v\n", event.UserID, dbErr)
+	}
+	fmt.Printf("Upload %q from user %s rejected (%s): %v\n", event.Filename, event.UserID, event.Outcome, err)
+
+	if event.Outcome == models.FileScanInfected {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": tr(c, "The file failed a security scan and was rejected")})
+		return
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": tr(c, "Uploads cannot be scanned right now. Please try again later.")})
+}
+
+// fileRefs resolves URLs of uploads to file IDs and storage keys
+func fileRefs(urls []string) (ids []uuid.UUID, keys []string, err error) {
+	ids, keys = []uuid.UUID{}, []string{}
+	var storageSvc *services.StorageService
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		if strings.HasPrefix(url, filesRoute) {
+			if id, err := uuid.Parse(strings.TrimPrefix(url, filesRoute)); err == nil {
+				ids = append(ids, id)
+			}
+			continue
+		}
+
+		if storageSvc == nil {
+			if storageSvc, err = services.NewStorageService(&config.GetConfig().Storage); err != nil {
+				return nil, nil, fmt.Errorf("failed to initialize storage service: %w", err)
+			}
+		}
+		if key, ok := storageSvc.KeyForURL(url); ok {
+			keys = append(keys, key)
+		}
+	}
+	return ids, keys, nil
+}
+
+// isOwnUpload reports whether url refers to a file the user uploaded through this API
+func isOwnUpload(db *gorm.DB, url string, ownerID uuid.UUID) bool {
+	ids, keys, err := fileRefs([]string{url})
+	if err != nil || len(ids)+len(keys) == 0 {
+		return false
+	}
+
+	var count int64
+	db.Model(&models.File{}).
+		Where("owner_id = ?", ownerID).
+		Where("(id IN ? OR storage_key IN ?)", ids, keys).
+		Count(&count)
+	return count > 0
+}
+
+// attachFiles links the uploads behind urls to the record that uses them, and
+// releases files the record no longer uses so the collector can remove them.
+// Only uploads owned by ownerID are linked, so nobody can claim another user's file.
+func attachFiles(db *gorm.DB, resourceType string, resourceID, ownerID uuid.UUID, urls ...string) {
+	ids, keys, err := fileRefs(urls)
+	if err != nil {
+		// Without resolving every URL, files still in use could be released
+		fmt.Printf("Failed to resolve files of %s %s: %v\n", resourceType, resourceID, err)
+		return
+	}
+
+	if len(ids)+len(keys) > 0 {
+		if err := db.Model(&models.File{}).
+			Where("owner_id = ? AND resource_id IS NULL", ownerID).
+			Where("(id IN ? OR storage_key IN ?)", ids, keys).
+			Updates(map[string]interface{}{"resource_type": resourceType, "resource_id": resourceID}).Error; err != nil {
+			fmt.Printf("Failed to link files to %s %s: %v\n", resourceType, resourceID, err)
+		}
+	}
+
+	release := db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID)
+	if len(ids) > 0 {
+		release = release.Where("id NOT IN ?", ids)
+	}
+	if len(keys) > 0 {
+		release = release.Where("storage_key NOT IN ?", keys)
+	}
+	if err := release.Delete(&models.File{}).Error; err != nil {
+		fmt.Printf("Failed to release files of %s %s: %v\n", resourceType, resourceID, err)
+	}
+}
+
+// deleteResourceFiles removes the files of a deleted record; their blobs are
+// deleted by the file collector once no other file shares them
+func deleteResourceFiles(db *gorm.DB, resourceType string, resourceID uuid.UUID) {
+	if err := db.Where("resource_type = ? AND resource_id = ?", resourceType, resourceID).
+		Delete(&models.File{}).Error; err != nil {
+		fmt.Printf("Failed to delete files of %s %s: %v\n", resourceType, resourceID, err)
+	}
+}
+
+// storageQuota is the number of bytes each user may store, or 0 for no limit
+func storageQuota() int64 {
+	return int64(config.GetConfig().Storage.UserQuotaMB) << 20
+}
+
+// storageUsed is the total size of a user's files
+func storageUsed(db *gorm.DB, ownerID uuid.UUID) (used int64, count int64, err error) {
+	var usage struct {
+		Used  int64
+		Count int64
+	}
+	err = db.Model(&models.File{}).
+		Select("COALESCE(SUM(size), 0) AS used, COUNT(*) AS count").
+		Where("owner_id = ?", ownerID).
+		Scan(&usage).Error
+	return usage.Used, usage.Count, err
+}
+
+// checkStorageQuota responds and returns false when an upload of size bytes would
+// take the user over their quota. Admins uploading catalog content are exempt.
+func checkStorageQuota(c *gin.Context, db *gorm.DB, userID uuid.UUID, size int64) bool {
+	quota := storageQuota()
+	if role, _ := c.Get("role"); quota <= 0 || role == "admin" {
+		return true
+	}
+
+	used, _, err := storageUsed(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to check storage quota")})
+		return false
+	}
+	if used+size > quota {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":       tr(c, "Storage quota exceeded. Delete old files or contact support."),
+			"used_bytes":  used,
+			"quota_bytes": quota,
+		})
+		return false
+	}
+	return true
+}
+
+// @Summary Storage usage
+// @Description Get how much storage the current user's uploads use against their quota
+// @Tags Files
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Storage usage"
+// @Router /api/v1/files/usage [get]
+// @Security Bearer
+func GetStorageUsage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		used, count, err := storageUsed(db, userID.(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to check storage quota")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"used_bytes":  used,
+			"quota_bytes": storageQuota(),
+			"file_count":  count,
+		})
+	}
+}
+
+// fileAccessReason decides whether a user may read a file and why
+func fileAccessReason(db *gorm.DB, file *models.File, userID uuid.UUID, role string) (string, bool) {
+	if file.OwnerID == userID {
+		return "owner", true
+	}
+	if file.ResourceType == models.FileResourceDataExport {
+		// A data export holds the patient's whole account, so not even clinicians may read it
+		return "denied", false
+	}
+	if middleware.IsClinicianRole(role) {
+		// Clinicians only see the files of patients who shared their record with them
+		shared, err := recordSharedWith(db, file.OwnerID, userID)
+		if err != nil {
+			fmt.Printf("Failed to check record sharing of patient %s with %s: %v\n", file.OwnerID, userID, err)
+			return "denied", false
+		}
+		if shared {
+			return "clinician", true
+		}
+	}
+	if file.ResourceType == models.FileResourcePrescription && middleware.IsPharmacistRole(role) {
+		return "pharmacist", true
+	}
+
+	table, ok := fileResourceTables[file.ResourceType]
+	if ok && file.ResourceID != nil {
+		var count int64
+		db.Table(table).Where("id = ? AND user_id = ?", *file.ResourceID, userID).Count(&count)
+		if count > 0 {
+			return "resource_owner", true
+		}
+	}
+	return "denied", false
+}
+
+// @Summary Get a private file
+// @Description Authorize access to an uploaded medical file and return a short-lived signed URL. Every request is logged.
+// @Tags Files
+// @Produce json
+// @Param id path string true "File ID"
+// @Param redirect query bool false "Redirect to the signed URL instead of returning it"
+// @Success 200 {object} map[string]interface{} "Signed URL"
+// @Success 302 "Redirect to the signed URL"
+// @Failure 403 {object} map[string]string "Access denied"
+// @Failure 404 {object} map[string]string "File not found"
+// @Router /api/v1/files/{id} [get]
+// @Security Bearer
+func GetFile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+		role, _ := c.Get("role")
+		roleName, _ := role.(string)
+
+		fileID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid file ID")})
+			return
+		}
+
+		var file models.File
+		if err := db.First(&file, "id = ?", fileID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "File not found")})
+			return
+		}
+
+		reason, allowed := fileAccessReason(db, &file, userID.(uuid.UUID), roleName)
+		accessLog := models.FileAccessLog{
+			FileID:    file.ID,
+			UserID:    userID.(uuid.UUID),
+			Role:      roleName,
+			Allowed:   allowed,
+			Reason:    reason,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+		}
+		if err := db.Create(&accessLog).Error; err != nil {
+			// Access to medical files must be auditable, so refuse when the log cannot be written
+			fmt.Printf("Failed to log access to file %s: %v\n", file.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to read file")})
+			return
+		}
+
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Access denied")})
+			return
+		}
+
+		cfg := config.GetConfig().Storage
+		storageSvc, err := services.NewStorageService(&cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to initialize storage service")})
+			return
+		}
+
+		ttl := time.Duration(cfg.SignedURLTTLSeconds) * time.Second
+		signedURL, err := storageSvc.SignedURL(file.StorageKey, ttl)
+		if err != nil {
+			fmt.Printf("Failed to sign URL for file %s: %v\n", file.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to read file")})
+			return
+		}
+
+		c.Header("Cache-Control", "no-store")
+		if c.Query("redirect") == "true" {
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"url":          signedURL,
+			"expires_at":   time.Now().Add(ttl),
+			"filename":     file.Filename,
+			"content_type": file.ContentType,
+		})
+	}
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
 	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
 	"gorm.io/gorm"
 )
 
@@ -15,7 +17,7 @@ func GetCurrentUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -26,25 +28,31 @@ func GetCurrentUser(db *gorm.DB) gin.HandlerFunc {
 		case string:
 			userID, err = uuid.Parse(v)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid user ID format")})
 				return
 			}
 		case uuid.UUID:
 			userID = v
 		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID type"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid user ID type")})
 			return
 		}
 		var user models.User
-		fmt.Printf("DEBUG: Looking for user with ID: %s\n", userID.String())
 		if err := db.First(&user, "id = ?", userID).Error; err != nil {
-			fmt.Printf("DEBUG: Database error: %v\n", err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "User not found")})
 			return
 		}
-		fmt.Printf("DEBUG: Found user: %s (%s)\n", user.Email, user.ID.String())
 
-		c.JSON(http.StatusOK, user)
+		consents, err := loadConsentState(db, user.ID)
+		if err != nil {
+			fmt.Printf("Failed to load consents of user %s: %v\n", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consents")})
+			return
+		}
+		c.JSON(http.StatusOK, struct {
+			models.User
+			Consents *consentState `json:"consents"`
+		}{user, consents})
 	}
 }
 
@@ -52,7 +60,7 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -63,21 +71,22 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 		case string:
 			userID, err = uuid.Parse(v)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid user ID format")})
 				return
 			}
 		case uuid.UUID:
 			userID = v
 		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID type"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid user ID type")})
 			return
 		}
 
 		var req struct {
-			FirstName   string `json:"first_name"`
-			LastName    string `json:"last_name"`
-			PhoneNumber string `json:"phone_number"`
-			Address     string `json:"address"`
+			FirstName         string `json:"first_name"`
+			LastName          string `json:"last_name"`
+			PhoneNumber       string `json:"phone_number"`
+			Address           string `json:"address"`
+			PreferredLanguage string `json:"preferred_language"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -85,19 +94,27 @@ func UpdateUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if req.PreferredLanguage != "" && !i18n.IsSupported(req.PreferredLanguage) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unsupported language")})
+			return
+		}
+
 		var user models.User
 		if err := db.First(&user, "id = ?", userID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "User not found")})
 			return
 		}
 
+		if req.PreferredLanguage != "" {
+			user.PreferredLanguage = i18n.Normalize(req.PreferredLanguage)
+		}
 		user.FirstName = req.FirstName
 		user.LastName = req.LastName
 		user.PhoneNumber = req.PhoneNumber
 		user.Address = req.Address
 
 		if err := db.Save(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update user")})
 			return
 		}
 
@@ -109,10 +126,12 @@ func ListTestKits(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var testKits []models.TestKit
 		if err := db.Find(&testKits).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch test kits"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch test kits")})
 			return
 		}
 
+		localizeTestKits(db, requestLanguage(c), testKits)
+
 		c.JSON(http.StatusOK, testKits)
 	}
 }
@@ -122,11 +141,35 @@ func GetTestKit(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var testKit models.TestKit
 		if err := db.First(&testKit, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test kit not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit not found")})
 			return
 		}
 
-		c.JSON(http.StatusOK, testKit)
+		testKits := []models.TestKit{testKit}
+		localizeTestKits(db, requestLanguage(c), testKits)
+
+		c.JSON(http.StatusOK, testKits[0])
+	}
+}
+
+// ListKitTypes returns the kit type catalog used to interpret test kit results,
+// with names and recommended steps in the request language
+func ListKitTypes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := requestLanguage(c)
+
+		kitTypes := make([]services.KitTypeSpec, len(services.KitCatalog))
+		for i, spec := range services.KitCatalog {
+			spec.Name = i18n.T(lang, spec.Name)
+			steps := make(map[string][]string, len(spec.RecommendedSteps))
+			for result, resultSteps := range spec.RecommendedSteps {
+				steps[result] = i18n.TSlice(lang, resultSteps)
+			}
+			spec.RecommendedSteps = steps
+			kitTypes[i] = spec
+		}
+
+		c.JSON(http.StatusOK, kitTypes)
 	}
 }
 
@@ -138,10 +181,22 @@ func CreateTestKit(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if testKit.KitType != "" {
+			spec := services.GetKitType(testKit.KitType)
+			if spec == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unsupported test kit type")})
+				return
+			}
+			testKit.KitType = spec.Code
+		}
+
 		if err := db.Create(&testKit).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create test kit"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create test kit")})
 			return
 		}
+		if userID, exists := c.Get("user_id"); exists {
+			attachFiles(db, models.FileResourceTestKit, testKit.ID, userID.(uuid.UUID), testKit.ImageURL)
+		}
 
 		c.JSON(http.StatusCreated, testKit)
 	}
@@ -152,7 +207,7 @@ func UpdateTestKit(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var testKit models.TestKit
 		if err := db.First(&testKit, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test kit not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit not found")})
 			return
 		}
 
@@ -161,10 +216,22 @@ func UpdateTestKit(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if testKit.KitType != "" {
+			spec := services.GetKitType(testKit.KitType)
+			if spec == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unsupported test kit type")})
+				return
+			}
+			testKit.KitType = spec.Code
+		}
+
 		if err := db.Save(&testKit).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update test kit"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update test kit")})
 			return
 		}
+		if userID, exists := c.Get("user_id"); exists {
+			attachFiles(db, models.FileResourceTestKit, testKit.ID, userID.(uuid.UUID), testKit.ImageURL)
+		}
 
 		c.JSON(http.StatusOK, testKit)
 	}
@@ -172,11 +239,16 @@ func UpdateTestKit(db *gorm.DB) gin.HandlerFunc {
 
 func DeleteTestKit(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
+		id, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid test kit ID")})
+			return
+		}
 		if err := db.Delete(&models.TestKit{}, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete test kit"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete test kit")})
 			return
 		}
+		deleteResourceFiles(db, models.FileResourceTestKit, id)
 
 		c.JSON(http.StatusOK, gin.H{"message": "Test kit deleted successfully"})
 	}
@@ -186,7 +258,7 @@ func CreateTestKitOrder(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -205,13 +277,13 @@ func CreateTestKitOrder(db *gorm.DB) gin.HandlerFunc {
 		// Get test kit details
 		var testKit models.TestKit
 		if err := db.First(&testKit, "id = ?", req.TestKitID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test kit not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit not found")})
 			return
 		}
 
 		// Check stock availability
 		if testKit.Stock < req.Quantity {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient stock"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Insufficient stock")})
 			return
 		}
 		order := models.TestKitOrder{
@@ -226,7 +298,7 @@ func CreateTestKitOrder(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&order).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create order"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create order")})
 			return
 		}
 
@@ -241,12 +313,12 @@ func ListUserOrders(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 		var orders []models.TestKitOrder
 		if err := db.Where("user_id = ?", userID).Find(&orders).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch orders")})
 			return
 		}
 
@@ -258,7 +330,7 @@ func GetOrder(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -272,7 +344,7 @@ func GetOrder(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&order).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Order not found")})
 			return
 		}
 
@@ -295,7 +367,7 @@ func UpdateOrderStatus(db *gorm.DB) gin.HandlerFunc {
 
 		var order models.TestKitOrder
 		if err := db.First(&order, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Order not found")})
 			return
 		}
 
@@ -305,7 +377,7 @@ func UpdateOrderStatus(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&order).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update order")})
 			return
 		}
 
@@ -317,7 +389,7 @@ func ListMedicalRecords(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -330,7 +402,7 @@ func ListMedicalRecords(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&records).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medical records"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medical records")})
 			return
 		}
 
@@ -342,7 +414,7 @@ func GetMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -356,7 +428,7 @@ func GetMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&record).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medical record not found")})
 			return
 		}
 
@@ -368,7 +440,7 @@ func CreateMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -381,7 +453,7 @@ func CreateMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 		record.UserID = userID.(uuid.UUID)
 
 		if err := db.Create(&record).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create medical record"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create medical record")})
 			return
 		}
 
@@ -393,7 +465,7 @@ func UpdateMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -407,7 +479,7 @@ func UpdateMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&record).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medical record not found")})
 			return
 		}
 
@@ -417,7 +489,7 @@ func UpdateMedicalRecord(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&record).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update medical record"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update medical record")})
 			return
 		}
 
@@ -434,7 +506,7 @@ func CreateTestResult(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&result).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create test result"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create test result")})
 			return
 		}
 
@@ -446,7 +518,7 @@ func ListTestResults(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 		var results []models.TestKitResult
@@ -458,7 +530,7 @@ func ListTestResults(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&results).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch test results"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch test results")})
 			return
 		}
 
@@ -470,7 +542,7 @@ func GetTestResult(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 		id := c.Param("id")
@@ -483,7 +555,7 @@ func GetTestResult(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&result).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test result not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test result not found")})
 			return
 		}
 
@@ -496,7 +568,7 @@ func UpdateTestResult(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var result models.TestKitResult
 		if err := db.First(&result, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test result not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test result not found")})
 			return
 		}
 
@@ -506,7 +578,7 @@ func UpdateTestResult(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&result).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update test result"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update test result")})
 			return
 		}
 
@@ -523,7 +595,7 @@ func CreateConsultation(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&consultation).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create consultation"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create consultation")})
 			return
 		}
 
@@ -535,7 +607,7 @@ func ListConsultations(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -549,7 +621,7 @@ func ListConsultations(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&consultations).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch consultations"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consultations")})
 			return
 		}
 
@@ -561,7 +633,7 @@ func GetConsultation(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -577,7 +649,7 @@ func GetConsultation(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&consultation).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Consultation not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Consultation not found")})
 			return
 		}
 
@@ -590,7 +662,7 @@ func UpdateConsultation(db *gorm.DB) gin.HandlerFunc {
 		id := c.Param("id")
 		var consultation models.Consultation
 		if err := db.First(&consultation, "id = ?", id).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Consultation not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Consultation not found")})
 			return
 		}
 
@@ -600,7 +672,7 @@ func UpdateConsultation(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Save(&consultation).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update consultation"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update consultation")})
 			return
 		}
 
@@ -619,7 +691,7 @@ func ListUsers(db *gorm.DB) gin.HandlerFunc {
 
 		db.Model(&models.User{}).Count(&total)
 		if err := db.Limit(limit).Offset(offset).Find(&users).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch users")})
 			return
 		}
 
@@ -643,7 +715,7 @@ func ListAllOrders(db *gorm.DB) gin.HandlerFunc {
 
 		db.Model(&models.TestKitOrder{}).Count(&total)
 		if err := db.Limit(limit).Offset(offset).Find(&orders).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch orders")})
 			return
 		}
 
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nyumbanicare/internal/i18n"
+)
+
+// tr translates a user-facing message into the language negotiated for the request
+func tr(c *gin.Context, message string) string {
+	return i18n.T(c.GetString("lang"), message)
+}
+
+// requestLanguage returns the language negotiated for the request
+func requestLanguage(c *gin.Context) string {
+	return i18n.Normalize(c.GetString("lang"))
+}
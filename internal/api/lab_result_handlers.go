@@ -0,0 +1,672 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// Lab bookings still waiting for their results
+var openLabBookingStatuses = []string{"booked", "confirmed", "sample_collected", "processing"}
+
+// labValuesInOrder preloads a result's values in the order the report lists them
+func labValuesInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("display_order")
+}
+
+// labAnalytesInOrder preloads a test's analytes in report order
+func labAnalytesInOrder(db *gorm.DB) *gorm.DB {
+	return db.Order("display_order, code")
+}
+
+// labResultPatient limits a result's patient to what lab staff and clinicians need
+func labResultPatient(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "first_name", "last_name", "phone_number", "date_of_birth", "gender")
+}
+
+// criticalResultClinician picks the clinician to escalate a patient's critical
+// lab result to: the clinician the patient most recently shared their record
+// with, otherwise the doctor or nurse with the fewest unacknowledged escalations
+func criticalResultClinician(db *gorm.DB, patientID uuid.UUID) (*models.User, error) {
+	var clinician models.User
+	var grant models.ConsentGrant
+	err := activeConsentGrants(db, patientID).
+		Where("purpose = ? AND provider_id IS NOT NULL", models.ConsentPurposeProviderSharing).
+		Order("granted_at DESC").
+		First(&grant).Error
+	if err == nil {
+		if err := db.Where("id = ? AND role IN ?", *grant.ProviderID, []string{"doctor", "nurse"}).First(&clinician).Error; err == nil {
+			return &clinician, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var candidate struct{ ID uuid.UUID }
+	err = db.Model(&models.User{}).
+		Select("users.id").
+		Joins("LEFT JOIN lab_results ON lab_results.escalated_to_id = users.id AND lab_results.acknowledged_at IS NULL AND lab_results.deleted_at IS NULL").
+		Where("users.role IN ?", []string{"doctor", "nurse"}).
+		Group("users.id, users.created_at").
+		Order("COUNT(lab_results.id), users.created_at").
+		Limit(1).
+		Scan(&candidate).Error
+	if err != nil {
+		return nil, err
+	}
+	if candidate.ID == uuid.Nil {
+		return nil, errors.New("no clinician is available")
+	}
+	if err := db.First(&clinician, "id = ?", candidate.ID).Error; err != nil {
+		return nil, err
+	}
+	return &clinician, nil
+}
+
+// escalateCriticalLabResult hands a result with critical values to a clinician
+// straight away, before it is verified, so the patient can be contacted. A result
+// no one could be found for stays in every clinician's critical queue.
+func escalateCriticalLabResult(db *gorm.DB, result *models.LabResult, testName string) {
+	clinician, err := criticalResultClinician(db, result.UserID)
+	if err != nil {
+		fmt.Printf("Failed to find a clinician for critical lab result %s: %v\n", result.ID, err)
+		return
+	}
+	now := time.Now()
+	if err := db.Model(result).Updates(map[string]interface{}{"escalated_to_id": clinician.ID, "escalated_at": now}).Error; err != nil {
+		fmt.Printf("Failed to escalate critical lab result %s: %v\n", result.ID, err)
+		return
+	}
+	result.EscalatedToID = &clinician.ID
+	result.EscalatedAt = &now
+
+	var patient models.User
+	if err := db.First(&patient, "id = ?", result.UserID).Error; err != nil {
+		fmt.Printf("Failed to load patient of critical lab result %s: %v\n", result.ID, err)
+		return
+	}
+	if err := NotifyCriticalLabResult(db, clinician, &patient, result, testName); err != nil {
+		fmt.Printf("Failed to notify clinician %s of critical lab result %s: %v\n", clinician.ID, result.ID, err)
+	}
+	fmt.Printf("Critical lab result %s escalated to clinician %s\n", result.ID, clinician.ID)
+}
+
+// @Summary Lab worklist
+// @Description List lab bookings awaiting results, oldest booking first, with each test's analytes and reference ranges and the patient's age and sex
+// @Tags Lab
+// @Produce json
+// @Param status query string false "Booking status; every status awaiting results by default"
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {object} map[string]interface{} "Lab bookings"
+// @Failure 403 {object} map[string]string "Lab technician access required"
+// @Router /api/v1/lab/bookings [get]
+// @Security Bearer
+func ListLabWorklist(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses := openLabBookingStatuses
+		if status := c.Query("status"); status != "" {
+			statuses = []string{status}
+		}
+		query := db.Model(&models.LabBooking{}).Where("status IN ?", statuses)
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab bookings")})
+			return
+		}
+		var bookings []models.LabBooking
+		if err := query.
+			Preload("LabTest.Analytes", labAnalytesInOrder).
+			Preload("LabTest.Analytes.ReferenceRanges").
+			Preload("User", labResultPatient).
+			Order("booking_date ASC").
+			Scopes(Paginate(c)).
+			Find(&bookings).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab bookings")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"bookings": bookings, "total": total})
+	}
+}
+
+// @Summary Enter lab results
+// @Description Enter the values of a lab booking's analytes. Each value is flagged against the reference range for the patient's age and sex: L or H outside the normal range, LL or HH beyond the critical limits, A for an unexpected text value. Critical results are escalated to a clinician immediately. The result waits for a lab technician to verify it before the patient sees it; until then it can be entered again.
+// @Tags Lab
+// @Accept json
+// @Produce json
+// @Param id path string true "Lab booking ID"
+// @Success 201 {object} models.LabResult
+// @Failure 400 {object} map[string]string "Unknown analyte, missing value or report not uploaded"
+// @Failure 409 {object} map[string]string "Booking closed or result already verified"
+// @Router /api/v1/lab/bookings/{id}/results [post]
+// @Security Bearer
+func EnterLabResults(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		technicianID := c.MustGet("user_id").(uuid.UUID)
+
+		var req struct {
+			Values []struct {
+				Code      string   `json:"code" binding:"required"`
+				Value     *float64 `json:"value"`
+				ValueText string   `json:"value_text"`
+			} `json:"values" binding:"required,min=1,dive"`
+			Interpretation string `json:"interpretation"`
+			ReportURL      string `json:"report_url"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Only reports uploaded through /uploads/file have been type-checked and scanned
+		if req.ReportURL != "" && !isOwnUpload(db, req.ReportURL, technicianID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Upload the lab report first and use the returned file_url")})
+			return
+		}
+
+		var booking models.LabBooking
+		if err := db.Preload("LabTest.Analytes.ReferenceRanges").First(&booking, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab booking not found")})
+			return
+		}
+		if !slices.Contains(openLabBookingStatuses, booking.Status) {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This booking is not awaiting results")})
+			return
+		}
+		if len(booking.LabTest.Analytes) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The lab test has no analytes defined")})
+			return
+		}
+
+		var patient models.User
+		if err := db.First(&patient, "id = ?", booking.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Patient not found")})
+			return
+		}
+		ageMonths := services.AgeInMonths(patient.DateOfBirth, booking.BookingDate)
+
+		analytes := map[string]*models.LabAnalyte{}
+		for i := range booking.LabTest.Analytes {
+			analyte := &booking.LabTest.Analytes[i]
+			analytes[strings.ToUpper(analyte.Code)] = analyte
+		}
+		values := make([]models.LabResultValue, 0, len(req.Values))
+		seen := map[string]bool{}
+		critical := false
+		for _, entered := range req.Values {
+			code := strings.ToUpper(strings.TrimSpace(entered.Code))
+			analyte, ok := analytes[code]
+			if !ok || seen[code] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unknown or repeated analyte"), "code": entered.Code})
+				return
+			}
+			seen[code] = true
+			if analyte.ValueType == models.LabValueText && strings.TrimSpace(entered.ValueText) == "" ||
+				analyte.ValueType != models.LabValueText && entered.Value == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Missing value for analyte"), "code": analyte.Code})
+				return
+			}
+
+			value := models.LabResultValue{
+				AnalyteID:    &analyte.ID,
+				Code:         analyte.Code,
+				Name:         analyte.Name,
+				LOINCCode:    analyte.LOINCCode,
+				Unit:         analyte.Unit,
+				DisplayOrder: analyte.DisplayOrder,
+			}
+			if analyte.ValueType == models.LabValueText {
+				value.ValueText = strings.TrimSpace(entered.ValueText)
+			} else {
+				value.Value = entered.Value
+			}
+			services.FlagLabValue(&value, services.SelectReferenceRange(analyte.ReferenceRanges, ageMonths, patient.Gender))
+			critical = critical || value.Critical
+			values = append(values, value)
+		}
+
+		var result models.LabResult
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Where("lab_booking_id = ?", booking.ID).First(&result).Error
+			switch {
+			case err == nil:
+				if result.Status == models.LabResultStatusVerified {
+					return errLabResultVerified
+				}
+				if err := tx.Where("lab_result_id = ?", result.ID).Delete(&models.LabResultValue{}).Error; err != nil {
+					return err
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				result = models.LabResult{LabBookingID: booking.ID, UserID: booking.UserID}
+			default:
+				return err
+			}
+
+			result.Status = models.LabResultStatusPendingVerification
+			result.Interpretation = req.Interpretation
+			result.ReportURL = req.ReportURL
+			result.ResultDate = time.Now()
+			result.EnteredByID = technicianID
+			result.Critical = critical
+			if !critical {
+				result.EscalatedToID = nil
+				result.EscalatedAt = nil
+			}
+			if err := tx.Omit("Values", "LabBooking", "User").Save(&result).Error; err != nil {
+				return err
+			}
+			for i := range values {
+				values[i].LabResultID = result.ID
+			}
+			if err := tx.Create(&values).Error; err != nil {
+				return err
+			}
+			result.Values = values
+			return tx.Model(&booking).Update("status", "processing").Error
+		})
+		if errors.Is(err, errLabResultVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Lab result already verified")})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save lab results")})
+			return
+		}
+		// Linking the report to the result lets the patient read it
+		attachFiles(db, models.FileResourceLabResult, result.ID, technicianID, result.ReportURL)
+
+		if critical && result.EscalatedToID == nil {
+			escalateCriticalLabResult(db, &result, booking.LabTest.Name)
+		}
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+var errLabResultVerified = errors.New("lab result already verified")
+
+// @Summary List entered lab results
+// @Description List lab results for lab technicians, those awaiting verification by default, critical results first
+// @Tags Lab
+// @Produce json
+// @Param status query string false "pending_verification or verified"
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {object} map[string]interface{} "Lab results"
+// @Router /api/v1/lab/results [get]
+// @Security Bearer
+func ListLabResultsForVerification(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.LabResult{}).Where("status = ?", c.DefaultQuery("status", models.LabResultStatusPendingVerification))
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab results")})
+			return
+		}
+		var results []models.LabResult
+		if err := query.
+			Preload("Values", labValuesInOrder).
+			Preload("LabBooking.LabTest").
+			Preload("User", labResultPatient).
+			Order("critical DESC, created_at ASC").
+			Scopes(Paginate(c)).
+			Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab results")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results, "total": total})
+	}
+}
+
+// @Summary Verify a lab result
+// @Description Verify an entered lab result. The technician verifying a result must not be the one who entered it. The booking is completed, the result joins the patient's medical record and the patient is notified.
+// @Tags Lab
+// @Produce json
+// @Param id path string true "Lab result ID"
+// @Success 200 {object} models.LabResult
+// @Failure 403 {object} map[string]string "The result was entered by the same technician"
+// @Failure 404 {object} map[string]string "Lab result not found"
+// @Failure 409 {object} map[string]string "Already verified"
+// @Router /api/v1/lab/results/{id}/verify [post]
+// @Security Bearer
+func VerifyLabResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		technicianID := c.MustGet("user_id").(uuid.UUID)
+
+		var result models.LabResult
+		if err := db.Preload("LabBooking.LabTest").First(&result, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab result not found")})
+			return
+		}
+		if result.EnteredByID == technicianID {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "A lab result must be verified by a different technician from the one who entered it")})
+			return
+		}
+		var technician models.User
+		if err := db.First(&technician, "id = ?", technicianID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "User not found")})
+			return
+		}
+
+		now := time.Now()
+		verifiedBy := strings.TrimSpace(technician.FirstName + " " + technician.LastName)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			update := tx.Model(&models.LabResult{}).
+				// Re-checked here in case the verifier entered the result again since it was loaded
+				Where("id = ? AND status = ? AND entered_by_id <> ?", result.ID, models.LabResultStatusPendingVerification, technicianID).
+				Updates(map[string]interface{}{
+					"status":         models.LabResultStatusVerified,
+					"verified_by":    verifiedBy,
+					"verified_by_id": technicianID,
+					"verified_at":    now,
+					"result_date":    now,
+				})
+			if update.Error != nil {
+				return update.Error
+			}
+			if update.RowsAffected == 0 {
+				return errLabResultVerified
+			}
+			return tx.Model(&models.LabBooking{}).Where("id = ?", result.LabBookingID).Update("status", "completed").Error
+		})
+		if errors.Is(err, errLabResultVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Lab result already verified")})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to verify lab result")})
+			return
+		}
+
+		if err := db.Preload("Values", labValuesInOrder).Preload("LabBooking.LabTest").First(&result, "id = ?", result.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to verify lab result")})
+			return
+		}
+		if err := NotifyLabResultReady(db, &result); err != nil {
+			fmt.Printf("Failed to notify patient of lab result %s: %v\n", result.ID, err)
+		}
+		fmt.Printf("Lab result %s verified by %s\n", result.ID, technicianID)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary List my lab results
+// @Description List the patient's verified lab results, newest first, with each value's unit, reference range and flag
+// @Tags Lab Work
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Lab results"
+// @Router /api/v1/lab-results [get]
+// @Security Bearer
+func ListMyLabResults(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var results []models.LabResult
+		if err := db.Preload("Values", labValuesInOrder).Preload("LabBooking.LabTest").
+			Where("user_id = ? AND status = ?", c.MustGet("user_id"), models.LabResultStatusVerified).
+			Order("result_date DESC").
+			Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab results")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	}
+}
+
+// @Summary Get a lab result
+// @Description Get one of the patient's verified lab results
+// @Tags Lab Work
+// @Produce json
+// @Param id path string true "Lab result ID"
+// @Success 200 {object} models.LabResult
+// @Failure 404 {object} map[string]string "Lab result not found"
+// @Router /api/v1/lab-results/{id} [get]
+// @Security Bearer
+func GetMyLabResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var result models.LabResult
+		if err := db.Preload("Values", labValuesInOrder).Preload("LabBooking.LabTest").
+			Where("id = ? AND user_id = ? AND status = ?", c.Param("id"), c.MustGet("user_id"), models.LabResultStatusVerified).
+			First(&result).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab result not found")})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary Critical lab results
+// @Description List lab results with critical values that no clinician has acknowledged yet, oldest first: those escalated to the signed-in clinician with filter=mine, otherwise all of them
+// @Tags Review
+// @Produce json
+// @Param filter query string false "mine"
+// @Success 200 {object} map[string]interface{} "Critical lab results"
+// @Failure 403 {object} map[string]string "Clinician access required"
+// @Router /api/v1/review/lab-results [get]
+// @Security Bearer
+func ListCriticalLabResults(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Where("critical = ? AND acknowledged_at IS NULL", true)
+		if c.Query("filter") == "mine" {
+			query = query.Where("escalated_to_id = ?", c.MustGet("user_id"))
+		}
+		var results []models.LabResult
+		if err := query.
+			Preload("Values", labValuesInOrder).
+			Preload("LabBooking.LabTest").
+			Preload("User", labResultPatient).
+			Order("created_at ASC").
+			Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch lab results")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"results": results})
+	}
+}
+
+// @Summary Acknowledge a critical lab result
+// @Description Record that a clinician has acted on a critical lab result, with optional comments that are shown to the patient once the result is verified
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Lab result ID"
+// @Success 200 {object} models.LabResult
+// @Failure 404 {object} map[string]string "Lab result not found"
+// @Failure 409 {object} map[string]string "Already acknowledged"
+// @Router /api/v1/review/lab-results/{id}/acknowledge [post]
+// @Security Bearer
+func AcknowledgeCriticalLabResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Comments string `json:"comments"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var result models.LabResult
+		if err := db.First(&result, "id = ? AND critical = ?", c.Param("id"), true).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab result not found")})
+			return
+		}
+
+		updates := map[string]interface{}{
+			"acknowledged_by_id": c.MustGet("user_id"),
+			"acknowledged_at":    time.Now(),
+		}
+		if comments := strings.TrimSpace(req.Comments); comments != "" {
+			updates["doctor_comments"] = comments
+		}
+		update := db.Model(&result).Where("acknowledged_at IS NULL").Updates(updates)
+		if update.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to acknowledge lab result")})
+			return
+		}
+		if update.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Lab result already acknowledged")})
+			return
+		}
+		if err := db.Preload("Values", labValuesInOrder).First(&result, "id = ?", result.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to acknowledge lab result")})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary Get a lab test's analytes
+// @Description Get the analytes a lab test reports and their reference ranges
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Lab test ID"
+// @Success 200 {object} map[string]interface{} "Analytes"
+// @Router /api/v1/admin/lab-tests/{id}/analytes [get]
+// @Security Bearer
+func GetLabTestAnalytes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var analytes []models.LabAnalyte
+		if err := labAnalytesInOrder(db.Preload("ReferenceRanges").Where("lab_test_id = ?", c.Param("id"))).
+			Find(&analytes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch analytes")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"analytes": analytes})
+	}
+}
+
+// validReferenceRange checks a range's sex, age band and limits are consistent
+func validReferenceRange(r *models.LabReferenceRange) bool {
+	switch r.Sex {
+	case "", "male", "female":
+	default:
+		return false
+	}
+	if r.MinAgeMonths < 0 || (r.MaxAgeMonths != nil && *r.MaxAgeMonths <= r.MinAgeMonths) {
+		return false
+	}
+	ordered := func(a, b *float64) bool { return a == nil || b == nil || *a <= *b }
+	return ordered(r.Low, r.High) && ordered(r.CriticalLow, r.Low) && ordered(r.High, r.CriticalHigh) &&
+		ordered(r.CriticalLow, r.CriticalHigh)
+}
+
+// @Summary Set a lab test's analytes
+// @Description Replace the analytes a lab test reports, each with its unit and age- and sex-specific reference ranges. Analytes are matched by code; those left out are removed. Results already entered keep the ranges they were flagged against.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Lab test ID"
+// @Success 200 {object} map[string]interface{} "Analytes"
+// @Failure 400 {object} map[string]string "Invalid analyte or reference range"
+// @Router /api/v1/admin/lab-tests/{id}/analytes [put]
+// @Security Bearer
+func SetLabTestAnalytes(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var labTest models.LabTest
+		if err := db.First(&labTest, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Lab test not found")})
+			return
+		}
+
+		var req struct {
+			Analytes []models.LabAnalyte `json:"analytes" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		seen := map[string]bool{}
+		for i := range req.Analytes {
+			analyte := &req.Analytes[i]
+			analyte.Code = strings.ToUpper(strings.TrimSpace(analyte.Code))
+			if analyte.ValueType == "" {
+				analyte.ValueType = models.LabValueNumeric
+			}
+			if analyte.Code == "" || strings.TrimSpace(analyte.Name) == "" || seen[analyte.Code] ||
+				(analyte.ValueType != models.LabValueNumeric && analyte.ValueType != models.LabValueText) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Each analyte needs a unique code, a name and a numeric or text value type"), "code": analyte.Code})
+				return
+			}
+			seen[analyte.Code] = true
+			for j := range analyte.ReferenceRanges {
+				if !validReferenceRange(&analyte.ReferenceRanges[j]) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid reference range"), "code": analyte.Code})
+					return
+				}
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var existing []models.LabAnalyte
+			if err := tx.Where("lab_test_id = ?", labTest.ID).Find(&existing).Error; err != nil {
+				return err
+			}
+			existingIDs := map[string]uuid.UUID{}
+			for _, analyte := range existing {
+				existingIDs[analyte.Code] = analyte.ID
+			}
+
+			keep := make([]uuid.UUID, 0, len(req.Analytes))
+			for i := range req.Analytes {
+				analyte := req.Analytes[i]
+				ranges := analyte.ReferenceRanges
+				analyte.ReferenceRanges = nil
+				analyte.LabTestID = labTest.ID
+				analyte.ID = existingIDs[analyte.Code]
+				if analyte.ID == uuid.Nil {
+					if err := tx.Create(&analyte).Error; err != nil {
+						return err
+					}
+				} else if err := tx.Model(&analyte).Select("name", "loinc_code", "unit", "value_type", "display_order").Updates(&analyte).Error; err != nil {
+					return err
+				}
+				keep = append(keep, analyte.ID)
+
+				if err := tx.Where("analyte_id = ?", analyte.ID).Delete(&models.LabReferenceRange{}).Error; err != nil {
+					return err
+				}
+				for j := range ranges {
+					ranges[j].ID = uuid.Nil
+					ranges[j].AnalyteID = analyte.ID
+				}
+				if len(ranges) > 0 {
+					if err := tx.Create(&ranges).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			removed := tx.Model(&models.LabAnalyte{}).Select("id").Where("lab_test_id = ?", labTest.ID)
+			if len(keep) > 0 {
+				removed = removed.Where("id NOT IN ?", keep)
+			}
+			if err := tx.Where("analyte_id IN (?)", removed).Delete(&models.LabReferenceRange{}).Error; err != nil {
+				return err
+			}
+			query := tx.Where("lab_test_id = ?", labTest.ID)
+			if len(keep) > 0 {
+				query = query.Where("id NOT IN ?", keep)
+			}
+			return query.Delete(&models.LabAnalyte{}).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save analytes")})
+			return
+		}
+
+		var analytes []models.LabAnalyte
+		if err := labAnalytesInOrder(db.Preload("ReferenceRanges").Where("lab_test_id = ?", labTest.ID)).Find(&analytes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch analytes")})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"analytes": analytes})
+	}
+}
@@ -1,20 +1,62 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
 	"gorm.io/gorm"
 )
 
+// loadOwnMedicalRecord loads the medical record in the id path parameter when it
+// belongs to the signed-in patient. It returns false when it has responded.
+func loadOwnMedicalRecord(c *gin.Context, db *gorm.DB) (*models.MedicalRecord, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+		return nil, false
+	}
+
+	var record models.MedicalRecord
+	if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&record).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medical record not found")})
+		return nil, false
+	}
+	return &record, true
+}
+
+// respondWithMedicalRecord merges the patient's history into the record and
+// responds with the full aggregate
+func respondWithMedicalRecord(c *gin.Context, db *gorm.DB, record *models.MedicalRecord) {
+	if err := syncMedicalRecordHistory(db, record); err != nil {
+		fmt.Printf("Failed to merge history into medical record %s: %v\n", record.ID, err)
+	}
+	if err := loadMedicalRecord(db, record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medical records")})
+		return
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// @Summary Create medical record
+// @Description Add an entry to the patient's medical record, creating the record on first use. When testResultId names one of the patient's test kit results, its result is added to the record's test results.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Success 201 {object} map[string]interface{}
+// @Router /api/v1/medical-records [post]
+// @Security Bearer
 func CreateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -29,7 +71,7 @@ func CreateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.BindJSON(&requestData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record data"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid record data")})
 			return
 		}
 
@@ -41,16 +83,17 @@ func CreateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 			}
 		}
 
-		record := models.MedicalRecord{
-			ID:     uuid.New(),
-			UserID: userID.(uuid.UUID),
+		record, err := medicalRecordFor(db, userID.(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create medical record")})
+			return
 		}
 
 		if requestData.TestResultID != nil && *requestData.TestResultID != "" {
 			testResultID, err := uuid.Parse(*requestData.TestResultID)
 			if err == nil {
 				var testKitResult models.TestKitResult
-				if err := db.Where("id = ?", testResultID).First(&testKitResult).Error; err == nil {
+				if err := db.Where("id = ? AND user_id = ?", testResultID, userID).First(&testKitResult).Error; err == nil {
 					testResult := models.TestResult{
 						ID:              uuid.New(),
 						MedicalRecordID: record.ID,
@@ -63,18 +106,13 @@ func CreateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 					}
 
 					if err := db.Create(&testResult).Error; err != nil {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create test result record"})
+						c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create test result record")})
 						return
 					}
 				}
 			}
 		}
 
-		if err := db.Create(&record).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create medical record"})
-			return
-		}
-
 		c.JSON(http.StatusCreated, gin.H{
 			"message":  "Medical record created successfully",
 			"recordId": record.ID,
@@ -82,63 +120,170 @@ func CreateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-func ListMedicalRecordsHandler(db *gorm.DB) gin.HandlerFunc {
+// @Summary Create my medical record
+// @Description Create the patient's medical record with their blood type, allergies, chronic conditions and family history. Each patient has a single record; if one already exists its id is returned with a conflict.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Success 201 {object} models.MedicalRecord
+// @Failure 409 {object} map[string]interface{} "The patient already has a medical record"
+// @Router /api/v1/medical-records/me [post]
+// @Security Bearer
+func CreateMyMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
-		var records []models.MedicalRecord
-		if err := db.Where("user_id = ?", userID).Preload("TestResults").Find(&records).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch medical records"})
+		var req struct {
+			BloodType         string   `json:"blood_type"`
+			Allergies         []string `json:"allergies"`
+			ChronicConditions []string `json:"chronic_conditions"`
+			FamilyHistory     string   `json:"family_history"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid record data")})
 			return
 		}
 
-		c.JSON(http.StatusOK, records)
+		var existing models.MedicalRecord
+		err := db.Where("user_id = ?", userID).First(&existing).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "You already have a medical record"), "id": existing.ID})
+			return
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create medical record")})
+			return
+		}
+
+		record := models.MedicalRecord{
+			UserID:            userID.(uuid.UUID),
+			BloodType:         req.BloodType,
+			Allergies:         req.Allergies,
+			ChronicConditions: req.ChronicConditions,
+			FamilyHistory:     req.FamilyHistory,
+		}
+		if record.Allergies == nil {
+			record.Allergies = []string{}
+		}
+		if record.ChronicConditions == nil {
+			record.ChronicConditions = []string{}
+		}
+		if err := db.Create(&record).Error; err != nil {
+			// Lost a race with another request creating the record
+			if db.Where("user_id = ?", userID).First(&existing).Error == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": tr(c, "You already have a medical record"), "id": existing.ID})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create medical record")})
+			return
+		}
+
+		if err := syncMedicalRecordHistory(db, &record); err != nil {
+			fmt.Printf("Failed to merge history into medical record %s: %v\n", record.ID, err)
+		}
+		if err := loadMedicalRecord(db, &record); err != nil {
+			fmt.Printf("Failed to reload medical record %s: %v\n", record.ID, err)
+		}
+
+		c.JSON(http.StatusCreated, record)
 	}
 }
 
-func GetMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
+// @Summary List medical records
+// @Description List the patient's medical record with its medications, test results and consultations. A patient has at most one record.
+// @Tags Medical Records
+// @Produce json
+// @Success 200 {array} models.MedicalRecord
+// @Router /api/v1/medical-records [get]
+// @Security Bearer
+func ListMedicalRecordsHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
-		recordID := c.Param("id")
-		var record models.MedicalRecord
-
-		if err := db.Where("id = ? AND user_id = ?", recordID, userID).Preload("TestResults").Preload("Medications").Preload("Consultations").First(&record).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+		var records []models.MedicalRecord
+		if err := db.Where("user_id = ?", userID).Find(&records).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medical records")})
 			return
 		}
+		for i := range records {
+			if err := syncMedicalRecordHistory(db, &records[i]); err != nil {
+				fmt.Printf("Failed to merge history into medical record %s: %v\n", records[i].ID, err)
+			}
+			if err := loadMedicalRecord(db, &records[i]); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medical records")})
+				return
+			}
+		}
 
-		c.JSON(http.StatusOK, record)
+		c.JSON(http.StatusOK, records)
 	}
 }
 
-func UpdateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
+// @Summary Get my medical record
+// @Description Get the patient's canonical medical record, creating it if needed, with history merged from reviewed test kit results, verified lab results and completed telehealth sessions
+// @Tags Medical Records
+// @Produce json
+// @Success 200 {object} models.MedicalRecord
+// @Router /api/v1/medical-records/me [get]
+// @Security Bearer
+func GetMyMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
-		recordID := c.Param("id")
-		var record models.MedicalRecord
+		record, err := medicalRecordFor(db, userID.(uuid.UUID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medical records")})
+			return
+		}
+		respondWithMedicalRecord(c, db, record)
+	}
+}
 
-		if err := db.Where("id = ? AND user_id = ?", recordID, userID).First(&record).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Medical record not found"})
+// @Summary Get medical record
+// @Description Get a medical record with its medications, test results and consultations, with history merged from reviewed test kit results, verified lab results and completed telehealth sessions
+// @Tags Medical Records
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 200 {object} models.MedicalRecord
+// @Router /api/v1/medical-records/{id} [get]
+// @Security Bearer
+func GetMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		respondWithMedicalRecord(c, db, record)
+	}
+}
+
+func UpdateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
 			return
 		}
 
-		var updateData models.MedicalRecord
+		var updateData struct {
+			BloodType         string   `json:"blood_type"`
+			Allergies         []string `json:"allergies"`
+			ChronicConditions []string `json:"chronic_conditions"`
+			FamilyHistory     string   `json:"family_history"`
+		}
 		if err := c.BindJSON(&updateData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid record data"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid record data")})
 			return
 		}
 
@@ -155,11 +300,616 @@ func UpdateMedicalRecordHandler(db *gorm.DB) gin.HandlerFunc {
 			record.FamilyHistory = updateData.FamilyHistory
 		}
 
-		if err := db.Save(&record).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update medical record"})
+		if err := db.Save(record).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update medical record")})
 			return
 		}
 
 		c.JSON(http.StatusOK, record)
 	}
 }
+
+// @Summary Add medication
+// @Description Add a medication to a medical record. Dose times are parsed from the frequency (e.g. "bd", "every 8 hours") unless dose_times are given, and the patient is reminded of each dose. It is checked against the patient's allergies, chronic conditions and current medications; serious alerts block the change unless an override_reason is given.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 201 {object} map[string]interface{} "Medication and any safety alerts"
+// @Failure 409 {object} map[string]interface{} "Serious safety alerts need an override_reason"
+// @Router /api/v1/medical-records/{id}/medications [post]
+// @Security Bearer
+func CreateMedicationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		var req struct {
+			Name           string     `json:"name" binding:"required"`
+			Dosage         string     `json:"dosage"`
+			Frequency      string     `json:"frequency"`
+			DoseTimes      []string   `json:"dose_times"` // "HH:MM" times doses are due, instead of parsing the frequency
+			StartDate      *time.Time `json:"start_date"`
+			EndDate        *time.Time `json:"end_date"`
+			PrescribedBy   string     `json:"prescribed_by"`
+			Notes          string     `json:"notes"`
+			OverrideReason string     `json:"override_reason"` // required to add a medication with serious safety alerts
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var record models.MedicalRecord
+		if err := db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&record).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medical record not found")})
+			return
+		}
+
+		medication := models.Medication{
+			ID:              uuid.New(),
+			MedicalRecordID: record.ID,
+			Name:            strings.TrimSpace(req.Name),
+			Dosage:          req.Dosage,
+			Frequency:       req.Frequency,
+			StartDate:       time.Now(),
+			EndDate:         req.EndDate,
+			PrescribedBy:    req.PrescribedBy,
+			Notes:           req.Notes,
+		}
+		if req.StartDate != nil {
+			medication.StartDate = *req.StartDate
+		}
+		if err := applyDoseSchedule(&medication, req.DoseTimes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Dose times must be given as HH:MM")})
+			return
+		}
+
+		check, ok := checkMedicationSafety(c, db, safetyCheckInput{
+			PatientID:      record.UserID,
+			Context:        models.SafetyContextMedication,
+			MedicationID:   &medication.ID,
+			Medications:    []string{medication.Name},
+			OverrideReason: req.OverrideReason,
+		})
+		if !ok {
+			return
+		}
+
+		if err := db.Create(&medication).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save medication")})
+			return
+		}
+		if err := scheduleMedicationDoses(db, medication, record.UserID); err != nil {
+			fmt.Printf("Failed to schedule doses for medication %s: %v\n", medication.ID, err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"medication":    medication,
+			"safety_alerts": check.Alerts,
+			"unrecognized":  check.Unrecognized,
+		})
+	}
+}
+
+// @Summary List medications
+// @Description List the medications on a medical record, newest first
+// @Tags Medical Records
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 200 {array} models.Medication
+// @Router /api/v1/medical-records/{id}/medications [get]
+// @Security Bearer
+func ListMedicationsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+
+		var medications []models.Medication
+		if err := db.Where("medical_record_id = ?", record.ID).Order("start_date DESC").Find(&medications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch medications")})
+			return
+		}
+
+		c.JSON(http.StatusOK, medications)
+	}
+}
+
+// @Summary Update medication
+// @Description Update a medication on a medical record. Changing the frequency, dose times or course reschedules upcoming doses, and renaming it repeats the safety check.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Param medicationId path string true "Medication ID"
+// @Success 200 {object} map[string]interface{} "Medication and any safety alerts"
+// @Failure 409 {object} map[string]interface{} "Serious safety alerts need an override_reason"
+// @Router /api/v1/medical-records/{id}/medications/{medicationId} [put]
+// @Security Bearer
+func UpdateMedicationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+
+		var medication models.Medication
+		if err := db.Where("id = ? AND medical_record_id = ?", c.Param("medicationId"), record.ID).First(&medication).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medication not found")})
+			return
+		}
+
+		var req struct {
+			Name           *string    `json:"name"`
+			Dosage         *string    `json:"dosage"`
+			Frequency      *string    `json:"frequency"`
+			DoseTimes      []string   `json:"dose_times"`
+			StartDate      *time.Time `json:"start_date"`
+			EndDate        *time.Time `json:"end_date"`
+			PrescribedBy   *string    `json:"prescribed_by"`
+			Notes          *string    `json:"notes"`
+			OverrideReason string     `json:"override_reason"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		renamed := false
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Medication name is required")})
+				return
+			}
+			renamed = !strings.EqualFold(name, medication.Name)
+			medication.Name = name
+		}
+		if req.Dosage != nil {
+			medication.Dosage = *req.Dosage
+		}
+		if req.PrescribedBy != nil {
+			medication.PrescribedBy = *req.PrescribedBy
+		}
+		if req.Notes != nil {
+			medication.Notes = *req.Notes
+		}
+
+		reschedule := req.Frequency != nil || req.DoseTimes != nil || req.StartDate != nil || req.EndDate != nil
+		if req.StartDate != nil {
+			medication.StartDate = *req.StartDate
+		}
+		if req.EndDate != nil {
+			medication.EndDate = req.EndDate
+		}
+		if req.Frequency != nil || req.DoseTimes != nil {
+			if req.Frequency != nil {
+				medication.Frequency = *req.Frequency
+			}
+			medication.DoseTimes = []string{}
+			medication.AsNeeded = false
+			if err := applyDoseSchedule(&medication, req.DoseTimes); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Dose times must be given as HH:MM")})
+				return
+			}
+		}
+
+		check := &services.SafetyCheck{}
+		if renamed {
+			var ok bool
+			check, ok = checkMedicationSafety(c, db, safetyCheckInput{
+				PatientID:      record.UserID,
+				Context:        models.SafetyContextMedication,
+				MedicationID:   &medication.ID,
+				Medications:    []string{medication.Name},
+				OverrideReason: req.OverrideReason,
+			})
+			if !ok {
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&medication).Error; err != nil {
+				return err
+			}
+			if !reschedule {
+				return nil
+			}
+			// Upcoming doses are rebuilt from the new schedule; logged doses are kept
+			return tx.Where("medication_id = ? AND status = ? AND scheduled_at > ?", medication.ID, models.DoseStatusPending, time.Now()).
+				Delete(&models.MedicationDose{}).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save medication")})
+			return
+		}
+		if reschedule {
+			if err := scheduleMedicationDoses(db, medication, record.UserID); err != nil {
+				fmt.Printf("Failed to schedule doses for medication %s: %v\n", medication.ID, err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"medication":    medication,
+			"safety_alerts": check.Alerts,
+			"unrecognized":  check.Unrecognized,
+		})
+	}
+}
+
+// @Summary Delete medication
+// @Description Remove a medication from a medical record and cancel its upcoming doses. Logged doses are kept.
+// @Tags Medical Records
+// @Param id path string true "Medical record ID"
+// @Param medicationId path string true "Medication ID"
+// @Success 204
+// @Router /api/v1/medical-records/{id}/medications/{medicationId} [delete]
+// @Security Bearer
+func DeleteMedicationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+
+		var medication models.Medication
+		if err := db.Where("id = ? AND medical_record_id = ?", c.Param("medicationId"), record.ID).First(&medication).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medication not found")})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("medication_id = ? AND status = ?", medication.ID, models.DoseStatusPending).
+				Delete(&models.MedicationDose{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&medication).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete medication")})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// recordTestResultInput is a test result the patient adds to their record
+type recordTestResultInput struct {
+	TestType       string     `json:"test_type" binding:"required"`
+	TestDate       *time.Time `json:"test_date"`
+	Result         string     `json:"result" binding:"required"`
+	Interpretation string     `json:"interpretation"`
+	LabName        string     `json:"lab_name"`
+	DoctorNotes    string     `json:"doctor_notes"`
+}
+
+func (in recordTestResultInput) apply(result *models.TestResult) {
+	result.TestType = strings.TrimSpace(in.TestType)
+	result.Result = in.Result
+	result.Interpretation = in.Interpretation
+	result.LabName = in.LabName
+	result.DoctorNotes = in.DoctorNotes
+	if in.TestDate != nil {
+		result.TestDate = *in.TestDate
+	} else if result.TestDate.IsZero() {
+		result.TestDate = time.Now()
+	}
+}
+
+// loadRecordTestResult loads a test result on the record for editing. Results
+// merged from test kits and lab results are owned by their source and cannot be
+// changed here. It returns false when it has responded.
+func loadRecordTestResult(c *gin.Context, db *gorm.DB, record *models.MedicalRecord) (*models.TestResult, bool) {
+	var result models.TestResult
+	if err := db.Where("id = ? AND medical_record_id = ?", c.Param("resultId"), record.ID).First(&result).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test result not found")})
+		return nil, false
+	}
+	if result.SourceType != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This entry is kept in sync with its source and cannot be changed")})
+		return nil, false
+	}
+	return &result, true
+}
+
+// @Summary List record test results
+// @Description List the test results on a medical record, including reviewed test kit results and verified lab results, newest first
+// @Tags Medical Records
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 200 {array} models.TestResult
+// @Router /api/v1/medical-records/{id}/test-results [get]
+// @Security Bearer
+func ListRecordTestResultsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		if err := syncMedicalRecordHistory(db, record); err != nil {
+			fmt.Printf("Failed to merge history into medical record %s: %v\n", record.ID, err)
+		}
+
+		var results []models.TestResult
+		if err := db.Where("medical_record_id = ?", record.ID).Order("test_date DESC").Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch test results")})
+			return
+		}
+
+		c.JSON(http.StatusOK, results)
+	}
+}
+
+// @Summary Add record test result
+// @Description Add a test result from outside Nyumbani Care to a medical record
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 201 {object} models.TestResult
+// @Router /api/v1/medical-records/{id}/test-results [post]
+// @Security Bearer
+func CreateRecordTestResultHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+
+		var req recordTestResultInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		result := models.TestResult{MedicalRecordID: record.ID}
+		req.apply(&result)
+		if err := db.Create(&result).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create test result")})
+			return
+		}
+
+		c.JSON(http.StatusCreated, result)
+	}
+}
+
+// @Summary Update record test result
+// @Description Update a test result the patient added to a medical record. Merged results cannot be changed.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Param resultId path string true "Test result ID"
+// @Success 200 {object} models.TestResult
+// @Failure 409 {object} map[string]interface{} "The result is merged from a test kit or lab result"
+// @Router /api/v1/medical-records/{id}/test-results/{resultId} [put]
+// @Security Bearer
+func UpdateRecordTestResultHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		result, ok := loadRecordTestResult(c, db, record)
+		if !ok {
+			return
+		}
+
+		var req recordTestResultInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.apply(result)
+		if err := db.Save(result).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update test result")})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary Delete record test result
+// @Description Delete a test result the patient added to a medical record. Merged results cannot be deleted.
+// @Tags Medical Records
+// @Param id path string true "Medical record ID"
+// @Param resultId path string true "Test result ID"
+// @Success 204
+// @Router /api/v1/medical-records/{id}/test-results/{resultId} [delete]
+// @Security Bearer
+func DeleteRecordTestResultHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		result, ok := loadRecordTestResult(c, db, record)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(result).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete test result")})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// recordConsultationInput is a consultation the patient adds to their record,
+// usually with a clinician outside Nyumbani Care
+type recordConsultationInput struct {
+	DoctorName   string     `json:"doctor_name" binding:"required"`
+	Date         time.Time  `json:"date" binding:"required"`
+	Type         string     `json:"type"`
+	Diagnosis    string     `json:"diagnosis"`
+	Treatment    string     `json:"treatment"`
+	Notes        string     `json:"notes"`
+	FollowUpDate *time.Time `json:"follow_up_date"`
+}
+
+func (in recordConsultationInput) apply(consultation *models.Consultation) {
+	consultation.DoctorName = strings.TrimSpace(in.DoctorName)
+	consultation.Date = in.Date
+	consultation.Type = in.Type
+	if consultation.Type == "" {
+		consultation.Type = "in-person"
+	}
+	consultation.Diagnosis = in.Diagnosis
+	consultation.Treatment = in.Treatment
+	consultation.Notes = in.Notes
+	consultation.FollowUpDate = in.FollowUpDate
+}
+
+// loadRecordConsultation loads a consultation on the record for editing.
+// Consultations merged from telehealth sessions cannot be changed here. It
+// returns false when it has responded.
+func loadRecordConsultation(c *gin.Context, db *gorm.DB, record *models.MedicalRecord) (*models.Consultation, bool) {
+	var consultation models.Consultation
+	if err := db.Where("id = ? AND medical_record_id = ?", c.Param("consultationId"), record.ID).First(&consultation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Consultation not found")})
+		return nil, false
+	}
+	if consultation.SourceType != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This entry is kept in sync with its source and cannot be changed")})
+		return nil, false
+	}
+	return &consultation, true
+}
+
+// @Summary List record consultations
+// @Description List the consultations on a medical record, including completed telehealth sessions, newest first
+// @Tags Medical Records
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 200 {array} models.Consultation
+// @Router /api/v1/medical-records/{id}/consultations [get]
+// @Security Bearer
+func ListRecordConsultationsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		if err := syncMedicalRecordHistory(db, record); err != nil {
+			fmt.Printf("Failed to merge history into medical record %s: %v\n", record.ID, err)
+		}
+
+		var consultations []models.Consultation
+		if err := db.Where("medical_record_id = ?", record.ID).Order("date DESC").Find(&consultations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch consultations")})
+			return
+		}
+
+		c.JSON(http.StatusOK, consultations)
+	}
+}
+
+// @Summary Add record consultation
+// @Description Add a consultation from outside Nyumbani Care to a medical record
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Success 201 {object} models.Consultation
+// @Router /api/v1/medical-records/{id}/consultations [post]
+// @Security Bearer
+func CreateRecordConsultationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+
+		var req recordConsultationInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		consultation := models.Consultation{MedicalRecordID: record.ID}
+		req.apply(&consultation)
+		if err := db.Create(&consultation).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create consultation")})
+			return
+		}
+
+		c.JSON(http.StatusCreated, consultation)
+	}
+}
+
+// @Summary Update record consultation
+// @Description Update a consultation the patient added to a medical record. Telehealth consultations cannot be changed.
+// @Tags Medical Records
+// @Accept json
+// @Produce json
+// @Param id path string true "Medical record ID"
+// @Param consultationId path string true "Consultation ID"
+// @Success 200 {object} models.Consultation
+// @Failure 409 {object} map[string]interface{} "The consultation is merged from a telehealth session"
+// @Router /api/v1/medical-records/{id}/consultations/{consultationId} [put]
+// @Security Bearer
+func UpdateRecordConsultationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		consultation, ok := loadRecordConsultation(c, db, record)
+		if !ok {
+			return
+		}
+
+		var req recordConsultationInput
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		req.apply(consultation)
+		if err := db.Save(consultation).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update consultation")})
+			return
+		}
+
+		c.JSON(http.StatusOK, consultation)
+	}
+}
+
+// @Summary Delete record consultation
+// @Description Delete a consultation the patient added to a medical record. Telehealth consultations cannot be deleted.
+// @Tags Medical Records
+// @Param id path string true "Medical record ID"
+// @Param consultationId path string true "Consultation ID"
+// @Success 204
+// @Router /api/v1/medical-records/{id}/consultations/{consultationId} [delete]
+// @Security Bearer
+func DeleteRecordConsultationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		record, ok := loadOwnMedicalRecord(c, db)
+		if !ok {
+			return
+		}
+		consultation, ok := loadRecordConsultation(c, db, record)
+		if !ok {
+			return
+		}
+
+		if err := db.Delete(consultation).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete consultation")})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// medicalRecordFor returns the patient's canonical medical record, creating it on first use
+func medicalRecordFor(db *gorm.DB, userID uuid.UUID) (*models.MedicalRecord, error) {
+	var record models.MedicalRecord
+	err := db.Where("user_id = ?", userID).First(&record).Error
+	if err == nil {
+		return &record, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	record = models.MedicalRecord{UserID: userID, Allergies: []string{}, ChronicConditions: []string{}}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return nil, err
+	}
+	// A concurrent request may have created the record first, so read back whichever won
+	if err := db.Where("user_id = ?", userID).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// syncMedicalRecordHistory merges the patient's reviewed test kit results, verified
+// lab results and completed telehealth sessions into the record. Entries are keyed
+// on their source, so syncing again refreshes them instead of adding duplicates.
+func syncMedicalRecordHistory(db *gorm.DB, record *models.MedicalRecord) error {
+	var testResults []models.TestResult
+
+	var kitResults []models.TestKitResult
+	if err := db.Where("user_id = ? AND reviewed_at IS NOT NULL", record.UserID).Find(&kitResults).Error; err != nil {
+		return fmt.Errorf("failed to load reviewed test kit results: %w", err)
+	}
+	kitNames, err := testKitNames(db, kitResults)
+	if err != nil {
+		return err
+	}
+	for _, result := range kitResults {
+		sourceID := result.ID
+		testType := kitNames[result.TestKitID]
+		if testType == "" {
+			testType = result.KitType
+		}
+		testResults = append(testResults, models.TestResult{
+			MedicalRecordID: record.ID,
+			TestType:        testType,
+			TestDate:        result.CreatedAt,
+			Result:          result.Result,
+			Interpretation:  result.ReviewNotes,
+			LabName:         "Nyumbani Care Analysis",
+			DoctorNotes:     result.Notes,
+			SourceType:      models.RecordSourceTestKitResult,
+			SourceID:        &sourceID,
+		})
+	}
+
+	var labResults []models.LabResult
+	if err := db.Preload("LabBooking.LabTest").Preload("Values", labValuesInOrder).
+		Where("user_id = ? AND status = ?", record.UserID, models.LabResultStatusVerified).
+		Find(&labResults).Error; err != nil {
+		return fmt.Errorf("failed to load verified lab results: %w", err)
+	}
+	for _, result := range labResults {
+		sourceID := result.ID
+		testType := result.LabBooking.LabTest.Name
+		if testType == "" {
+			testType = "Laboratory Test"
+		}
+		testResults = append(testResults, models.TestResult{
+			MedicalRecordID: record.ID,
+			TestType:        testType,
+			TestDate:        result.ResultDate,
+			Result:          services.LabResultSummary(result.Values),
+			Interpretation:  result.Interpretation,
+			LabName:         "Nyumbani Care Laboratory",
+			DoctorNotes:     result.DoctorComments,
+			SourceType:      models.RecordSourceLabResult,
+			SourceID:        &sourceID,
+		})
+	}
+
+	if len(testResults) > 0 {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source_type"}, {Name: "source_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"medical_record_id", "test_type", "test_date", "result", "interpretation", "doctor_notes", "updated_at"}),
+		}).Create(&testResults).Error; err != nil {
+			return fmt.Errorf("failed to merge test results: %w", err)
+		}
+	}
+
+	var sessions []models.TelehealthSession
+	if err := db.Where("patient_id = ? AND status = ?", record.UserID, "completed").Find(&sessions).Error; err != nil {
+		return fmt.Errorf("failed to load telehealth sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	names, err := providerNames(db, sessions)
+	if err != nil {
+		return err
+	}
+
+	consultations := make([]models.Consultation, 0, len(sessions))
+	for _, session := range sessions {
+		sourceID, providerID := session.ID, session.ProviderID
+		consultations = append(consultations, models.Consultation{
+			MedicalRecordID: record.ID,
+			DoctorID:        &providerID,
+			DoctorName:      names[providerID],
+			Date:            session.ScheduledAt,
+			Type:            "telehealth",
+			Diagnosis:       session.Diagnosis,
+			Treatment:       session.Treatment,
+			Notes:           session.Notes,
+			SourceType:      models.RecordSourceTelehealth,
+			SourceID:        &sourceID,
+		})
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source_type"}, {Name: "source_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"medical_record_id", "doctor_name", "date", "diagnosis", "treatment", "notes", "updated_at"}),
+	}).Create(&consultations).Error; err != nil {
+		return fmt.Errorf("failed to merge telehealth consultations: %w", err)
+	}
+	return nil
+}
+
+// testKitNames maps the kits of test kit results to their catalogue names
+func testKitNames(db *gorm.DB, results []models.TestKitResult) (map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+	if len(results) == 0 {
+		return names, nil
+	}
+	kitIDs := make([]uuid.UUID, 0, len(results))
+	for _, result := range results {
+		kitIDs = append(kitIDs, result.TestKitID)
+	}
+	var kits []models.TestKit
+	if err := db.Unscoped().Where("id IN ?", kitIDs).Find(&kits).Error; err != nil {
+		return nil, fmt.Errorf("failed to load test kits: %w", err)
+	}
+	for _, kit := range kits {
+		names[kit.ID] = kit.Name
+	}
+	return names, nil
+}
+
+// providerNames maps the providers of telehealth sessions to their full names
+func providerNames(db *gorm.DB, sessions []models.TelehealthSession) (map[uuid.UUID]string, error) {
+	names := map[uuid.UUID]string{}
+	if len(sessions) == 0 {
+		return names, nil
+	}
+	providerIDs := make([]uuid.UUID, 0, len(sessions))
+	for _, session := range sessions {
+		providerIDs = append(providerIDs, session.ProviderID)
+	}
+	var providers []models.User
+	if err := db.Unscoped().Select("id", "first_name", "last_name").Where("id IN ?", providerIDs).Find(&providers).Error; err != nil {
+		return nil, fmt.Errorf("failed to load telehealth providers: %w", err)
+	}
+	for _, provider := range providers {
+		names[provider.ID] = strings.TrimSpace(provider.FirstName + " " + provider.LastName)
+	}
+	return names, nil
+}
+
+// loadMedicalRecord loads a medical record with its medications, test results and
+// consultations, newest first
+func loadMedicalRecord(db *gorm.DB, record *models.MedicalRecord) error {
+	return db.
+		Preload("Medications", func(tx *gorm.DB) *gorm.DB { return tx.Order("start_date DESC") }).
+		Preload("TestResults", func(tx *gorm.DB) *gorm.DB { return tx.Order("test_date DESC") }).
+		Preload("Consultations", func(tx *gorm.DB) *gorm.DB { return tx.Order("date DESC") }).
+		First(record, "id = ?", record.ID).Error
+}
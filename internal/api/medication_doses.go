@@ -0,0 +1,455 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// doseReminderInterval is how often doses are scheduled, reminded and marked missed
+	doseReminderInterval = 5 * time.Minute
+	// doseScheduleHorizon is how far ahead doses are scheduled, so reminders survive a short outage
+	doseScheduleHorizon = 24 * time.Hour
+	// doseReminderBatch caps how many reminders are sent per run
+	doseReminderBatch = 500
+	// doseLogWindow is how far from a scheduled time a dose logged without a dose_id is matched to it
+	doseLogWindow = 12 * time.Hour
+)
+
+var (
+	doseLoc     *time.Location
+	doseLocOnce sync.Once
+)
+
+// doseLocation returns the time zone dose times are scheduled in
+func doseLocation() *time.Location {
+	doseLocOnce.Do(func() {
+		loc, err := time.LoadLocation(config.GetConfig().External.DoseTimezone)
+		if err != nil {
+			fmt.Printf("Invalid dose time zone, scheduling doses in UTC: %v\n", err)
+			loc = time.UTC
+		}
+		doseLoc = loc
+	})
+	return doseLoc
+}
+
+// doseMissedAfter is how long after its scheduled time an unlogged dose counts as missed
+func doseMissedAfter() time.Duration {
+	return time.Duration(config.GetConfig().External.DoseMissedAfterHours) * time.Hour
+}
+
+// applyDoseSchedule sets a medication's dose times from those given, or else by
+// parsing its frequency. A frequency that cannot be parsed leaves it unscheduled.
+func applyDoseSchedule(medication *models.Medication, doseTimes []string) error {
+	if doseTimes != nil {
+		times, err := services.NormalizeDoseTimes(doseTimes)
+		if err != nil {
+			return err
+		}
+		medication.DoseTimes = times
+		medication.AsNeeded = false
+		return nil
+	}
+
+	if schedule, ok := services.ParseFrequency(medication.Frequency); ok {
+		medication.DoseTimes = append([]string{}, schedule.Times...)
+		medication.AsNeeded = schedule.AsNeeded
+	}
+	return nil
+}
+
+// scheduleMedicationDoses creates the pending doses due from now until the
+// horizon, within the medication's course. Doses already scheduled are kept.
+func scheduleMedicationDoses(db *gorm.DB, medication models.Medication, userID uuid.UUID) error {
+	if medication.AsNeeded || len(medication.DoseTimes) == 0 {
+		return nil
+	}
+
+	from := time.Now()
+	if medication.StartDate.After(from) {
+		from = medication.StartDate
+	}
+	to := time.Now().Add(doseScheduleHorizon)
+	if medication.EndDate != nil && medication.EndDate.Before(to) {
+		to = *medication.EndDate
+	}
+
+	var doses []models.MedicationDose
+	for _, at := range services.ScheduledDoses(medication.DoseTimes, doseLocation(), from, to) {
+		doses = append(doses, models.MedicationDose{
+			MedicationID: medication.ID,
+			UserID:       userID,
+			ScheduledAt:  at,
+			Status:       models.DoseStatusPending,
+		})
+	}
+	if len(doses) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&doses).Error
+}
+
+// StartDoseReminders periodically schedules upcoming doses, reminds patients of
+// doses that are due and marks unlogged doses as missed
+func StartDoseReminders(db *gorm.DB) {
+	go func() {
+		for {
+			if err := RunDoseReminders(db); err != nil {
+				fmt.Printf("Dose reminders failed: %v\n", err)
+			}
+			time.Sleep(doseReminderInterval)
+		}
+	}()
+}
+
+// RunDoseReminders runs one pass of dose scheduling, reminders and missed doses
+func RunDoseReminders(db *gorm.DB) error {
+	now := time.Now()
+
+	var medications []models.Medication
+	if err := db.Where("end_date IS NULL OR end_date > ?", now).Find(&medications).Error; err != nil {
+		return fmt.Errorf("failed to load active medications: %w", err)
+	}
+	owners, err := medicationOwners(db, medications)
+	if err != nil {
+		return err
+	}
+	for _, medication := range medications {
+		userID, ok := owners[medication.MedicalRecordID]
+		if !ok {
+			continue
+		}
+		// Medications added before dose schedules were parsed pick one up here
+		if len(medication.DoseTimes) == 0 && !medication.AsNeeded {
+			if schedule, ok := services.ParseFrequency(medication.Frequency); ok {
+				medication.DoseTimes = append([]string{}, schedule.Times...)
+				medication.AsNeeded = schedule.AsNeeded
+				if err := db.Model(&medication).Select("DoseTimes", "AsNeeded").Updates(&medication).Error; err != nil {
+					fmt.Printf("Failed to save dose schedule for medication %s: %v\n", medication.ID, err)
+					continue
+				}
+			}
+		}
+		if err := scheduleMedicationDoses(db, medication, userID); err != nil {
+			fmt.Printf("Failed to schedule doses for medication %s: %v\n", medication.ID, err)
+		}
+	}
+
+	missedBefore := now.Add(-doseMissedAfter())
+	var due []models.MedicationDose
+	if err := db.Where("status = ? AND reminded_at IS NULL AND NOT unscheduled AND scheduled_at <= ? AND scheduled_at > ?",
+		models.DoseStatusPending, now, missedBefore).
+		Order("scheduled_at").
+		Limit(doseReminderBatch).
+		Find(&due).Error; err != nil {
+		return fmt.Errorf("failed to load due doses: %w", err)
+	}
+	if len(due) > 0 {
+		if err := remindDoses(db, due); err != nil {
+			return err
+		}
+	}
+
+	result := db.Model(&models.MedicationDose{}).
+		Where("status = ? AND scheduled_at <= ?", models.DoseStatusPending, missedBefore).
+		Update("status", models.DoseStatusMissed)
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark missed doses: %w", result.Error)
+	}
+	return nil
+}
+
+// medicationOwners maps the medical records of medications to the patients they belong to
+func medicationOwners(db *gorm.DB, medications []models.Medication) (map[uuid.UUID]uuid.UUID, error) {
+	owners := map[uuid.UUID]uuid.UUID{}
+	if len(medications) == 0 {
+		return owners, nil
+	}
+	recordIDs := make([]uuid.UUID, 0, len(medications))
+	for _, medication := range medications {
+		recordIDs = append(recordIDs, medication.MedicalRecordID)
+	}
+	var records []models.MedicalRecord
+	if err := db.Select("id", "user_id").Where("id IN ?", recordIDs).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to load medical records: %w", err)
+	}
+	for _, record := range records {
+		owners[record.ID] = record.UserID
+	}
+	return owners, nil
+}
+
+// remindDoses notifies patients of due doses. Pending doses of medications that
+// were removed are deleted instead, so they do not count as missed.
+func remindDoses(db *gorm.DB, doses []models.MedicationDose) error {
+	medicationIDs := make([]uuid.UUID, 0, len(doses))
+	userIDs := make([]uuid.UUID, 0, len(doses))
+	for _, dose := range doses {
+		medicationIDs = append(medicationIDs, dose.MedicationID)
+		userIDs = append(userIDs, dose.UserID)
+	}
+
+	var medications []models.Medication
+	if err := db.Where("id IN ?", medicationIDs).Find(&medications).Error; err != nil {
+		return fmt.Errorf("failed to load medications: %w", err)
+	}
+	byID := make(map[uuid.UUID]models.Medication, len(medications))
+	for _, medication := range medications {
+		byID[medication.ID] = medication
+	}
+	var users []models.User
+	if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return fmt.Errorf("failed to load patients: %w", err)
+	}
+	usersByID := make(map[uuid.UUID]models.User, len(users))
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+
+	var removed []uuid.UUID
+	for i := range doses {
+		dose := &doses[i]
+		medication, ok := byID[dose.MedicationID]
+		if !ok {
+			removed = append(removed, dose.MedicationID)
+			continue
+		}
+		user, ok := usersByID[dose.UserID]
+		if !ok {
+			continue
+		}
+		if err := NotifyDoseDue(db, user, medication, dose); err != nil {
+			fmt.Printf("Failed to send reminder for dose %s: %v\n", dose.ID, err)
+			continue
+		}
+		if err := db.Model(dose).Update("reminded_at", time.Now()).Error; err != nil {
+			fmt.Printf("Failed to record reminder for dose %s: %v\n", dose.ID, err)
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := db.Where("medication_id IN ? AND status = ?", removed, models.DoseStatusPending).
+			Delete(&models.MedicationDose{}).Error; err != nil {
+			return fmt.Errorf("failed to remove doses of deleted medications: %w", err)
+		}
+	}
+	return nil
+}
+
+// DoseAdherence summarises how many due doses a patient took
+type DoseAdherence struct {
+	Due     int64    `json:"due"` // scheduled doses that were taken, skipped or missed
+	Taken   int64    `json:"taken"`
+	Skipped int64    `json:"skipped"`
+	Missed  int64    `json:"missed"`
+	Rate    *float64 `json:"rate"` // percentage of due doses taken, null when no doses were due
+}
+
+// doseAdherence counts a patient's scheduled doses since from, for one medication
+// when medicationID is set. Doses still awaiting a log are not counted yet.
+func doseAdherence(db *gorm.DB, userID uuid.UUID, medicationID *uuid.UUID, from time.Time) (DoseAdherence, error) {
+	var adherence DoseAdherence
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+
+	query := db.Model(&models.MedicationDose{}).
+		Select("status, COUNT(*) AS count").
+		Where("user_id = ? AND NOT unscheduled AND scheduled_at >= ? AND status <> ?", userID, from, models.DoseStatusPending)
+	if medicationID != nil {
+		query = query.Where("medication_id = ?", *medicationID)
+	}
+	if err := query.Group("status").Scan(&counts).Error; err != nil {
+		return adherence, err
+	}
+
+	for _, count := range counts {
+		switch count.Status {
+		case models.DoseStatusTaken:
+			adherence.Taken = count.Count
+		case models.DoseStatusSkipped:
+			adherence.Skipped = count.Count
+		case models.DoseStatusMissed:
+			adherence.Missed = count.Count
+		}
+	}
+	adherence.Due = adherence.Taken + adherence.Skipped + adherence.Missed
+	if adherence.Due > 0 {
+		rate := float64(adherence.Taken) / float64(adherence.Due) * 100
+		rate = math.Round(rate*10) / 10
+		adherence.Rate = &rate
+	}
+	return adherence, nil
+}
+
+// findPatientMedication loads a medication from one of the current patient's medical records
+func findPatientMedication(c *gin.Context, db *gorm.DB) (*models.Medication, uuid.UUID, bool) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var medication models.Medication
+	if err := db.Joins("JOIN medical_records ON medical_records.id = medications.medical_record_id AND medical_records.deleted_at IS NULL").
+		Where("medications.id = ? AND medical_records.user_id = ?", c.Param("id"), userID).
+		First(&medication).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Medication not found")})
+		return nil, userID, false
+	}
+	return &medication, userID, true
+}
+
+// @Summary Log a medication dose
+// @Description Record a dose as taken or skipped. Without a dose_id the scheduled dose nearest the time taken is used; medication taken as needed logs a new dose.
+// @Tags Medications
+// @Accept json
+// @Produce json
+// @Param id path string true "Medication ID"
+// @Success 200 {object} models.MedicationDose
+// @Failure 404 {object} map[string]interface{} "No scheduled dose near that time"
+// @Router /api/v1/medications/{id}/doses [post]
+// @Security Bearer
+func LogMedicationDose(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Status  string     `json:"status" binding:"required"` // taken or skipped
+			DoseID  *uuid.UUID `json:"dose_id"`
+			TakenAt *time.Time `json:"taken_at"`
+			Note    string     `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Status != models.DoseStatusTaken && req.Status != models.DoseStatusSkipped {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Status must be taken or skipped")})
+			return
+		}
+
+		medication, userID, ok := findPatientMedication(c, db)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		at := now
+		if req.TakenAt != nil {
+			if req.TakenAt.After(now.Add(5 * time.Minute)) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "A dose cannot be logged in the future")})
+				return
+			}
+			at = *req.TakenAt
+		}
+
+		var dose models.MedicationDose
+		switch {
+		case req.DoseID != nil:
+			if err := db.Where("id = ? AND medication_id = ?", *req.DoseID, medication.ID).First(&dose).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Dose not found")})
+				return
+			}
+		case medication.AsNeeded || len(medication.DoseTimes) == 0:
+			if req.Status != models.DoseStatusTaken {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Only scheduled doses can be skipped")})
+				return
+			}
+			dose = models.MedicationDose{
+				MedicationID: medication.ID,
+				UserID:       userID,
+				ScheduledAt:  at,
+				Unscheduled:  true,
+			}
+		default:
+			var candidates []models.MedicationDose
+			if err := db.Where("medication_id = ? AND status IN ? AND scheduled_at BETWEEN ? AND ?",
+				medication.ID, []string{models.DoseStatusPending, models.DoseStatusMissed},
+				at.Add(-doseLogWindow), at.Add(doseLogWindow)).
+				Find(&candidates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load doses")})
+				return
+			}
+			if len(candidates) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No scheduled dose found near that time")})
+				return
+			}
+			dose = candidates[0]
+			for _, candidate := range candidates[1:] {
+				if candidate.ScheduledAt.Sub(at).Abs() < dose.ScheduledAt.Sub(at).Abs() {
+					dose = candidate
+				}
+			}
+		}
+
+		dose.Status = req.Status
+		dose.LoggedAt = &now
+		dose.TakenAt = nil
+		if req.Status == models.DoseStatusTaken {
+			dose.TakenAt = &at
+		}
+		dose.Note = strings.TrimSpace(req.Note)
+		if err := db.Save(&dose).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to log dose")})
+			return
+		}
+
+		c.JSON(http.StatusOK, dose)
+	}
+}
+
+// @Summary List medication doses
+// @Description List a medication's doses over the last days (default 7) with the adherence for that period
+// @Tags Medications
+// @Produce json
+// @Param id path string true "Medication ID"
+// @Param days query int false "Number of days to include, up to 90"
+// @Success 200 {object} map[string]interface{} "Doses and adherence"
+// @Router /api/v1/medications/{id}/doses [get]
+// @Security Bearer
+func ListMedicationDoses(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		medication, userID, ok := findPatientMedication(c, db)
+		if !ok {
+			return
+		}
+
+		days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+		if err != nil || days < 1 || days > 90 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "days must be between 1 and 90")})
+			return
+		}
+		from := time.Now().AddDate(0, 0, -days)
+
+		var doses []models.MedicationDose
+		if err := db.Where("medication_id = ? AND scheduled_at >= ? AND scheduled_at <= ?",
+			medication.ID, from, time.Now().Add(doseScheduleHorizon)).
+			Order("scheduled_at DESC").
+			Find(&doses).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load doses")})
+			return
+		}
+
+		adherence, err := doseAdherence(db, userID, &medication.ID, from)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to load doses")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"medication": medication,
+			"doses":      doses,
+			"adherence":  adherence,
+		})
+	}
+}
@@ -1,12 +1,15 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
 	"github.com/nyumbanicare/internal/models"
 	"github.com/nyumbanicare/internal/services"
 	"gorm.io/gorm"
@@ -28,13 +31,13 @@ func CreateNotification(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
 		var notification models.Notification
 		if err := c.BindJSON(&notification); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification data"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid notification data")})
 			return
 		}
 
@@ -44,7 +47,7 @@ func CreateNotification(db *gorm.DB) gin.HandlerFunc {
 		notification.IsSent = false
 
 		if err := db.Create(&notification).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create notification")})
 			return
 		}
 
@@ -68,7 +71,7 @@ func SendEmailNotification(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -80,7 +83,7 @@ func SendEmailNotification(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.BindJSON(&emailReq); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification data"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid notification data")})
 			return
 		}
 
@@ -111,7 +114,7 @@ func SendEmailNotification(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := db.Create(&notification).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification record"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create notification record")})
 			return
 		}
 		emailSvc := services.NewEmailService(&config.GetConfig().Email)
@@ -124,7 +127,7 @@ func SendEmailNotification(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := emailSvc.SendEmail(emailData); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send email"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to send email")})
 			return
 		}
 
@@ -144,7 +147,7 @@ func GetUserNotifications(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -163,7 +166,7 @@ func GetUserNotifications(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Order("created_at DESC").Find(&notifications).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch notifications")})
 			return
 		}
 
@@ -175,26 +178,26 @@ func MarkNotificationAsRead(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
 		notificationID := c.Param("id")
 		notifUUID, err := uuid.Parse(notificationID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid notification ID")})
 			return
 		}
 
 		var notification models.Notification
 		if err := db.Where("id = ? AND user_id = ?", notifUUID, userID).First(&notification).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Notification not found")})
 			return
 		}
 
 		notification.IsRead = true
 		if err := db.Save(&notification).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update notification")})
 			return
 		}
 
@@ -206,7 +209,7 @@ func MarkAllNotificationsAsRead(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -215,7 +218,7 @@ func MarkAllNotificationsAsRead(db *gorm.DB) gin.HandlerFunc {
 			Updates(map[string]interface{}{"is_read": true})
 
 		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to mark notifications as read")})
 			return
 		}
 
@@ -230,13 +233,13 @@ func GetUnreadNotificationCount(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
 		var count int64
 		if err := db.Model(&models.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to count notifications")})
 			return
 		}
 
@@ -260,3 +263,289 @@ func NotifyTestResultReady(db *gorm.DB, userID uuid.UUID, testResult *models.Tes
 
 	return db.Create(&notification).Error
 }
+
+// NotifyTestResultReviewed tells the patient a clinician has confirmed or corrected
+// their result, in the app and by email, in the patient's preferred language
+func NotifyTestResultReviewed(db *gorm.DB, testResult *models.TestKitResult) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", testResult.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	message := i18n.Tf(lang, "A clinician has reviewed and confirmed your %s result.", i18n.T(lang, testResult.Result))
+	if testResult.ReviewDecision == models.ReviewDecisionOverridden {
+		message = i18n.Tf(lang, "A clinician has reviewed your test and updated the result to %s. Please check the details and recommended next steps.", i18n.T(lang, testResult.Result))
+	}
+
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeTestResult,
+		Title:        i18n.T(lang, "Your test result has been reviewed"),
+		Message:      message,
+		ResourceID:   &testResult.ID,
+		ResourceType: "test_result",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	emailSvc := services.NewEmailService(&config.GetConfig().Email)
+	if err := emailSvc.SendTestResultsReady(user, *testResult); err != nil {
+		fmt.Printf("Failed to send reviewed test result email to %s: %v\n", user.Email, err)
+	}
+
+	return nil
+}
+
+// NotifyPrescriptionUpdated tells the patient their prescription was quoted, rejected, dispatched or dispensed
+func NotifyPrescriptionUpdated(db *gorm.DB, prescription *models.Prescription) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", prescription.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	var message string
+	switch prescription.Status {
+	case models.PrescriptionStatusApproved:
+		message = i18n.Tf(lang, "Your prescription has been reviewed. A quote of KES %.2f is ready for you to accept.", prescription.QuoteTotal)
+	case models.PrescriptionStatusRejected:
+		message = i18n.Tf(lang, "We could not fill your prescription: %s", prescription.RejectionReason)
+	case models.PrescriptionStatusOutForDelivery:
+		message = i18n.Tf(lang, "Your medication is on its way with %s. Tracking number: %s", prescription.Courier, prescription.TrackingNumber)
+	case models.PrescriptionStatusDispensed:
+		message = i18n.T(lang, "Your medication has been dispensed.")
+	default:
+		return nil
+	}
+
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeMedication,
+		Title:        i18n.T(lang, "Prescription update"),
+		Message:      message,
+		ResourceID:   &prescription.ID,
+		ResourceType: "prescription",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyDoseDue reminds the patient that a scheduled medication dose is due
+func NotifyDoseDue(db *gorm.DB, user models.User, medication models.Medication, dose *models.MedicationDose) error {
+	lang := user.PreferredLanguage
+	name := medication.Name
+	if dosage := strings.TrimSpace(medication.Dosage); dosage != "" {
+		name += " " + dosage
+	}
+
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeMedication,
+		Title:        i18n.T(lang, "Medication reminder"),
+		Message:      i18n.Tf(lang, "It is time to take your %s. Log the dose once you have taken it.", name),
+		ResourceID:   &dose.ID,
+		ResourceType: "medication_dose",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyRefillReviewed tells the patient whether a clinician approved their refill request
+func NotifyRefillReviewed(db *gorm.DB, request *models.RefillRequest) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", request.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	var message string
+	switch request.Status {
+	case models.RefillStatusApproved:
+		message = i18n.T(lang, "Your refill request has been approved. The pharmacy is preparing your medication and will send you a quote.")
+	case models.RefillStatusDenied:
+		message = i18n.Tf(lang, "Your refill request was declined: %s", request.DenialReason)
+	default:
+		return nil
+	}
+
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeMedication,
+		Title:        i18n.T(lang, "Refill request update"),
+		Message:      message,
+		ResourceID:   &request.ID,
+		ResourceType: "refill_request",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyRefillDue reminds the patient that the medication from a prescription is
+// running out. renewal is set when no refills remain and a clinician must renew it.
+func NotifyRefillDue(db *gorm.DB, prescription *models.Prescription, renewal bool) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", prescription.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	runsOut := prescription.NextRefillAt.Format("2 Jan 2006")
+	message := i18n.Tf(lang, "Your medication is expected to run out on %s. Request a refill from your prescriptions.", runsOut)
+	if renewal {
+		message = i18n.Tf(lang, "Your medication is expected to run out on %s and no refills remain. Request a renewal from your prescriptions for a clinician to review.", runsOut)
+	}
+
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeMedication,
+		Title:        i18n.T(lang, "Time to refill your medication"),
+		Message:      message,
+		ResourceID:   &prescription.ID,
+		ResourceType: "prescription",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyDataExportReady tells a patient their data export can be downloaded
+func NotifyDataExportReady(db *gorm.DB, export *models.DataExport) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", export.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeSystem,
+		Title:        i18n.T(lang, "Your data export is ready"),
+		Message:      i18n.Tf(lang, "A copy of your data is ready to download until %s.", export.ExpiresAt.Format("2 Jan 2006 15:04")),
+		ResourceID:   &export.ID,
+		ResourceType: "data_export",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyDataExportFailed tells a patient their data export could not be built
+func NotifyDataExportFailed(db *gorm.DB, export *models.DataExport) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", export.UserID).Error; err != nil {
+		return err
+	}
+
+	lang := user.PreferredLanguage
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeSystem,
+		Title:        i18n.T(lang, "Your data export failed"),
+		Message:      i18n.T(lang, "We could not prepare a copy of your data. Please request the export again, or contact support if it keeps failing."),
+		ResourceID:   &export.ID,
+		ResourceType: "data_export",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyAccountDeletionScheduled confirms a deletion request and when it takes effect
+func NotifyAccountDeletionScheduled(db *gorm.DB, user *models.User, deletion *models.AccountDeletion) error {
+	lang := user.PreferredLanguage
+	notification := models.Notification{
+		ID:     uuid.New(),
+		UserID: user.ID,
+		Type:   models.NotificationTypeSystem,
+		Title:  i18n.T(lang, "Account deletion scheduled"),
+		Message: i18n.Tf(lang, "Your account and health records will be permanently deleted on %s. You can cancel the deletion until then from your account settings.",
+			deletion.ScheduledFor.Format("2 Jan 2006")),
+		ResourceID:   &deletion.ID,
+		ResourceType: "account_deletion",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyCaregiverAdded tells a user they can now act on a patient's behalf
+func NotifyCaregiverAdded(db *gorm.DB, caregiver, patient *models.User, link *models.CaregiverLink) error {
+	lang := caregiver.PreferredLanguage
+	notification := models.Notification{
+		ID:     uuid.New(),
+		UserID: caregiver.ID,
+		Type:   models.NotificationTypeSystem,
+		Title:  i18n.T(lang, "You have been added as a caregiver"),
+		Message: i18n.Tf(lang, "You can now manage the health of %s in Nyumbani Care, within the access you were given.",
+			strings.TrimSpace(patient.FirstName+" "+patient.LastName)),
+		ResourceID:   &link.ID,
+		ResourceType: "caregiver_link",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyCriticalLabResult alerts the clinician a critical lab result was escalated to
+func NotifyCriticalLabResult(db *gorm.DB, clinician, patient *models.User, result *models.LabResult, testName string) error {
+	lang := clinician.PreferredLanguage
+	notification := models.Notification{
+		ID:     uuid.New(),
+		UserID: clinician.ID,
+		Type:   models.NotificationTypeTestResult,
+		Title:  i18n.T(lang, "Critical lab result"),
+		Message: i18n.Tf(lang, "The %s result for %s has critical values and needs your attention.",
+			testName, strings.TrimSpace(patient.FirstName+" "+patient.LastName)),
+		ResourceID:   &result.ID,
+		ResourceType: "lab_result",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
+
+// NotifyLabResultReady tells a patient their verified lab result can be viewed
+func NotifyLabResultReady(db *gorm.DB, result *models.LabResult) error {
+	var user models.User
+	if err := db.First(&user, "id = ?", result.UserID).Error; err != nil {
+		return err
+	}
+	lang := user.PreferredLanguage
+	notification := models.Notification{
+		ID:           uuid.New(),
+		UserID:       user.ID,
+		Type:         models.NotificationTypeTestResult,
+		Title:        i18n.T(lang, "Lab results ready"),
+		Message:      i18n.Tf(lang, "Your %s results are ready to view.", result.LabBooking.LabTest.Name),
+		ResourceID:   &result.ID,
+		ResourceType: "lab_result",
+		IsRead:       false,
+		IsSent:       false,
+		SendMethod:   "app",
+	}
+	return db.Create(&notification).Error
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/nyumbanicare/internal/config"
 	"github.com/nyumbanicare/internal/models"
 	"github.com/nyumbanicare/internal/services"
@@ -23,7 +24,7 @@ func ProcessPaystackPayment(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -36,31 +37,23 @@ func ProcessPaystackPayment(db *gorm.DB) gin.HandlerFunc {
 		// Validate order exists and belongs to user
 		var order models.TestKitOrder
 		if err := db.Where("id = ? AND user_id = ?", req.OrderID, userID).First(&order).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Order not found")})
 			return
 		}
 
 		// Create payment service
 		paymentSvc := services.NewPaymentService(&config.GetConfig().Payment)
 
-		// Generate callback URL for this payment
-		host := c.Request.Host
-		protocol := "https"
-		if host == "localhost" || host == "127.0.0.1" {
-			protocol = "http"
-		}
-		callbackURL := fmt.Sprintf("%s://%s/api/payments/paystack/callback", protocol, host)
-
 		// Create payment record
-		payment, err := paymentSvc.InitiatePayment(&order, req.Email, callbackURL)
+		payment, err := paymentSvc.InitiatePayment(&order, req.Email, paystackCallbackURL(c))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize payment"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to initialize payment")})
 			return
 		}
 
 		// Save payment record
 		if err := db.Create(payment).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create payment record"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create payment record")})
 			return
 		}
 
@@ -78,7 +71,7 @@ func GetPaymentStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -91,9 +84,13 @@ func GetPaymentStatus(db *gorm.DB) gin.HandlerFunc {
 		if role != "admin" {
 			query = query.Where("user_id = ?", userID)
 		}
+		// Caregivers act on test kit orders, not on the patient's prescriptions
+		if _, acting := actingUserID(c); acting {
+			query = query.Where("order_type = ?", models.PaymentOrderTestKit)
+		}
 
 		if err := query.First(&payment).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Payment not found")})
 			return
 		}
 
@@ -106,7 +103,7 @@ func ListUserPayments(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -118,9 +115,13 @@ func ListUserPayments(db *gorm.DB) gin.HandlerFunc {
 		if role != "admin" {
 			query = query.Where("user_id = ?", userID)
 		}
+		// Caregivers act on test kit orders, not on the patient's prescriptions
+		if _, acting := actingUserID(c); acting {
+			query = query.Where("order_type = ?", models.PaymentOrderTestKit)
+		}
 
 		if err := query.Find(&payments).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch payments")})
 			return
 		}
 
@@ -128,12 +129,35 @@ func ListUserPayments(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// markOrderPaid records a completed payment on the test kit order or prescription it paid for
+func markOrderPaid(db *gorm.DB, payment *models.Payment) error {
+	now := time.Now()
+	if payment.OrderType == models.PaymentOrderPrescription {
+		return db.Model(&models.Prescription{}).
+			Where("id = ? AND status = ?", payment.OrderID, models.PrescriptionStatusAccepted).
+			Updates(map[string]interface{}{
+				"status":         models.PrescriptionStatusPaid,
+				"payment_status": "paid",
+				"paid_at":        now,
+			}).Error
+	}
+
+	var order models.TestKitOrder
+	if err := db.First(&order, "id = ?", payment.OrderID).Error; err != nil {
+		return nil
+	}
+	order.PaymentStatus = "paid"
+	order.Status = "confirmed"
+	order.UpdatedAt = now
+	return db.Save(&order).Error
+}
+
 // Paystack webhook handler
 func PaystackWebhook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) { // Read request body
 		body, err := io.ReadAll(c.Request.Body)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read request body"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unable to read request body")})
 			return
 		}
 
@@ -163,21 +187,14 @@ func PaystackWebhook(db *gorm.DB) gin.HandlerFunc {
 				payment.Notes = fmt.Sprintf("Payment confirmed via webhook on %s", time.Now().Format(time.RFC3339))
 
 				if err := db.Save(&payment).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+					c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update payment status")})
 					return
 				}
 
 				// Update order status
-				var order models.TestKitOrder
-				if err := db.First(&order, "id = ?", payment.OrderID).Error; err == nil {
-					order.PaymentStatus = "paid"
-					order.Status = "confirmed"
-					order.UpdatedAt = time.Now()
-
-					if err := db.Save(&order).Error; err != nil {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
-						return
-					}
+				if err := markOrderPaid(db, &payment); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update order status")})
+					return
 				}
 			} else {
 				// Log that a payment was not found
@@ -193,12 +210,23 @@ func PaystackWebhook(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// paystackCallbackURL is where Paystack sends the customer after paying, which
+// verifies the payment through PaystackCallback
+func paystackCallbackURL(c *gin.Context) string {
+	host := c.Request.Host
+	protocol := "https"
+	if host == "localhost" || host == "127.0.0.1" {
+		protocol = "http"
+	}
+	return fmt.Sprintf("%s://%s/api/v1/payments/paystack/callback", protocol, host)
+}
+
 // Paystack callback handler (for browser redirects after payment)
 func PaystackCallback(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		reference := c.Query("reference")
 		if reference == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No reference provided"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "No reference provided")})
 			return
 		}
 
@@ -208,7 +236,7 @@ func PaystackCallback(db *gorm.DB) gin.HandlerFunc {
 		// Verify payment
 		verifyResp, err := paymentSvc.VerifyPayment(reference)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify payment"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to verify payment")})
 			return
 		}
 		if verifyResp.Status && verifyResp.Data.Status == "success" {
@@ -221,28 +249,21 @@ func PaystackCallback(db *gorm.DB) gin.HandlerFunc {
 				payment.Notes = fmt.Sprintf("Payment verified via callback on %s", time.Now().Format(time.RFC3339))
 
 				if err := db.Save(&payment).Error; err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update payment status"})
+					c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update payment status")})
 					return
 				}
 
 				// Update order status
-				var order models.TestKitOrder
-				if err := db.First(&order, "id = ?", payment.OrderID).Error; err == nil {
-					order.PaymentStatus = "paid"
-					order.Status = "confirmed"
-					order.UpdatedAt = time.Now()
-
-					if err := db.Save(&order).Error; err != nil {
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
-						return
-					}
+				if err := markOrderPaid(db, &payment); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update order status")})
+					return
 				}
 
 				// Redirect to success page
 				c.Redirect(http.StatusFound, "/payments/success")
 				return
 			} else {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Payment record not found"})
+				c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Payment record not found")})
 				return
 			}
 		}
@@ -257,38 +278,75 @@ func UploadFile(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get folder from query param, default to "general"
 		folder := c.DefaultQuery("folder", "general")
+		policy, ok := uploadFolders[folder]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid upload folder")})
+			return
+		}
 
 		file, header, err := c.Request.FormFile("file")
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "No file uploaded")})
 			return
 		}
 		defer file.Close()
 
 		// Validate file type and size
 		if header.Size > 10*1024*1024 { // 10MB limit
-			c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 10MB)"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "File too large (max 10MB)")})
+			return
+		}
+
+		// Check the type from the file's bytes; the client's Content-Type is not trusted
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file, head)
+		contentType := http.DetectContentType(head[:n])
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unable to read uploaded file")})
+			return
+		}
+		if !policy.allows(contentType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{
+				"error":         tr(c, "File type not allowed for this folder"),
+				"content_type":  contentType,
+				"allowed_types": policy.ContentTypes,
+			})
 			return
 		}
 
-		// Create storage service for Cloudinary
 		storageSvc, err := services.NewStorageService(&config.GetConfig().Storage)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to initialize storage service")})
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+		if !checkStorageQuota(c, db, userID.(uuid.UUID), header.Size) {
 			return
 		}
 
-		// Upload file to Cloudinary
-		fileURL, err := storageSvc.UploadFile(header, folder)
+		// Medical documents are stored privately and fetched through GET /files/:id
+		stored, url, err := saveFile(db, storageSvc, file, header.Filename, folder, userID.(uuid.UUID), policy.Private)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to upload file: %v", err)})
+			respondUploadError(c, db, err, models.FileScanEvent{
+				UserID:      userID.(uuid.UUID),
+				Folder:      folder,
+				Filename:    header.Filename,
+				Size:        header.Size,
+				ContentType: contentType,
+			})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"file_url":  fileURL,
+			"file_id":   stored.ID,
+			"file_url":  url,
 			"file_name": header.Filename,
-			"message":   "File uploaded successfully to Cloudinary",
+			"message":   "File uploaded successfully",
 		})
 	}
 }
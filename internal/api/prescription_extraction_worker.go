@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// maxPrescriptionImageSize caps how much of a stored prescription is read for extraction
+const maxPrescriptionImageSize = 20 << 20
+
+// errExtractionPending is returned when a prescription already has an extraction running
+var errExtractionPending = errors.New("prescription extraction already pending")
+
+// StartPrescriptionExtractionWorkers fails extractions left pending by a previous
+// run, whose queued jobs were lost when it stopped. Extraction shares the test kit
+// analysis workers.
+func StartPrescriptionExtractionWorkers(db *gorm.DB) {
+	testKitAnalysisPool()
+
+	result := db.Model(&models.PrescriptionExtraction{}).
+		Where("status = ? AND updated_at < ?", models.ExtractionStatusPending, time.Now().Add(-staleAnalysisAge)).
+		Updates(map[string]interface{}{
+			"status": models.ExtractionStatusFailed,
+			"error":  "extraction interrupted by restart",
+		})
+	if result.Error != nil {
+		fmt.Printf("Failed to settle interrupted prescription extractions: %v\n", result.Error)
+	} else if result.RowsAffected > 0 {
+		fmt.Printf("Settled %d interrupted prescription extractions\n", result.RowsAffected)
+	}
+}
+
+// queuePrescriptionExtraction records a pending extraction for a prescription and
+// queues it. requestedBy is the pharmacist re-running it, or nil on upload.
+func queuePrescriptionExtraction(db *gorm.DB, prescriptionID uuid.UUID, requestedBy *uuid.UUID) (*models.PrescriptionExtraction, error) {
+	var pending int64
+	if err := db.Model(&models.PrescriptionExtraction{}).
+		Where("prescription_id = ? AND status = ?", prescriptionID, models.ExtractionStatusPending).
+		Count(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, errExtractionPending
+	}
+
+	extraction := models.PrescriptionExtraction{
+		PrescriptionID: prescriptionID,
+		Status:         models.ExtractionStatusPending,
+		RequestedByID:  requestedBy,
+	}
+	if err := db.Create(&extraction).Error; err != nil {
+		return nil, err
+	}
+
+	job := &prescriptionExtractionJob{ExtractionID: extraction.ID, PrescriptionID: prescriptionID}
+	if err := testKitAnalysisPool().Submit(job.asJob(db)); err != nil {
+		db.Unscoped().Delete(&extraction)
+		return nil, err
+	}
+	return &extraction, nil
+}
+
+// prescriptionExtractionJob runs OCR and structuring on a prescription image in the background
+type prescriptionExtractionJob struct {
+	ExtractionID   uuid.UUID
+	PrescriptionID uuid.UUID
+}
+
+func (job *prescriptionExtractionJob) asJob(db *gorm.DB) services.Job {
+	return services.Job{
+		Name:        "prescription extraction " + job.ExtractionID.String(),
+		MaxAttempts: config.GetConfig().External.AnalysisMaxAttempts,
+		Run: func(attempt int) error {
+			return job.run(db, attempt)
+		},
+		OnFailure: func(err error) {
+			fmt.Printf("Prescription extraction %s failed: %v\n", job.ExtractionID, err)
+			if dbErr := db.Model(&models.PrescriptionExtraction{}).Where("id = ?", job.ExtractionID).Updates(map[string]interface{}{
+				"status": models.ExtractionStatusFailed,
+				"error":  err.Error(),
+			}).Error; dbErr != nil {
+				fmt.Printf("Failed to save failed prescription extraction %s: %v\n", job.ExtractionID, dbErr)
+			}
+		},
+	}
+}
+
+func (job *prescriptionExtractionJob) run(db *gorm.DB, attempt int) error {
+	var extraction models.PrescriptionExtraction
+	if err := db.First(&extraction, "id = ?", job.ExtractionID).Error; err != nil {
+		return fmt.Errorf("failed to load prescription extraction: %w", err)
+	}
+	extraction.Attempts = attempt
+
+	var file models.File
+	if err := db.Where("resource_type = ? AND resource_id = ?", models.FileResourcePrescription, job.PrescriptionID).
+		Order("created_at DESC").
+		First(&file).Error; err != nil {
+		return fmt.Errorf("failed to find prescription image: %w", err)
+	}
+
+	// PDFs and other documents are left for the pharmacist to read
+	if !strings.HasPrefix(file.ContentType, "image/") {
+		now := time.Now()
+		extraction.Status = models.ExtractionStatusUnsupported
+		extraction.Error = "automated extraction only reads images, not " + file.ContentType
+		extraction.CompletedAt = &now
+		return db.Save(&extraction).Error
+	}
+
+	image, err := readStoredFile(&file)
+	if err != nil {
+		return err
+	}
+
+	cfg := config.GetConfig().External
+	var ocr *services.OCRResult
+	engine, err := services.NewOCREngine(&cfg)
+	if err != nil {
+		fmt.Printf("OCR is unavailable: %v\n", err)
+	} else if engine != nil {
+		// The vision model can still read the image, so OCR failures are not fatal
+		if ocr, err = engine.Recognize(context.Background(), image); err != nil {
+			fmt.Printf("OCR failed for prescription %s: %v\n", job.PrescriptionID, err)
+			ocr = nil
+		}
+	}
+
+	var prescription models.Prescription
+	if err := db.Select("id", "user_id").First(&prescription, "id = ?", job.PrescriptionID).Error; err != nil {
+		return fmt.Errorf("failed to load prescription: %w", err)
+	}
+	aiSvc := aiServiceFor(db, prescription.UserID)
+	extracted, err := aiSvc.ExtractPrescription(&services.PrescriptionExtractionRequest{
+		ImageData:        image,
+		ImageContentType: file.ContentType,
+		ImagePrivate:     true,
+		OCR:              ocr,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	extraction.Status = models.ExtractionStatusCompleted
+	extraction.Source = extracted.Source
+	extraction.PrescriberName = extracted.PrescriberName
+	extraction.PrescriberRegistration = extracted.PrescriberRegistration
+	extraction.PrescriptionDate = extracted.PrescriptionDate
+	extraction.Medications = extracted.Medications
+	extraction.Flags = extracted.Flags
+	extraction.Error = ""
+	extraction.CompletedAt = &now
+	if ocr != nil {
+		extraction.OCREngine = ocr.Engine
+		extraction.OCRText = ocr.Text
+		extraction.OCRConfidence = ocr.Confidence
+	}
+
+	if err := db.Save(&extraction).Error; err != nil {
+		return fmt.Errorf("failed to save prescription extraction: %w", err)
+	}
+	return nil
+}
+
+// readStoredFile reads a stored file's content from the current storage provider
+func readStoredFile(file *models.File) ([]byte, error) {
+	storageSvc, err := services.NewStorageService(&config.GetConfig().Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage service: %w", err)
+	}
+	if file.Provider != storageSvc.Provider() {
+		return nil, fmt.Errorf("file %s is kept by %s storage, which is no longer configured", file.ID, file.Provider)
+	}
+
+	src, err := storageSvc.Open(file.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stored file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxPrescriptionImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored file: %w", err)
+	}
+	if len(data) > maxPrescriptionImageSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", file.ID, maxPrescriptionImageSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,829 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/middleware"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// openPharmacyStatuses are prescriptions waiting on the pharmacy
+var openPharmacyStatuses = []string{
+	models.PrescriptionStatusUploaded,
+	models.PrescriptionStatusProcessing,
+	models.PrescriptionStatusPaid,
+	models.PrescriptionStatusOutForDelivery,
+}
+
+// loadPrescription finds the prescription in the :id path parameter. Patients
+// only see their own prescriptions; pharmacists see all of them.
+func loadPrescription(c *gin.Context, db *gorm.DB) (*models.Prescription, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+		return nil, false
+	}
+
+	query := db.Preload("Medications").Where("id = ?", c.Param("id"))
+	role, _ := c.Get("role")
+	if !middleware.IsPharmacistRole(role) {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var prescription models.Prescription
+	if err := query.First(&prescription).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Prescription not found")})
+		return nil, false
+	}
+	return &prescription, true
+}
+
+// transitionPrescription moves a prescription to a new status along with updates.
+// The update only applies if the status is unchanged since the prescription was
+// loaded; otherwise it responds with a conflict and returns false.
+func transitionPrescription(c *gin.Context, db *gorm.DB, prescription *models.Prescription, status string, updates map[string]interface{}) bool {
+	if !services.CanTransitionPrescription(prescription.Status, status) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  tr(c, "This action is not allowed for the prescription's current status"),
+			"status": prescription.Status,
+		})
+		return false
+	}
+
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["status"] = status
+
+	update := db.Model(&models.Prescription{}).
+		Where("id = ? AND status = ?", prescription.ID, prescription.Status).
+		Updates(updates)
+	if update.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update prescription")})
+		return false
+	}
+	if update.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The prescription was updated by someone else. Reload and try again.")})
+		return false
+	}
+
+	db.Preload("Medications").First(prescription, "id = ?", prescription.ID)
+	return true
+}
+
+// @Summary Get prescription
+// @Description Get a prescription with its medication lines, quote and delivery status
+// @Tags Prescriptions
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 404 {object} map[string]string "Prescription not found"
+// @Router /api/v1/prescriptions/{id} [get]
+// @Security Bearer
+func GetPrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Accept prescription quote
+// @Description Accept the pharmacy's quote and choose delivery or pickup
+// @Tags Prescriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 409 {object} map[string]string "Prescription has no quote to accept"
+// @Router /api/v1/prescriptions/{id}/accept [post]
+// @Security Bearer
+func AcceptPrescriptionQuote(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			DeliveryMethod  string `json:"delivery_method" binding:"required,oneof=delivery pickup"`
+			DeliveryAddress string `json:"delivery_address"`
+			ContactNumber   string `json:"contact_number" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.DeliveryMethod == "delivery" && strings.TrimSpace(req.DeliveryAddress) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "A delivery address is required for delivery")})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.UserID != c.MustGet("user_id").(uuid.UUID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Only the patient can accept a prescription quote")})
+			return
+		}
+
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusAccepted, map[string]interface{}{
+			"accepted_at":      time.Now(),
+			"delivery_method":  req.DeliveryMethod,
+			"delivery_address": req.DeliveryAddress,
+			"contact_number":   req.ContactNumber,
+		}) {
+			return
+		}
+
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Cancel prescription
+// @Description Cancel a prescription before it has been paid for
+// @Tags Prescriptions
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 409 {object} map[string]string "Prescription can no longer be cancelled"
+// @Router /api/v1/prescriptions/{id}/cancel [post]
+// @Security Bearer
+func CancelPrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusCancelled, nil) {
+			return
+		}
+		releaseRefill(db, prescription)
+
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Pay for prescription
+// @Description Start payment of an accepted prescription quote
+// @Tags Prescriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} map[string]interface{} "Payment initialized"
+// @Failure 409 {object} map[string]string "Quote not accepted"
+// @Router /api/v1/prescriptions/{id}/pay [post]
+// @Security Bearer
+func PayForPrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email" binding:"required,email"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.UserID != c.MustGet("user_id").(uuid.UUID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Only the patient can pay for a prescription")})
+			return
+		}
+		if prescription.Status != models.PrescriptionStatusAccepted || prescription.QuoteTotal <= 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  tr(c, "Accept the prescription quote before paying"),
+				"status": prescription.Status,
+			})
+			return
+		}
+
+		paymentSvc := services.NewPaymentService(&config.GetConfig().Payment)
+
+		payment, err := paymentSvc.InitiatePrescriptionPayment(prescription, req.Email, paystackCallbackURL(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to initialize payment")})
+			return
+		}
+
+		if err := db.Create(payment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to create payment record")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"payment_id": payment.ID,
+			"reference":  payment.TransactionID,
+			"amount":     payment.Amount,
+			"status":     "pending",
+			"message":    "Payment initialized successfully",
+		})
+	}
+}
+
+// @Summary Pharmacy prescription queue
+// @Description List prescriptions waiting on the pharmacy, oldest first
+// @Tags Pharmacy
+// @Produce json
+// @Param status query string false "Only prescriptions with this status"
+// @Param mine query bool false "Only prescriptions handled by the current pharmacist"
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {array} models.Prescription
+// @Failure 403 {object} map[string]string "Pharmacist access required"
+// @Router /api/v1/pharmacy/prescriptions [get]
+// @Security Bearer
+func ListPharmacyPrescriptions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		query := db.Preload("Medications").Order("created_at ASC")
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		} else {
+			query = query.Where("status IN ?", openPharmacyStatuses)
+		}
+		if c.Query("mine") == "true" {
+			query = query.Where("pharmacist_id = ?", userID)
+		}
+		if c.Query("page") != "" && c.Query("limit") != "" {
+			query = query.Scopes(Paginate(c))
+		}
+
+		var prescriptions []models.Prescription
+		if err := query.Find(&prescriptions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch prescriptions")})
+			return
+		}
+
+		c.JSON(http.StatusOK, prescriptions)
+	}
+}
+
+// @Summary Start transcribing a prescription
+// @Description Take an uploaded prescription, or reopen an approved quote for changes
+// @Tags Pharmacy
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 409 {object} map[string]string "Prescription is not open for transcription"
+// @Router /api/v1/pharmacy/prescriptions/{id}/start [post]
+// @Security Bearer
+func StartPrescriptionTranscription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusProcessing, map[string]interface{}{
+			"pharmacist_id": c.MustGet("user_id"),
+		}) {
+			return
+		}
+
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// replacePrescriptionMedications swaps a prescription's medication lines and
+// recalculates its quote, applying any other prescription updates alongside
+func replacePrescriptionMedications(db *gorm.DB, prescriptionID uuid.UUID, medications []models.PrescriptionMedication, updates map[string]interface{}) error {
+	if updates == nil {
+		updates = map[string]interface{}{}
+	}
+	updates["quote_total"] = services.PrescriptionQuote(medications)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("prescription_id = ?", prescriptionID).Delete(&models.PrescriptionMedication{}).Error; err != nil {
+			return err
+		}
+		if len(medications) > 0 {
+			if err := tx.Create(&medications).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Prescription{}).Where("id = ?", prescriptionID).Updates(updates).Error
+	})
+}
+
+// medicationNames lists the names of medication lines, optionally only the available ones
+func medicationNames(medications []models.PrescriptionMedication, availableOnly bool) []string {
+	names := make([]string, 0, len(medications))
+	for _, medication := range medications {
+		if availableOnly && !medication.Available {
+			continue
+		}
+		names = append(names, medication.Name)
+	}
+	return names
+}
+
+// PrescriptionMedicationInput is a medication line transcribed by a pharmacist
+type PrescriptionMedicationInput struct {
+	Name         string  `json:"name" binding:"required"`
+	Dosage       string  `json:"dosage"`
+	Frequency    string  `json:"frequency"`
+	Duration     string  `json:"duration"`
+	Instructions string  `json:"instructions"`
+	Quantity     int     `json:"quantity" binding:"required,min=1"`
+	Price        float64 `json:"price" binding:"min=0"`
+	Available    bool    `json:"available"`
+}
+
+// @Summary Set prescription medication lines
+// @Description Replace the transcribed medication lines with their availability and unit price
+// @Tags Pharmacy
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 409 {object} map[string]string "Prescription is not being transcribed"
+// @Failure 409 {object} map[string]interface{} "Serious safety alerts need an override_reason"
+// @Router /api/v1/pharmacy/prescriptions/{id}/medications [put]
+// @Security Bearer
+func SetPrescriptionMedications(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Medications    []PrescriptionMedicationInput `json:"medications" binding:"required,dive"`
+			OverrideReason string                        `json:"override_reason"` // required to save lines with serious safety alerts
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.Status != models.PrescriptionStatusProcessing {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  tr(c, "Start transcribing the prescription before editing its medications"),
+				"status": prescription.Status,
+			})
+			return
+		}
+
+		medications := make([]models.PrescriptionMedication, len(req.Medications))
+		for i, input := range req.Medications {
+			medications[i] = models.PrescriptionMedication{
+				PrescriptionID: prescription.ID,
+				Name:           strings.TrimSpace(input.Name),
+				Dosage:         input.Dosage,
+				Frequency:      input.Frequency,
+				Duration:       input.Duration,
+				Instructions:   input.Instructions,
+				Quantity:       input.Quantity,
+				Price:          input.Price,
+				Available:      input.Available,
+			}
+		}
+
+		check, ok := checkMedicationSafety(c, db, safetyCheckInput{
+			PatientID:      prescription.UserID,
+			Context:        models.SafetyContextPrescriptionMedications,
+			PrescriptionID: &prescription.ID,
+			Medications:    medicationNames(medications, false),
+			OverrideReason: req.OverrideReason,
+		})
+		if !ok {
+			return
+		}
+
+		if err := replacePrescriptionMedications(db, prescription.ID, medications, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save prescription medications")})
+			return
+		}
+		saveSafetyAlerts(db, prescription.ID, check)
+
+		db.Preload("Medications").First(prescription, "id = ?", prescription.ID)
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Approve prescription
+// @Description Approve a transcribed prescription and send the quote to the patient, recording any refills the prescriber allowed
+// @Tags Pharmacy
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Failure 400 {object} map[string]string "No available medication to quote"
+// @Failure 409 {object} map[string]interface{} "Serious safety alerts need an override_reason"
+// @Router /api/v1/pharmacy/prescriptions/{id}/approve [post]
+// @Security Bearer
+func ApprovePrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PharmacyNotes  string `json:"pharmacy_notes"`
+			OverrideReason string `json:"override_reason"` // required to approve with serious safety alerts
+			Refills        *int   `json:"refills"`         // repeats allowed by the prescriber
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Refills != nil && (*req.Refills < 0 || *req.Refills > maxRefills) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Tf(c.GetString("lang"), "Refills must be between 0 and %d", maxRefills)})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+
+		quote := services.PrescriptionQuote(prescription.Medications)
+		if quote <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Add at least one available, priced medication before approving")})
+			return
+		}
+
+		// The patient's record may have changed since the lines were added
+		check, ok := checkMedicationSafety(c, db, safetyCheckInput{
+			PatientID:      prescription.UserID,
+			Context:        models.SafetyContextPrescriptionApproval,
+			PrescriptionID: &prescription.ID,
+			Medications:    medicationNames(prescription.Medications, true),
+			OverrideReason: req.OverrideReason,
+		})
+		if !ok {
+			return
+		}
+
+		updates := map[string]interface{}{
+			"quote_total":    quote,
+			"quoted_at":      time.Now(),
+			"payment_status": "pending",
+		}
+		if req.PharmacyNotes != "" {
+			updates["pharmacy_notes"] = req.PharmacyNotes
+		}
+		if req.Refills != nil {
+			if prescription.OriginalPrescriptionID != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Refills are set on the original prescription, not on a refill order")})
+				return
+			}
+			updates["refills_remaining"] = *req.Refills
+		}
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusApproved, updates) {
+			return
+		}
+		saveSafetyAlerts(db, prescription.ID, check)
+		prescription.SafetyAlerts = check.Alerts
+
+		if err := NotifyPrescriptionUpdated(db, prescription); err != nil {
+			fmt.Printf("Failed to create prescription notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Reject prescription
+// @Description Reject a prescription the pharmacy cannot fill, with the reason shown to the patient
+// @Tags Pharmacy
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Router /api/v1/pharmacy/prescriptions/{id}/reject [post]
+// @Security Bearer
+func RejectPrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason        string `json:"reason" binding:"required"`
+			PharmacyNotes string `json:"pharmacy_notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+
+		updates := map[string]interface{}{
+			"rejection_reason": req.Reason,
+			"pharmacist_id":    c.MustGet("user_id"),
+		}
+		if req.PharmacyNotes != "" {
+			updates["pharmacy_notes"] = req.PharmacyNotes
+		}
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusRejected, updates) {
+			return
+		}
+		releaseRefill(db, prescription)
+
+		if err := NotifyPrescriptionUpdated(db, prescription); err != nil {
+			fmt.Printf("Failed to create prescription notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Dispatch prescription
+// @Description Hand a paid prescription to a courier for delivery
+// @Tags Pharmacy
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Router /api/v1/pharmacy/prescriptions/{id}/dispatch [post]
+// @Security Bearer
+func DispatchPrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Courier        string `json:"courier" binding:"required"`
+			TrackingNumber string `json:"tracking_number" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.DeliveryMethod != "delivery" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "This prescription is for pickup and is not delivered")})
+			return
+		}
+
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusOutForDelivery, map[string]interface{}{
+			"courier":         req.Courier,
+			"tracking_number": req.TrackingNumber,
+			"dispatched_at":   time.Now(),
+		}) {
+			return
+		}
+
+		if err := NotifyPrescriptionUpdated(db, prescription); err != nil {
+			fmt.Printf("Failed to create prescription notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// @Summary Dispense prescription
+// @Description Record that the medication was delivered or collected
+// @Tags Pharmacy
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.Prescription
+// @Router /api/v1/pharmacy/prescriptions/{id}/dispense [post]
+// @Security Bearer
+func DispensePrescription(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.Status == models.PrescriptionStatusPaid && prescription.DeliveryMethod == "delivery" {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Dispatch the prescription for delivery first")})
+			return
+		}
+
+		if !transitionPrescription(c, db, prescription, models.PrescriptionStatusDispensed, map[string]interface{}{
+			"dispensed_at": time.Now(),
+		}) {
+			return
+		}
+		recordRefillSupply(db, prescription)
+
+		if err := NotifyPrescriptionUpdated(db, prescription); err != nil {
+			fmt.Printf("Failed to create prescription notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, prescription)
+	}
+}
+
+// latestExtraction returns the most recent extraction for a prescription
+func latestExtraction(db *gorm.DB, prescriptionID uuid.UUID) (*models.PrescriptionExtraction, error) {
+	var extraction models.PrescriptionExtraction
+	if err := db.Where("prescription_id = ?", prescriptionID).Order("created_at DESC").First(&extraction).Error; err != nil {
+		return nil, err
+	}
+	return &extraction, nil
+}
+
+// extractionFieldLabels name the fields an extraction flag can point at
+var extractionFieldLabels = map[string]string{
+	"prescriber_name":         "Prescriber name",
+	"prescriber_registration": "Prescriber registration number",
+	"prescription_date":       "Prescription date",
+	"medications":             "Medications",
+	"name":                    "name",
+	"dosage":                  "dosage",
+	"frequency":               "frequency",
+	"duration":                "duration",
+	"instructions":            "instructions",
+}
+
+// localizeExtractionFlags describes each flag in the request language
+func localizeExtractionFlags(c *gin.Context, extraction *models.PrescriptionExtraction) {
+	lang := c.GetString("lang")
+	for i := range extraction.Flags {
+		flag := &extraction.Flags[i]
+
+		label := tr(c, extractionFieldLabels[flag.Field])
+		var line int
+		var field string
+		if _, err := fmt.Sscanf(flag.Field, "medications[%d].%s", &line, &field); err == nil {
+			label = i18n.Tf(lang, "Medication %d %s", line+1, tr(c, extractionFieldLabels[field]))
+		}
+
+		switch flag.Issue {
+		case models.ExtractionIssueMissing:
+			flag.Message = i18n.Tf(lang, "%s is missing from the prescription", label)
+		case models.ExtractionIssueIllegible:
+			flag.Message = i18n.Tf(lang, "%s could not be read reliably; check it against the image", label)
+		}
+	}
+}
+
+// @Summary Get prescription extraction
+// @Description Get the latest automated reading of a prescription image, with proposed medication lines, per-field confidence and flags for missing or illegible fields
+// @Tags Pharmacy
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} models.PrescriptionExtraction
+// @Failure 404 {object} map[string]string "No extraction for this prescription"
+// @Router /api/v1/pharmacy/prescriptions/{id}/extraction [get]
+// @Security Bearer
+func GetPrescriptionExtraction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+
+		extraction, err := latestExtraction(db, prescription.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No extraction has been run for this prescription")})
+			return
+		}
+
+		localizeExtractionFlags(c, extraction)
+		c.JSON(http.StatusOK, extraction)
+	}
+}
+
+// @Summary Re-run prescription extraction
+// @Description Queue a new automated reading of the prescription image, e.g. after an earlier attempt failed
+// @Tags Pharmacy
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 202 {object} models.PrescriptionExtraction
+// @Failure 409 {object} map[string]string "Extraction already running or prescription already transcribed"
+// @Router /api/v1/pharmacy/prescriptions/{id}/extraction [post]
+// @Security Bearer
+func RunPrescriptionExtraction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.Status != models.PrescriptionStatusUploaded && prescription.Status != models.PrescriptionStatusProcessing {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  tr(c, "This action is not allowed for the prescription's current status"),
+				"status": prescription.Status,
+			})
+			return
+		}
+
+		pharmacistID := c.MustGet("user_id").(uuid.UUID)
+		extraction, err := queuePrescriptionExtraction(db, prescription.ID, &pharmacistID)
+		switch {
+		case errors.Is(err, errExtractionPending):
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The prescription is already being read")})
+			return
+		case errors.Is(err, services.ErrQueueFull):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": tr(c, "Too many prescriptions are being read. Please try again shortly.")})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to start prescription extraction")})
+			return
+		}
+
+		c.Header("Location", "/api/v1/pharmacy/prescriptions/"+prescription.ID.String()+"/extraction")
+		c.JSON(http.StatusAccepted, extraction)
+	}
+}
+
+// @Summary Apply prescription extraction
+// @Description Copy the proposed medication lines into the prescription for the pharmacist to check, price and approve. Existing lines are replaced; empty prescriber details are filled in.
+// @Tags Pharmacy
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} map[string]interface{} "Prescription and the applied extraction"
+// @Failure 409 {object} map[string]string "Prescription is not being transcribed or extraction not completed"
+// @Router /api/v1/pharmacy/prescriptions/{id}/extraction/apply [post]
+// @Security Bearer
+func ApplyPrescriptionExtraction(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.Status != models.PrescriptionStatusProcessing {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  tr(c, "Start transcribing the prescription before editing its medications"),
+				"status": prescription.Status,
+			})
+			return
+		}
+
+		extraction, err := latestExtraction(db, prescription.ID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "No extraction has been run for this prescription")})
+			return
+		}
+		if extraction.Status != models.ExtractionStatusCompleted {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":  tr(c, "The prescription extraction has not completed"),
+				"status": extraction.Status,
+			})
+			return
+		}
+
+		// Proposed lines start unavailable and unpriced until the pharmacist confirms them
+		medications := make([]models.PrescriptionMedication, 0, len(extraction.Medications))
+		for _, proposed := range extraction.Medications {
+			name := strings.TrimSpace(proposed.Name.Value)
+			if name == "" {
+				continue
+			}
+			medications = append(medications, models.PrescriptionMedication{
+				PrescriptionID: prescription.ID,
+				Name:           name,
+				Dosage:         proposed.Dosage.Value,
+				Frequency:      proposed.Frequency.Value,
+				Duration:       proposed.Duration.Value,
+				Instructions:   proposed.Instructions.Value,
+				Quantity:       1,
+			})
+		}
+
+		updates := map[string]interface{}{}
+		if prescription.PrescriberName == "" && extraction.PrescriberName.Value != "" {
+			updates["prescriber_name"] = extraction.PrescriberName.Value
+		}
+		if prescription.PrescriberRegistration == "" && extraction.PrescriberRegistration.Value != "" {
+			updates["prescriber_registration"] = extraction.PrescriberRegistration.Value
+		}
+
+		// Proposed lines are checked without blocking; approval blocks until serious alerts are overridden
+		check, ok := checkMedicationSafety(c, db, safetyCheckInput{
+			PatientID:      prescription.UserID,
+			Context:        models.SafetyContextPrescriptionExtraction,
+			PrescriptionID: &prescription.ID,
+			Medications:    medicationNames(medications, false),
+			WarnOnly:       true,
+		})
+		if !ok {
+			return
+		}
+
+		if err := replacePrescriptionMedications(db, prescription.ID, medications, updates); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save prescription medications")})
+			return
+		}
+		saveSafetyAlerts(db, prescription.ID, check)
+
+		now := time.Now()
+		extraction.AppliedAt = &now
+		pharmacistID := c.MustGet("user_id").(uuid.UUID)
+		extraction.AppliedByID = &pharmacistID
+		db.Model(&models.PrescriptionExtraction{}).Where("id = ?", extraction.ID).Updates(map[string]interface{}{
+			"applied_at":    extraction.AppliedAt,
+			"applied_by_id": extraction.AppliedByID,
+		})
+
+		db.Preload("Medications").First(prescription, "id = ?", prescription.ID)
+		localizeExtractionFlags(c, extraction)
+		c.JSON(http.StatusOK, gin.H{
+			"prescription": prescription,
+			"extraction":   extraction,
+		})
+	}
+}
@@ -0,0 +1,573 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// refillLeadTime is how long before the supply runs out a refill can be requested,
+	// and when the patient is reminded to request one
+	refillLeadTime = 5 * 24 * time.Hour
+	// refillReminderInterval is how often refill-due reminders are sent
+	refillReminderInterval = time.Hour
+	// refillReminderBatch caps how many refill reminders are sent per run
+	refillReminderBatch = 500
+	// maxRefills caps the refills a pharmacist or clinician can authorise at once
+	maxRefills = 12
+)
+
+var (
+	errRefillReviewed     = errors.New("refill request already reviewed")
+	errNoRefillsRemaining = errors.New("no refills remaining")
+)
+
+// refillOriginal returns the prescription a refill repeats: the prescription
+// itself, or the original one when it is a refill order
+func refillOriginal(db *gorm.DB, prescription *models.Prescription) (*models.Prescription, error) {
+	if prescription.OriginalPrescriptionID == nil {
+		return prescription, nil
+	}
+	var original models.Prescription
+	if err := db.Preload("Medications").First(&original, "id = ?", *prescription.OriginalPrescriptionID).Error; err != nil {
+		return nil, err
+	}
+	return &original, nil
+}
+
+// hasChronicConditions reports whether any of a patient's medical records lists a
+// chronic condition, in which case repeat medicines can be renewed once refills run out
+func hasChronicConditions(db *gorm.DB, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := db.Model(&models.MedicalRecord{}).
+		Where("user_id = ? AND cardinality(chronic_conditions) > 0", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// recordRefillSupply runs when a prescription is dispensed. It works out when the
+// supply runs out so the patient can be reminded to refill, and completes the
+// refill request that created the order, if any.
+func recordRefillSupply(db *gorm.DB, prescription *models.Prescription) {
+	original, err := refillOriginal(db, prescription)
+	if err != nil {
+		fmt.Printf("Failed to load original of prescription %s: %v\n", prescription.ID, err)
+		return
+	}
+
+	updates := map[string]interface{}{
+		"next_refill_at":          nil,
+		"refill_reminder_sent_at": nil,
+	}
+	if days, ok := services.SupplyDays(prescription.Medications); ok {
+		dispensedAt := time.Now()
+		if prescription.DispensedAt != nil {
+			dispensedAt = *prescription.DispensedAt
+		}
+		updates["next_refill_at"] = dispensedAt.AddDate(0, 0, days)
+	}
+	if err := db.Model(&models.Prescription{}).Where("id = ?", original.ID).Updates(updates).Error; err != nil {
+		fmt.Printf("Failed to record refill date for prescription %s: %v\n", original.ID, err)
+	}
+
+	if prescription.OriginalPrescriptionID != nil {
+		if err := db.Model(&models.RefillRequest{}).
+			Where("refill_prescription_id = ? AND status = ?", prescription.ID, models.RefillStatusApproved).
+			Update("status", models.RefillStatusFulfilled).Error; err != nil {
+			fmt.Printf("Failed to complete refill request for prescription %s: %v\n", prescription.ID, err)
+		}
+	}
+}
+
+// releaseRefill returns the refill used by a refill order the pharmacy rejected
+// or the patient cancelled, so it can be requested again
+func releaseRefill(db *gorm.DB, prescription *models.Prescription) {
+	if prescription.OriginalPrescriptionID == nil {
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(&models.RefillRequest{}).
+			Where("refill_prescription_id = ? AND status = ?", prescription.ID, models.RefillStatusApproved).
+			Update("status", models.RefillStatusCancelled)
+		if update.Error != nil || update.RowsAffected == 0 {
+			return update.Error
+		}
+		return tx.Model(&models.Prescription{}).
+			Where("id = ?", *prescription.OriginalPrescriptionID).
+			Update("refills_remaining", gorm.Expr("refills_remaining + 1")).Error
+	})
+	if err != nil {
+		fmt.Printf("Failed to release refill for prescription %s: %v\n", prescription.ID, err)
+	}
+}
+
+// @Summary Request a prescription refill
+// @Description Ask for a dispensed prescription to be repeated. Refills can be requested from five days before the last supply runs out. Once no refills remain, patients with a chronic condition can request a renewal. A clinician reviews the request.
+// @Tags Prescriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 201 {object} models.RefillRequest
+// @Failure 409 {object} map[string]interface{} "Refill not available yet, already requested, or no refills remain"
+// @Router /api/v1/prescriptions/{id}/refills [post]
+// @Security Bearer
+func RequestPrescriptionRefill(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Notes string `json:"notes"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userID := c.MustGet("user_id").(uuid.UUID)
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		if prescription.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Only the patient can request a refill")})
+			return
+		}
+
+		original, err := refillOriginal(db, prescription)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Prescription not found")})
+			return
+		}
+		if original.Status != models.PrescriptionStatusDispensed {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Only dispensed prescriptions can be refilled")})
+			return
+		}
+
+		var open int64
+		if err := db.Model(&models.RefillRequest{}).
+			Where("prescription_id = ? AND status IN ?", original.ID, []string{models.RefillStatusRequested, models.RefillStatusApproved}).
+			Count(&open).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to request refill")})
+			return
+		}
+		if open > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "A refill is already in progress for this prescription")})
+			return
+		}
+
+		if original.NextRefillAt != nil {
+			if availableAt := original.NextRefillAt.Add(-refillLeadTime); time.Now().Before(availableAt) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":               tr(c, "It is too early to refill this prescription"),
+					"refill_available_at": availableAt,
+				})
+				return
+			}
+		}
+
+		renewal := original.RefillsRemaining <= 0
+		if renewal {
+			chronic, err := hasChronicConditions(db, userID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to request refill")})
+				return
+			}
+			if !chronic {
+				c.JSON(http.StatusConflict, gin.H{"error": tr(c, "No refills remain on this prescription. Please upload a new prescription.")})
+				return
+			}
+		}
+
+		request := models.RefillRequest{
+			PrescriptionID: original.ID,
+			UserID:         userID,
+			Status:         models.RefillStatusRequested,
+			Renewal:        renewal,
+			Notes:          strings.TrimSpace(req.Notes),
+		}
+		if err := db.Create(&request).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to request refill")})
+			return
+		}
+
+		c.JSON(http.StatusCreated, request)
+	}
+}
+
+// @Summary List prescription refills
+// @Description List the refill requests made for a prescription, newest first
+// @Tags Prescriptions
+// @Produce json
+// @Param id path string true "Prescription ID"
+// @Success 200 {object} map[string]interface{} "Refill requests and refills remaining"
+// @Router /api/v1/prescriptions/{id}/refills [get]
+// @Security Bearer
+func ListPrescriptionRefills(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prescription, ok := loadPrescription(c, db)
+		if !ok {
+			return
+		}
+		original, err := refillOriginal(db, prescription)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Prescription not found")})
+			return
+		}
+
+		var requests []models.RefillRequest
+		if err := db.Where("prescription_id = ?", original.ID).Order("created_at DESC").Find(&requests).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch refill requests")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"refill_requests":   requests,
+			"refills_remaining": original.RefillsRemaining,
+			"next_refill_at":    original.NextRefillAt,
+		})
+	}
+}
+
+// @Summary Refill request queue
+// @Description List refill requests awaiting clinician review, oldest first, with the patient's chronic conditions when the patient has shared their medical record with the reviewer
+// @Tags Review
+// @Produce json
+// @Param status query string false "Request status, requested by default"
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {object} map[string]interface{} "Refill requests"
+// @Failure 403 {object} map[string]string "Clinician access required"
+// @Router /api/v1/review/refill-requests [get]
+// @Security Bearer
+func ListRefillRequests(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.RefillRequest{}).Where("status = ?", c.DefaultQuery("status", models.RefillStatusRequested))
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch refill requests")})
+			return
+		}
+
+		var requests []models.RefillRequest
+		if err := query.
+			Preload("Prescription.Medications").
+			Order("created_at ASC").
+			Scopes(Paginate(c)).
+			Find(&requests).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch refill requests")})
+			return
+		}
+
+		type refillQueueItem struct {
+			models.RefillRequest
+			RecordShared      bool     `json:"record_shared"`      // The patient has shared their medical record with the reviewer
+			ChronicConditions []string `json:"chronic_conditions"` // Only when the record is shared
+		}
+		items := make([]refillQueueItem, 0, len(requests))
+		for _, request := range requests {
+			item := refillQueueItem{RefillRequest: request, ChronicConditions: []string{}}
+			item.RecordShared = clinicianMayAccessRecord(c, db, request.UserID)
+			if !item.RecordShared {
+				items = append(items, item)
+				continue
+			}
+			var records []models.MedicalRecord
+			if err := db.Select("chronic_conditions").Where("user_id = ?", request.UserID).Find(&records).Error; err == nil {
+				for _, record := range records {
+					item.ChronicConditions = appendUnique(item.ChronicConditions, record.ChronicConditions...)
+				}
+			}
+			items = append(items, item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"refill_requests": items,
+			"total":           total,
+		})
+	}
+}
+
+// loadRefillRequest finds the refill request in the :id path parameter with its prescription
+func loadRefillRequest(c *gin.Context, db *gorm.DB) (*models.RefillRequest, bool) {
+	var request models.RefillRequest
+	if err := db.Preload("Prescription.Medications").First(&request, "id = ?", c.Param("id")).Error; err != nil || request.Prescription == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Refill request not found")})
+		return nil, false
+	}
+	if request.Status != models.RefillStatusRequested {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This refill request has already been reviewed"), "status": request.Status})
+		return nil, false
+	}
+	return &request, true
+}
+
+// @Summary Approve refill request
+// @Description Approve a refill and create a refill order with the same medication lines for the pharmacy to quote. A renewal must authorise refills, which set the refills remaining including this one. The medication is safety checked against the patient's current record.
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Refill request ID"
+// @Success 200 {object} map[string]interface{} "Refill request and refill order"
+// @Failure 409 {object} map[string]interface{} "Already reviewed, no refills remaining or serious safety alerts"
+// @Router /api/v1/review/refill-requests/{id}/approve [post]
+// @Security Bearer
+func ApproveRefillRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Refills        *int   `json:"refills"` // refills authorised from now on, including this one
+			ReviewNotes    string `json:"review_notes"`
+			OverrideReason string `json:"override_reason"` // required to approve with serious safety alerts
+		}
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Refills != nil && (*req.Refills < 1 || *req.Refills > maxRefills) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.Tf(c.GetString("lang"), "Refills must be between 1 and %d", maxRefills)})
+			return
+		}
+
+		clinicianID := c.MustGet("user_id").(uuid.UUID)
+		request, ok := loadRefillRequest(c, db)
+		if !ok {
+			return
+		}
+		original := request.Prescription
+		if req.Refills == nil && original.RefillsRemaining <= 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "No refills remain. Authorise refills to renew the prescription.")})
+			return
+		}
+
+		var lines []models.PrescriptionMedication
+		for _, medication := range original.Medications {
+			if medication.Available {
+				lines = append(lines, medication)
+			}
+		}
+		if len(lines) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "The prescription has no dispensed medication to refill")})
+			return
+		}
+
+		// The patient's record may have changed since the prescription was dispensed
+		check, ok := checkMedicationSafety(c, db, safetyCheckInput{
+			PatientID:      original.UserID,
+			Context:        models.SafetyContextPrescriptionRefill,
+			PrescriptionID: &original.ID,
+			Medications:    medicationNames(lines, true),
+			OverrideReason: req.OverrideReason,
+		})
+		if !ok {
+			return
+		}
+
+		var clinician models.User
+		if err := db.First(&clinician, "id = ?", clinicianID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to approve refill")})
+			return
+		}
+
+		now := time.Now()
+		order := models.Prescription{
+			ID:                     uuid.New(),
+			UserID:                 original.UserID,
+			DoctorID:               &clinicianID,
+			PrescriberName:         strings.TrimSpace(clinician.FirstName + " " + clinician.LastName),
+			ImageURL:               original.ImageURL,
+			Status:                 models.PrescriptionStatusProcessing,
+			Notes:                  original.Notes,
+			OriginalPrescriptionID: &original.ID,
+			SafetyAlerts:           check.Alerts,
+			DeliveryMethod:         original.DeliveryMethod,
+			DeliveryAddress:        original.DeliveryAddress,
+			ContactNumber:          original.ContactNumber,
+		}
+		for _, line := range lines {
+			order.Medications = append(order.Medications, models.PrescriptionMedication{
+				PrescriptionID: order.ID,
+				Name:           line.Name,
+				Dosage:         line.Dosage,
+				Frequency:      line.Frequency,
+				Duration:       line.Duration,
+				Instructions:   line.Instructions,
+				Quantity:       line.Quantity,
+				Price:          line.Price,
+				Available:      true,
+			})
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			update := tx.Model(&models.RefillRequest{}).
+				Where("id = ? AND status = ?", request.ID, models.RefillStatusRequested).
+				Updates(map[string]interface{}{
+					"status":                 models.RefillStatusApproved,
+					"reviewed_by_id":         clinicianID,
+					"reviewed_at":            now,
+					"review_notes":           req.ReviewNotes,
+					"refill_prescription_id": order.ID,
+				})
+			if update.Error != nil {
+				return update.Error
+			}
+			if update.RowsAffected == 0 {
+				return errRefillReviewed
+			}
+
+			if req.Refills != nil {
+				if err := tx.Model(&models.Prescription{}).Where("id = ?", original.ID).
+					Update("refills_remaining", *req.Refills).Error; err != nil {
+					return err
+				}
+			}
+			use := tx.Model(&models.Prescription{}).
+				Where("id = ? AND refills_remaining > 0", original.ID).
+				Update("refills_remaining", gorm.Expr("refills_remaining - 1"))
+			if use.Error != nil {
+				return use.Error
+			}
+			if use.RowsAffected == 0 {
+				return errNoRefillsRemaining
+			}
+
+			return tx.Create(&order).Error
+		})
+		switch {
+		case errors.Is(err, errRefillReviewed):
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This refill request has already been reviewed")})
+			return
+		case errors.Is(err, errNoRefillsRemaining):
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "No refills remain. Authorise refills to renew the prescription.")})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to approve refill")})
+			return
+		}
+
+		db.Preload("Prescription").First(request, "id = ?", request.ID)
+		if err := NotifyRefillReviewed(db, request); err != nil {
+			fmt.Printf("Failed to create refill notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"refill_request": request,
+			"refill_order":   order,
+		})
+	}
+}
+
+// @Summary Deny refill request
+// @Description Decline a refill request, with the reason shown to the patient
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Refill request ID"
+// @Success 200 {object} models.RefillRequest
+// @Failure 409 {object} map[string]interface{} "Already reviewed"
+// @Router /api/v1/review/refill-requests/{id}/deny [post]
+// @Security Bearer
+func DenyRefillRequest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Reason string `json:"reason" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		request, ok := loadRefillRequest(c, db)
+		if !ok {
+			return
+		}
+
+		update := db.Model(&models.RefillRequest{}).
+			Where("id = ? AND status = ?", request.ID, models.RefillStatusRequested).
+			Updates(map[string]interface{}{
+				"status":         models.RefillStatusDenied,
+				"reviewed_by_id": c.MustGet("user_id"),
+				"reviewed_at":    time.Now(),
+				"denial_reason":  req.Reason,
+			})
+		if update.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to deny refill")})
+			return
+		}
+		if update.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "This refill request has already been reviewed")})
+			return
+		}
+
+		db.First(request, "id = ?", request.ID)
+		if err := NotifyRefillReviewed(db, request); err != nil {
+			fmt.Printf("Failed to create refill notification: %v\n", err)
+		}
+		c.JSON(http.StatusOK, request)
+	}
+}
+
+// StartRefillReminders periodically reminds patients whose dispensed medication is running out
+func StartRefillReminders(db *gorm.DB) {
+	go func() {
+		for {
+			if err := RunRefillReminders(db); err != nil {
+				fmt.Printf("Refill reminders failed: %v\n", err)
+			}
+			time.Sleep(refillReminderInterval)
+		}
+	}()
+}
+
+// RunRefillReminders reminds patients to request a refill once it can be requested.
+// Prescriptions with no refills left are only reminded for patients with a chronic
+// condition, who can ask for a renewal; each supply is reminded at most once.
+func RunRefillReminders(db *gorm.DB) error {
+	var due []models.Prescription
+	if err := db.Where("original_prescription_id IS NULL AND status = ? AND next_refill_at <= ? AND refill_reminder_sent_at IS NULL",
+		models.PrescriptionStatusDispensed, time.Now().Add(refillLeadTime)).
+		Order("next_refill_at").
+		Limit(refillReminderBatch).
+		Find(&due).Error; err != nil {
+		return fmt.Errorf("failed to load prescriptions due for refill: %w", err)
+	}
+
+	for i := range due {
+		prescription := &due[i]
+
+		var open int64
+		if err := db.Model(&models.RefillRequest{}).
+			Where("prescription_id = ? AND status IN ?", prescription.ID, []string{models.RefillStatusRequested, models.RefillStatusApproved}).
+			Count(&open).Error; err != nil {
+			return fmt.Errorf("failed to check refill requests: %w", err)
+		}
+
+		renewal := prescription.RefillsRemaining <= 0
+		notify := open == 0
+		if notify && renewal {
+			chronic, err := hasChronicConditions(db, prescription.UserID)
+			if err != nil {
+				return fmt.Errorf("failed to check chronic conditions: %w", err)
+			}
+			notify = chronic
+		}
+
+		if notify {
+			if err := NotifyRefillDue(db, prescription, renewal); err != nil {
+				fmt.Printf("Failed to send refill reminder for prescription %s: %v\n", prescription.ID, err)
+				continue
+			}
+		}
+		if err := db.Model(prescription).Update("refill_reminder_sent_at", time.Now()).Error; err != nil {
+			fmt.Printf("Failed to record refill reminder for prescription %s: %v\n", prescription.ID, err)
+		}
+	}
+	return nil
+}
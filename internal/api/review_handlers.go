@@ -0,0 +1,391 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/middleware"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+var openReviewStatuses = []string{models.TestKitResultStatusPending, models.TestKitResultStatusInReview}
+
+// ReviewQueueItem is a test kit result in the review queue with its SLA state
+type ReviewQueueItem struct {
+	models.TestKitResult
+	SLARemainingSeconds int64 `json:"sla_remaining_seconds"`
+	Overdue             bool  `json:"overdue"`
+	ClaimActive         bool  `json:"claim_active"`
+}
+
+// @Summary Test kit result review queue
+// @Description List AI-analysed test kit results awaiting clinician review, highest risk first
+// @Tags Review
+// @Produce json
+// @Param filter query string false "mine, unclaimed or overdue"
+// @Param page query int false "Page number"
+// @Param limit query int false "Results per page"
+// @Success 200 {object} map[string]interface{} "Review queue"
+// @Failure 403 {object} map[string]string "Clinician access required"
+// @Router /api/v1/review/test-kit-results [get]
+// @Security Bearer
+func ListTestKitReviewQueue(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		now := time.Now()
+		claimExpiry := now.Add(-services.ReviewClaimTTL)
+
+		query := db.Model(&models.TestKitResult{}).Where("status IN ?", openReviewStatuses)
+		switch c.Query("filter") {
+		case "mine":
+			query = query.Where("claimed_by = ? AND claimed_at >= ?", userID, claimExpiry)
+		case "unclaimed":
+			query = query.Where("claimed_by IS NULL OR claimed_at < ?", claimExpiry)
+		case "overdue":
+			query = query.Where("review_due_at < ?", now)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch review queue")})
+			return
+		}
+
+		var results []models.TestKitResult
+		if err := query.
+			Order("review_priority DESC").
+			Order("review_due_at ASC NULLS LAST").
+			Order("created_at ASC").
+			Scopes(Paginate(c)).
+			Find(&results).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch review queue")})
+			return
+		}
+
+		items := make([]ReviewQueueItem, 0, len(results))
+		for _, result := range results {
+			item := ReviewQueueItem{TestKitResult: result}
+			if result.ReviewDueAt != nil {
+				item.SLARemainingSeconds = int64(result.ReviewDueAt.Sub(now).Seconds())
+				item.Overdue = now.After(*result.ReviewDueAt)
+			}
+			item.ClaimActive = result.ClaimedBy != nil && result.ClaimedAt != nil && result.ClaimedAt.After(claimExpiry)
+			items = append(items, item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"results": items,
+			"total":   total,
+		})
+	}
+}
+
+// @Summary Claim a test kit result for review
+// @Description Claim a result so no other clinician reviews it at the same time. Claims expire after 30 minutes.
+// @Tags Review
+// @Produce json
+// @Param id path string true "Test Kit Result ID"
+// @Success 200 {object} models.TestKitResult "Claimed result"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Already claimed or reviewed"
+// @Router /api/v1/review/test-kit-results/{id}/claim [post]
+// @Security Bearer
+func ClaimTestKitResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		now := time.Now()
+		update := db.Model(&models.TestKitResult{}).
+			Where("id = ? AND status IN ?", c.Param("id"), openReviewStatuses).
+			Where("claimed_by IS NULL OR claimed_by = ? OR claimed_at < ?", userID, now.Add(-services.ReviewClaimTTL)).
+			Updates(map[string]interface{}{
+				"claimed_by": userID,
+				"claimed_at": now,
+				"status":     models.TestKitResultStatusInReview,
+			})
+		if update.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to claim test kit result")})
+			return
+		}
+		if update.RowsAffected == 0 {
+			respondReviewConflict(c, db)
+			return
+		}
+
+		var result models.TestKitResult
+		db.First(&result, "id = ?", c.Param("id"))
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary Release a review claim
+// @Description Return a claimed result to the open queue
+// @Tags Review
+// @Produce json
+// @Param id path string true "Test Kit Result ID"
+// @Success 200 {object} models.TestKitResult "Released result"
+// @Failure 409 {object} map[string]string "Not claimed by you"
+// @Router /api/v1/review/test-kit-results/{id}/release [post]
+// @Security Bearer
+func ReleaseTestKitResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		query := db.Model(&models.TestKitResult{}).
+			Where("id = ? AND status = ?", c.Param("id"), models.TestKitResultStatusInReview)
+
+		// Admins can release any claim, clinicians only their own
+		role, _ := c.Get("role")
+		if role != "admin" {
+			query = query.Where("claimed_by = ?", userID)
+		}
+
+		update := query.Updates(map[string]interface{}{
+			"claimed_by": nil,
+			"claimed_at": nil,
+			"status":     models.TestKitResultStatusPending,
+		})
+		if update.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to release test kit result")})
+			return
+		}
+		if update.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Test kit result is not claimed by you")})
+			return
+		}
+
+		var result models.TestKitResult
+		db.First(&result, "id = ?", c.Param("id"))
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// @Summary Assign a test kit result to a clinician
+// @Description Admin only. Assign an open result to a doctor or nurse, replacing any existing claim.
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Test Kit Result ID"
+// @Param assignment body object true "clinician_id"
+// @Success 200 {object} models.TestKitResult "Assigned result"
+// @Failure 400 {object} map[string]string "Invalid clinician"
+// @Failure 409 {object} map[string]string "Already reviewed"
+// @Router /api/v1/review/test-kit-results/{id}/assign [post]
+// @Security Bearer
+func AssignTestKitResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		if role != "admin" {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Admin access required")})
+			return
+		}
+
+		var req struct {
+			ClinicianID string `json:"clinician_id" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		clinicianID, err := uuid.Parse(req.ClinicianID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid user ID format")})
+			return
+		}
+
+		var clinician models.User
+		if err := db.First(&clinician, "id = ?", clinicianID).Error; err != nil || !middleware.IsClinicianRole(clinician.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Assignee must be a clinician")})
+			return
+		}
+
+		update := db.Model(&models.TestKitResult{}).
+			Where("id = ? AND status IN ?", c.Param("id"), openReviewStatuses).
+			Updates(map[string]interface{}{
+				"claimed_by": clinicianID,
+				"claimed_at": time.Now(),
+				"status":     models.TestKitResultStatusInReview,
+			})
+		if update.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to assign test kit result")})
+			return
+		}
+		if update.RowsAffected == 0 {
+			respondReviewConflict(c, db)
+			return
+		}
+
+		var result models.TestKitResult
+		db.First(&result, "id = ?", c.Param("id"))
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// ReviewDecisionRequest is a clinician's decision on an AI-analysed result
+type ReviewDecisionRequest struct {
+	Decision         string   `json:"decision" binding:"required,oneof=confirm override"`
+	Result           string   `json:"result"` // required when overriding
+	ReviewNotes      string   `json:"review_notes"`
+	RecommendedSteps []string `json:"recommended_steps"`
+}
+
+// @Summary Confirm or override an AI result
+// @Description Record the clinician's decision on a claimed result and notify the patient
+// @Tags Review
+// @Accept json
+// @Produce json
+// @Param id path string true "Test Kit Result ID"
+// @Param decision body ReviewDecisionRequest true "Review decision"
+// @Success 200 {object} models.TestKitResult "Reviewed result"
+// @Failure 400 {object} map[string]string "Invalid decision"
+// @Failure 409 {object} map[string]string "Not claimed by you"
+// @Router /api/v1/review/test-kit-results/{id}/decision [post]
+// @Security Bearer
+func DecideTestKitResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		var req ReviewDecisionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var result models.TestKitResult
+		if err := db.First(&result, "id = ?", c.Param("id")).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit result not found")})
+			return
+		}
+
+		recordReviewDecision(c, db, userID, &result, req)
+	}
+}
+
+// recordReviewDecision applies a clinician's decision to a result they hold an
+// active claim on and notifies the patient
+func recordReviewDecision(c *gin.Context, db *gorm.DB, userID interface{}, result *models.TestKitResult, req ReviewDecisionRequest) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"reviewed_by":  userID,
+		"reviewed_at":  now,
+		"review_notes": req.ReviewNotes,
+		"status":       models.TestKitResultStatusReviewed,
+		"claimed_by":   nil,
+		"claimed_at":   nil,
+	}
+
+	if req.Decision == "override" {
+		if req.Result == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "A result is required to override the AI reading")})
+			return
+		}
+		normalized, ok := validKitResult(result.KitType, req.Result)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid test result value")})
+			return
+		}
+		req.Result = normalized
+		if req.Result == result.Result {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Override result matches the AI reading; confirm it instead")})
+			return
+		}
+		updates["review_decision"] = models.ReviewDecisionOverridden
+		updates["ai_result"] = result.Result
+		updates["result"] = req.Result
+		// The AI's steps no longer apply, so use the catalog steps unless the clinician gave their own
+		if spec := services.GetKitType(result.KitType); spec != nil && len(req.RecommendedSteps) == 0 {
+			var patient models.User
+			db.Select("preferred_language").First(&patient, "id = ?", result.UserID)
+			updates["recommended_steps"] = i18n.TSlice(patient.PreferredLanguage, spec.StandardSteps(req.Result))
+		}
+	} else {
+		updates["review_decision"] = models.ReviewDecisionConfirmed
+	}
+
+	if len(req.RecommendedSteps) > 0 {
+		updates["recommended_steps"] = req.RecommendedSteps
+	}
+
+	// Only the clinician holding an active claim can record the decision
+	update := db.Model(&models.TestKitResult{}).
+		Where("id = ? AND status = ? AND claimed_by = ? AND claimed_at >= ?",
+			result.ID, models.TestKitResultStatusInReview, userID, now.Add(-services.ReviewClaimTTL)).
+		Updates(updates)
+	if update.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to update test kit result")})
+		return
+	}
+	if update.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Claim the test kit result before recording a decision")})
+		return
+	}
+
+	db.First(result, "id = ?", result.ID)
+
+	if err := NotifyTestResultReviewed(db, result); err != nil {
+		fmt.Printf("Failed to notify patient of reviewed test result: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// validKitResult normalises a clinician-entered result and checks it against the
+// kit catalog. Results recorded before the catalog existed accept the generic values.
+func validKitResult(kitType, result string) (string, bool) {
+	spec := services.GetKitType(kitType)
+	if spec == nil {
+		result = services.NormalizeTestKitResult(result)
+		return result, result == "positive" || result == "negative" || result == "inconclusive"
+	}
+	result = spec.NormalizeResult(result)
+	return result, spec.ValidResult(result)
+}
+
+// respondReviewConflict explains why a claim or assignment did not apply
+func respondReviewConflict(c *gin.Context, db *gorm.DB) {
+	var result models.TestKitResult
+	if err := db.First(&result, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit result not found")})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch test kit results")})
+		return
+	}
+
+	if result.Status != models.TestKitResultStatusPending && result.Status != models.TestKitResultStatusInReview {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Test kit result has already been reviewed")})
+		return
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":      tr(c, "Test kit result is already claimed by another clinician"),
+		"claimed_by": result.ClaimedBy,
+		"claimed_at": result.ClaimedAt,
+	})
+}
@@ -12,11 +12,12 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 	router.Use(middleware.Logger())
 	router.Use(middleware.SecurityLogger())
 	router.Use(middleware.RateLimit())
+	router.Use(middleware.Language())
 
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Accept-Language, Idempotency-Key, X-Acting-For")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -48,9 +49,16 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 		testKits := public.Group("/test-kits")
 		{
 			testKits.GET("", ListTestKits(db))
+			testKits.GET("/types", ListKitTypes(db))
 			testKits.GET("/:id", GetTestKit(db))
 		}
 
+		public.GET("/storage/local/*key", ServeLocalFile(db))
+
+		// Paystack redirects the customer's browser here without a token; the
+		// handler only acts on a reference it verifies with Paystack
+		public.GET("/payments/paystack/callback", PaystackCallback(db))
+
 		labTests := public.Group("/lab-tests")
 		{
 			labTests.GET("", ListLabTests(db))
@@ -64,20 +72,65 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 	}
 
 	protected := router.Group("/api/v1")
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(), ActingFor(db))
 	{
 		users := protected.Group("/users")
 		{
 			users.GET("/me", GetCurrentUser(db))
 			users.PUT("/me", UpdateUser(db))
+			users.POST("/me/export", RequestDataExport(db))
+			users.GET("/me/exports", ListDataExports(db))
+			users.GET("/me/exports/:id", GetDataExport(db))
+			users.POST("/me/deletion", RequestAccountDeletion(db))
+			users.GET("/me/deletion", GetAccountDeletion(db))
+			users.DELETE("/me/deletion", CancelAccountDeletion(db))
+		}
+
+		consents := protected.Group("/consents")
+		{
+			consents.GET("/documents", ListConsentDocuments(db))
+			consents.GET("", ListConsents(db))
+			consents.POST("", GrantConsent(db))
+			consents.DELETE("/:id", RevokeConsent(db))
+		}
+
+		dependants := protected.Group("/dependants")
+		{
+			dependants.POST("", CreateDependant(db))
+			dependants.GET("", ListDependants(db))
+			dependants.PUT("/:id", UpdateDependant(db))
+			dependants.DELETE("/:id", LeaveDependant(db))
+		}
+
+		caregivers := protected.Group("/caregivers")
+		{
+			caregivers.GET("", ListCaregivers(db))
+			caregivers.POST("", AddCaregiver(db))
+			caregivers.GET("/activity", ListCaregiverActivity(db))
+			caregivers.PUT("/:id", UpdateCaregiver(db))
+			caregivers.DELETE("/:id", RemoveCaregiver(db))
 		}
 
 		records := protected.Group("/medical-records")
 		{
 			records.GET("", ListMedicalRecordsHandler(db))
+			records.GET("/me", GetMyMedicalRecordHandler(db))
+			records.POST("/me", CreateMyMedicalRecordHandler(db))
 			records.GET("/:id", GetMedicalRecordHandler(db))
 			records.POST("", CreateMedicalRecordHandler(db))
 			records.PUT("/:id", UpdateMedicalRecordHandler(db))
+			records.GET("/:id/medications", ListMedicationsHandler(db))
+			records.POST("/:id/medications", CreateMedicationHandler(db))
+			records.PUT("/:id/medications/:medicationId", UpdateMedicationHandler(db))
+			records.DELETE("/:id/medications/:medicationId", DeleteMedicationHandler(db))
+			records.GET("/:id/test-results", ListRecordTestResultsHandler(db))
+			records.POST("/:id/test-results", CreateRecordTestResultHandler(db))
+			records.PUT("/:id/test-results/:resultId", UpdateRecordTestResultHandler(db))
+			records.DELETE("/:id/test-results/:resultId", DeleteRecordTestResultHandler(db))
+			records.GET("/:id/consultations", ListRecordConsultationsHandler(db))
+			records.POST("/:id/consultations", CreateRecordConsultationHandler(db))
+			records.PUT("/:id/consultations/:consultationId", UpdateRecordConsultationHandler(db))
+			records.DELETE("/:id/consultations/:consultationId", DeleteRecordConsultationHandler(db))
 		}
 		notifications := protected.Group("/notifications")
 		{
@@ -110,6 +163,23 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			testKitResults.GET("", ListTestKitResults(db))
 			testKitResults.GET("/:id", GetTestKitResult(db))
 			testKitResults.PUT("/:id", UpdateTestKitResult(db))
+			testKitResults.DELETE("/:id", DeleteTestKitResult(db))
+		}
+
+		review := protected.Group("/review")
+		review.Use(middleware.ClinicianMiddleware())
+		{
+			review.GET("/test-kit-results", ListTestKitReviewQueue(db))
+			review.POST("/test-kit-results/:id/claim", ClaimTestKitResult(db))
+			review.POST("/test-kit-results/:id/release", ReleaseTestKitResult(db))
+			review.POST("/test-kit-results/:id/assign", AssignTestKitResult(db))
+			review.POST("/test-kit-results/:id/decision", DecideTestKitResult(db))
+			review.GET("/refill-requests", ListRefillRequests(db))
+			review.POST("/refill-requests/:id/approve", ApproveRefillRequest(db))
+			review.POST("/refill-requests/:id/deny", DenyRefillRequest(db))
+			review.GET("/patients/:id/medical-record", GetSharedMedicalRecord(db))
+			review.GET("/lab-results", ListCriticalLabResults(db))
+			review.POST("/lab-results/:id/acknowledge", AcknowledgeCriticalLabResult(db))
 		}
 
 		consultations := protected.Group("/consultations")
@@ -124,7 +194,28 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 		{
 			prescriptions.POST("", CreatePrescription(db))
 			prescriptions.GET("", ListPrescriptions(db))
+			prescriptions.GET("/:id", GetPrescription(db))
 			prescriptions.PUT("/:id/status", UpdatePrescriptionStatus(db))
+			prescriptions.POST("/:id/accept", AcceptPrescriptionQuote(db))
+			prescriptions.POST("/:id/cancel", CancelPrescription(db))
+			prescriptions.POST("/:id/pay", PayForPrescription(db))
+			prescriptions.GET("/:id/refills", ListPrescriptionRefills(db))
+			prescriptions.POST("/:id/refills", RequestPrescriptionRefill(db))
+		}
+
+		pharmacy := protected.Group("/pharmacy")
+		pharmacy.Use(middleware.PharmacistMiddleware())
+		{
+			pharmacy.GET("/prescriptions", ListPharmacyPrescriptions(db))
+			pharmacy.POST("/prescriptions/:id/start", StartPrescriptionTranscription(db))
+			pharmacy.GET("/prescriptions/:id/extraction", GetPrescriptionExtraction(db))
+			pharmacy.POST("/prescriptions/:id/extraction", RunPrescriptionExtraction(db))
+			pharmacy.POST("/prescriptions/:id/extraction/apply", ApplyPrescriptionExtraction(db))
+			pharmacy.PUT("/prescriptions/:id/medications", SetPrescriptionMedications(db))
+			pharmacy.POST("/prescriptions/:id/approve", ApprovePrescription(db))
+			pharmacy.POST("/prescriptions/:id/reject", RejectPrescription(db))
+			pharmacy.POST("/prescriptions/:id/dispatch", DispatchPrescription(db))
+			pharmacy.POST("/prescriptions/:id/dispense", DispensePrescription(db))
 		}
 
 		labBookings := protected.Group("/lab-bookings")
@@ -134,6 +225,21 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			labBookings.PUT("/:id/status", UpdateLabBookingStatus(db))
 		}
 
+		labResults := protected.Group("/lab-results")
+		{
+			labResults.GET("", ListMyLabResults(db))
+			labResults.GET("/:id", GetMyLabResult(db))
+		}
+
+		lab := protected.Group("/lab")
+		lab.Use(middleware.LabTechnicianMiddleware())
+		{
+			lab.GET("/bookings", ListLabWorklist(db))
+			lab.POST("/bookings/:id/results", EnterLabResults(db))
+			lab.GET("/results", ListLabResultsForVerification(db))
+			lab.POST("/results/:id/verify", VerifyLabResult(db))
+		}
+
 		telehealth := protected.Group("/telehealth")
 		{
 			telehealth.POST("/sessions", CreateTelehealthSession(db))
@@ -145,6 +251,16 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			symptoms.POST("/check", CreateSymptomCheck(db))
 			symptoms.GET("/history", ListSymptomChecks(db))
 		}
+		medications := protected.Group("/medications")
+		{
+			medications.GET("/:id/doses", ListMedicationDoses(db))
+			medications.POST("/:id/doses", LogMedicationDose(db))
+		}
+		fhir := protected.Group("/fhir")
+		{
+			fhir.GET("/Patient/:id/$everything", ExportPatientEverything(db))
+			fhir.POST("/Bundle", ImportFHIRBundle(db))
+		}
 		caresense := protected.Group("/caresense")
 		{
 			caresense.POST("/analytics", GenerateCareSenseAnalytics(db))
@@ -153,7 +269,6 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 		payments := protected.Group("/payments")
 		{
 			payments.POST("/paystack", ProcessPaystackPayment(db))
-			payments.GET("/paystack/callback", PaystackCallback(db))
 
 			payments.GET("", ListUserPayments(db))
 			payments.GET("/:id", GetPaymentStatus(db))
@@ -169,6 +284,12 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			uploads.POST("/file", UploadFile(db))
 		}
 
+		files := protected.Group("/files")
+		{
+			files.GET("/usage", GetStorageUsage(db))
+			files.GET("/:id", GetFile(db))
+		}
+
 		admin := protected.Group("/admin")
 		admin.Use(middleware.AdminMiddleware())
 		{
@@ -182,10 +303,23 @@ func SetupRoutes(router *gin.Engine, db *gorm.DB) {
 			admin.POST("/lab-tests", CreateLabTest(db))
 			admin.PUT("/lab-tests/:id", UpdateLabTest(db))
 			admin.DELETE("/lab-tests/:id", DeleteLabTest(db))
+			admin.GET("/lab-tests/:id/analytes", GetLabTestAnalytes(db))
+			admin.PUT("/lab-tests/:id/analytes", SetLabTestAnalytes(db))
 
 			admin.POST("/health-articles", CreateHealthArticle(db))
 			admin.PUT("/health-articles/:id", UpdateHealthArticle(db))
 			admin.DELETE("/health-articles/:id", DeleteHealthArticle(db))
+
+			admin.GET("/translations", ListContentTranslations(db))
+			admin.PUT("/translations", UpsertContentTranslation(db))
+			admin.DELETE("/translations/:id", DeleteContentTranslation(db))
+
+			admin.GET("/email-templates", ListEmailTemplates(db))
+			admin.GET("/email-templates/:name/preview", PreviewEmailTemplate(db))
+
+			admin.GET("/ai/usage", GetAIUsage(db))
+
+			admin.POST("/consent-documents", PublishConsentDocument(db))
 		}
 	}
 }
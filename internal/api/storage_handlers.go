@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+// ServeLocalFile serves a file from the local blob store when the URL signature is valid.
+// Only available when STORAGE_PROVIDER is local.
+func ServeLocalFile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := config.GetConfig().Storage
+		if cfg.Provider != services.StorageProviderLocal {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "File not found")})
+			return
+		}
+
+		store, err := services.NewLocalBlobStore(&cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to initialize storage service")})
+			return
+		}
+
+		key := strings.TrimPrefix(c.Param("key"), "/")
+		if !store.Verify(key, c.Query("expires"), c.Query("signature")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": tr(c, "Invalid or expired file link")})
+			return
+		}
+
+		file, err := store.Open(c.Request.Context(), key)
+		if errors.Is(err, services.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "File not found")})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to read file")})
+			return
+		}
+		defer file.Close()
+
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Cache-Control", "private, max-age=300")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.DataFromReader(http.StatusOK, -1, contentType, file, nil)
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/models"
+	"github.com/nyumbanicare/internal/services"
+	"gorm.io/gorm"
+)
+
+const (
+	// analysisRetryBackoff is the delay before the second attempt, doubling after each failure
+	analysisRetryBackoff = 2 * time.Second
+	// staleAnalysisAge is how long a result can stay processing before it is treated as interrupted
+	staleAnalysisAge = 15 * time.Minute
+)
+
+var (
+	analysisPool     *services.WorkerPool
+	analysisPoolOnce sync.Once
+)
+
+// testKitAnalysisPool returns the shared worker pool for test kit uploads and analysis
+func testKitAnalysisPool() *services.WorkerPool {
+	analysisPoolOnce.Do(func() {
+		cfg := config.GetConfig().External
+		analysisPool = services.NewWorkerPool(cfg.AnalysisWorkers, cfg.AnalysisQueueSize, analysisRetryBackoff)
+	})
+	return analysisPool
+}
+
+// StartTestKitAnalysisWorkers starts the analysis workers and settles results left
+// processing by a previous run, whose queued jobs were lost when it stopped
+func StartTestKitAnalysisWorkers(db *gorm.DB) {
+	testKitAnalysisPool()
+
+	var stale []models.TestKitResult
+	if err := db.Where("status = ? AND updated_at < ?", models.TestKitResultStatusProcessing, time.Now().Add(-staleAnalysisAge)).
+		Find(&stale).Error; err != nil {
+		fmt.Printf("Failed to load interrupted test kit analyses: %v\n", err)
+		return
+	}
+
+	for i := range stale {
+		var user models.User
+		db.Select("preferred_language").First(&user, "id = ?", stale[i].UserID)
+		settleFailedAnalysis(db, &stale[i], user.PreferredLanguage, fmt.Errorf("analysis interrupted by restart"))
+	}
+	if len(stale) > 0 {
+		fmt.Printf("Settled %d interrupted test kit analyses\n", len(stale))
+	}
+}
+
+// testKitAnalysisJob uploads a test kit photo and analyses it in the background
+type testKitAnalysisJob struct {
+	ResultID uuid.UUID
+	UserID   uuid.UUID
+	Filename string
+	Image    *services.SanitizedImage
+	KitType  string
+	Language string
+
+	imageURL string // set once uploaded so retries do not upload again
+}
+
+func (job *testKitAnalysisJob) asJob(db *gorm.DB) services.Job {
+	return services.Job{
+		Name:        "test kit analysis " + job.ResultID.String(),
+		MaxAttempts: config.GetConfig().External.AnalysisMaxAttempts,
+		Run: func(attempt int) error {
+			return job.run(db, attempt)
+		},
+		OnFailure: func(err error) {
+			var result models.TestKitResult
+			if dbErr := db.First(&result, "id = ?", job.ResultID).Error; dbErr != nil {
+				fmt.Printf("Failed to load test kit result %s after failed analysis: %v\n", job.ResultID, dbErr)
+				return
+			}
+			settleFailedAnalysis(db, &result, job.Language, err)
+		},
+	}
+}
+
+func (job *testKitAnalysisJob) run(db *gorm.DB, attempt int) error {
+	db.Model(&models.TestKitResult{}).Where("id = ?", job.ResultID).Update("analysis_attempts", attempt)
+
+	if job.imageURL == "" {
+		storageSvc, err := services.NewStorageService(&config.GetConfig().Storage)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage service: %w", err)
+		}
+
+		_, imageURL, err := saveFile(db, storageSvc, bytes.NewReader(job.Image.Data), job.Filename, "test_results", job.UserID, true)
+		if err != nil {
+			return fmt.Errorf("failed to upload file: %w", err)
+		}
+		attachFiles(db, models.FileResourceTestKitResult, job.ResultID, job.UserID, imageURL)
+		if err := db.Model(&models.TestKitResult{}).Where("id = ?", job.ResultID).Update("image_url", imageURL).Error; err != nil {
+			return fmt.Errorf("failed to save image URL: %w", err)
+		}
+		job.imageURL = imageURL
+	}
+
+	aiSvc := aiServiceFor(db, job.UserID)
+	analysisResp, err := aiSvc.AnalyzeTestKitResult(&services.TestKitResultRequest{
+		TestKitType:      job.KitType,
+		ImageURL:         job.imageURL,
+		ImageData:        job.Image.Data,
+		ImageContentType: job.Image.ContentType,
+		ImagePrivate:     true,
+		Language:         job.Language,
+	})
+	if err != nil {
+		return err
+	}
+
+	var result models.TestKitResult
+	if err := db.First(&result, "id = ?", job.ResultID).Error; err != nil {
+		return fmt.Errorf("failed to load test kit result: %w", err)
+	}
+
+	result.Result = analysisResp.Result
+	result.AIConfidence = analysisResp.Confidence
+	result.DetectedMarkers = analysisResp.DetectedMarkers
+	result.RecommendedSteps = analysisResp.RecommendedSteps
+	result.Notes = analysisResp.Notes
+	result.KitType = analysisResp.KitType
+	result.AnalysisSource = analysisResp.Source
+	result.AnalysisError = ""
+	if analysisResp.RequiresReview {
+		services.QueueForReview(&result, time.Now())
+	} else {
+		result.Status = models.TestKitResultStatusConfirmed
+	}
+
+	if err := db.Save(&result).Error; err != nil {
+		return fmt.Errorf("failed to save test kit result: %w", err)
+	}
+
+	if err := NotifyTestResultReady(db, result.UserID, &result); err != nil {
+		fmt.Printf("Failed to create test result notification: %v\n", err)
+	}
+	return nil
+}
+
+// settleFailedAnalysis closes out a result whose analysis never succeeded. If the
+// photo was stored a clinician reads it instead; otherwise the user must upload again.
+func settleFailedAnalysis(db *gorm.DB, result *models.TestKitResult, language string, cause error) {
+	fmt.Printf("Test kit analysis for result %s failed: %v\n", result.ID, cause)
+
+	result.AnalysisError = cause.Error()
+	if result.ImageURL != "" {
+		result.Result = "inconclusive"
+		result.AIConfidence = 0
+		result.AnalysisSource = services.AnalysisSourceFallback
+		result.Notes = i18n.T(language, "Automated image analysis is unavailable. A clinician will review this result.")
+		if spec := services.GetKitType(result.KitType); spec != nil {
+			result.RecommendedSteps = i18n.TSlice(language, spec.StandardSteps(result.Result))
+		}
+		services.QueueForReview(result, time.Now())
+	} else {
+		result.Status = models.TestKitResultStatusFailed
+		result.Notes = i18n.T(language, "We could not process your test photo. Please upload it again.")
+	}
+
+	if err := db.Save(result).Error; err != nil {
+		fmt.Printf("Failed to save failed analysis for test kit result %s: %v\n", result.ID, err)
+	}
+}
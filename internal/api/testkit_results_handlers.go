@@ -1,12 +1,16 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/middleware"
 	"github.com/nyumbanicare/internal/models"
 	"github.com/nyumbanicare/internal/services"
 	"gorm.io/gorm"
@@ -16,7 +20,7 @@ func UploadAndAnalyzeTestKitResult(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -25,13 +29,28 @@ func UploadAndAnalyzeTestKitResult(db *gorm.DB) gin.HandlerFunc {
 		testKitType := c.PostForm("test_kit_type")
 
 		if testKitID == "" || testKitType == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Test kit ID and type are required"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Test kit ID and type are required")})
 			return
 		}
 
 		testKitUUID, err := uuid.Parse(testKitID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid test kit ID"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid test kit ID")})
+			return
+		}
+
+		// Resolve the catalog kit type from the kit record, falling back to the submitted type
+		var testKit models.TestKit
+		if err := db.First(&testKit, "id = ?", testKitUUID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit not found")})
+			return
+		}
+		kitSpec := services.LookupKitType(testKit.KitType, testKitType, testKit.Category, testKit.Name)
+		if kitSpec == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":           tr(c, "Unsupported test kit type"),
+				"supported_types": services.KitCatalog,
+			})
 			return
 		}
 
@@ -39,86 +58,152 @@ func UploadAndAnalyzeTestKitResult(db *gorm.DB) gin.HandlerFunc {
 		if orderID != "" {
 			orderUUID, err = uuid.Parse(orderID)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid order ID")})
 				return
 			}
 		}
 
 		file, header, err := c.Request.FormFile("file")
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "No file uploaded")})
 			return
 		}
 		defer file.Close()
 
 		if header.Size > 10*1024*1024 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 10MB)"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "File too large (max 10MB)")})
 			return
 		}
 
-		storageSvc, err := services.NewStorageService(&config.GetConfig().Storage)
+		imageData, err := io.ReadAll(file)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize storage service"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unable to read uploaded file")})
 			return
 		}
-
-		fileURL, err := storageSvc.UploadFile(header, "test_results")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file"})
+		if !checkStorageQuota(c, db, userID.(uuid.UUID), int64(len(imageData))) {
 			return
 		}
 
-		aiSvc := services.NewAIService(&config.GetConfig().External)
-
-		analysisReq := &services.TestKitResultRequest{
-			TestKitType: testKitType,
-			ImageURL:    fileURL,
+		// Repeated submissions with the same Idempotency-Key return the original result.
+		// Keys stay used after their result is deleted, as the unique index still holds them.
+		idempotencyKey := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if len(idempotencyKey) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Idempotency-Key is too long")})
+			return
+		}
+		requestHash := testKitUploadHash(testKitID, orderID, testKitType, imageData)
+		if idempotencyKey != "" {
+			var existing models.TestKitResult
+			if err := db.Unscoped().Where("user_id = ? AND idempotency_key = ?", userID, idempotencyKey).First(&existing).Error; err == nil {
+				replayTestKitUpload(c, &existing, requestHash)
+				return
+			}
 		}
 
-		analysisResp, err := aiSvc.AnalyzeTestKitResult(analysisReq)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze test kit result"})
+		report, sanitized := services.CheckImageQuality(imageData, header.Header.Get("Content-Type"), requestLanguage(c))
+		if !report.Accepted {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   tr(c, "Image quality check failed"),
+				"issues":  report.Issues,
+				"quality": report,
+			})
 			return
 		}
 
 		result := models.TestKitResult{
-			ID:               uuid.New(),
-			UserID:           userID.(uuid.UUID),
-			TestKitID:        testKitUUID,
-			ImageURL:         fileURL,
-			Result:           analysisResp.Result,
-			AIConfidence:     analysisResp.Confidence,
-			DetectedMarkers:  analysisResp.DetectedMarkers,
-			RecommendedSteps: analysisResp.RecommendedSteps,
-			Notes:            analysisResp.Notes,
-			Status:           "pending",
+			ID:          uuid.New(),
+			UserID:      userID.(uuid.UUID),
+			TestKitID:   testKitUUID,
+			KitType:     kitSpec.Code,
+			Status:      models.TestKitResultStatusProcessing,
+			RequestHash: requestHash,
+		}
+		if idempotencyKey != "" {
+			result.IdempotencyKey = &idempotencyKey
 		}
-
 		if orderID != "" {
 			result.OrderID = orderUUID
 		}
-		// Save to database
+
 		if err := db.Create(&result).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save test kit result"})
+			// A concurrent request with the same key may have been saved first
+			if idempotencyKey != "" {
+				var existing models.TestKitResult
+				if db.Unscoped().Where("user_id = ? AND idempotency_key = ?", userID, idempotencyKey).First(&existing).Error == nil {
+					replayTestKitUpload(c, &existing, requestHash)
+					return
+				}
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save test kit result")})
 			return
 		}
 
-		if err := NotifyTestResultReady(db, userID.(uuid.UUID), &result); err != nil {
-			fmt.Printf("Failed to create test result notification: %v\n", err)
+		job := &testKitAnalysisJob{
+			ResultID: result.ID,
+			UserID:   result.UserID,
+			Filename: header.Filename,
+			Image:    sanitized,
+			KitType:  kitSpec.Code,
+			Language: requestLanguage(c),
+		}
+		if err := testKitAnalysisPool().Submit(job.asJob(db)); err != nil {
+			db.Unscoped().Delete(&result)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": tr(c, "Too many test kits are being analyzed. Please try again shortly.")})
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Test kit result analyzed successfully",
-			"result":  result,
+		c.Header("Location", "/api/v1/test-kits/results/"+result.ID.String())
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":   "Test kit result received and is being analyzed",
+			"result_id": result.ID,
+			"status":    result.Status,
+			"result":    result,
 		})
 	}
 }
 
+// testKitUploadHash fingerprints an upload so a reused Idempotency-Key with different content is rejected
+func testKitUploadHash(testKitID, orderID, testKitType string, imageData []byte) string {
+	hash := sha256.New()
+	for _, field := range []string{testKitID, orderID, testKitType} {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	hash.Write(imageData)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayTestKitUpload answers a repeated submission with the result created by the first one
+func replayTestKitUpload(c *gin.Context, existing *models.TestKitResult, requestHash string) {
+	if existing.DeletedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Idempotency-Key was used for a test kit result that has been deleted")})
+		return
+	}
+	if existing.RequestHash != requestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": tr(c, "Idempotency-Key was already used for a different upload")})
+		return
+	}
+
+	status := http.StatusOK
+	if existing.Status == models.TestKitResultStatusProcessing {
+		status = http.StatusAccepted
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Header("Location", "/api/v1/test-kits/results/"+existing.ID.String())
+	c.JSON(status, gin.H{
+		"message":   "Test kit result already submitted",
+		"result_id": existing.ID,
+		"status":    existing.Status,
+		"result":    existing,
+	})
+}
+
 func GetTestKitResult(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 		resultID := c.Param("id")
@@ -132,7 +217,7 @@ func GetTestKitResult(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.First(&result).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test kit result not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit result not found")})
 			return
 		}
 
@@ -140,6 +225,51 @@ func GetTestKitResult(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// @Summary Delete test kit result
+// @Description Delete a test kit result and its uploaded photo
+// @Tags TestKitResults
+// @Produce json
+// @Param id path string true "Test Kit Result ID"
+// @Success 200 {object} map[string]string "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Still being analyzed"
+// @Router /api/v1/test-kits/results/{id} [delete]
+// @Security Bearer
+func DeleteTestKitResult(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
+			return
+		}
+
+		var result models.TestKitResult
+		query := db.Where("id = ?", c.Param("id"))
+		role, _ := c.Get("role")
+		if role != "admin" {
+			query = query.Where("user_id = ?", userID)
+		}
+		if err := query.First(&result).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit result not found")})
+			return
+		}
+
+		// The analysis worker still writes to the result and its photo
+		if result.Status == models.TestKitResultStatusProcessing {
+			c.JSON(http.StatusConflict, gin.H{"error": tr(c, "Test kit result is still being analyzed")})
+			return
+		}
+
+		if err := db.Delete(&result).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete test kit result")})
+			return
+		}
+		deleteResourceFiles(db, models.FileResourceTestKitResult, result.ID)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Test kit result deleted successfully"})
+	}
+}
+
 // @Summary List user's test kit results
 // @Description Get a list of all test kit results for the current user
 // @Tags TestKitResults
@@ -155,7 +285,7 @@ func ListTestKitResults(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
@@ -178,7 +308,7 @@ func ListTestKitResults(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := query.Find(&results).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch test kit results"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch test kit results")})
 			return
 		}
 
@@ -187,7 +317,7 @@ func ListTestKitResults(db *gorm.DB) gin.HandlerFunc {
 }
 
 // @Summary Update test kit result
-// @Description Update a test kit result (admin or healthcare professional only)
+// @Description Record a clinician's review of a test kit result they have claimed from the review queue. A result different from the AI reading overrides it; otherwise the reading is confirmed.
 // @Tags TestKitResults
 // @Accept json
 // @Produce json
@@ -197,6 +327,7 @@ func ListTestKitResults(db *gorm.DB) gin.HandlerFunc {
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Not claimed by you"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/test-kits/results/{id} [put]
 // @Security Bearer
@@ -204,27 +335,27 @@ func UpdateTestKitResult(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "User not authenticated")})
 			return
 		}
 
 		role, _ := c.Get("role")
-		if role != "admin" && role != "healthcare_professional" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Insufficient permissions"})
+		if !middleware.IsClinicianRole(role) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": tr(c, "Insufficient permissions")})
 			return
 		}
 
 		resultID := c.Param("id")
 		var existingResult models.TestKitResult
 		if err := db.First(&existingResult, "id = ?", resultID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Test kit result not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Test kit result not found")})
 			return
 		}
 
 		var updateData struct {
 			Result           string   `json:"result"`
 			ReviewNotes      string   `json:"review_notes"`
-			Status           string   `json:"status"`
+			Status           string   `json:"status"` // only reviewed, which the decision records anyway
 			RecommendedSteps []string `json:"recommended_steps"`
 		}
 
@@ -232,32 +363,31 @@ func UpdateTestKitResult(db *gorm.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Update only allowed fields
-		updates := map[string]interface{}{
-			"reviewed_by":  userID,
-			"review_notes": updateData.ReviewNotes,
-			"status":       updateData.Status,
-		}
-
-		// Only update result if provided
-		if updateData.Result != "" {
-			updates["result"] = updateData.Result
+		if updateData.Status != "" && updateData.Status != models.TestKitResultStatusReviewed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Test kit results can only be marked reviewed")})
+			return
 		}
 
-		// Only update recommended steps if provided
-		if len(updateData.RecommendedSteps) > 0 {
-			updates["recommended_steps"] = updateData.RecommendedSteps
+		// This is the review decision under its older route: a different result
+		// overrides the AI reading, anything else confirms it
+		req := ReviewDecisionRequest{
+			Decision:         "confirm",
+			ReviewNotes:      updateData.ReviewNotes,
+			RecommendedSteps: updateData.RecommendedSteps,
 		}
-
-		if err := db.Model(&existingResult).Updates(updates).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update test kit result"})
-			return
+		if updateData.Result != "" {
+			result, ok := validKitResult(existingResult.KitType, updateData.Result)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Invalid test result value")})
+				return
+			}
+			if result != existingResult.Result {
+				req.Decision = "override"
+				req.Result = result
+			}
 		}
 
-		// Reload the updated record
-		db.First(&existingResult, "id = ?", resultID)
-		c.JSON(http.StatusOK, existingResult)
+		recordReviewDecision(c, db, userID, &existingResult, req)
 	}
 }
 
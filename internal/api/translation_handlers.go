@@ -0,0 +1,193 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// loadTranslations returns the translated fields for the given records, keyed by resource ID then field
+func loadTranslations(db *gorm.DB, resourceType, lang string, ids []uuid.UUID) map[uuid.UUID]map[string]string {
+	translations := make(map[uuid.UUID]map[string]string)
+	if lang == i18n.DefaultLanguage || len(ids) == 0 {
+		return translations
+	}
+
+	var rows []models.ContentTranslation
+	if err := db.Where("resource_type = ? AND language = ? AND resource_id IN ?", resourceType, lang, ids).Find(&rows).Error; err != nil {
+		return translations
+	}
+
+	for _, row := range rows {
+		if row.Value == "" {
+			continue
+		}
+		if translations[row.ResourceID] == nil {
+			translations[row.ResourceID] = make(map[string]string)
+		}
+		translations[row.ResourceID][row.Field] = row.Value
+	}
+
+	return translations
+}
+
+// localizeHealthArticles replaces article text with translations, keeping English where none exist
+func localizeHealthArticles(db *gorm.DB, lang string, articles []models.HealthArticle) {
+	ids := make([]uuid.UUID, len(articles))
+	for i, article := range articles {
+		ids[i] = article.ID
+	}
+
+	translations := loadTranslations(db, models.TranslationResourceHealthArticle, lang, ids)
+	for i := range articles {
+		fields := translations[articles[i].ID]
+		if value, ok := fields["title"]; ok {
+			articles[i].Title = value
+		}
+		if value, ok := fields["summary"]; ok {
+			articles[i].Summary = value
+		}
+		if value, ok := fields["content"]; ok {
+			articles[i].Content = value
+		}
+	}
+}
+
+// localizeTestKits replaces test kit instructions with translations, keeping English where none exist
+func localizeTestKits(db *gorm.DB, lang string, testKits []models.TestKit) {
+	ids := make([]uuid.UUID, len(testKits))
+	for i, testKit := range testKits {
+		ids[i] = testKit.ID
+	}
+
+	translations := loadTranslations(db, models.TranslationResourceTestKit, lang, ids)
+	for i := range testKits {
+		if value, ok := translations[testKits[i].ID]["instructions"]; ok {
+			testKits[i].Instructions = value
+		}
+	}
+}
+
+// localizeLabTests replaces lab test preparation instructions with translations, keeping English where none exist
+func localizeLabTests(db *gorm.DB, lang string, labTests []models.LabTest) {
+	ids := make([]uuid.UUID, len(labTests))
+	for i, labTest := range labTests {
+		ids[i] = labTest.ID
+	}
+
+	translations := loadTranslations(db, models.TranslationResourceLabTest, lang, ids)
+	for i := range labTests {
+		if value, ok := translations[labTests[i].ID]["preparation_instructions"]; ok {
+			labTests[i].PreparationInstructions = value
+		}
+	}
+}
+
+func isTranslatableField(resourceType, field string) bool {
+	for _, allowed := range models.TranslatableFields[resourceType] {
+		if allowed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// @Summary Create or update a content translation
+// @Description Store a translated value for a health article, test kit or lab test field
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.ContentTranslation "Saved translation"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Router /api/v1/admin/translations [put]
+// @Security Bearer
+func UpsertContentTranslation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			ResourceType string    `json:"resource_type" binding:"required"`
+			ResourceID   uuid.UUID `json:"resource_id" binding:"required"`
+			Language     string    `json:"language" binding:"required"`
+			Field        string    `json:"field" binding:"required"`
+			Value        string    `json:"value" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !i18n.IsSupported(req.Language) || i18n.Normalize(req.Language) == i18n.DefaultLanguage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unsupported language")})
+			return
+		}
+
+		if !isTranslatableField(req.ResourceType, req.Field) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": tr(c, "Unsupported translation field")})
+			return
+		}
+
+		translation := models.ContentTranslation{
+			ResourceType: req.ResourceType,
+			ResourceID:   req.ResourceID,
+			Language:     i18n.Normalize(req.Language),
+			Field:        req.Field,
+			Value:        req.Value,
+		}
+
+		err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "resource_type"}, {Name: "resource_id"}, {Name: "language"}, {Name: "field"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at", "deleted_at"}),
+		}).Create(&translation).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to save translation")})
+			return
+		}
+
+		c.JSON(http.StatusOK, translation)
+	}
+}
+
+func ListContentTranslations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		query := db.Model(&models.ContentTranslation{})
+		if resourceType := c.Query("resource_type"); resourceType != "" {
+			query = query.Where("resource_type = ?", resourceType)
+		}
+		if resourceID := c.Query("resource_id"); resourceID != "" {
+			query = query.Where("resource_id = ?", resourceID)
+		}
+		if language := c.Query("language"); language != "" {
+			query = query.Where("language = ?", i18n.Normalize(language))
+		}
+
+		var translations []models.ContentTranslation
+		if err := query.Order("resource_type, resource_id, field").Find(&translations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to fetch translations")})
+			return
+		}
+
+		c.JSON(http.StatusOK, translations)
+	}
+}
+
+func DeleteContentTranslation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		result := db.Delete(&models.ContentTranslation{}, "id = ?", id)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": tr(c, "Failed to delete translation")})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": tr(c, "Translation not found")})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Translation deleted successfully"})
+	}
+}
@@ -37,7 +37,7 @@ type ServerConfig struct {
 }
 
 type StorageConfig struct {
-	Provider     string
+	Provider     string // cloudinary, local, s3
 	CloudName    string
 	APIKey       string
 	APISecret    string
@@ -46,6 +46,20 @@ type StorageConfig struct {
 	Region       string
 	AccessKey    string
 	SecretKey    string
+
+	Endpoint      string // S3-compatible endpoint, e.g. http://localhost:9000 for MinIO
+	PathStyle     bool   // address buckets as endpoint/bucket/key instead of bucket.endpoint/key
+	LocalPath     string // root directory for the local provider
+	PublicBaseURL string // prefix for signed local file URLs, e.g. https://api.example.com
+	SigningKey    string // HMAC key for signed local file URLs
+
+	SignedURLTTLSeconds    int // lifetime of download URLs for private files
+	UserQuotaMB            int // total storage each user may hold; 0 disables the quota
+	UnattachedFileTTLHours int // uploads never linked to a record are removed after this long
+
+	ScanProvider       string // clamav, or none to store uploads unscanned
+	ClamAVAddress      string // clamd TCP address, e.g. localhost:3310
+	ScanTimeoutSeconds int
 }
 
 type EmailConfig struct {
@@ -68,6 +82,35 @@ type ExternalConfig struct {
 	ChatGPTAPIKey   string
 	ChatGPTModel    string
 	ChatGPTEndpoint string
+
+	AIProvider     string // openai, openai_compatible, rules
+	SymptomsModel  string
+	AnalyticsModel string
+	VisionModel    string
+
+	AITimeoutSeconds                int
+	AIMaxRetries                    int
+	AICircuitBreakerThreshold       int
+	AICircuitBreakerCooldownSeconds int
+	AIInputCostPer1K                float64 // USD, overrides the built-in price table when set
+	AIOutputCostPer1K               float64
+
+	AnalysisWorkers     int // background workers for test kit uploads and analysis
+	AnalysisQueueSize   int
+	AnalysisMaxAttempts int
+
+	OCRProvider       string // tesseract, or none to rely on the vision model alone
+	TesseractPath     string
+	OCRLanguages      string // tesseract language codes, e.g. eng+swa
+	OCRTimeoutSeconds int
+
+	DrugDatabasePath string // JSON drug database replacing the bundled one
+
+	DoseTimezone         string // IANA time zone that medication dose times are scheduled in
+	DoseMissedAfterHours int    // hours after a scheduled dose before an unlogged dose counts as missed
+
+	DataExportTTLHours       int // hours a patient's data export archive can be downloaded
+	AccountDeletionGraceDays int // days before a requested account deletion takes effect, while it can be cancelled
 }
 
 func Load() (*Config, error) {
@@ -99,6 +142,20 @@ func Load() (*Config, error) {
 			Region:       getEnv("STORAGE_REGION", "us-east-1"),
 			AccessKey:    getEnv("STORAGE_ACCESS_KEY", ""),
 			SecretKey:    getEnv("STORAGE_SECRET_KEY", ""),
+
+			Endpoint:      getEnv("STORAGE_ENDPOINT", ""),
+			PathStyle:     getEnvAsBool("STORAGE_PATH_STYLE", os.Getenv("STORAGE_ENDPOINT") != ""),
+			LocalPath:     getEnv("STORAGE_LOCAL_PATH", "./uploads"),
+			PublicBaseURL: getEnv("STORAGE_PUBLIC_BASE_URL", ""),
+			SigningKey:    getEnv("STORAGE_SIGNING_KEY", getEnv("JWT_SECRET_KEY", "")),
+
+			SignedURLTTLSeconds:    getEnvAsInt("STORAGE_SIGNED_URL_TTL_SECONDS", 300),
+			UserQuotaMB:            getEnvAsInt("STORAGE_USER_QUOTA_MB", 200),
+			UnattachedFileTTLHours: getEnvAsInt("STORAGE_UNATTACHED_FILE_TTL_HOURS", 24),
+
+			ScanProvider:       getEnv("UPLOAD_SCAN_PROVIDER", "none"),
+			ClamAVAddress:      getEnv("CLAMAV_ADDRESS", "localhost:3310"),
+			ScanTimeoutSeconds: getEnvAsInt("UPLOAD_SCAN_TIMEOUT_SECONDS", 30),
 		},
 		Email: EmailConfig{
 			Provider:  getEnv("EMAIL_PROVIDER", "smtp"),
@@ -116,6 +173,35 @@ func Load() (*Config, error) {
 			ChatGPTAPIKey:   getEnv("CHATGPT_API_KEY", ""),
 			ChatGPTModel:    getEnv("CHATGPT_MODEL", "gpt-4"),
 			ChatGPTEndpoint: getEnv("CHATGPT_ENDPOINT", "https://api.openai.com/v1/chat/completions"),
+
+			AIProvider:     getEnv("AI_PROVIDER", "openai"),
+			SymptomsModel:  getEnv("AI_SYMPTOMS_MODEL", getEnv("CHATGPT_MODEL", "gpt-4")),
+			AnalyticsModel: getEnv("AI_ANALYTICS_MODEL", getEnv("CHATGPT_MODEL", "gpt-4")),
+			VisionModel:    getEnv("AI_VISION_MODEL", "gpt-4o"),
+
+			AITimeoutSeconds:                getEnvAsInt("AI_TIMEOUT_SECONDS", 30),
+			AIMaxRetries:                    getEnvAsInt("AI_MAX_RETRIES", 2),
+			AICircuitBreakerThreshold:       getEnvAsInt("AI_CIRCUIT_BREAKER_THRESHOLD", 5),
+			AICircuitBreakerCooldownSeconds: getEnvAsInt("AI_CIRCUIT_BREAKER_COOLDOWN_SECONDS", 60),
+			AIInputCostPer1K:                getEnvAsFloat("AI_INPUT_COST_PER_1K", 0),
+			AIOutputCostPer1K:               getEnvAsFloat("AI_OUTPUT_COST_PER_1K", 0),
+
+			AnalysisWorkers:     getEnvAsInt("ANALYSIS_WORKERS", 4),
+			AnalysisQueueSize:   getEnvAsInt("ANALYSIS_QUEUE_SIZE", 100),
+			AnalysisMaxAttempts: getEnvAsInt("ANALYSIS_MAX_ATTEMPTS", 3),
+
+			OCRProvider:       getEnv("OCR_PROVIDER", "none"),
+			TesseractPath:     getEnv("TESSERACT_PATH", "tesseract"),
+			OCRLanguages:      getEnv("OCR_LANGUAGES", "eng"),
+			OCRTimeoutSeconds: getEnvAsInt("OCR_TIMEOUT_SECONDS", 30),
+
+			DrugDatabasePath: getEnv("DRUG_DATABASE_PATH", ""),
+
+			DoseTimezone:         getEnv("DOSE_TIMEZONE", "Africa/Nairobi"),
+			DoseMissedAfterHours: getEnvAsInt("DOSE_MISSED_AFTER_HOURS", 4),
+
+			DataExportTTLHours:       getEnvAsInt("DATA_EXPORT_TTL_HOURS", 168),
+			AccountDeletionGraceDays: getEnvAsInt("ACCOUNT_DELETION_GRACE_DAYS", 30),
 		},
 	}, nil
 }
@@ -152,3 +238,27 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+		return floatValue
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	if boolValue, err := strconv.ParseBool(value); err == nil {
+		return boolValue
+	}
+	return defaultValue
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/nyumbanicare/internal/config"
@@ -52,11 +53,40 @@ func autoMigrate(db *gorm.DB) error {
 			fmt.Printf("Successfully migrated %T\n", model)
 		}
 	}
+	mergeDuplicateMedicalRecords(db)
+
 	relatedModels := []interface{}{
 		&models.TestKitOrder{},
 		&models.TestKitResult{},
+		&models.SymptomCheck{},
 		&models.Payment{},
 		&models.MedicalRecord{},
+		&models.Medication{},
+		&models.TestResult{},
+		&models.Consultation{},
+		&models.MedicationDose{},
+		&models.TelehealthSession{},
+		&models.LabTest{},
+		&models.LabBooking{},
+		&models.LabResult{},
+		&models.LabAnalyte{},
+		&models.LabReferenceRange{},
+		&models.LabResultValue{},
+		&models.Prescription{},
+		&models.PrescriptionMedication{},
+		&models.ContentTranslation{},
+		&models.File{},
+		&models.FileAccessLog{},
+		&models.FileScanEvent{},
+		&models.PrescriptionExtraction{},
+		&models.MedicationSafetyCheck{},
+		&models.RefillRequest{},
+		&models.DataExport{},
+		&models.AccountDeletion{},
+		&models.ConsentDocument{},
+		&models.ConsentGrant{},
+		&models.CaregiverLink{},
+		&models.CaregiverAuditEntry{},
 	}
 
 	for _, model := range relatedModels {
@@ -137,9 +167,132 @@ func autoMigrate(db *gorm.DB) error {
 		}
 	}
 
+	db.Model(&models.ConsentDocument{}).Count(&count)
+	if count == 0 {
+		fmt.Println("Creating default consent documents...")
+		for _, document := range defaultConsentDocuments {
+			if err := db.Create(&document).Error; err != nil {
+				fmt.Printf("Warning: Failed to create %s consent document: %v\n", document.Purpose, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// defaultConsentDocuments are the first version of each consent, published on a new
+// database so patients can be asked for consent before an admin has written their own
+var defaultConsentDocuments = []models.ConsentDocument{
+	{
+		Purpose: models.ConsentPurposeAIAnalysis,
+		Version: 1,
+		Title:   "AI analysis of your health information",
+		Body: "Nyumbani Care can use an AI service run by a third party to analyse the symptoms you report, " +
+			"read photos of your test kits and read your prescriptions. Only the information needed for each " +
+			"analysis is sent, and the AI service may process it outside Kenya. Without this consent your " +
+			"information stays on our platform: symptoms are assessed with our built-in rules and test kit " +
+			"photos and prescriptions are read by our clinicians and pharmacists. You can withdraw this consent at any time.",
+	},
+	{
+		Purpose: models.ConsentPurposeProviderSharing,
+		Version: 1,
+		Title:   "Sharing your medical record with a clinician",
+		Body: "You can share your medical record, including your allergies, conditions, medications, test results " +
+			"and consultations, with a clinician you choose. Only the clinicians you name can see it, and you can " +
+			"stop sharing with any of them at any time.",
+	},
+	{
+		Purpose: models.ConsentPurposeResearch,
+		Version: 1,
+		Title:   "Use of your data in health research",
+		Body: "Nyumbani Care may include your health information, with your name and contact details removed, in " +
+			"research to improve healthcare in Kenya. You can withdraw this consent at any time; data already " +
+			"included in completed research cannot be withdrawn from it.",
+	},
+	{
+		Purpose: models.ConsentPurposeMarketing,
+		Version: 1,
+		Title:   "Offers and news from Nyumbani Care",
+		Body: "Nyumbani Care may send you news about our services, health campaigns and offers by app notification, " +
+			"email or SMS. You can withdraw this consent at any time.",
+	},
+}
+
+// mergeDuplicateMedicalRecords folds each patient's extra medical records into their
+// oldest one, moving medications, test results and consultations across, so the
+// one-record-per-patient index can be created
+func mergeDuplicateMedicalRecords(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.MedicalRecord{}) {
+		return
+	}
+
+	var userIDs []uuid.UUID
+	if err := db.Model(&models.MedicalRecord{}).Group("user_id").Having("COUNT(*) > 1").Pluck("user_id", &userIDs).Error; err != nil {
+		fmt.Printf("Warning: Failed to find duplicate medical records: %v\n", err)
+		return
+	}
+
+	children := []interface{}{&models.Medication{}, &models.TestResult{}, &models.Consultation{}}
+	for _, userID := range userIDs {
+		var records []models.MedicalRecord
+		if err := db.Where("user_id = ?", userID).Order("created_at, id").Find(&records).Error; err != nil || len(records) < 2 {
+			continue
+		}
+
+		keep := records[0]
+		var duplicateIDs []uuid.UUID
+		for _, record := range records[1:] {
+			duplicateIDs = append(duplicateIDs, record.ID)
+			if keep.BloodType == "" {
+				keep.BloodType = record.BloodType
+			}
+			keep.Allergies = mergeTerms(keep.Allergies, record.Allergies)
+			keep.ChronicConditions = mergeTerms(keep.ChronicConditions, record.ChronicConditions)
+			if history := strings.TrimSpace(record.FamilyHistory); history != "" && !strings.Contains(keep.FamilyHistory, history) {
+				keep.FamilyHistory = strings.TrimSpace(keep.FamilyHistory + "\n" + history)
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, child := range children {
+				if !tx.Migrator().HasTable(child) {
+					continue
+				}
+				if err := tx.Unscoped().Model(child).Where("medical_record_id IN ?", duplicateIDs).
+					Update("medical_record_id", keep.ID).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&keep).Select("BloodType", "Allergies", "ChronicConditions", "FamilyHistory").Updates(&keep).Error; err != nil {
+				return err
+			}
+			return tx.Where("id IN ?", duplicateIDs).Delete(&models.MedicalRecord{}).Error
+		})
+		if err != nil {
+			fmt.Printf("Warning: Failed to merge medical records of user %s: %v\n", userID, err)
+		} else {
+			fmt.Printf("Merged %d duplicate medical records of user %s\n", len(duplicateIDs), userID)
+		}
+	}
+}
+
+// mergeTerms appends the terms not already listed, ignoring case
+func mergeTerms(list, terms []string) []string {
+	for _, term := range terms {
+		found := false
+		for _, existing := range list {
+			if strings.EqualFold(existing, term) {
+				found = true
+				break
+			}
+		}
+		if !found && strings.TrimSpace(term) != "" {
+			list = append(list, term)
+		}
+	}
+	return list
+}
+
 func createHealthArticlesTable(db *gorm.DB) {
 	var count int64
 	db.Raw("SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = 'health_articles'").Count(&count)
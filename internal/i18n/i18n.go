@@ -0,0 +1,118 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const DefaultLanguage = "en"
+
+// SupportedLanguages lists the languages with a message catalog. English is the source language.
+var SupportedLanguages = []string{"en", "sw"}
+
+// catalogs maps a language to its translations, keyed by the English source message
+var catalogs = map[string]map[string]string{
+	"sw": swahiliMessages,
+}
+
+// Normalize maps a language tag such as "sw-KE" to a supported language, defaulting to English
+func Normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		lang = lang[:i]
+	}
+	for _, supported := range SupportedLanguages {
+		if lang == supported {
+			return supported
+		}
+	}
+	return DefaultLanguage
+}
+
+// IsSupported reports whether lang (after normalization of region subtags) has a catalog
+func IsSupported(lang string) bool {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i != -1 {
+		lang = lang[:i]
+	}
+	for _, supported := range SupportedLanguages {
+		if lang == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate picks the best supported language from an Accept-Language header value
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang    string
+		quality float64
+		order   int
+	}
+
+	var candidates []candidate
+	for i, part := range strings.Split(acceptLanguage, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		lang := part
+		quality := 1.0
+		if semi := strings.Index(part, ";"); semi != -1 {
+			lang = strings.TrimSpace(part[:semi])
+			for _, param := range strings.Split(part[semi+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						quality = q
+					}
+				}
+			}
+		}
+
+		if quality > 0 && IsSupported(lang) {
+			candidates = append(candidates, candidate{lang: Normalize(lang), quality: quality, order: i})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].quality != candidates[j].quality {
+			return candidates[i].quality > candidates[j].quality
+		}
+		return candidates[i].order < candidates[j].order
+	})
+
+	return candidates[0].lang
+}
+
+// T translates an English source message, falling back to the message itself
+func T(lang, message string) string {
+	if catalog, ok := catalogs[Normalize(lang)]; ok {
+		if translated, ok := catalog[message]; ok {
+			return translated
+		}
+	}
+	return message
+}
+
+// Tf translates a format string and applies the arguments
+func Tf(lang, format string, args ...interface{}) string {
+	return fmt.Sprintf(T(lang, format), args...)
+}
+
+// TSlice translates each message in a slice
+func TSlice(lang string, messages []string) []string {
+	translated := make([]string, len(messages))
+	for i, message := range messages {
+		translated[i] = T(lang, message)
+	}
+	return translated
+}
@@ -0,0 +1,500 @@
+package i18n
+
+// swahiliMessages holds the Swahili catalog, keyed by the English source message
+var swahiliMessages = map[string]string{
+	// Authentication and authorization
+	"Admin access required":                        "Ruhusa ya msimamizi inahitajika",
+	"Authorization header is required":             "Kichwa cha idhini kinahitajika",
+	"Invalid authorization header format":          "Muundo wa kichwa cha idhini si sahihi",
+	"Invalid token":                                "Tokeni si sahihi",
+	"Invalid token claims":                         "Madai ya tokeni si sahihi",
+	"Invalid credentials":                          "Taarifa za kuingia si sahihi",
+	"Insufficient permissions":                     "Huna ruhusa ya kutosha",
+	"User not authenticated":                       "Mtumiaji hajathibitishwa",
+	"User role not found":                          "Wadhifa wa mtumiaji haukupatikana",
+	"User already exists":                          "Mtumiaji tayari yupo",
+	"User not found":                               "Mtumiaji hakupatikana",
+	"Failed to create user":                        "Imeshindwa kuunda mtumiaji",
+	"Failed to update user":                        "Imeshindwa kusasisha mtumiaji",
+	"Failed to fetch users":                        "Imeshindwa kupata watumiaji",
+	"Failed to generate token":                     "Imeshindwa kutengeneza tokeni",
+	"Failed to process password":                   "Imeshindwa kuchakata nenosiri",
+	"Invalid user ID format":                       "Muundo wa kitambulisho cha mtumiaji si sahihi",
+	"Invalid user ID type":                         "Aina ya kitambulisho cha mtumiaji si sahihi",
+	"Unsupported language":                         "Lugha haitumiki",
+	"Invalid date of birth format. Use YYYY-MM-DD": "Muundo wa tarehe ya kuzaliwa si sahihi. Tumia YYYY-MM-DD",
+
+	// Validation
+	"Invalid ID format":                                    "Muundo wa kitambulisho si sahihi",
+	"Invalid request format":                               "Muundo wa ombi si sahihi",
+	"Invalid date format":                                  "Muundo wa tarehe si sahihi",
+	"Invalid email format":                                 "Muundo wa barua pepe si sahihi",
+	"Invalid Kenyan phone number format":                   "Muundo wa nambari ya simu ya Kenya si sahihi",
+	"Quantity must be between 1 and 10":                    "Idadi lazima iwe kati ya 1 na 10",
+	"Unable to read request body":                          "Imeshindwa kusoma maudhui ya ombi",
+	"Appointment must be scheduled for a future date":      "Miadi lazima ipangwe kwa tarehe ijayo",
+	"Appointments must be scheduled between 8 AM and 8 PM": "Miadi lazima ipangwe kati ya saa 2 asubuhi na saa 2 usiku",
+	"Duration must be between 15 and 120 minutes":          "Muda lazima uwe kati ya dakika 15 na 120",
+
+	// Test kits and orders
+	"Test kit not found":                  "Kifaa cha kupima hakikupatikana",
+	"Test kit ID and type are required":   "Kitambulisho na aina ya kifaa cha kupima vinahitajika",
+	"Invalid test kit ID":                 "Kitambulisho cha kifaa cha kupima si sahihi",
+	"Failed to fetch test kits":           "Imeshindwa kupata vifaa vya kupima",
+	"Failed to create test kit":           "Imeshindwa kuunda kifaa cha kupima",
+	"Failed to update test kit":           "Imeshindwa kusasisha kifaa cha kupima",
+	"Failed to delete test kit":           "Imeshindwa kufuta kifaa cha kupima",
+	"Insufficient stock":                  "Bidhaa haitoshi katika stoo",
+	"Order not found":                     "Oda haikupatikana",
+	"Invalid order ID":                    "Kitambulisho cha oda si sahihi",
+	"Failed to create order":              "Imeshindwa kuunda oda",
+	"Failed to fetch orders":              "Imeshindwa kupata oda",
+	"Failed to update order":              "Imeshindwa kusasisha oda",
+	"Failed to update order status":       "Imeshindwa kusasisha hali ya oda",
+	"Test result not found":               "Majibu ya kipimo hayakupatikana",
+	"Test kit result not found":           "Majibu ya kifaa cha kupima hayakupatikana",
+	"Failed to create test result":        "Imeshindwa kuunda majibu ya kipimo",
+	"Failed to create test result record": "Imeshindwa kuhifadhi rekodi ya majibu ya kipimo",
+	"Failed to fetch test results":        "Imeshindwa kupata majibu ya vipimo",
+	"Failed to update test result":        "Imeshindwa kusasisha majibu ya kipimo",
+	"Failed to fetch test kit results":    "Imeshindwa kupata majibu ya vifaa vya kupima",
+	"Failed to save test kit result":      "Imeshindwa kuhifadhi majibu ya kifaa cha kupima",
+	"Failed to update test kit result":    "Imeshindwa kusasisha majibu ya kifaa cha kupima",
+	"Failed to analyze test kit result":   "Imeshindwa kuchambua majibu ya kifaa cha kupima",
+
+	// Files
+	"Unable to read uploaded file":         "Imeshindwa kusoma faili iliyopakiwa",
+	"No file uploaded":                     "Hakuna faili lililopakiwa",
+	"File too large (max 10MB)":            "Faili ni kubwa mno (kiwango cha juu ni MB 10)",
+	"Failed to upload file":                "Imeshindwa kupakia faili",
+	"Failed to initialize storage service": "Imeshindwa kuanzisha huduma ya hifadhi",
+
+	// Medical records and consultations
+	"Medical record not found":        "Rekodi ya matibabu haikupatikana",
+	"Invalid record data":             "Taarifa za rekodi si sahihi",
+	"Failed to create medical record": "Imeshindwa kuunda rekodi ya matibabu",
+	"Failed to fetch medical records": "Imeshindwa kupata rekodi za matibabu",
+	"Failed to update medical record": "Imeshindwa kusasisha rekodi ya matibabu",
+	"Consultation not found":          "Ushauri haukupatikana",
+	"Failed to create consultation":   "Imeshindwa kuunda ushauri",
+	"Failed to fetch consultations":   "Imeshindwa kupata mashauriano",
+	"Failed to update consultation":   "Imeshindwa kusasisha ushauri",
+
+	// Prescriptions, labs and telehealth
+	"Prescription not found":              "Agizo la dawa halikupatikana",
+	"Failed to create prescription":       "Imeshindwa kuunda agizo la dawa",
+	"Failed to fetch prescriptions":       "Imeshindwa kupata maagizo ya dawa",
+	"Failed to update prescription":       "Imeshindwa kusasisha agizo la dawa",
+	"Lab test not found":                  "Kipimo cha maabara hakikupatikana",
+	"Failed to fetch lab tests":           "Imeshindwa kupata vipimo vya maabara",
+	"Failed to create lab test":           "Imeshindwa kuunda kipimo cha maabara",
+	"Failed to update lab test":           "Imeshindwa kusasisha kipimo cha maabara",
+	"Failed to delete lab test":           "Imeshindwa kufuta kipimo cha maabara",
+	"Lab booking not found":               "Uhifadhi wa maabara haukupatikana",
+	"Invalid booking date format":         "Muundo wa tarehe ya uhifadhi si sahihi",
+	"Failed to create lab booking":        "Imeshindwa kuhifadhi nafasi ya maabara",
+	"Failed to fetch lab bookings":        "Imeshindwa kupata nafasi za maabara",
+	"Failed to update booking status":     "Imeshindwa kusasisha hali ya uhifadhi",
+	"Invalid scheduled time format":       "Muundo wa muda uliopangwa si sahihi",
+	"Failed to create telehealth session": "Imeshindwa kuunda kikao cha matibabu mtandaoni",
+	"Failed to fetch telehealth sessions": "Imeshindwa kupata vikao vya matibabu mtandaoni",
+	"Failed to analyze symptoms":          "Imeshindwa kuchambua dalili",
+	"Failed to create symptom check":      "Imeshindwa kuunda ukaguzi wa dalili",
+	"Failed to fetch symptom checks":      "Imeshindwa kupata ukaguzi wa dalili",
+
+	// Health education
+	"Article not found":               "Makala haikupatikana",
+	"Health article not found":        "Makala ya afya haikupatikana",
+	"Failed to fetch articles":        "Imeshindwa kupata makala",
+	"Failed to create health article": "Imeshindwa kuunda makala ya afya",
+	"Failed to update health article": "Imeshindwa kusasisha makala ya afya",
+	"Failed to delete health article": "Imeshindwa kufuta makala ya afya",
+	"Translation not found":           "Tafsiri haikupatikana",
+	"Failed to save translation":      "Imeshindwa kuhifadhi tafsiri",
+	"Failed to fetch translations":    "Imeshindwa kupata tafsiri",
+	"Failed to delete translation":    "Imeshindwa kufuta tafsiri",
+	"Unsupported translation field":   "Sehemu hii haiwezi kutafsiriwa",
+
+	// CareSense analytics
+	"Failed to generate analytics: ": "Imeshindwa kutengeneza uchambuzi: ",
+	"Failed to save analytics":       "Imeshindwa kuhifadhi uchambuzi",
+	"Failed to fetch analytics":      "Imeshindwa kupata uchambuzi",
+	"Invalid analysis type. Supported types: health_trends, risk_assessment, wellness_score, comprehensive": "Aina ya uchambuzi si sahihi. Aina zinazotumika: health_trends, risk_assessment, wellness_score, comprehensive",
+
+	// Notifications and email
+	"Notification not found":               "Arifa haikupatikana",
+	"Invalid notification ID":              "Kitambulisho cha arifa si sahihi",
+	"Invalid notification data":            "Taarifa za arifa si sahihi",
+	"Failed to create notification":        "Imeshindwa kuunda arifa",
+	"Failed to create notification record": "Imeshindwa kuhifadhi rekodi ya arifa",
+	"Failed to fetch notifications":        "Imeshindwa kupata arifa",
+	"Failed to update notification":        "Imeshindwa kusasisha arifa",
+	"Failed to mark notifications as read": "Imeshindwa kuweka arifa kama zimesomwa",
+	"Failed to count notifications":        "Imeshindwa kuhesabu arifa",
+	"Failed to send email":                 "Imeshindwa kutuma barua pepe",
+	"Email template not found":             "Kiolezo cha barua pepe hakikupatikana",
+	"Failed to load email templates":       "Imeshindwa kupakia violezo vya barua pepe",
+	"Failed to render email template: ":    "Imeshindwa kuandaa kiolezo cha barua pepe: ",
+
+	// Payments
+	"Payment not found":               "Malipo hayakupatikana",
+	"Payment record not found":        "Rekodi ya malipo haikupatikana",
+	"No reference provided":           "Hakuna kumbukumbu iliyotolewa",
+	"Failed to initialize payment":    "Imeshindwa kuanzisha malipo",
+	"Failed to create payment record": "Imeshindwa kuhifadhi rekodi ya malipo",
+	"Failed to fetch payments":        "Imeshindwa kupata malipo",
+	"Failed to update payment status": "Imeshindwa kusasisha hali ya malipo",
+	"Failed to verify payment":        "Imeshindwa kuthibitisha malipo",
+
+	// AI service prompts
+	"You are a healthcare AI assistant. Respond only with valid JSON following the specified structure.":                                                                      "Wewe ni msaidizi wa afya wa AI. Jibu kwa JSON halali pekee kwa kufuata muundo ulioelezwa.",
+	"You are a healthcare analytics AI. Respond only with valid JSON following the specified structure.":                                                                      "Wewe ni AI ya uchambuzi wa afya. Jibu kwa JSON halali pekee kwa kufuata muundo ulioelezwa.",
+	"I need a medical symptom analysis based on these symptoms: %s. The patient is a %d year old %s. Please provide possible conditions, recommendations, and urgency level.": "Ninahitaji uchambuzi wa kitabibu wa dalili hizi: %s. Mgonjwa ana umri wa miaka %d, jinsia %s. Tafadhali toa magonjwa yanayowezekana, mapendekezo na kiwango cha dharura.",
+	"Analyze the following health data for user %s over %s. Data type: %s. Data: %s Generate health insights, trends, patterns, and risk assessment.":                         "Chambua taarifa zifuatazo za afya za mtumiaji %s kwa kipindi cha %s. Aina ya taarifa: %s. Taarifa: %s Toa maarifa ya afya, mienendo, mifumo na tathmini ya hatari.",
+	"I'm analyzing a %s result from the attached image. Please interpret the result.":                                                                                         "Ninachambua majibu ya %s kutoka kwenye picha iliyoambatishwa. Tafadhali tafsiri majibu.",
+	"Valid result values for this kit are: %s. Report detected markers only from: %s.":                                                                                        "Thamani halali za matokeo kwa kifaa hiki ni: %s. Ripoti alama zilizogunduliwa kutoka kwenye orodha hii pekee: %s.",
+	"Write all human-readable text values in English. Keep JSON keys and enum values (such as urgency and result) in English.":                                                "Andika maandishi yote yanayosomwa na binadamu kwa Kiswahili. Acha funguo za JSON na thamani za orodha (kama urgency na result) kwa Kiingereza.",
+	"You are a medical diagnostic assistant specialized in analyzing test kit results from images. Provide a detailed analysis of the test kit image including: 1. Whether the result is positive, negative, or inconclusive 2. Your confidence level in the interpretation (0-1) 3. Any markers or indicators you can detect 4. Recommended next steps based on the result 5. Any additional notes or observations. Return your analysis in JSON format that matches the TestKitResultResponse structure.": "Wewe ni msaidizi wa uchunguzi wa kitabibu aliyebobea katika kuchambua majibu ya vifaa vya kupima kutoka kwenye picha. Toa uchambuzi wa kina wa picha ya kifaa cha kupima ikijumuisha: 1. Kama majibu ni positive, negative, au inconclusive 2. Kiwango chako cha uhakika katika tafsiri (0-1) 3. Alama au viashiria vyovyote unavyoweza kuona 4. Hatua zinazopendekezwa kulingana na majibu 5. Maelezo au uchunguzi mwingine wowote. Rudisha uchambuzi wako kwa muundo wa JSON unaolingana na muundo wa TestKitResultResponse.",
+
+	// AI service mock responses
+	"Upper Respiratory Infection":                                  "Maambukizi ya Njia ya Juu ya Hewa",
+	"Common viral infection affecting the upper respiratory tract": "Maambukizi ya kawaida ya virusi yanayoathiri njia ya juu ya hewa",
+	"Chest Pain Syndrome":                                          "Maumivu ya Kifua",
+	"Various conditions that can cause chest discomfort":           "Hali mbalimbali zinazoweza kusababisha usumbufu wa kifua",
+	"General Symptoms":                                             "Dalili za Jumla",
+	"Common symptoms that may indicate various conditions":         "Dalili za kawaida zinazoweza kuashiria hali mbalimbali",
+	"Monitor symptoms for 24-48 hours":                             "Fuatilia dalili kwa saa 24 hadi 48",
+	"Stay hydrated and get adequate rest":                          "Kunywa maji ya kutosha na upumzike vya kutosha",
+	"Consider consulting a healthcare provider if symptoms worsen": "Fikiria kumwona mhudumu wa afya iwapo dalili zitazidi",
+	"Seek immediate medical attention":                             "Tafuta huduma ya matibabu mara moja",
+	"Consider visiting an emergency room":                          "Fikiria kwenda kwenye chumba cha dharura",
+	"Do not delay seeking professional help":                       "Usichelewe kutafuta msaada wa kitaalamu",
+	"Blood pressure shows stable trend within normal range":        "Shinikizo la damu linaonyesha mwenendo thabiti ndani ya kiwango cha kawaida",
+	"Heart rate variability indicates good cardiovascular health":  "Mabadiliko ya mapigo ya moyo yanaonyesha afya nzuri ya moyo na mishipa",
+	"Sleep patterns show improvement over the past month":          "Mpangilio wa usingizi umeimarika katika mwezi uliopita",
+	"Your health metrics show positive trends":                     "Vipimo vyako vya afya vinaonyesha mienendo mizuri",
+	"Consider maintaining current lifestyle habits":                "Endelea na mtindo wako wa maisha wa sasa",
+	"Regular monitoring is recommended":                            "Ufuatiliaji wa mara kwa mara unapendekezwa",
+	"Consult a healthcare professional":                            "Wasiliana na mtaalamu wa afya",
+	"Your previous reply did not match the required JSON structure: %s. Reply again with only the corrected JSON object and no other text.": "Jibu lako la awali halikulingana na muundo wa JSON unaohitajika: %s. Jibu tena kwa kitu cha JSON kilichosahihishwa pekee bila maandishi mengine.",
+	"Read by automated line detection. A clinician will review this result.":                                                                "Imesomwa kwa utambuzi wa mistari wa kiotomatiki. Daktari atakagua matokeo haya.",
+	"No control line was detected. The test may be invalid.":                                                                                "Mstari wa udhibiti haukuonekana. Kipimo huenda si halali.",
+	"Repeat the test with a new kit": "Rudia kipimo kwa kifaa kipya",
+	"Line detection could not find a control line; please confirm the test is valid.":                        "Utambuzi wa mistari haukupata mstari wa udhibiti; tafadhali thibitisha kuwa kipimo ni halali.",
+	"The AI reading (%s) disagreed with automated line detection (%s). A clinician will review this result.": "Usomaji wa AI (%s) haukukubaliana na utambuzi wa mistari wa kiotomatiki (%s). Daktari atakagua matokeo haya.",
+	"positive":     "chanya",
+	"negative":     "hasi",
+	"inconclusive": "haijulikani",
+	"Automated image analysis is unavailable. A clinician will review this result.": "Uchambuzi wa picha wa kiotomatiki haupatikani. Daktari atakagua matokeo haya.",
+
+	// Symptom triage red flags and emergency guidance
+	"Chest pain or pressure":                    "Maumivu au kubanwa kifuani",
+	"Difficulty breathing":                      "Shida ya kupumua",
+	"Severe or uncontrolled bleeding":           "Kutokwa na damu nyingi au isiyokoma",
+	"Loss of consciousness or unresponsiveness": "Kupoteza fahamu au kutoitikia",
+	"Seizure or convulsions":                    "Degedege au kifafa",
+	"Possible stroke signs":                     "Dalili zinazowezekana za kiharusi",
+	"Possible severe allergic reaction":         "Uwezekano wa mzio mkali",
+	"Thoughts of suicide or self-harm":          "Mawazo ya kujiua au kujidhuru",
+	"Poisoning or overdose":                     "Sumu au kuzidisha dawa",
+	"Fever with stiff neck":                     "Homa pamoja na shingo ngumu",
+	"Fever in an infant":                        "Homa kwa mtoto mchanga",
+	"Severe abdominal pain":                     "Maumivu makali ya tumbo",
+	"Blood in stool or urine":                   "Damu kwenye choo au mkojo",
+	"Signs of severe dehydration":               "Dalili za upungufu mkubwa wa maji mwilini",
+	"Possible heart attack":                     "Uwezekano wa shambulio la moyo",
+	"Possible stroke":                           "Uwezekano wa kiharusi",
+	"Possible pulmonary embolism":               "Uwezekano wa kuganda kwa damu kwenye mapafu",
+	"Possible sepsis":                           "Uwezekano wa sepsisi",
+	"Possible meningitis":                       "Uwezekano wa uti wa mgongo",
+	"Possible anaphylaxis":                      "Uwezekano wa mzio mkali (anaphylaxis)",
+	"Possible ectopic pregnancy":                "Uwezekano wa mimba nje ya mfuko wa uzazi",
+	"Possible severe malaria":                   "Uwezekano wa malaria kali",
+	"Possible appendicitis":                     "Uwezekano wa kidole tumbo",
+	"Possible pneumonia":                        "Uwezekano wa nimonia",
+	"This may be a medical emergency. Call 999 or 112 now, or go to the nearest hospital emergency department.": "Hii inaweza kuwa dharura ya kiafya. Piga 999 au 112 sasa, au nenda kitengo cha dharura cha hospitali iliyo karibu.",
+	"Kenya Red Cross ambulance: call 1199.":                                     "Ambulansi ya Shirika la Msalaba Mwekundu Kenya: piga 1199.",
+	"Do not drive yourself. Ask someone to take you or wait for the ambulance.": "Usiendeshe gari mwenyewe. Mwombe mtu akupeleke au subiri ambulansi.",
+	"Do not wait for online advice or a telehealth appointment.":                "Usisubiri ushauri wa mtandaoni au miadi ya matibabu kwa njia ya mtandao.",
+
+	// Image quality checks
+	"Image quality check failed":  "Ukaguzi wa ubora wa picha umeshindwa",
+	"Upload a JPEG or PNG photo.": "Pakia picha ya JPEG au PNG.",
+	"The file content does not match its type. Upload the original photo from your camera.":                               "Maudhui ya faili hayalingani na aina yake. Pakia picha halisi kutoka kwenye kamera yako.",
+	"The image could not be read. Take a new photo and upload it again.":                                                  "Picha haikuweza kusomwa. Piga picha mpya na uipakie tena.",
+	"The photo resolution is too high. Upload a photo under 40 megapixels.":                                               "Ubora wa picha ni mkubwa mno. Pakia picha yenye chini ya megapikseli 40.",
+	"The photo is too small. Move closer so the test kit fills most of the frame, and use your camera's full resolution.": "Picha ni ndogo mno. Sogea karibu ili kifaa cha kupima kijaze sehemu kubwa ya picha, na tumia ubora kamili wa kamera yako.",
+	"The photo is blurry. Hold the phone steady, tap to focus on the test window and try again.":                          "Picha haiko wazi. Shika simu bila kutikisika, gusa skrini kulenga dirisha la kipimo kisha ujaribu tena.",
+	"The photo is too dark. Move to a well-lit area or turn on more light, avoiding shadows over the test.":               "Picha ina giza mno. Nenda mahali penye mwanga wa kutosha au washa taa zaidi, ukiepuka vivuli juu ya kipimo.",
+	"The photo is too bright. Avoid direct sunlight or flash glare on the test window.":                                   "Picha ina mwanga mwingi mno. Epuka jua moja kwa moja au mng'ao wa flash kwenye dirisha la kipimo.",
+
+	// Clinician review queue
+	"Clinician access required":                                  "Ufikiaji wa mhudumu wa afya unahitajika",
+	"Failed to fetch review queue":                               "Imeshindwa kupata foleni ya ukaguzi",
+	"Failed to claim test kit result":                            "Imeshindwa kuchukua matokeo ya kifaa cha kupima",
+	"Failed to release test kit result":                          "Imeshindwa kuachilia matokeo ya kifaa cha kupima",
+	"Failed to assign test kit result":                           "Imeshindwa kukabidhi matokeo ya kifaa cha kupima",
+	"Test kit result is not claimed by you":                      "Matokeo ya kifaa cha kupima hayajachukuliwa na wewe",
+	"Test kit result is already claimed by another clinician":    "Matokeo ya kifaa cha kupima tayari yamechukuliwa na mhudumu mwingine wa afya",
+	"Test kit result has already been reviewed":                  "Matokeo ya kifaa cha kupima tayari yamekaguliwa",
+	"Assignee must be a clinician":                               "Anayekabidhiwa lazima awe mhudumu wa afya",
+	"A result is required to override the AI reading":            "Matokeo yanahitajika ili kubadilisha usomaji wa AI",
+	"Invalid test result value":                                  "Thamani ya matokeo ya kipimo si sahihi",
+	"Test kit results can only be marked reviewed":               "Majibu ya kifaa cha kupima yanaweza kuwekwa tu kuwa yamekaguliwa",
+	"Override result matches the AI reading; confirm it instead": "Matokeo mapya yanalingana na usomaji wa AI; yathibitishe badala yake",
+	"Claim the test kit result before recording a decision":      "Chukua matokeo ya kifaa cha kupima kabla ya kurekodi uamuzi",
+	"Your test result has been reviewed":                         "Matokeo ya kipimo chako yamekaguliwa",
+	"A clinician has reviewed and confirmed your %s result.":     "Mhudumu wa afya amekagua na kuthibitisha matokeo yako: %s.",
+	"A clinician has reviewed your test and updated the result to %s. Please check the details and recommended next steps.": "Mhudumu wa afya amekagua kipimo chako na kubadilisha matokeo kuwa %s. Tafadhali angalia maelezo na hatua zinazopendekezwa.",
+
+	// Test kit catalog
+	"Unsupported test kit type":     "Aina ya kifaa cha kupima haitumiki",
+	"COVID-19 antigen test":         "Kipimo cha antijeni cha COVID-19",
+	"HIV self-test":                 "Kipimo binafsi cha VVU",
+	"Malaria rapid diagnostic test": "Kipimo cha haraka cha malaria",
+	"Pregnancy test":                "Kipimo cha ujauzito",
+	"Blood glucose test":            "Kipimo cha sukari kwenye damu",
+	"low":                           "chini",
+	"normal":                        "kawaida",
+	"high":                          "juu",
+	"Isolate at home and avoid close contact with others, especially older or vulnerable people":       "Jitenge nyumbani na uepuke kukaribiana na wengine, hasa wazee au watu walio hatarini",
+	"Seek care immediately if you have difficulty breathing":                                           "Tafuta huduma mara moja ikiwa unapata shida kupumua",
+	"Inform people you have been in close contact with":                                                "Wajulishe watu uliokaribiana nao",
+	"A negative antigen test does not rule out infection. Test again in 48 hours if you have symptoms": "Kipimo hasi cha antijeni hakiondoi uwezekano wa maambukizi. Pima tena baada ya saa 48 ikiwa una dalili",
+	"A reactive self-test must be confirmed at a health facility":                                      "Kipimo binafsi chenye majibu chanya lazima kithibitishwe kwenye kituo cha afya",
+	"Visit a health facility or HIV testing centre for confirmatory testing":                           "Tembelea kituo cha afya au kituo cha kupima VVU kwa kipimo cha uthibitisho",
+	"If you may have been exposed in the last 3 months, test again after 3 months":                     "Ikiwa huenda uliambukizwa katika miezi 3 iliyopita, pima tena baada ya miezi 3",
+	"Consider prevention options such as condoms and PrEP":                                             "Fikiria njia za kujikinga kama kondomu na PrEP",
+	"Visit a health facility today for malaria treatment":                                              "Tembelea kituo cha afya leo kwa matibabu ya malaria",
+	"Seek emergency care if you have confusion, convulsions or difficulty breathing":                   "Tafuta huduma ya dharura ikiwa unachanganyikiwa, una degedege au unapata shida kupumua",
+	"If your fever continues, visit a health facility for further testing":                             "Ikiwa homa itaendelea, tembelea kituo cha afya kwa vipimo zaidi",
+	"Book an antenatal care visit with a healthcare provider":                                          "Panga miadi ya kliniki ya wajawazito na mhudumu wa afya",
+	"Start taking folic acid supplements if you are not already":                                       "Anza kutumia virutubisho vya asidi ya foliki ikiwa bado hujaanza",
+	"If your period does not start within a week, repeat the test":                                     "Ikiwa hedhi yako haitaanza ndani ya wiki moja, rudia kipimo",
+	"Eat or drink something sugary now, such as juice or glucose tablets":                              "Kula au kunywa kitu chenye sukari sasa, kama juisi au vidonge vya glukosi",
+	"Recheck your glucose in 15 minutes and seek care if it stays low":                                 "Pima sukari tena baada ya dakika 15 na utafute huduma ikiwa itabaki chini",
+	"Continue your usual monitoring schedule":                                                          "Endelea na ratiba yako ya kawaida ya kupima",
+	"Drink water and recheck your glucose":                                                             "Kunywa maji na upime sukari tena",
+	"Contact a healthcare provider if your glucose stays high or you feel unwell":                      "Wasiliana na mhudumu wa afya ikiwa sukari yako itabaki juu au ukijisikia vibaya",
+	"Repeat the reading with a new test strip":                                                         "Rudia kipimo kwa kipande kipya cha kupimia",
+
+	// Background test kit analysis
+	"Too many test kits are being analyzed. Please try again shortly.":     "Vifaa vingi vya kupima vinachambuliwa kwa sasa. Tafadhali jaribu tena baada ya muda mfupi.",
+	"Idempotency-Key was already used for a different upload":              "Idempotency-Key tayari imetumika kwa upakiaji mwingine",
+	"Idempotency-Key was used for a test kit result that has been deleted": "Idempotency-Key ilitumika kwa matokeo ya kifaa cha kupima yaliyofutwa",
+	"Idempotency-Key is too long":                                          "Idempotency-Key ni ndefu mno",
+	"We could not process your test photo. Please upload it again.":        "Hatukuweza kuchakata picha ya kipimo chako. Tafadhali ipakie tena.",
+
+	// File storage
+	"File not found":               "Faili halikupatikana",
+	"Invalid or expired file link": "Kiungo cha faili si sahihi au muda wake umekwisha",
+	"Failed to read file":          "Imeshindwa kusoma faili",
+
+	// Private files
+	"Invalid file ID": "Kitambulisho cha faili si sahihi",
+	"Access denied":   "Ufikiaji umekataliwa",
+
+	// File lifecycle
+	"Invalid health article ID":                                    "Kitambulisho cha makala ya afya si sahihi",
+	"Failed to check storage quota":                                "Imeshindwa kukagua kiwango cha hifadhi",
+	"Storage quota exceeded. Delete old files or contact support.": "Kiwango cha hifadhi kimepitwa. Futa faili za zamani au wasiliana na huduma kwa wateja.",
+	"Test kit result is still being analyzed":                      "Majibu ya kifaa cha kupima bado yanachambuliwa",
+	"Failed to delete test kit result":                             "Imeshindwa kufuta majibu ya kifaa cha kupima",
+
+	// Upload scanning
+	"Invalid upload folder":                                             "Folda ya kupakia si sahihi",
+	"File type not allowed for this folder":                             "Aina ya faili hairuhusiwi kwenye folda hii",
+	"The file failed a security scan and was rejected":                  "Faili halikupita ukaguzi wa usalama na limekataliwa",
+	"Uploads cannot be scanned right now. Please try again later.":      "Faili haziwezi kukaguliwa kwa sasa. Tafadhali jaribu tena baadaye.",
+	"Upload the prescription image first and use the returned file_url": "Pakia picha ya agizo la daktari kwanza kisha utumie file_url uliyopewa",
+
+	// Prescription fulfilment
+	"Pharmacist access required":                                                           "Ufikiaji wa mfamasia unahitajika",
+	"Prescribing doctor not found":                                                         "Daktari aliyeandika agizo hakupatikana",
+	"A delivery address is required for delivery":                                          "Anwani ya kufikishiwa inahitajika kwa huduma ya kufikishiwa",
+	"Accept the prescription quote before paying":                                          "Kubali bei ya agizo la daktari kabla ya kulipa",
+	"Add at least one available, priced medication before approving":                       "Ongeza angalau dawa moja inayopatikana yenye bei kabla ya kuidhinisha",
+	"Dispatch the prescription for delivery first":                                         "Tuma agizo la daktari kwa ajili ya kufikishwa kwanza",
+	"Failed to save prescription medications":                                              "Imeshindwa kuhifadhi dawa za agizo la daktari",
+	"Only the patient can accept a prescription quote":                                     "Ni mgonjwa pekee anayeweza kukubali bei ya agizo la daktari",
+	"Only the patient can pay for a prescription":                                          "Ni mgonjwa pekee anayeweza kulipia agizo la daktari",
+	"Start transcribing the prescription before editing its medications":                   "Anza kunakili agizo la daktari kabla ya kuhariri dawa zake",
+	"The prescription was updated by someone else. Reload and try again.":                  "Agizo la daktari limesasishwa na mtu mwingine. Pakia upya na ujaribu tena.",
+	"This action is not allowed for the prescription's current status":                     "Kitendo hiki hakiruhusiwi kwa hali ya sasa ya agizo la daktari",
+	"This prescription is for pickup and is not delivered":                                 "Agizo hili la daktari ni la kuchukuliwa na halifikishwi",
+	"Use the prescription workflow endpoints for this status":                              "Tumia hatua maalum za agizo la daktari kwa hali hii",
+	"Prescription update":                                                                  "Taarifa kuhusu agizo la daktari",
+	"We could not fill your prescription: %s":                                              "Hatukuweza kukamilisha agizo lako la daktari: %s",
+	"Your medication has been dispensed.":                                                  "Dawa zako zimetolewa.",
+	"Your medication is on its way with %s. Tracking number: %s":                           "Dawa zako ziko njiani kupitia %s. Nambari ya ufuatiliaji: %s",
+	"Your prescription has been reviewed. A quote of KES %.2f is ready for you to accept.": "Agizo lako la daktari limekaguliwa. Bei ya KES %.2f iko tayari ukubali.",
+
+	// Prescription extraction
+	"Prescriber name":                     "Jina la mwandishi wa agizo",
+	"Prescriber registration number":      "Nambari ya usajili ya mwandishi wa agizo",
+	"Prescription date":                   "Tarehe ya agizo la daktari",
+	"Medications":                         "Dawa",
+	"name":                                "jina",
+	"dosage":                              "kipimo",
+	"frequency":                           "mara za kutumia",
+	"duration":                            "muda wa matumizi",
+	"instructions":                        "maelekezo",
+	"Medication %d %s":                    "Dawa ya %d: %s",
+	"%s is missing from the prescription": "%s haipo kwenye agizo la daktari",
+	"%s could not be read reliably; check it against the image":        "%s haikuweza kusomwa kwa uhakika; ilinganishe na picha",
+	"No extraction has been run for this prescription":                 "Agizo hili la daktari bado halijasomwa kiotomatiki",
+	"The prescription is already being read":                           "Agizo la daktari tayari linasomwa",
+	"Too many prescriptions are being read. Please try again shortly.": "Maagizo mengi ya daktari yanasomwa kwa sasa. Tafadhali jaribu tena baada ya muda mfupi.",
+	"Failed to start prescription extraction":                          "Imeshindwa kuanza kusoma agizo la daktari",
+	"The prescription extraction has not completed":                    "Usomaji wa agizo la daktari haujakamilika",
+
+	// Medication safety checks
+	"Medication safety checks are unavailable":    "Ukaguzi wa usalama wa dawa haupatikani kwa sasa",
+	"Failed to load the patient's medical record": "Imeshindwa kupakia rekodi ya matibabu ya mgonjwa",
+	"Failed to record the safety override":        "Imeshindwa kurekodi uamuzi wa kupuuza tahadhari ya usalama",
+	"Failed to save medication":                   "Imeshindwa kuhifadhi dawa",
+	"The medication safety check found serious problems. Review the alerts and provide an override_reason to continue.": "Ukaguzi wa usalama wa dawa umepata matatizo makubwa. Pitia tahadhari na utoe override_reason ili kuendelea.",
+
+	// Medication doses and reminders
+	"Medication reminder": "Kikumbusho cha dawa",
+	"It is time to take your %s. Log the dose once you have taken it.": "Ni wakati wa kutumia %s yako. Rekodi dozi baada ya kuitumia.",
+	"Dose times must be given as HH:MM":                                "Nyakati za dozi lazima ziandikwe kama HH:MM",
+	"Status must be taken or skipped":                                  "Hali lazima iwe taken au skipped",
+	"A dose cannot be logged in the future":                            "Dozi haiwezi kurekodiwa kwa muda ujao",
+	"Dose not found":                                                   "Dozi haikupatikana",
+	"Only scheduled doses can be skipped":                              "Dozi zilizopangwa pekee ndizo zinaweza kurukwa",
+	"Failed to load doses":                                             "Imeshindwa kupakia dozi",
+	"No scheduled dose found near that time":                           "Hakuna dozi iliyopangwa karibu na wakati huo",
+	"Failed to log dose":                                               "Imeshindwa kurekodi dozi",
+	"days must be between 1 and 90":                                    "days lazima iwe kati ya 1 na 90",
+	"Medication not found":                                             "Dawa haikupatikana",
+
+	// Prescription refills
+	"A refill is already in progress for this prescription":                     "Dawa za kujaza upya tayari zinashughulikiwa kwa agizo hili",
+	"Failed to approve refill":                                                  "Imeshindwa kuidhinisha kujaza upya",
+	"Failed to deny refill":                                                     "Imeshindwa kukataa kujaza upya",
+	"Failed to fetch refill requests":                                           "Imeshindwa kupata maombi ya kujaza upya",
+	"Failed to request refill":                                                  "Imeshindwa kuomba kujaza upya",
+	"It is too early to refill this prescription":                               "Ni mapema mno kujaza upya agizo hili",
+	"No refills remain on this prescription. Please upload a new prescription.": "Hakuna nafasi ya kujaza upya iliyobaki kwenye agizo hili. Tafadhali pakia agizo jipya la daktari.",
+	"No refills remain. Authorise refills to renew the prescription.":           "Hakuna nafasi ya kujaza upya iliyobaki. Idhinisha nafasi zaidi ili kuhuisha agizo.",
+	"Only dispensed prescriptions can be refilled":                              "Ni maagizo yaliyotolewa pekee yanayoweza kujazwa upya",
+	"Only the patient can request a refill":                                     "Ni mgonjwa pekee anayeweza kuomba kujaza upya",
+	"Refill request not found":                                                  "Ombi la kujaza upya halikupatikana",
+	"Refill request update":                                                     "Taarifa ya ombi la kujaza upya",
+	"Refills are set on the original prescription, not on a refill order":       "Nafasi za kujaza upya huwekwa kwenye agizo la awali, si kwenye agizo la kujaza upya",
+	"Refills must be between 0 and %d":                                          "Nafasi za kujaza upya lazima ziwe kati ya 0 na %d",
+	"Refills must be between 1 and %d":                                          "Nafasi za kujaza upya lazima ziwe kati ya 1 na %d",
+	"The prescription has no dispensed medication to refill":                    "Agizo hili halina dawa zilizotolewa za kujaza upya",
+	"This refill request has already been reviewed":                             "Ombi hili la kujaza upya tayari limekaguliwa",
+	"Time to refill your medication":                                            "Ni wakati wa kujaza upya dawa zako",
+	"Your medication is expected to run out on %s and no refills remain. Request a renewal from your prescriptions for a clinician to review.": "Dawa zako zinatarajiwa kuisha tarehe %s na hakuna nafasi ya kujaza upya iliyobaki. Omba kuhuishwa kwa agizo kutoka kwenye maagizo yako ili mhudumu wa afya alikague.",
+	"Your medication is expected to run out on %s. Request a refill from your prescriptions.":                                                  "Dawa zako zinatarajiwa kuisha tarehe %s. Omba kujaza upya kutoka kwenye maagizo yako.",
+	"Your refill request has been approved. The pharmacy is preparing your medication and will send you a quote.":                              "Ombi lako la kujaza upya limeidhinishwa. Duka la dawa linaandaa dawa zako na litakutumia bei.",
+	"Your refill request was declined: %s": "Ombi lako la kujaza upya limekataliwa: %s",
+	// Medical record entries
+	"Failed to delete consultation":                                    "Imeshindwa kufuta mashauriano",
+	"Failed to delete medication":                                      "Imeshindwa kufuta dawa",
+	"Failed to delete test result":                                     "Imeshindwa kufuta matokeo ya kipimo",
+	"Failed to fetch medications":                                      "Imeshindwa kupata dawa",
+	"Medication name is required":                                      "Jina la dawa linahitajika",
+	"This entry is kept in sync with its source and cannot be changed": "Kipengee hiki kinasawazishwa na chanzo chake na hakiwezi kubadilishwa",
+	"You already have a medical record":                                "Tayari una rekodi ya matibabu",
+	// FHIR exchange
+	"Failed to encode the FHIR response": "Imeshindwa kuandaa jibu la FHIR",
+	"Failed to import the bundle":        "Imeshindwa kuingiza kifurushi",
+	"Invalid patient ID":                 "Kitambulisho cha mgonjwa si sahihi",
+	"No patient matches the bundle's Patient identifier, email or phone number": "Hakuna mgonjwa anayelingana na kitambulisho, barua pepe au nambari ya simu ya mgonjwa katika kifurushi",
+	"Patient not found":                                "Mgonjwa hakupatikana",
+	"The bundle describes a different patient":         "Kifurushi kinamhusu mgonjwa mwingine",
+	"The bundle is too large":                          "Kifurushi ni kikubwa mno",
+	"The bundle must include the Patient it describes": "Kifurushi lazima kijumuishe mgonjwa kinachomhusu",
+	"You can only export your own record":              "Unaweza kuhamisha rekodi yako pekee",
+
+	// Data export and account deletion
+	"A copy of your data is ready to download until %s.": "Nakala ya data yako iko tayari kupakuliwa hadi %s.",
+	"Account deletion already scheduled":                 "Ufutaji wa akaunti tayari umepangwa",
+	"Account deletion is already in progress":            "Ufutaji wa akaunti tayari unaendelea",
+	"Account deletion scheduled":                         "Ufutaji wa akaunti umepangwa",
+	"Data export not found":                              "Uhamishaji wa data haukupatikana",
+	"Failed to cancel account deletion":                  "Imeshindwa kughairi ufutaji wa akaunti",
+	"Failed to fetch data exports":                       "Imeshindwa kupata uhamishaji wa data",
+	"Failed to request data export":                      "Imeshindwa kuomba uhamishaji wa data",
+	"Failed to schedule account deletion":                "Imeshindwa kupanga ufutaji wa akaunti",
+	"Incorrect password":                                 "Nenosiri si sahihi",
+	"Invalid data export ID":                             "Kitambulisho cha uhamishaji wa data si sahihi",
+	"No account deletion requested":                      "Hakuna ombi la kufuta akaunti",
+	"No account deletion scheduled":                      "Hakuna ufutaji wa akaunti uliopangwa",
+	"We could not prepare a copy of your data. Please request the export again, or contact support if it keeps failing.":                    "Hatukuweza kuandaa nakala ya data yako. Tafadhali omba uhamishaji tena, au wasiliana na huduma kwa wateja ikiendelea kushindwa.",
+	"Your account and health records will be permanently deleted on %s. You can cancel the deletion until then from your account settings.": "Akaunti yako na rekodi zako za afya zitafutwa kabisa tarehe %s. Unaweza kughairi ufutaji hadi wakati huo kupitia mipangilio ya akaunti yako.",
+	"Your data export failed":   "Uhamishaji wa data yako umeshindwa",
+	"Your data export is ready": "Uhamishaji wa data yako uko tayari",
+
+	// Consent management
+	"A newer version of this consent document has been published": "Toleo jipya la hati hii ya idhini limechapishwa",
+	"Choose the clinician to share your record with":              "Chagua mhudumu wa afya wa kushiriki naye rekodi yako",
+	"Clinician not found":                                      "Mhudumu wa afya hakupatikana",
+	"Consent already given":                                    "Idhini tayari imetolewa",
+	"Consent document not found":                               "Hati ya idhini haikupatikana",
+	"Consent not found":                                        "Idhini haikupatikana",
+	"Failed to fetch consent documents":                        "Imeshindwa kupata hati za idhini",
+	"Failed to fetch consents":                                 "Imeshindwa kupata idhini",
+	"Failed to publish consent document":                       "Imeshindwa kuchapisha hati ya idhini",
+	"Failed to record consent":                                 "Imeshindwa kuhifadhi idhini",
+	"Failed to withdraw consent":                               "Imeshindwa kuondoa idhini",
+	"Invalid consent ID":                                       "Kitambulisho cha idhini si sahihi",
+	"The end of sharing must be in the future":                 "Mwisho wa kushiriki lazima uwe wakati ujao",
+	"The patient has not shared their medical record with you": "Mgonjwa hajashiriki nawe rekodi yake ya matibabu",
+	"Unknown consent purpose":                                  "Madhumuni ya idhini hayajulikani",
+
+	// Caregivers and dependants
+	"A dependant cannot add dependants":  "Mtegemezi hawezi kuongeza wategemezi",
+	"Already a caregiver":                "Tayari ni mlezi",
+	"Caregiver not found":                "Mlezi hakupatikana",
+	"Dependant not found":                "Mtegemezi hakupatikana",
+	"Failed to add caregiver":            "Imeshindwa kuongeza mlezi",
+	"Failed to add dependant":            "Imeshindwa kuongeza mtegemezi",
+	"Failed to check caregiver access":   "Imeshindwa kuthibitisha ruhusa ya mlezi",
+	"Failed to fetch caregiver activity": "Imeshindwa kupata shughuli za walezi",
+	"Failed to fetch caregivers":         "Imeshindwa kupata walezi",
+	"Failed to fetch dependants":         "Imeshindwa kupata wategemezi",
+	"Failed to update caregiver access":  "Imeshindwa kusasisha ruhusa ya mlezi",
+	"Failed to update dependant":         "Imeshindwa kusasisha mtegemezi",
+	"Invalid X-Acting-For patient ID":    "Kitambulisho cha mgonjwa katika X-Acting-For si sahihi",
+	"Invalid caregiver scopes":           "Ruhusa za mlezi si sahihi",
+	"No user with that email":            "Hakuna mtumiaji mwenye barua pepe hiyo",
+	"The access of the caregiver managing this dependant cannot be changed":                   "Ruhusa ya mlezi anayemsimamia mtegemezi huyu haiwezi kubadilishwa",
+	"The caregiver who manages a dependant profile cannot leave it":                           "Mlezi anayesimamia wasifu wa mtegemezi hawezi kuuacha",
+	"This action cannot be taken on behalf of someone else":                                   "Kitendo hiki hakiwezi kufanywa kwa niaba ya mtu mwingine",
+	"You are not a caregiver for this patient":                                                "Wewe si mlezi wa mgonjwa huyu",
+	"You can now manage the health of %s in Nyumbani Care, within the access you were given.": "Sasa unaweza kusimamia afya ya %s katika Nyumbani Care, kwa ruhusa uliyopewa.",
+	"You cannot be your own caregiver":                                                        "Huwezi kuwa mlezi wako mwenyewe",
+	"You have been added as a caregiver":                                                      "Umeongezwa kama mlezi",
+	"Your caregiver access does not include this":                                             "Ruhusa yako ya mlezi haijumuishi hili",
+	"You can only act on behalf of patients":                                                  "Unaweza kutenda kwa niaba ya wagonjwa pekee",
+
+	// Lab results
+	"Bookings are completed by verifying their results":                         "Miadi hukamilishwa kwa kuthibitisha majibu yake",
+	"Critical lab result":                                                       "Jibu hatari la maabara",
+	"Each analyte needs a unique code, a name and a numeric or text value type": "Kila kipimo kinahitaji msimbo wa kipekee, jina na aina ya thamani ya nambari au maandishi",
+	"Failed to acknowledge lab result":                                          "Imeshindwa kuthibitisha kupokea jibu la maabara",
+	"Failed to fetch analytes":                                                  "Imeshindwa kupata vipimo",
+	"Failed to fetch lab results":                                               "Imeshindwa kupata majibu ya maabara",
+	"Failed to save analytes":                                                   "Imeshindwa kuhifadhi vipimo",
+	"Failed to save lab results":                                                "Imeshindwa kuhifadhi majibu ya maabara",
+	"Failed to verify lab result":                                               "Imeshindwa kuthibitisha jibu la maabara",
+	"Invalid reference range":                                                   "Kiwango cha marejeleo si sahihi",
+	"Lab result already acknowledged":                                           "Jibu la maabara tayari limepokelewa",
+	"Lab result already verified":                                               "Jibu la maabara tayari limethibitishwa",
+	"A lab result must be verified by a different technician from the one who entered it": "Jibu la maabara lazima lithibitishwe na mtaalamu tofauti na aliyeliingiza",
+	"Upload the lab report first and use the returned file_url":                           "Pakia ripoti ya maabara kwanza kisha utumie file_url uliyopewa",
+	"Lab result not found":           "Jibu la maabara halikupatikana",
+	"Lab results ready":              "Majibu ya maabara yako tayari",
+	"Lab technician access required": "Ufikiaji wa fundi wa maabara unahitajika",
+	"Missing value for analyte":      "Thamani ya kipimo haipo",
+	"The %s result for %s has critical values and needs your attention.": "Jibu la %s la %s lina thamani hatari na linahitaji uangalizi wako.",
+	"The lab test has no analytes defined":                               "Kipimo cha maabara hakina vipimo vilivyofafanuliwa",
+	"This booking is not awaiting results":                               "Miadi hii haisubiri majibu",
+	"Unknown or repeated analyte":                                        "Kipimo kisichojulikana au kilichorudiwa",
+	"Your %s results are ready to view.":                                 "Majibu yako ya %s yako tayari kutazamwa.",
+}
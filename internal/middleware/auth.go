@@ -6,14 +6,16 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c.GetString("lang"), "Authorization header is required")})
 			c.Abort()
 			return
 		}
@@ -21,7 +23,7 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Check if the header has the Bearer prefix
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid authorization header format")})
 			c.Abort()
 			return
 		}
@@ -36,18 +38,25 @@ func AuthMiddleware() gin.HandlerFunc {
 		})
 
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid token")})
 			c.Abort()
 			return
 		}
 
 		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			// Set user ID in context
-			c.Set("user_id", claims["user_id"])
+			// Handlers read the user ID as a uuid.UUID; the token carries it as a string
+			subject, _ := claims["user_id"].(string)
+			userID, err := uuid.Parse(subject)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid token claims")})
+				c.Abort()
+				return
+			}
+			c.Set("user_id", userID)
 			c.Set("role", claims["role"])
 			c.Next()
 		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid token claims")})
 			c.Abort()
 			return
 		}
@@ -58,10 +67,61 @@ func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
 		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c.GetString("lang"), "Admin access required")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsClinicianRole reports whether a role may review clinical results
+func IsClinicianRole(role interface{}) bool {
+	return role == "doctor" || role == "nurse" || role == "admin"
+}
+
+func ClinicianMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists || !IsClinicianRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c.GetString("lang"), "Clinician access required")})
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
+
+// IsPharmacistRole reports whether a role may transcribe and dispense prescriptions
+func IsPharmacistRole(role interface{}) bool {
+	return role == "pharmacist" || role == "admin"
+}
+
+func PharmacistMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists || !IsPharmacistRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c.GetString("lang"), "Pharmacist access required")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IsLabTechnicianRole reports whether a role may enter and verify lab results
+func IsLabTechnicianRole(role interface{}) bool {
+	return role == "lab_technician" || role == "admin"
+}
+
+func LabTechnicianMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, exists := c.Get("role")
+		if !exists || !IsLabTechnicianRole(role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": i18n.T(c.GetString("lang"), "Lab technician access required")})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nyumbanicare/internal/i18n"
+)
+
+// Language negotiates the response language from the lang query parameter or the
+// Accept-Language header and stores it in the context under "lang"
+func Language() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.Negotiate(c.GetHeader("Accept-Language"))
+		if override := c.Query("lang"); override != "" && i18n.IsSupported(override) {
+			lang = i18n.Normalize(override)
+		}
+
+		c.Set("lang", lang)
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
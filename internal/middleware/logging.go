@@ -3,6 +3,7 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"time"
@@ -38,7 +39,7 @@ func (w responseWriter) Write(b []byte) (int, error) {
 func Logger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		
+
 		// Capture request body for POST/PUT requests
 		var requestBody []byte
 		if c.Request.Method == "POST" || c.Request.Method == "PUT" {
@@ -49,7 +50,7 @@ func Logger() gin.HandlerFunc {
 		// Create custom response writer
 		w := &responseWriter{
 			ResponseWriter: c.Writer,
-			body:          bytes.NewBufferString(""),
+			body:           bytes.NewBufferString(""),
 		}
 		c.Writer = w
 
@@ -63,7 +64,7 @@ func Logger() gin.HandlerFunc {
 		userID := ""
 		role := ""
 		if uid, exists := c.Get("user_id"); exists {
-			userID = uid.(string)
+			userID = fmt.Sprint(uid)
 		}
 		if r, exists := c.Get("role"); exists {
 			role = r.(string)
@@ -95,7 +96,7 @@ func Logger() gin.HandlerFunc {
 
 		// Log detailed info for errors
 		if c.Writer.Status() >= 400 {
-			log.Printf("ERROR_DETAILS: %s %s - Status: %d - Error: %s - User: %s", 
+			log.Printf("ERROR_DETAILS: %s %s - Status: %d - Error: %s - User: %s",
 				c.Request.Method, c.Request.URL.Path, c.Writer.Status(), errorMsg, userID)
 		}
 	}
@@ -117,10 +118,10 @@ func SecurityLogger() gin.HandlerFunc {
 			if len(path) >= len(endpoint) && path[:len(endpoint)] == endpoint {
 				userID := ""
 				if uid, exists := c.Get("user_id"); exists {
-					userID = uid.(string)
+					userID = fmt.Sprint(uid)
 				}
 
-				log.Printf("SECURITY_LOG: %s %s - User: %s - IP: %s - UA: %s", 
+				log.Printf("SECURITY_LOG: %s %s - User: %s - IP: %s - UA: %s",
 					c.Request.Method, path, userID, c.ClientIP(), c.Request.UserAgent())
 				break
 			}
@@ -135,16 +136,16 @@ func RateLimit() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// TODO: Implement actual rate limiting
 		// For now, just log high-frequency requests
-		
+
 		userID := ""
 		if uid, exists := c.Get("user_id"); exists {
-			userID = uid.(string)
+			userID = fmt.Sprint(uid)
 		}
 
 		// Simple IP-based logging
 		ip := c.ClientIP()
 		log.Printf("RATE_CHECK: IP=%s User=%s Path=%s", ip, userID, c.Request.URL.Path)
-		
+
 		c.Next()
 	}
 }
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
 )
 
 // Validation middleware
@@ -15,7 +16,7 @@ func ValidateUUID() gin.HandlerFunc {
 		id := c.Param("id")
 		if id != "" {
 			if _, err := uuid.Parse(id); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+				c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid ID format")})
 				c.Abort()
 				return
 			}
@@ -33,14 +34,14 @@ func ValidateTestKitOrder() gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid request format")})
 			c.Abort()
 			return
 		}
 
 		// Validate quantity
 		if req.Quantity <= 0 || req.Quantity > 10 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Quantity must be between 1 and 10"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Quantity must be between 1 and 10")})
 			c.Abort()
 			return
 		}
@@ -59,7 +60,7 @@ func ValidateAppointment() gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid request format")})
 			c.Abort()
 			return
 		}
@@ -67,14 +68,14 @@ func ValidateAppointment() gin.HandlerFunc {
 		// Parse and validate scheduled time
 		scheduledAt, err := time.Parse(time.RFC3339, req.ScheduledAt)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid date format")})
 			c.Abort()
 			return
 		}
 
 		// Check if appointment is in the future
 		if scheduledAt.Before(time.Now()) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Appointment must be scheduled for a future date"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Appointment must be scheduled for a future date")})
 			c.Abort()
 			return
 		}
@@ -82,14 +83,14 @@ func ValidateAppointment() gin.HandlerFunc {
 		// Check if appointment is within business hours (8 AM - 8 PM)
 		hour := scheduledAt.Hour()
 		if hour < 8 || hour > 20 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Appointments must be scheduled between 8 AM and 8 PM"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Appointments must be scheduled between 8 AM and 8 PM")})
 			c.Abort()
 			return
 		}
 
 		// Validate duration
 		if req.Duration < 15 || req.Duration > 120 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Duration must be between 15 and 120 minutes"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Duration must be between 15 and 120 minutes")})
 			c.Abort()
 			return
 		}
@@ -107,14 +108,14 @@ func ValidateEmail() gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid request format")})
 			c.Abort()
 			return
 		}
 
 		email := strings.ToLower(strings.TrimSpace(req.Email))
 		if !isValidEmail(email) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid email format")})
 			c.Abort()
 			return
 		}
@@ -132,7 +133,7 @@ func ValidateKenyanPhone() gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid request format")})
 			c.Abort()
 			return
 		}
@@ -142,7 +143,7 @@ func ValidateKenyanPhone() gin.HandlerFunc {
 
 		// Validate Kenyan phone number format
 		if !isValidKenyanPhone(phone) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Kenyan phone number format"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": i18n.T(c.GetString("lang"), "Invalid Kenyan phone number format")})
 			c.Abort()
 			return
 		}
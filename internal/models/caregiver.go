@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Areas of a patient's care a caregiver can be allowed to act on
+const (
+	CaregiverScopeOrders         = "orders"           // test kit orders and their payments
+	CaregiverScopeTestKitResults = "test_kit_results" // submitting and reading test kit results
+	CaregiverScopeSymptomChecks  = "symptom_checks"
+	CaregiverScopeLabBookings    = "lab_bookings"
+	CaregiverScopeTelehealth     = "telehealth"
+	CaregiverScopeMedicalRecords = "medical_records" // the medical record, medication doses and FHIR exchange
+)
+
+// CaregiverScopes lists every scope, which dependant profiles are created with
+var CaregiverScopes = []string{
+	CaregiverScopeOrders,
+	CaregiverScopeTestKitResults,
+	CaregiverScopeSymptomChecks,
+	CaregiverScopeLabBookings,
+	CaregiverScopeTelehealth,
+	CaregiverScopeMedicalRecords,
+}
+
+// Caregiver link statuses
+const (
+	CaregiverLinkActive  = "active"
+	CaregiverLinkRevoked = "revoked"
+)
+
+// CaregiverLink lets a caregiver act on behalf of a patient, within its scopes.
+// The patient is either a dependant profile the caregiver manages, or a patient
+// with their own account who delegated access.
+type CaregiverLink struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CaregiverID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"caregiver_id"`
+	PatientID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"patient_id"`
+	Relationship string     `json:"relationship"` // parent, child, spouse, sibling, guardian, other
+	Scopes       []string   `gorm:"type:text[]" json:"scopes"`
+	Status       string     `gorm:"not null;index" json:"status"`
+	GrantedByID  uuid.UUID  `gorm:"type:uuid;not null" json:"granted_by_id"`
+	RevokedByID  *uuid.UUID `gorm:"type:uuid" json:"revoked_by_id,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	Caregiver    *User      `gorm:"foreignKey:CaregiverID" json:"caregiver,omitempty"`
+	Patient      *User      `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// HasScope reports whether the link allows acting on an area of care
+func (l *CaregiverLink) HasScope(scope string) bool {
+	for _, s := range l.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CaregiverAuditEntry records a request a caregiver made, or tried to make, on
+// behalf of a patient
+type CaregiverAuditEntry struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	LinkID      *uuid.UUID `gorm:"type:uuid;index" json:"link_id,omitempty"`
+	CaregiverID uuid.UUID  `gorm:"type:uuid;not null;index" json:"caregiver_id"`
+	PatientID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"patient_id"`
+	Scope       string     `json:"scope"`
+	Method      string     `json:"method"`
+	Path        string     `json:"path"`
+	StatusCode  int        `json:"status_code"`
+	Allowed     bool       `json:"allowed"`
+	IPAddress   string     `json:"ip_address"`
+	UserAgent   string     `json:"user_agent"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (l *CaregiverLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *CaregiverAuditEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
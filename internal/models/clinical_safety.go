@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SafetyAlert is a problem found when checking a medication against a patient's
+// allergies, chronic conditions and other medications
+type SafetyAlert struct {
+	Type        string `json:"type"`       // interaction, allergy, condition, duplicate
+	Severity    string `json:"severity"`   // minor, moderate, major, contraindicated
+	Medication  string `json:"medication"` // medication being added
+	Against     string `json:"against"`    // interacting medication, allergy or condition
+	Description string `json:"description"`
+}
+
+// Safety alert types
+const (
+	SafetyAlertInteraction = "interaction"
+	SafetyAlertAllergy     = "allergy"
+	SafetyAlertCondition   = "condition"
+	SafetyAlertDuplicate   = "duplicate"
+)
+
+// Safety alert severities, from least to most serious
+const (
+	SafetySeverityMinor           = "minor"
+	SafetySeverityModerate        = "moderate"
+	SafetySeverityMajor           = "major"
+	SafetySeverityContraindicated = "contraindicated"
+)
+
+// MedicationSafetyCheck is the audit record of a safety check that raised alerts,
+// including who overrode a blocking alert and why
+type MedicationSafetyCheck struct {
+	ID             uuid.UUID     `gorm:"type:uuid;primaryKey" json:"id"`
+	PatientID      uuid.UUID     `gorm:"type:uuid;not null;index" json:"patient_id"`
+	CheckedByID    uuid.UUID     `gorm:"type:uuid;not null" json:"checked_by_id"`
+	Role           string        `json:"role"`
+	Context        string        `gorm:"index" json:"context"` // prescription_medications, prescription_approval, prescription_extraction, prescription_refill, medication
+	PrescriptionID *uuid.UUID    `gorm:"type:uuid;index" json:"prescription_id,omitempty"`
+	MedicationID   *uuid.UUID    `gorm:"type:uuid;index" json:"medication_id,omitempty"`
+	Medications    []string      `gorm:"type:text[]" json:"medications"`
+	Alerts         []SafetyAlert `gorm:"type:jsonb;serializer:json" json:"alerts"`
+	Outcome        string        `json:"outcome"` // warned, blocked, overridden
+	OverrideReason string        `json:"override_reason,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// Safety check contexts
+const (
+	SafetyContextPrescriptionMedications = "prescription_medications"
+	SafetyContextPrescriptionApproval    = "prescription_approval"
+	SafetyContextPrescriptionExtraction  = "prescription_extraction"
+	SafetyContextPrescriptionRefill      = "prescription_refill"
+	SafetyContextMedication              = "medication"
+)
+
+// Safety check outcomes
+const (
+	SafetyOutcomeWarned     = "warned"
+	SafetyOutcomeBlocked    = "blocked"
+	SafetyOutcomeOverridden = "overridden"
+)
+
+func (sc *MedicationSafetyCheck) BeforeCreate(tx *gorm.DB) error {
+	if sc.ID == uuid.Nil {
+		sc.ID = uuid.New()
+	}
+	return nil
+}
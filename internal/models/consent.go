@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Purposes a patient can consent to
+const (
+	ConsentPurposeAIAnalysis      = "ai_analysis"      // sending symptoms, test kit photos and prescriptions to an external AI model
+	ConsentPurposeResearch        = "research"         // use of de-identified data in research
+	ConsentPurposeProviderSharing = "provider_sharing" // sharing the medical record with a named clinician
+	ConsentPurposeMarketing       = "marketing"        // promotional messages
+)
+
+// ConsentPurposes lists every purpose in the order they are presented
+var ConsentPurposes = []string{
+	ConsentPurposeAIAnalysis,
+	ConsentPurposeProviderSharing,
+	ConsentPurposeResearch,
+	ConsentPurposeMarketing,
+}
+
+// ConsentDocument is a version of the text a patient agrees to for a purpose.
+// Documents are never edited; a change is published as the next version.
+type ConsentDocument struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Purpose           string     `gorm:"not null;uniqueIndex:idx_consent_document_version" json:"purpose"`
+	Version           int        `gorm:"not null;uniqueIndex:idx_consent_document_version" json:"version"`
+	Title             string     `gorm:"not null" json:"title"`
+	Body              string     `gorm:"type:text;not null" json:"body"`
+	RequiresReconsent bool       `json:"requires_reconsent"` // Consent given to earlier versions lapses once this version is published
+	PublishedByID     *uuid.UUID `gorm:"type:uuid" json:"published_by_id,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// ConsentGrant records a patient agreeing to a consent document. Grants are kept
+// after they are revoked, as the history of what the patient agreed to and when.
+type ConsentGrant struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Purpose         string     `gorm:"not null;index" json:"purpose"`
+	DocumentID      uuid.UUID  `gorm:"type:uuid;not null" json:"document_id"`
+	DocumentVersion int        `json:"document_version"`
+	ProviderID      *uuid.UUID `gorm:"type:uuid;index" json:"provider_id,omitempty"` // Clinician the record is shared with, for provider_sharing
+	GrantedAt       time.Time  `json:"granted_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"` // Optional end of a provider sharing grant
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+	IPAddress       string     `json:"ip_address"`
+	UserAgent       string     `json:"user_agent"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (d *ConsentDocument) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+func (g *ConsentGrant) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
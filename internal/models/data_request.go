@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DataExport is a patient's request for a copy of their data. A background job
+// builds a ZIP archive of JSON, a PDF summary and their uploaded files, stored as
+// a private File that expires after a while.
+type DataExport struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status      string         `gorm:"index" json:"status"`                // pending, processing, completed, failed, expired
+	FileID      *uuid.UUID     `gorm:"type:uuid" json:"file_id,omitempty"` // The archive, fetched through GET /files/:id
+	FileCount   int            `json:"file_count"`                         // Uploaded files included in the archive
+	Size        int64          `json:"size"`                               // Size of the archive in bytes
+	Attempts    int            `json:"attempts"`
+	Error       string         `json:"-"` // Last failure, for operators
+	StartedAt   *time.Time     `json:"started_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time     `json:"expires_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Data export statuses
+const (
+	DataExportPending    = "pending"
+	DataExportProcessing = "processing"
+	DataExportCompleted  = "completed"
+	DataExportFailed     = "failed"
+	DataExportExpired    = "expired"
+)
+
+// AccountDeletion is a patient's request to erase their account. It takes effect
+// after a grace period during which it can be cancelled. The request is kept as a
+// record of the erasure, after the account's data has gone.
+type AccountDeletion struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status       string     `gorm:"index" json:"status"` // scheduled, processing, cancelled, completed, failed
+	Reason       string     `json:"reason,omitempty"`
+	ScheduledFor time.Time  `gorm:"index" json:"scheduled_for"` // End of the grace period
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	Attempts     int        `json:"attempts"`
+	Error        string     `json:"-"` // Last failure, for operators
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Account deletion statuses
+const (
+	AccountDeletionScheduled  = "scheduled"
+	AccountDeletionProcessing = "processing"
+	AccountDeletionCancelled  = "cancelled"
+	AccountDeletionCompleted  = "completed"
+	AccountDeletionFailed     = "failed"
+)
+
+func (e *DataExport) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+func (d *AccountDeletion) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
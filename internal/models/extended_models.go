@@ -8,19 +8,40 @@ import (
 )
 
 type Prescription struct {
-	ID            uuid.UUID                `gorm:"type:uuid;primaryKey" json:"id"`
-	UserID        uuid.UUID                `gorm:"type:uuid;not null" json:"user_id"`
-	DoctorID      uuid.UUID                `gorm:"type:uuid;not null" json:"doctor_id"`
-	ImageURL      string                   `json:"image_url"`
-	Status        string                   `json:"status"` // uploaded, processing, approved, rejected, dispensed
-	Notes         string                   `json:"notes"`
-	PharmacyNotes string                   `json:"pharmacy_notes"`
-	Medications   []PrescriptionMedication `gorm:"foreignKey:PrescriptionID" json:"medications"`
-	CreatedAt     time.Time                `json:"created_at"`
-	UpdatedAt     time.Time                `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt           `gorm:"index" json:"-"`
-	User          User                     `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Doctor        User                     `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
+	ID                     uuid.UUID                `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID                 uuid.UUID                `gorm:"type:uuid;not null" json:"user_id"`
+	DoctorID               *uuid.UUID               `gorm:"type:uuid" json:"doctor_id,omitempty"` // Set when the prescriber is a clinician on the platform
+	PrescriberName         string                   `json:"prescriber_name"`
+	PrescriberRegistration string                   `json:"prescriber_registration"` // Practitioner board registration number
+	ImageURL               string                   `json:"image_url"`
+	Status                 string                   `gorm:"index" json:"status"` // uploaded, processing, approved, rejected, accepted, paid, out_for_delivery, dispensed, cancelled
+	Notes                  string                   `json:"notes"`
+	PharmacyNotes          string                   `json:"pharmacy_notes"`
+	PharmacistID           *uuid.UUID               `gorm:"type:uuid;index" json:"pharmacist_id,omitempty"` // Pharmacist handling the prescription
+	RejectionReason        string                   `json:"rejection_reason,omitempty"`
+	QuoteTotal             float64                  `json:"quote_total"` // Price of the available medication lines
+	QuotedAt               *time.Time               `json:"quoted_at,omitempty"`
+	AcceptedAt             *time.Time               `json:"accepted_at,omitempty"`
+	PaymentStatus          string                   `json:"payment_status"` // pending, paid
+	PaidAt                 *time.Time               `json:"paid_at,omitempty"`
+	DeliveryMethod         string                   `json:"delivery_method,omitempty"` // delivery, pickup
+	DeliveryAddress        string                   `json:"delivery_address,omitempty"`
+	ContactNumber          string                   `json:"contact_number,omitempty"`
+	Courier                string                   `json:"courier,omitempty"`
+	TrackingNumber         string                   `json:"tracking_number,omitempty"`
+	DispatchedAt           *time.Time               `json:"dispatched_at,omitempty"`
+	DispensedAt            *time.Time               `json:"dispensed_at,omitempty"`
+	SafetyAlerts           []SafetyAlert            `gorm:"type:jsonb;serializer:json" json:"safety_alerts"`           // Latest safety check of the medication lines
+	OriginalPrescriptionID *uuid.UUID               `gorm:"type:uuid;index" json:"original_prescription_id,omitempty"` // Set on refill orders, to the prescription being repeated
+	RefillsRemaining       int                      `json:"refills_remaining"`
+	NextRefillAt           *time.Time               `json:"next_refill_at,omitempty"` // When the last dispensed supply is expected to run out
+	RefillReminderSentAt   *time.Time               `json:"-"`
+	Medications            []PrescriptionMedication `gorm:"foreignKey:PrescriptionID" json:"medications"`
+	CreatedAt              time.Time                `json:"created_at"`
+	UpdatedAt              time.Time                `json:"updated_at"`
+	DeletedAt              gorm.DeletedAt           `gorm:"index" json:"-"`
+	User                   User                     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Doctor                 *User                    `gorm:"foreignKey:DoctorID" json:"doctor,omitempty"`
 }
 
 type PrescriptionMedication struct {
@@ -31,13 +52,27 @@ type PrescriptionMedication struct {
 	Frequency      string         `json:"frequency"`
 	Duration       string         `json:"duration"`
 	Instructions   string         `json:"instructions"`
-	Price          float64        `json:"price"`
-	Available      bool           `gorm:"default:true" json:"available"`
+	Quantity       int            `gorm:"default:1" json:"quantity"`
+	Price          float64        `json:"price"` // Unit price
+	Available      bool           `json:"available"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Prescription statuses
+const (
+	PrescriptionStatusUploaded       = "uploaded"
+	PrescriptionStatusProcessing     = "processing"
+	PrescriptionStatusApproved       = "approved"
+	PrescriptionStatusRejected       = "rejected"
+	PrescriptionStatusAccepted       = "accepted"
+	PrescriptionStatusPaid           = "paid"
+	PrescriptionStatusOutForDelivery = "out_for_delivery"
+	PrescriptionStatusDispensed      = "dispensed"
+	PrescriptionStatusCancelled      = "cancelled"
+)
+
 type LabTest struct {
 	ID                      uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	Name                    string         `json:"name"`
@@ -51,6 +86,7 @@ type LabTest struct {
 	CreatedAt               time.Time      `json:"created_at"`
 	UpdatedAt               time.Time      `json:"updated_at"`
 	DeletedAt               gorm.DeletedAt `gorm:"index" json:"-"`
+	Analytes                []LabAnalyte   `gorm:"foreignKey:LabTestID" json:"analytes,omitempty"`
 }
 
 type LabBooking struct {
@@ -75,21 +111,29 @@ type LabBooking struct {
 }
 
 type LabResult struct {
-	ID              uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
-	LabBookingID    uuid.UUID      `gorm:"type:uuid;not null" json:"lab_booking_id"`
-	UserID          uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
-	ResultData      string         `json:"result_data"` // JSON string with test values
-	ReferenceRanges string         `json:"reference_ranges"`
-	Interpretation  string         `json:"interpretation"`
-	DoctorComments  string         `json:"doctor_comments"`
-	ResultDate      time.Time      `json:"result_date"`
-	VerifiedBy      string         `json:"verified_by"`
-	ReportURL       string         `json:"report_url"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
-	LabBooking      LabBooking     `gorm:"foreignKey:LabBookingID" json:"lab_booking,omitempty"`
-	User            User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID               uuid.UUID        `gorm:"type:uuid;primaryKey" json:"id"`
+	LabBookingID     uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex" json:"lab_booking_id"`
+	UserID           uuid.UUID        `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status           string           `gorm:"index" json:"status"` // pending_verification, verified
+	Interpretation   string           `json:"interpretation"`
+	DoctorComments   string           `json:"doctor_comments"`
+	ResultDate       time.Time        `json:"result_date"`
+	EnteredByID      uuid.UUID        `gorm:"type:uuid" json:"entered_by_id"`
+	VerifiedBy       string           `json:"verified_by"` // Name of the lab technician who verified the result
+	VerifiedByID     *uuid.UUID       `gorm:"type:uuid" json:"verified_by_id,omitempty"`
+	VerifiedAt       *time.Time       `json:"verified_at,omitempty"`
+	Critical         bool             `gorm:"index" json:"critical"` // At least one value is outside its critical limits
+	EscalatedToID    *uuid.UUID       `gorm:"type:uuid;index" json:"escalated_to_id,omitempty"`
+	EscalatedAt      *time.Time       `json:"escalated_at,omitempty"`
+	AcknowledgedByID *uuid.UUID       `gorm:"type:uuid" json:"acknowledged_by_id,omitempty"`
+	AcknowledgedAt   *time.Time       `json:"acknowledged_at,omitempty"`
+	ReportURL        string           `json:"report_url"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt   `gorm:"index" json:"-"`
+	Values           []LabResultValue `gorm:"foreignKey:LabResultID" json:"values,omitempty"`
+	LabBooking       LabBooking       `gorm:"foreignKey:LabBookingID" json:"lab_booking,omitempty"`
+	User             User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
 }
 
 // Health Education models
@@ -188,6 +232,7 @@ type SymptomCheck struct {
 	Recommendations  string         `json:"recommendations"`
 	UrgencyLevel     string         `json:"urgency_level"` // low, medium, high, emergency
 	FollowUpRequired bool           `json:"follow_up_required"`
+	AnalysisSource   string         `json:"analysis_source"` // model, rules, fallback
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
@@ -214,6 +259,7 @@ type CareSenseAnalytics struct {
 type Payment struct {
 	ID            uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	OrderID       uuid.UUID      `gorm:"type:uuid;not null" json:"order_id"`
+	OrderType     string         `gorm:"default:'test_kit_order'" json:"order_type"` // test_kit_order, prescription
 	UserID        uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
 	Amount        float64        `json:"amount"`
 	Currency      string         `json:"currency"`
@@ -228,20 +274,39 @@ type Payment struct {
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// What a payment's OrderID refers to
+const (
+	PaymentOrderTestKit      = "test_kit_order"
+	PaymentOrderPrescription = "prescription"
+)
+
 type TestKitResult struct {
 	ID               uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
-	UserID           uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
+	UserID           uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_test_kit_results_idempotency" json:"user_id"`
 	OrderID          uuid.UUID      `gorm:"type:uuid" json:"order_id"`
 	TestKitID        uuid.UUID      `gorm:"type:uuid" json:"test_kit_id"`
 	ImageURL         string         `json:"image_url"`
-	Result           string         `json:"result"`        // positive, negative, inconclusive
+	Result           string         `json:"result"`        // One of the kit type's result values
 	AIConfidence     float64        `json:"ai_confidence"` // 0-1 confidence level
 	DetectedMarkers  []string       `gorm:"type:text[]" json:"detected_markers"`
 	RecommendedSteps []string       `gorm:"type:text[]" json:"recommended_steps"`
 	Notes            string         `json:"notes"`
+	KitType          string         `json:"kit_type"`                     // Kit catalog code the result was validated against
+	AnalysisSource   string         `json:"analysis_source"`              // model, rules, fallback
 	ReviewedBy       *uuid.UUID     `gorm:"type:uuid" json:"reviewed_by"` // Healthcare professional who reviewed
 	ReviewNotes      string         `json:"review_notes"`
-	Status           string         `json:"status"` // pending, reviewed, confirmed
+	Status           string         `json:"status"` // processing, failed, pending, in_review, reviewed, confirmed
+	IdempotencyKey   *string        `gorm:"uniqueIndex:idx_test_kit_results_idempotency" json:"-"`
+	RequestHash      string         `json:"-"` // Fingerprint of the upload the idempotency key was first used with
+	AnalysisAttempts int            `json:"analysis_attempts"`
+	AnalysisError    string         `json:"-"`                            // Last pipeline error, for operators
+	ReviewPriority   int            `gorm:"index" json:"review_priority"` // Higher is reviewed first
+	ReviewDueAt      *time.Time     `json:"review_due_at,omitempty"`
+	ClaimedBy        *uuid.UUID     `gorm:"type:uuid;index" json:"claimed_by,omitempty"`
+	ClaimedAt        *time.Time     `json:"claimed_at,omitempty"`
+	ReviewDecision   string         `json:"review_decision,omitempty"` // confirmed, overridden
+	AIResult         string         `json:"ai_result,omitempty"`       // Original AI result when overridden
+	ReviewedAt       *time.Time     `json:"reviewed_at,omitempty"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
 	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
@@ -252,6 +317,22 @@ type TestKitResult struct {
 	// Reviewer         *User          `gorm:"foreignKey:ReviewedBy" json:"reviewer,omitempty"`
 }
 
+// Test kit result statuses
+const (
+	TestKitResultStatusProcessing = "processing"
+	TestKitResultStatusFailed     = "failed"
+	TestKitResultStatusPending    = "pending"
+	TestKitResultStatusInReview   = "in_review"
+	TestKitResultStatusReviewed   = "reviewed"
+	TestKitResultStatusConfirmed  = "confirmed"
+)
+
+// Clinician review decisions
+const (
+	ReviewDecisionConfirmed  = "confirmed"
+	ReviewDecisionOverridden = "overridden"
+)
+
 func (p *Prescription) BeforeCreate(tx *gorm.DB) error {
 	if p.ID == uuid.Nil {
 		p.ID = uuid.New()
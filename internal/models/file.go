@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Resource types a stored file can belong to
+const (
+	FileResourceTestKitResult = "test_kit_result"
+	FileResourcePrescription  = "prescription"
+	FileResourceLabResult     = "lab_result"
+	FileResourceTestKit       = "test_kit"
+	FileResourceHealthArticle = "health_article"
+	FileResourceDataExport    = "data_export" // only ever readable by the owner
+)
+
+// File is an uploaded file in blob storage. Private files are never exposed by
+// URL; clients fetch a short-lived signed URL from GET /files/:id instead.
+// Files not linked to a resource are garbage collected, and identical uploads
+// share a StorageKey, so a blob is only deleted once no file refers to it.
+type File struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	OwnerID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"owner_id"` // User who uploaded the file
+	StorageKey    string         `gorm:"not null;index" json:"-"`
+	Provider      string         `json:"-"` // cloudinary, s3, local
+	Filename      string         `json:"filename"`
+	ContentType   string         `json:"content_type"`
+	Purpose       string         `gorm:"index" json:"purpose"` // Upload folder, e.g. prescriptions, test_results
+	Size          int64          `json:"size"`
+	Checksum      string         `gorm:"index" json:"checksum"` // hex SHA-256 of the content
+	Private       bool           `json:"private"`
+	ScannedBy     string         `json:"scanned_by,omitempty"` // Scanner that passed the file; empty when scanning is disabled
+	ScannedAt     *time.Time     `json:"scanned_at,omitempty"`
+	ResourceType  string         `gorm:"index:idx_files_resource" json:"resource_type,omitempty"` // test_kit_result, prescription, lab_result, test_kit, health_article, data_export
+	ResourceID    *uuid.UUID     `gorm:"type:uuid;index:idx_files_resource" json:"resource_id,omitempty"`
+	PurgeFailedAt *time.Time     `json:"-"` // Last failed attempt to delete the blob of a deleted file
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// FileAccessLog records every request for a file, including refused ones
+type FileAccessLog struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	FileID    uuid.UUID `gorm:"type:uuid;not null;index" json:"file_id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Role      string    `json:"role"`
+	Allowed   bool      `json:"allowed"`
+	Reason    string    `json:"reason"` // owner, clinician, pharmacist, resource_owner, denied
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Upload scan outcomes recorded as audit events
+const (
+	FileScanInfected = "infected"
+	FileScanFailed   = "scan_failed"
+)
+
+// FileScanEvent records an upload the scanner rejected or could not scan
+type FileScanEvent struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Folder      string    `json:"folder"`
+	Filename    string    `json:"filename"`
+	Outcome     string    `gorm:"index" json:"outcome"` // infected, scan_failed
+	Scanner     string    `json:"scanner"`
+	Signature   string    `json:"signature,omitempty"` // Detected threat
+	Detail      string    `json:"detail,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	IPAddress   string    `json:"ip_address"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (f *File) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+func (l *FileAccessLog) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == uuid.Nil {
+		l.ID = uuid.New()
+	}
+	return nil
+}
+
+func (e *FileScanEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
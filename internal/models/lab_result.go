@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of value an analyte reports
+const (
+	LabValueNumeric = "numeric"
+	LabValueText    = "text" // qualitative, such as positive or negative
+)
+
+// LabAnalyte is one value a lab test measures, such as haemoglobin in a full
+// blood count
+type LabAnalyte struct {
+	ID              uuid.UUID           `gorm:"type:uuid;primaryKey" json:"id"`
+	LabTestID       uuid.UUID           `gorm:"type:uuid;not null;uniqueIndex:idx_lab_analyte_code" json:"lab_test_id"`
+	Code            string              `gorm:"not null;uniqueIndex:idx_lab_analyte_code" json:"code"` // Short code the lab reports it under, such as HGB
+	Name            string              `gorm:"not null" json:"name"`
+	LOINCCode       string              `json:"loinc_code,omitempty"`
+	Unit            string              `json:"unit"`
+	ValueType       string              `gorm:"default:'numeric'" json:"value_type"` // numeric, text
+	DisplayOrder    int                 `json:"display_order"`
+	ReferenceRanges []LabReferenceRange `gorm:"foreignKey:AnalyteID" json:"reference_ranges"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// LabReferenceRange is the normal range of an analyte for patients of a sex and
+// age band. Empty sex applies to everyone; the most specific matching range is used.
+type LabReferenceRange struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	AnalyteID    uuid.UUID `gorm:"type:uuid;not null;index" json:"analyte_id"`
+	Sex          string    `json:"sex,omitempty"`            // male, female, or empty for any
+	MinAgeMonths int       `json:"min_age_months"`           // inclusive
+	MaxAgeMonths *int      `json:"max_age_months,omitempty"` // exclusive; no upper bound when empty
+	Low          *float64  `json:"low,omitempty"`            // values below are flagged L
+	High         *float64  `json:"high,omitempty"`           // values above are flagged H
+	CriticalLow  *float64  `json:"critical_low,omitempty"`   // values below are flagged LL and escalated
+	CriticalHigh *float64  `json:"critical_high,omitempty"`  // values above are flagged HH and escalated
+	NormalText   string    `json:"normal_text,omitempty"`    // expected text value, such as Negative
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Abnormal flags on a lab value, following the HL7 v2 abnormal flag codes
+const (
+	LabFlagNormal       = "N"
+	LabFlagLow          = "L"
+	LabFlagHigh         = "H"
+	LabFlagCriticalLow  = "LL"
+	LabFlagCriticalHigh = "HH"
+	LabFlagAbnormal     = "A" // a text value other than the expected one
+)
+
+// LabResultValue is one analyte's value in a lab result. The analyte's name,
+// unit and the reference range applied are copied so the result still reads
+// the same after the test's definition changes.
+type LabResultValue struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	LabResultID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"lab_result_id"`
+	AnalyteID     *uuid.UUID `gorm:"type:uuid" json:"analyte_id,omitempty"`
+	Code          string     `json:"code"`
+	Name          string     `json:"name"`
+	LOINCCode     string     `json:"loinc_code,omitempty"`
+	Value         *float64   `json:"value,omitempty"`
+	ValueText     string     `json:"value_text,omitempty"`
+	Unit          string     `json:"unit"`
+	ReferenceLow  *float64   `json:"reference_low,omitempty"`
+	ReferenceHigh *float64   `json:"reference_high,omitempty"`
+	ReferenceText string     `json:"reference_text,omitempty"` // the range as printed on the report
+	Flag          string     `json:"flag,omitempty"`           // N, L, H, LL, HH or A; empty when no range applies
+	Critical      bool       `json:"critical"`
+	DisplayOrder  int        `json:"display_order"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Lab result statuses
+const (
+	LabResultStatusPendingVerification = "pending_verification"
+	LabResultStatusVerified            = "verified"
+)
+
+func (a *LabAnalyte) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+func (r *LabReferenceRange) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+func (v *LabResultValue) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == uuid.Nil {
+		v.ID = uuid.New()
+	}
+	return nil
+}
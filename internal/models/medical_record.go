@@ -7,20 +7,20 @@ import (
 	"gorm.io/gorm"
 )
 
+// MedicalRecord is a patient's single, canonical health record
 type MedicalRecord struct {
-	ID                uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	UserID            uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	BloodType         string    `json:"blood_type"`
-	Allergies         []string  `gorm:"type:text[]" json:"allergies"`
-	ChronicConditions []string  `gorm:"type:text[]" json:"chronic_conditions"`
-	FamilyHistory     string    `json:"family_history"`
-	// Temporarily disabled foreign key relationships for migration
-	// Medications       []Medication   `gorm:"foreignKey:MedicalRecordID" json:"medications"`
-	// TestResults       []TestResult   `gorm:"foreignKey:MedicalRecordID" json:"test_results"`
-	// Consultations     []Consultation `gorm:"foreignKey:MedicalRecordID" json:"consultations"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID            uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_medical_records_user,where:deleted_at IS NULL" json:"user_id"`
+	BloodType         string         `json:"blood_type"`
+	Allergies         []string       `gorm:"type:text[]" json:"allergies"`
+	ChronicConditions []string       `gorm:"type:text[]" json:"chronic_conditions"`
+	FamilyHistory     string         `json:"family_history"`
+	Medications       []Medication   `gorm:"foreignKey:MedicalRecordID" json:"medications,omitempty"`
+	TestResults       []TestResult   `gorm:"foreignKey:MedicalRecordID" json:"test_results,omitempty"`
+	Consultations     []Consultation `gorm:"foreignKey:MedicalRecordID" json:"consultations,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type Medication struct {
@@ -29,10 +29,14 @@ type Medication struct {
 	Name            string         `json:"name"`
 	Dosage          string         `json:"dosage"`
 	Frequency       string         `json:"frequency"`
+	DoseTimes       []string       `gorm:"type:text[]" json:"dose_times"` // "HH:MM" times of day doses are due, parsed from the frequency
+	AsNeeded        bool           `json:"as_needed"`                     // taken when required, with no scheduled doses
 	StartDate       time.Time      `json:"start_date"`
 	EndDate         *time.Time     `json:"end_date,omitempty"`
 	PrescribedBy    string         `json:"prescribed_by"`
 	Notes           string         `json:"notes"`
+	SourceType      string         `gorm:"uniqueIndex:idx_medications_source" json:"source_type,omitempty"` // fhir_import when imported from another health system
+	SourceID        *uuid.UUID     `gorm:"type:uuid;uniqueIndex:idx_medications_source" json:"source_id,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
@@ -47,6 +51,8 @@ type TestResult struct {
 	Interpretation  string         `json:"interpretation"`
 	LabName         string         `json:"lab_name"`
 	DoctorNotes     string         `json:"doctor_notes"`
+	SourceType      string         `gorm:"uniqueIndex:idx_test_results_source" json:"source_type,omitempty"` // test_kit_result or lab_result when merged from another record, fhir_import when imported
+	SourceID        *uuid.UUID     `gorm:"type:uuid;uniqueIndex:idx_test_results_source" json:"source_id,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
@@ -55,18 +61,29 @@ type TestResult struct {
 type Consultation struct {
 	ID              uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
 	MedicalRecordID uuid.UUID      `gorm:"type:uuid;not null" json:"medical_record_id"`
-	DoctorID        uuid.UUID      `gorm:"type:uuid;not null" json:"doctor_id"`
+	DoctorID        *uuid.UUID     `gorm:"type:uuid" json:"doctor_id,omitempty"` // set when the clinician is on the platform
+	DoctorName      string         `json:"doctor_name"`
 	Date            time.Time      `json:"date"`
 	Type            string         `json:"type"` // in-person, telehealth
 	Diagnosis       string         `json:"diagnosis"`
 	Treatment       string         `json:"treatment"`
 	Notes           string         `json:"notes"`
 	FollowUpDate    *time.Time     `json:"follow_up_date,omitempty"`
+	SourceType      string         `gorm:"uniqueIndex:idx_consultations_source" json:"source_type,omitempty"` // telehealth_session when merged from a session, fhir_import when imported
+	SourceID        *uuid.UUID     `gorm:"type:uuid;uniqueIndex:idx_consultations_source" json:"source_id,omitempty"`
 	CreatedAt       time.Time      `json:"created_at"`
 	UpdatedAt       time.Time      `json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// Sources of medications, test results and consultations merged into a medical record
+const (
+	RecordSourceTestKitResult = "test_kit_result"
+	RecordSourceLabResult     = "lab_result"
+	RecordSourceTelehealth    = "telehealth_session"
+	RecordSourceFHIRImport    = "fhir_import" // imported from another health system's FHIR bundle
+)
+
 func (m *MedicalRecord) BeforeCreate(tx *gorm.DB) error {
 	if m.ID == uuid.Nil {
 		m.ID = uuid.New()
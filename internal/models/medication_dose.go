@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MedicationDose is one dose of a medication, scheduled from its dose times or
+// logged by the patient when taken as needed
+type MedicationDose struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	MedicationID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_medication_dose_time" json:"medication_id"`
+	UserID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	ScheduledAt  time.Time      `gorm:"not null;uniqueIndex:idx_medication_dose_time;index" json:"scheduled_at"`
+	Unscheduled  bool           `json:"unscheduled"` // logged outside the schedule, for as-needed medication
+	Status       string         `gorm:"type:varchar(20);not null;index" json:"status"`
+	TakenAt      *time.Time     `json:"taken_at,omitempty"`
+	LoggedAt     *time.Time     `json:"logged_at,omitempty"`
+	Note         string         `json:"note,omitempty"`
+	RemindedAt   *time.Time     `json:"reminded_at,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Medication dose statuses
+const (
+	DoseStatusPending = "pending"
+	DoseStatusTaken   = "taken"
+	DoseStatusSkipped = "skipped"
+	DoseStatusMissed  = "missed"
+)
+
+func (d *MedicationDose) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
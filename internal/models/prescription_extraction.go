@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ExtractedField is a value read from a prescription with the reader's confidence in it
+type ExtractedField struct {
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"` // 0-1
+}
+
+// ExtractedMedication is a proposed medication line read from a prescription
+type ExtractedMedication struct {
+	Name         ExtractedField `json:"name"`
+	Dosage       ExtractedField `json:"dosage"`
+	Frequency    ExtractedField `json:"frequency"`
+	Duration     ExtractedField `json:"duration"`
+	Instructions ExtractedField `json:"instructions"`
+}
+
+// ExtractionFlag points the pharmacist at a field that is missing or could not be read reliably
+type ExtractionFlag struct {
+	Field   string `json:"field"` // e.g. prescriber_registration, medications[0].dosage
+	Issue   string `json:"issue"` // missing, illegible
+	Message string `json:"message,omitempty"`
+}
+
+// Extraction flag issues
+const (
+	ExtractionIssueMissing   = "missing"
+	ExtractionIssueIllegible = "illegible"
+)
+
+// PrescriptionExtraction is one automated reading of a prescription image. Its
+// medication lines are proposals only; a pharmacist confirms them by applying
+// the extraction and then edits, prices and approves the prescription as usual.
+type PrescriptionExtraction struct {
+	ID                     uuid.UUID             `gorm:"type:uuid;primaryKey" json:"id"`
+	PrescriptionID         uuid.UUID             `gorm:"type:uuid;not null;index" json:"prescription_id"`
+	Status                 string                `gorm:"index" json:"status"` // pending, completed, failed, unsupported
+	Source                 string                `json:"source,omitempty"`    // model, rules, fallback
+	OCREngine              string                `json:"ocr_engine,omitempty"`
+	OCRText                string                `gorm:"type:text" json:"ocr_text,omitempty"`
+	OCRConfidence          float64               `json:"ocr_confidence"`
+	PrescriberName         ExtractedField        `gorm:"type:jsonb;serializer:json" json:"prescriber_name"`
+	PrescriberRegistration ExtractedField        `gorm:"type:jsonb;serializer:json" json:"prescriber_registration"`
+	PrescriptionDate       ExtractedField        `gorm:"type:jsonb;serializer:json" json:"prescription_date"`
+	Medications            []ExtractedMedication `gorm:"type:jsonb;serializer:json" json:"medications"`
+	Flags                  []ExtractionFlag      `gorm:"type:jsonb;serializer:json" json:"flags"`
+	Error                  string                `json:"error,omitempty"`
+	Attempts               int                   `json:"attempts"`
+	RequestedByID          *uuid.UUID            `gorm:"type:uuid" json:"requested_by_id,omitempty"` // Pharmacist who re-ran the extraction; empty for the upload
+	AppliedByID            *uuid.UUID            `gorm:"type:uuid" json:"applied_by_id,omitempty"`
+	AppliedAt              *time.Time            `json:"applied_at,omitempty"`
+	CompletedAt            *time.Time            `json:"completed_at,omitempty"`
+	CreatedAt              time.Time             `json:"created_at"`
+	UpdatedAt              time.Time             `json:"updated_at"`
+	DeletedAt              gorm.DeletedAt        `gorm:"index" json:"-"`
+}
+
+// Prescription extraction statuses
+const (
+	ExtractionStatusPending     = "pending"
+	ExtractionStatusCompleted   = "completed"
+	ExtractionStatusFailed      = "failed"
+	ExtractionStatusUnsupported = "unsupported" // the upload is not an image, e.g. a PDF
+)
+
+func (pe *PrescriptionExtraction) BeforeCreate(tx *gorm.DB) error {
+	if pe.ID == uuid.Nil {
+		pe.ID = uuid.New()
+	}
+	return nil
+}
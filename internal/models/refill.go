@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefillRequest is a patient's request to repeat a dispensed prescription. Once a
+// clinician approves it, a refill order is created for the pharmacy to fill.
+type RefillRequest struct {
+	ID                   uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	PrescriptionID       uuid.UUID      `gorm:"type:uuid;not null;index" json:"prescription_id"` // Original prescription being repeated
+	UserID               uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status               string         `gorm:"index" json:"status"` // requested, approved, denied, fulfilled, cancelled
+	Renewal              bool           `json:"renewal"`             // No refills remained, so the clinician must authorise more
+	Notes                string         `json:"notes"`
+	ReviewedByID         *uuid.UUID     `gorm:"type:uuid" json:"reviewed_by_id,omitempty"`
+	ReviewedAt           *time.Time     `json:"reviewed_at,omitempty"`
+	ReviewNotes          string         `json:"review_notes,omitempty"`
+	DenialReason         string         `json:"denial_reason,omitempty"`
+	RefillPrescriptionID *uuid.UUID     `gorm:"type:uuid;index" json:"refill_prescription_id,omitempty"` // Refill order created on approval
+	CreatedAt            time.Time      `json:"created_at"`
+	UpdatedAt            time.Time      `json:"updated_at"`
+	DeletedAt            gorm.DeletedAt `gorm:"index" json:"-"`
+	Prescription         *Prescription  `gorm:"foreignKey:PrescriptionID" json:"prescription,omitempty"`
+}
+
+// Refill request statuses
+const (
+	RefillStatusRequested = "requested"
+	RefillStatusApproved  = "approved"
+	RefillStatusDenied    = "denied"
+	RefillStatusFulfilled = "fulfilled"
+	RefillStatusCancelled = "cancelled"
+)
+
+func (r *RefillRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -13,6 +13,7 @@ type TestKit struct {
 	Description  string         `json:"description"`
 	Price        float64        `json:"price"`
 	Category     string         `json:"category"`
+	KitType      string         `json:"kit_type"` // Kit catalog code, e.g. hiv_self_test
 	Stock        int            `json:"stock"`
 	ImageURL     string         `json:"image_url"`
 	Instructions string         `json:"instructions"`
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Translatable resource types
+const (
+	TranslationResourceHealthArticle = "health_article"
+	TranslationResourceTestKit       = "test_kit"
+	TranslationResourceLabTest       = "lab_test"
+)
+
+// TranslatableFields lists the fields that can be translated for each resource type
+var TranslatableFields = map[string][]string{
+	TranslationResourceHealthArticle: {"title", "summary", "content"},
+	TranslationResourceTestKit:       {"instructions"},
+	TranslationResourceLabTest:       {"preparation_instructions"},
+}
+
+// ContentTranslation stores a translated value for a single field of a content record.
+// English is the source language and lives on the record itself.
+type ContentTranslation struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	ResourceType string         `gorm:"uniqueIndex:idx_content_translation;not null" json:"resource_type"` // health_article, test_kit, lab_test
+	ResourceID   uuid.UUID      `gorm:"type:uuid;uniqueIndex:idx_content_translation;not null" json:"resource_id"`
+	Language     string         `gorm:"uniqueIndex:idx_content_translation;not null" json:"language"`
+	Field        string         `gorm:"uniqueIndex:idx_content_translation;not null" json:"field"`
+	Value        string         `gorm:"type:text" json:"value"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (t *ContentTranslation) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
@@ -8,21 +8,23 @@ import (
 )
 
 type User struct {
-	ID          uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
-	Email       string         `gorm:"uniqueIndex;not null" json:"email"`
-	Password    string         `gorm:"not null" json:"-"`
-	FirstName   string         `json:"first_name"`
-	LastName    string         `json:"last_name"`
-	PhoneNumber string         `gorm:"uniqueIndex" json:"phone_number"`
-	DateOfBirth *time.Time     `json:"date_of_birth,omitempty"`
-	Gender      string         `json:"gender"`
-	Address     string         `json:"address"`
-	Role        string         `gorm:"default:'patient'" json:"role"` // patient, doctor, nurse, admin
-	IsVerified  bool           `gorm:"default:false" json:"is_verified"`
-	LastLoginAt *time.Time     `json:"last_login_at"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uuid.UUID      `gorm:"type:uuid;primaryKey" json:"id"`
+	Email             string         `gorm:"uniqueIndex;not null" json:"email"`
+	Password          string         `gorm:"not null" json:"-"`
+	FirstName         string         `json:"first_name"`
+	LastName          string         `json:"last_name"`
+	PhoneNumber       string         `gorm:"uniqueIndex" json:"phone_number"`
+	DateOfBirth       *time.Time     `json:"date_of_birth,omitempty"`
+	Gender            string         `json:"gender"`
+	Address           string         `json:"address"`
+	Role              string         `gorm:"default:'patient'" json:"role"` // patient, doctor, nurse, pharmacist, lab_technician, admin
+	IsVerified        bool           `gorm:"default:false" json:"is_verified"`
+	LastLoginAt       *time.Time     `json:"last_login_at"`
+	PreferredLanguage string         `gorm:"default:'en'" json:"preferred_language"`         // en, sw
+	ManagedByID       *uuid.UUID     `gorm:"type:uuid;index" json:"managed_by_id,omitempty"` // Caregiver managing a dependant profile, which cannot sign in
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
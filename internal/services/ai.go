@@ -2,32 +2,92 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/http"
 	"strings"
 
 	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
 	"github.com/nyumbanicare/internal/models"
 	"github.com/sashabaranov/go-openai"
 )
 
 type AIService struct {
-	config       *config.ExternalConfig
-	openaiClient *openai.Client
+	config   *config.ExternalConfig
+	provider LLMProvider
+	fallback LLMProvider
 }
 
 func NewAIService(cfg *config.ExternalConfig) *AIService {
-	client := openai.NewClient(cfg.ChatGPTAPIKey)
+	provider := NewLLMProvider(cfg)
+
+	var fallback LLMProvider
+	if provider.Name() != LLMProviderRules {
+		fallback = NewRulesProvider()
+	}
+
+	return &AIService{
+		config:   cfg,
+		provider: provider,
+		fallback: fallback,
+	}
+}
+
+// ProviderName returns the name of the primary LLM provider
+func (ai *AIService) ProviderName() string {
+	return ai.provider.Name()
+}
+
+// ForPatient returns the service to use on a patient's data. Without their consent
+// to AI analysis nothing is sent to a model: the rules provider, which runs on
+// the platform, answers every request instead.
+func (ai *AIService) ForPatient(aiConsent bool) *AIService {
+	if aiConsent || ai.provider.Name() == LLMProviderRules {
+		return ai
+	}
 	return &AIService{
-		config:       cfg,
-		openaiClient: client,
+		config:   ai.config,
+		provider: NewRulesProvider(),
+	}
+}
+
+// modelFor returns the model configured for a feature
+func (ai *AIService) modelFor(feature string) string {
+	var model string
+	switch feature {
+	case LLMFeatureSymptoms:
+		model = ai.config.SymptomsModel
+	case LLMFeatureAnalytics:
+		model = ai.config.AnalyticsModel
+	case LLMFeatureVision, LLMFeaturePrescription:
+		model = ai.config.VisionModel
+	}
+	if model == "" {
+		model = ai.config.ChatGPTModel
+	}
+	return model
+}
+
+// complete sends a request for a feature to the primary provider and records usage
+func (ai *AIService) complete(req LLMRequest) (*LLMResponse, error) {
+	req.Model = ai.modelFor(req.Feature)
+
+	resp, err := ai.provider.Complete(context.Background(), req)
+	if err == nil {
+		resp.Usage.CostUSD = llmCallCost(ai.config, resp.Model, resp.Usage)
 	}
+	recordLLMUsage(req.Feature, ai.provider.Name(), req.Model, resp, err)
+	return resp, err
 }
 
 type SymptomCheckRequest struct {
 	Symptoms []string `json:"symptoms"`
 	Age      int      `json:"age"`
 	Gender   string   `json:"gender"`
+	Language string   `json:"language"` // en, sw
 }
 
 type SymptomCheckResponse struct {
@@ -35,6 +95,9 @@ type SymptomCheckResponse struct {
 	Recommendations    []string            `json:"recommendations"`
 	Urgency            string              `json:"urgency"`
 	Confidence         float64             `json:"confidence"`
+	Source             string              `json:"source"` // model, rules, fallback
+	RedFlags           []RedFlag           `json:"red_flags,omitempty"`
+	EmergencyGuidance  []string            `json:"emergency_guidance,omitempty"`
 }
 
 type PossibleCondition struct {
@@ -48,6 +111,7 @@ type AnalyticsRequest struct {
 	DataType  string                 `json:"data_type"`
 	TimeRange string                 `json:"time_range"`
 	Data      map[string]interface{} `json:"data"`
+	Language  string                 `json:"language"` // en, sw
 }
 
 type AnalyticsResponse struct {
@@ -55,6 +119,7 @@ type AnalyticsResponse struct {
 	Patterns  []string `json:"patterns"`
 	Insights  []string `json:"insights"`
 	RiskScore float64  `json:"risk_score"`
+	Source    string   `json:"source"` // model, rules, fallback
 }
 
 type Trend struct {
@@ -64,142 +129,141 @@ type Trend struct {
 }
 
 type TestKitResultRequest struct {
-	TestKitType string `json:"test_kit_type"`
-	ImageURL    string `json:"image_url"`
+	TestKitType      string `json:"test_kit_type"`
+	ImageURL         string `json:"image_url"`
+	ImageData        []byte `json:"-"` // uploaded image, used for line detection and inline vision input
+	ImageContentType string `json:"-"`
+	ImagePrivate     bool   `json:"-"`        // the model cannot fetch ImageURL, so the image is sent inline
+	Language         string `json:"language"` // en, sw
 }
 
 type TestKitResultResponse struct {
-	Result           string   `json:"result"`           // positive, negative, inconclusive
-	Confidence       float64  `json:"confidence"`       // 0-1 confidence level
-	DetectedMarkers  []string `json:"detected_markers"` // Any markers detected in the test
-	RecommendedSteps []string `json:"recommended_steps"`
-	Notes            string   `json:"notes"`
+	Result           string        `json:"result"`           // one of the kit type's result values
+	Confidence       float64       `json:"confidence"`       // 0-1 confidence level
+	DetectedMarkers  []string      `json:"detected_markers"` // Any markers detected in the test
+	RecommendedSteps []string      `json:"recommended_steps"`
+	Notes            string        `json:"notes"`
+	Source           string        `json:"source"` // model, rules, fallback, line_detection
+	LineAnalysis     *LineAnalysis `json:"line_analysis,omitempty"`
+	KitType          string        `json:"kit_type"`
+	RequiresReview   bool          `json:"requires_review"` // false when the catalog allows auto-confirmation
 }
 
-func (ai *AIService) AnalyzeSymptoms(request SymptomCheckRequest) (*SymptomCheckResponse, error) {
-	if ai.config.ChatGPTAPIKey == "" {
-		return ai.mockSymptomAnalysis(request), nil
+// Prompts are English source strings; translations live in the i18n catalog.
+// Response schemas are never translated so the JSON keys stay stable.
+const (
+	languageInstructionPrompt = "Write all human-readable text values in English. Keep JSON keys and enum values (such as urgency and result) in English."
+
+	symptomSystemPrompt   = "You are a healthcare AI assistant. Respond only with valid JSON following the specified structure."
+	symptomPrompt         = "I need a medical symptom analysis based on these symptoms: %s. The patient is a %d year old %s. Please provide possible conditions, recommendations, and urgency level."
+	symptomResponseSchema = "Format your response as JSON with the following structure: " +
+		"{\"possible_conditions\": [{\"name\": string, \"probability\": float, \"description\": string}], " +
+		"\"recommendations\": [string], \"urgency\": string, \"confidence\": float}"
+
+	analyticsSystemPrompt   = "You are a healthcare analytics AI. Respond only with valid JSON following the specified structure."
+	analyticsPrompt         = "Analyze the following health data for user %s over %s. Data type: %s. Data: %s Generate health insights, trends, patterns, and risk assessment."
+	analyticsResponseSchema = "Format your response as JSON with the following structure: " +
+		"{\"trends\": [{\"metric\": string, \"values\": [float], \"dates\": [string]}], " +
+		"\"patterns\": [string], \"insights\": [string], \"risk_score\": float}"
+
+	testKitSystemPrompt = "You are a medical diagnostic assistant specialized in analyzing test kit results from images. " +
+		"Provide a detailed analysis of the test kit image including: " +
+		"1. Whether the result is positive, negative, or inconclusive " +
+		"2. Your confidence level in the interpretation (0-1) " +
+		"3. Any markers or indicators you can detect " +
+		"4. Recommended next steps based on the result " +
+		"5. Any additional notes or observations. " +
+		"Return your analysis in JSON format that matches the TestKitResultResponse structure."
+	testKitPrompt        = "I'm analyzing a %s result from the attached image. Please interpret the result."
+	testKitCatalogPrompt = "Valid result values for this kit are: %s. Report detected markers only from: %s."
+)
+
+// localizedPrompt joins a translated prompt with the untranslated response schema and language instruction
+func localizedPrompt(lang, prompt, schema string) string {
+	parts := []string{prompt}
+	if schema != "" {
+		parts = append(parts, schema)
 	}
+	parts = append(parts, i18n.T(lang, languageInstructionPrompt))
+	return strings.Join(parts, " ")
+}
 
-	// Create a prompt for GPT
+func (ai *AIService) AnalyzeSymptoms(request SymptomCheckRequest) (*SymptomCheckResponse, error) {
 	symptomsJSON, _ := json.Marshal(request.Symptoms)
-	prompt := fmt.Sprintf(
-		"I need a medical symptom analysis based on these symptoms: %s. "+
-			"The patient is a %d year old %s. "+
-			"Please provide possible conditions, recommendations, and urgency level. "+
-			"Format your response as JSON with the following structure: "+
-			"{\"possible_conditions\": [{\"name\": string, \"probability\": float, \"description\": string}], "+
-			"\"recommendations\": [string], \"urgency\": string, \"confidence\": float}",
-		string(symptomsJSON),
-		request.Age,
-		request.Gender,
+	prompt := localizedPrompt(request.Language,
+		i18n.Tf(request.Language, symptomPrompt, string(symptomsJSON), request.Age, request.Gender),
+		symptomResponseSchema,
 	)
 
-	ctx := context.Background()
-	resp, err := ai.openaiClient.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: ai.config.ChatGPTModel,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are a healthcare AI assistant. Respond only with valid JSON following the specified structure.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: 0.2,
+	var response SymptomCheckResponse
+	source, err := ai.structuredCompletion(LLMRequest{
+		Feature: LLMFeatureSymptoms,
+		Messages: []LLMMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: i18n.T(request.Language, symptomSystemPrompt)},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
-	)
-
+		Temperature: 0.2,
+		Input:       request,
+	}, request.Language, symptomJSONSchema, normalizeSymptomResponse, &response)
 	if err != nil {
-		return nil, fmt.Errorf("ChatGPT API error: %v", err)
-	}
-	var response SymptomCheckResponse
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &response); err != nil {
-		return nil, fmt.Errorf("failed to parse ChatGPT response: %v", err)
+		return nil, err
 	}
 
+	response.Source = source
 	return &response, nil
 }
 
 func (ai *AIService) GenerateHealthAnalytics(request AnalyticsRequest) (*AnalyticsResponse, error) {
-	if ai.config.ChatGPTAPIKey == "" {
-		return ai.mockHealthAnalytics(request), nil
-	}
-
 	dataJSON, _ := json.Marshal(request.Data)
-	prompt := fmt.Sprintf(
-		"Analyze the following health data for user %s over %s. Data type: %s. "+
-			"Data: %s "+
-			"Generate health insights, trends, patterns, and risk assessment. "+
-			"Format your response as JSON with the following structure: "+
-			"{\"trends\": [{\"metric\": string, \"values\": [float], \"dates\": [string]}], "+
-			"\"patterns\": [string], \"insights\": [string], \"risk_score\": float}",
-		request.UserID,
-		request.TimeRange,
-		request.DataType,
-		string(dataJSON),
+	prompt := localizedPrompt(request.Language,
+		i18n.Tf(request.Language, analyticsPrompt, request.UserID, request.TimeRange, request.DataType, string(dataJSON)),
+		analyticsResponseSchema,
 	)
 
-	ctx := context.Background()
-	resp, err := ai.openaiClient.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: ai.config.ChatGPTModel,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "You are a healthcare analytics AI. Respond only with valid JSON following the specified structure.",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: 0.2,
+	var response AnalyticsResponse
+	source, err := ai.structuredCompletion(LLMRequest{
+		Feature: LLMFeatureAnalytics,
+		Messages: []LLMMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: i18n.T(request.Language, analyticsSystemPrompt)},
+			{Role: openai.ChatMessageRoleUser, Content: prompt},
 		},
-	)
-
+		Temperature: 0.2,
+		Input:       request,
+	}, request.Language, analyticsJSONSchema, normalizeAnalyticsResponse, &response)
 	if err != nil {
-		return nil, fmt.Errorf("ChatGPT API error: %v", err)
-	}
-	var response AnalyticsResponse
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &response); err != nil {
-		return nil, fmt.Errorf("failed to parse ChatGPT response: %v", err)
+		return nil, err
 	}
 
+	response.Source = source
 	return &response, nil
 }
 
-func (ai *AIService) mockSymptomAnalysis(request SymptomCheckRequest) *SymptomCheckResponse {
+func mockSymptomAnalysis(request SymptomCheckRequest) *SymptomCheckResponse {
 	conditions := []PossibleCondition{}
 	urgency := "low"
 	if contains(request.Symptoms, "fever") && contains(request.Symptoms, "cough") {
 		conditions = append(conditions, PossibleCondition{
-			Name:        "Upper Respiratory Infection",
+			Name:        i18n.T(request.Language, "Upper Respiratory Infection"),
 			Probability: 0.75,
-			Description: "Common viral infection affecting the upper respiratory tract",
+			Description: i18n.T(request.Language, "Common viral infection affecting the upper respiratory tract"),
 		})
 		urgency = "medium"
 	}
 
 	if contains(request.Symptoms, "chest pain") {
 		conditions = append(conditions, PossibleCondition{
-			Name:        "Chest Pain Syndrome",
+			Name:        i18n.T(request.Language, "Chest Pain Syndrome"),
 			Probability: 0.6,
-			Description: "Various conditions that can cause chest discomfort",
+			Description: i18n.T(request.Language, "Various conditions that can cause chest discomfort"),
 		})
 		urgency = "high"
 	}
 
 	if len(conditions) == 0 {
 		conditions = append(conditions, PossibleCondition{
-			Name:        "General Symptoms",
+			Name:        i18n.T(request.Language, "General Symptoms"),
 			Probability: 0.4,
-			Description: "Common symptoms that may indicate various conditions",
+			Description: i18n.T(request.Language, "Common symptoms that may indicate various conditions"),
 		})
 	}
 
@@ -219,13 +283,13 @@ func (ai *AIService) mockSymptomAnalysis(request SymptomCheckRequest) *SymptomCh
 
 	return &SymptomCheckResponse{
 		PossibleConditions: conditions,
-		Recommendations:    recommendations,
+		Recommendations:    i18n.TSlice(request.Language, recommendations),
 		Urgency:            urgency,
 		Confidence:         0.7,
 	}
 }
 
-func (ai *AIService) mockHealthAnalytics(request AnalyticsRequest) *AnalyticsResponse {
+func mockHealthAnalytics(request AnalyticsRequest) *AnalyticsResponse {
 	return &AnalyticsResponse{
 		Trends: []Trend{
 			{
@@ -239,16 +303,16 @@ func (ai *AIService) mockHealthAnalytics(request AnalyticsRequest) *AnalyticsRes
 				Dates:  []string{"2025-05-01", "2025-05-08", "2025-05-15", "2025-05-22", "2025-05-29"},
 			},
 		},
-		Patterns: []string{
+		Patterns: i18n.TSlice(request.Language, []string{
 			"Blood pressure shows stable trend within normal range",
 			"Heart rate variability indicates good cardiovascular health",
 			"Sleep patterns show improvement over the past month",
-		},
-		Insights: []string{
+		}),
+		Insights: i18n.TSlice(request.Language, []string{
 			"Your health metrics show positive trends",
 			"Consider maintaining current lifestyle habits",
 			"Regular monitoring is recommended",
-		},
+		}),
 		RiskScore: 0.2,
 	}
 }
@@ -262,93 +326,190 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-func (ai *AIService) ProcessSymptomCheck(symptomCheck *models.SymptomCheck) error {
-	request := SymptomCheckRequest{
-		Symptoms: symptomCheck.Symptoms,
-		Age:      25,
-		Gender:   "unknown",
+// TriageSymptoms runs the red flag rules around the model analysis. An emergency
+// found in the reported symptoms is returned without consulting the model, and
+// the model can only ever raise the urgency the rules assigned, never lower it.
+func (ai *AIService) TriageSymptoms(request SymptomCheckRequest, severity string) *SymptomCheckResponse {
+	pre := EvaluateSymptomRedFlags(request, severity)
+	if pre.Urgency == UrgencyEmergency {
+		response := &SymptomCheckResponse{
+			PossibleConditions: []PossibleCondition{},
+			Recommendations:    []string{},
+			Confidence:         1.0,
+			Source:             AnalysisSourceRules,
+		}
+		ApplyTriage(response, request.Language, pre)
+		return response
 	}
 
 	response, err := ai.AnalyzeSymptoms(request)
 	if err != nil {
-		return err
+		fmt.Printf("Symptom analysis failed, falling back to rules: %v\n", err)
+		response = mockSymptomAnalysis(request)
+		response.Source = AnalysisSourceFallback
 	}
 
-	conditionsJson, _ := json.Marshal(response.PossibleConditions)
+	ApplyTriage(response, request.Language, pre, EvaluateAnalysisRedFlags(response, request.Language))
+	return response
+}
 
-	symptomCheck.Results = string(conditionsJson)
-	if len(response.Recommendations) > 0 {
-		symptomCheck.Recommendations = response.Recommendations[0]
+// ProcessSymptomCheck analyses a symptom check and stores the structured response on it
+func (ai *AIService) ProcessSymptomCheck(symptomCheck *models.SymptomCheck, language string) (*SymptomCheckResponse, error) {
+	gender := symptomCheck.Gender
+	if gender == "" {
+		gender = "unknown"
 	}
-	symptomCheck.UrgencyLevel = response.Urgency
-	if len(response.Recommendations) > 1 {
-		additionalRecommendations := strings.Join(response.Recommendations[1:], "; ")
-		symptomCheck.Results += fmt.Sprintf(", \"additional_recommendations\": \"%s\"", additionalRecommendations)
+
+	response := ai.TriageSymptoms(SymptomCheckRequest{
+		Symptoms: symptomCheck.Symptoms,
+		Age:      symptomCheck.Age,
+		Gender:   gender,
+		Language: language,
+	}, symptomCheck.Severity)
+
+	results, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode symptom analysis: %v", err)
 	}
 
-	return nil
+	symptomCheck.Results = string(results)
+	symptomCheck.Recommendations = strings.Join(response.Recommendations, "; ")
+	symptomCheck.UrgencyLevel = response.Urgency
+	symptomCheck.AnalysisSource = response.Source
+	symptomCheck.FollowUpRequired = response.Urgency != UrgencyLow
+
+	return response, nil
 }
 
-func (s *AIService) AnalyzeTestKitResult(req *TestKitResultRequest) (*TestKitResultResponse, error) {
-	if s.openaiClient == nil {
-		return nil, fmt.Errorf("OpenAI client not initialized")
+// AnalyzeTestKitResult reads a test kit photo and validates the reading against
+// the kit type's catalog entry, which also decides whether a clinician must review it
+func (ai *AIService) AnalyzeTestKitResult(req *TestKitResultRequest) (*TestKitResultResponse, error) {
+	spec := LookupKitType(req.TestKitType)
+	if spec == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedKitType, req.TestKitType)
 	}
 
-	systemPrompt := "You are a medical diagnostic assistant specialized in analyzing test kit results from images. " +
-		"Provide a detailed analysis of the test kit image including: " +
-		"1. Whether the result is positive, negative, or inconclusive " +
-		"2. Your confidence level in the interpretation (0-1) " +
-		"3. Any markers or indicators you can detect " +
-		"4. Recommended next steps based on the result " +
-		"5. Any additional notes or observations. " +
-		"Return your analysis in JSON format that matches the TestKitResultResponse structure."
+	userPrompt := localizedPrompt(req.Language, strings.Join([]string{
+		i18n.Tf(req.Language, testKitPrompt, i18n.T(req.Language, spec.Name)),
+		i18n.Tf(req.Language, testKitCatalogPrompt, strings.Join(spec.ResultValues, ", "), strings.Join(spec.Markers, ", ")),
+	}, " "), "")
 
-	userPrompt := fmt.Sprintf("I'm analyzing a %s test kit result from this image: %s. Please interpret the result.",
-		req.TestKitType, req.ImageURL)
-
-	completion, err := s.openaiClient.CreateChatCompletion(
-		context.Background(),
-		openai.ChatCompletionRequest{
-			Model: s.config.ChatGPTModel,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: systemPrompt,
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: userPrompt,
-				},
-			},
-			Temperature: 0.2,
-			MaxTokens:   1000,
+	var response TestKitResultResponse
+	source, err := ai.structuredCompletion(LLMRequest{
+		Feature: LLMFeatureVision,
+		Messages: []LLMMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: i18n.T(req.Language, testKitSystemPrompt)},
+			{Role: openai.ChatMessageRoleUser, Content: userPrompt, ImageURL: testKitImageInput(req)},
 		},
-	)
-
+		Temperature: 0.2,
+		MaxTokens:   1000,
+		Input:       *req,
+	}, req.Language, spec.schema(), spec.normalizeResponse, &response)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze test kit result: %w", err)
 	}
+	response.Source = source
+
+	if len(req.ImageData) > 0 && spec.LateralFlow {
+		lines, err := AnalyzeLateralFlowImage(req.ImageData)
+		if err != nil {
+			fmt.Printf("Line detection failed for %s test kit: %v\n", spec.Code, err)
+		} else {
+			crossCheckLineAnalysis(&response, lines, req.Language)
+		}
+	}
+
+	response.KitType = spec.Code
+	response.RecommendedSteps = mergeSteps(i18n.TSlice(req.Language, spec.StandardSteps(response.Result)), response.RecommendedSteps)
+	response.RequiresReview = spec.RequiresReview(response.Result, response.Confidence, response.Source)
+
+	return &response, nil
+}
 
-	if len(completion.Choices) == 0 || completion.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("no response from AI service")
+// mergeSteps puts the standard steps first and keeps any additional model steps, without duplicates
+func mergeSteps(standard, extra []string) []string {
+	steps := make([]string, 0, len(standard)+len(extra))
+	for _, step := range append(append([]string{}, standard...), extra...) {
+		if !contains(steps, step) {
+			steps = append(steps, step)
+		}
 	}
+	return steps
+}
 
-	content := completion.Choices[0].Message.Content
+// testKitImageInput returns the image reference sent to the vision model for a test kit photo
+func testKitImageInput(req *TestKitResultRequest) string {
+	return imageInput(req.ImageURL, req.ImagePrivate, req.ImageData, req.ImageContentType)
+}
 
-	jsonStr := extractJSON(content)
+// imageInput returns the image reference sent to a vision model: the storage
+// URL when the model can fetch it, otherwise the image as a data URI
+func imageInput(imageURL string, private bool, data []byte, contentType string) string {
+	public := strings.HasPrefix(imageURL, "https://") || strings.HasPrefix(imageURL, "http://")
+	if public && !private {
+		return imageURL
+	}
+	if len(data) == 0 {
+		return ""
+	}
 
-	var response TestKitResultResponse
-	if err := json.Unmarshal([]byte(jsonStr), &response); err != nil {
-		return &TestKitResultResponse{
-			Result:           "inconclusive",
-			Confidence:       0.0,
-			DetectedMarkers:  []string{},
-			RecommendedSteps: []string{"Consult a healthcare professional"},
-			Notes:            fmt.Sprintf("Unable to parse result automatically. Raw response: %s", content),
-		}, nil
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
 
-	return &response, nil
+// crossCheckLineAnalysis combines the model verdict with the local line detector.
+// Without a model reading the detector result is used directly; when both read the
+// image and disagree the result is marked inconclusive for clinician review.
+func crossCheckLineAnalysis(response *TestKitResultResponse, lines *LineAnalysis, language string) {
+	response.LineAnalysis = lines
+
+	if response.Source != AnalysisSourceModel {
+		response.Result = lines.Result
+		response.Confidence = lines.Confidence
+		response.Source = AnalysisSourceLineDetection
+		response.DetectedMarkers = lineMarkers(lines)
+		response.Notes = i18n.T(language, "Read by automated line detection. A clinician will review this result.")
+		if !lines.ControlLineDetected {
+			response.Notes = i18n.T(language, "No control line was detected. The test may be invalid.")
+			response.RecommendedSteps = append(response.RecommendedSteps, i18n.T(language, "Repeat the test with a new kit"))
+		}
+		return
+	}
+
+	switch {
+	case !lines.ControlLineDetected:
+		// The detector may have missed the strip, so only temper the model's confidence
+		response.Confidence = math.Min(response.Confidence, 0.5)
+		response.Notes = strings.TrimSpace(response.Notes + " " + i18n.T(language, "Line detection could not find a control line; please confirm the test is valid."))
+	case lines.Result == response.Result:
+		response.Confidence = math.Max(response.Confidence, lines.Confidence)
+	default:
+		response.Notes = strings.TrimSpace(response.Notes + " " + i18n.Tf(language,
+			"The AI reading (%s) disagreed with automated line detection (%s). A clinician will review this result.",
+			i18n.T(language, response.Result), i18n.T(language, lines.Result)))
+		response.Result = "inconclusive"
+		response.Confidence = math.Min(response.Confidence, lines.Confidence)
+	}
+}
+
+func lineMarkers(lines *LineAnalysis) []string {
+	markers := []string{}
+	if lines.ControlLineDetected {
+		markers = append(markers, "control_line")
+	}
+	if lines.TestLineDetected {
+		markers = append(markers, "test_line")
+	}
+	return markers
+}
+
+// chatGPTBaseURL turns the configured chat completions endpoint into the API
+// base URL expected by the client, so OpenAI-compatible servers can be used
+func chatGPTBaseURL(endpoint string) string {
+	endpoint = strings.TrimSuffix(strings.TrimSpace(endpoint), "/")
+	return strings.TrimSuffix(endpoint, "/chat/completions")
 }
 
 func extractJSON(content string) string {
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/sashabaranov/go-openai"
+)
+
+// Analysis sources recorded with every AI response
+const (
+	AnalysisSourceModel    = "model"    // validated model output
+	AnalysisSourceRules    = "rules"    // rules provider by configuration or triage
+	AnalysisSourceFallback = "fallback" // model failed or never produced valid output
+
+	AnalysisSourceLineDetection = "line_detection" // local lateral flow line detector
+)
+
+// maxRepairAttempts is how many times an invalid model reply is sent back for correction
+const maxRepairAttempts = 2
+
+const repairPrompt = "Your previous reply did not match the required JSON structure: %s. Reply again with only the corrected JSON object and no other text."
+
+// jsonSchema is the subset of JSON Schema used to validate AI responses
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []string               `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  int                    `json:"minLength"`
+}
+
+var (
+	symptomJSONSchema = mustParseJSONSchema(`{
+		"type": "object",
+		"required": ["possible_conditions", "recommendations", "urgency", "confidence"],
+		"properties": {
+			"possible_conditions": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["name", "probability"],
+					"properties": {
+						"name": {"type": "string", "minLength": 1},
+						"probability": {"type": "number", "minimum": 0, "maximum": 1},
+						"description": {"type": "string"}
+					}
+				}
+			},
+			"recommendations": {"type": "array", "items": {"type": "string", "minLength": 1}},
+			"urgency": {"type": "string", "enum": ["low", "medium", "high", "emergency"]},
+			"confidence": {"type": "number", "minimum": 0, "maximum": 1}
+		}
+	}`)
+
+	analyticsJSONSchema = mustParseJSONSchema(`{
+		"type": "object",
+		"required": ["patterns", "insights", "risk_score"],
+		"properties": {
+			"trends": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["metric", "values"],
+					"properties": {
+						"metric": {"type": "string", "minLength": 1},
+						"values": {"type": "array", "items": {"type": "number"}},
+						"dates": {"type": "array", "items": {"type": "string"}}
+					}
+				}
+			},
+			"patterns": {"type": "array", "items": {"type": "string"}},
+			"insights": {"type": "array", "items": {"type": "string"}},
+			"risk_score": {"type": "number", "minimum": 0, "maximum": 1}
+		}
+	}`)
+
+	testKitJSONSchema = mustParseJSONSchema(`{
+		"type": "object",
+		"required": ["result", "confidence"],
+		"properties": {
+			"result": {"type": "string", "enum": ["positive", "negative", "inconclusive"]},
+			"confidence": {"type": "number", "minimum": 0, "maximum": 1},
+			"detected_markers": {"type": "array", "items": {"type": "string"}},
+			"recommended_steps": {"type": "array", "items": {"type": "string"}},
+			"notes": {"type": "string"}
+		}
+	}`)
+)
+
+func mustParseJSONSchema(raw string) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		panic("invalid AI response schema: " + err.Error())
+	}
+	return &schema
+}
+
+// validate returns a description of every violation found in value
+func (s *jsonSchema) validate(path string, value interface{}) []string {
+	if path == "" {
+		path = "$"
+	}
+	if value == nil {
+		return []string{fmt.Sprintf("%s must not be null", path)}
+	}
+
+	var errs []string
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an object", path)}
+		}
+		for _, key := range s.Required {
+			if _, ok := obj[key]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s is required", path, key))
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if child, ok := obj[key]; ok {
+				errs = append(errs, s.Properties[key].validate(path+"."+key, child)...)
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s must be an array", path)}
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s must be a string", path)}
+		}
+		if len(strings.TrimSpace(str)) < s.MinLength {
+			errs = append(errs, fmt.Sprintf("%s must not be empty", path))
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			errs = append(errs, fmt.Sprintf("%s must be one of %s, got %q", path, strings.Join(s.Enum, ", "), str))
+		}
+	case "number":
+		num, ok := value.(float64)
+		if !ok {
+			return []string{fmt.Sprintf("%s must be a number", path)}
+		}
+		if s.Minimum != nil && num < *s.Minimum {
+			errs = append(errs, fmt.Sprintf("%s must be at least %g, got %g", path, *s.Minimum, num))
+		}
+		if s.Maximum != nil && num > *s.Maximum {
+			errs = append(errs, fmt.Sprintf("%s must be at most %g, got %g", path, *s.Maximum, num))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s must be a boolean", path)}
+		}
+	}
+
+	return errs
+}
+
+// NormalizeTestKitResult maps model wording such as "reactive" or "invalid" onto positive, negative or inconclusive
+func NormalizeTestKitResult(result string) string {
+	switch strings.ToLower(strings.TrimSpace(result)) {
+	case "positive", "pos", "reactive", "detected", "+":
+		return "positive"
+	case "negative", "neg", "non-reactive", "nonreactive", "non reactive", "not detected", "-":
+		return "negative"
+	case "inconclusive", "invalid", "indeterminate", "unclear", "equivocal", "unknown":
+		return "inconclusive"
+	default:
+		return result
+	}
+}
+
+// normalizeEnum rewrites a string field with the given normalizer, leaving unknown values for validation to reject
+func normalizeEnum(obj map[string]interface{}, key string, normalize func(string) string) {
+	value, ok := obj[key].(string)
+	if !ok {
+		return
+	}
+	if normalized := normalize(value); normalized != "" {
+		obj[key] = normalized
+	}
+}
+
+// normalizeFraction converts percentages such as 85 into 0.85
+func normalizeFraction(obj map[string]interface{}, key string) {
+	if value, ok := obj[key].(float64); ok && value > 1 && value <= 100 {
+		obj[key] = value / 100
+	}
+}
+
+// defaultArray replaces a missing or null optional array with an empty one
+func defaultArray(obj map[string]interface{}, key string) {
+	if obj[key] == nil {
+		obj[key] = []interface{}{}
+	}
+}
+
+func normalizeSymptomResponse(obj map[string]interface{}) {
+	normalizeEnum(obj, "urgency", NormalizeUrgency)
+	normalizeFraction(obj, "confidence")
+	if conditions, ok := obj["possible_conditions"].([]interface{}); ok {
+		for _, condition := range conditions {
+			if condition, ok := condition.(map[string]interface{}); ok {
+				normalizeFraction(condition, "probability")
+			}
+		}
+	}
+}
+
+func normalizeAnalyticsResponse(obj map[string]interface{}) {
+	normalizeFraction(obj, "risk_score")
+	defaultArray(obj, "trends")
+}
+
+func normalizeTestKitResponse(obj map[string]interface{}) {
+	normalizeEnum(obj, "result", NormalizeTestKitResult)
+	normalizeFraction(obj, "confidence")
+	defaultArray(obj, "detected_markers")
+	defaultArray(obj, "recommended_steps")
+}
+
+// parseStructuredResponse extracts, normalises and validates a model reply, decoding it into out when valid
+func parseStructuredResponse(content string, schema *jsonSchema, normalize func(map[string]interface{}), out interface{}) []string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(extractJSON(content)), &value); err != nil {
+		return []string{fmt.Sprintf("reply is not valid JSON: %v", err)}
+	}
+
+	if obj, ok := value.(map[string]interface{}); ok {
+		normalize(obj)
+	}
+
+	if errs := schema.validate("", value); len(errs) > 0 {
+		return errs
+	}
+
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if err := json.Unmarshal(normalized, out); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// structuredCompletion requests JSON from the provider, re-asking with the
+// validation errors until the reply passes the schema. If the model fails or
+// never produces a valid reply the rules provider answers instead. It returns
+// the analysis source.
+func (ai *AIService) structuredCompletion(req LLMRequest, language string, schema *jsonSchema, normalize func(map[string]interface{}), out interface{}) (string, error) {
+	if ai.provider.Name() == LLMProviderRules {
+		return AnalysisSourceRules, ai.rulesCompletion(ai.provider, req, schema, normalize, out)
+	}
+
+	for attempt := 0; attempt <= maxRepairAttempts; attempt++ {
+		resp, err := ai.complete(req)
+		if err != nil {
+			break
+		}
+
+		errs := parseStructuredResponse(resp.Content, schema, normalize, out)
+		if len(errs) == 0 {
+			return AnalysisSourceModel, nil
+		}
+
+		fmt.Printf("Invalid %s response from %s (attempt %d): %s\n", req.Feature, resp.Provider, attempt+1, strings.Join(errs, "; "))
+		req.Messages = append(req.Messages,
+			LLMMessage{Role: openai.ChatMessageRoleAssistant, Content: resp.Content},
+			LLMMessage{Role: openai.ChatMessageRoleUser, Content: i18n.Tf(language, repairPrompt, strings.Join(errs, "; "))},
+		)
+	}
+
+	if ai.fallback == nil {
+		return "", fmt.Errorf("no valid %s response from %s", req.Feature, ai.provider.Name())
+	}
+	return AnalysisSourceFallback, ai.rulesCompletion(ai.fallback, req, schema, normalize, out)
+}
+
+func (ai *AIService) rulesCompletion(provider LLMProvider, req LLMRequest, schema *jsonSchema, normalize func(map[string]interface{}), out interface{}) error {
+	resp, err := provider.Complete(context.Background(), req)
+	recordLLMUsage(req.Feature, provider.Name(), LLMProviderRules, resp, err)
+	if err != nil {
+		return err
+	}
+	if errs := parseStructuredResponse(resp.Content, schema, normalize, out); len(errs) > 0 {
+		return fmt.Errorf("invalid %s response from %s: %s", req.Feature, provider.Name(), strings.Join(errs, "; "))
+	}
+	return nil
+}
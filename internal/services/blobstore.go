@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// Storage providers
+const (
+	StorageProviderCloudinary = "cloudinary"
+	StorageProviderLocal      = "local"
+	StorageProviderS3         = "s3"
+)
+
+// ErrBlobNotFound is returned when a stored object does not exist
+var ErrBlobNotFound = errors.New("stored object not found")
+
+// BlobStore stores file content under keys chosen by the caller
+type BlobStore interface {
+	Name() string
+	// Put streams size bytes from src to key
+	Put(ctx context.Context, key string, src io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// URL is the long-lived address recorded with a stored file
+	URL(key string) string
+	// SignedURL grants temporary read access to a stored file
+	SignedURL(key string, ttl time.Duration) (string, error)
+	// KeyForURL recovers the key from a URL returned by URL or SignedURL
+	KeyForURL(fileURL string) (string, bool)
+}
+
+// NewBlobStore returns the blob store for the configured storage provider
+func NewBlobStore(cfg *config.StorageConfig) (BlobStore, error) {
+	switch cfg.Provider {
+	case StorageProviderCloudinary:
+		return NewCloudinaryBlobStore(cfg)
+	case StorageProviderLocal:
+		return NewLocalBlobStore(cfg)
+	case StorageProviderS3:
+		return NewS3BlobStore(cfg)
+	default:
+		return nil, fmt.Errorf("storage provider not configured or unsupported: %s", cfg.Provider)
+	}
+}
+
+// privateKeyPrefix marks keys that must only be served through expiring signed URLs
+const privateKeyPrefix = "private/"
+
+// IsPrivateKey reports whether a key holds a private file
+func IsPrivateKey(key string) bool {
+	return strings.HasPrefix(key, privateKeyPrefix)
+}
+
+// contentKey names a file by its SHA-256 checksum so user-supplied filenames never
+// become storage paths, and identical uploads share one object
+func contentKey(prefix, folder, checksum, filename, contentType string) string {
+	return path.Join(prefix, folder, checksum[:2], checksum+fileExtension(filename, contentType))
+}
+
+// fileExtension keeps a short alphanumeric extension from the filename, or derives one from the content type
+func fileExtension(filename, contentType string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	valid := len(ext) > 1 && len(ext) <= 6
+	for _, r := range strings.TrimPrefix(ext, ".") {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			valid = false
+		}
+	}
+	if valid {
+		return ext
+	}
+
+	switch strings.Split(contentType, ";")[0] {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "application/pdf":
+		return ".pdf"
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cloudinary/cloudinary-go/v2"
+	"github.com/cloudinary/cloudinary-go/v2/api"
+	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
+	"github.com/nyumbanicare/internal/config"
+)
+
+// cloudinaryVersion matches the optional version segment in delivery URLs
+var cloudinaryVersion = regexp.MustCompile(`^v\d+/`)
+
+// CloudinaryBlobStore stores files as Cloudinary assets whose public ID is the key without its extension
+type CloudinaryBlobStore struct {
+	cloudName  string
+	cloudinary *cloudinary.Cloudinary
+	client     *http.Client
+}
+
+func NewCloudinaryBlobStore(cfg *config.StorageConfig) (*CloudinaryBlobStore, error) {
+	if cfg.CloudName == "" || cfg.APIKey == "" || cfg.APISecret == "" {
+		return nil, fmt.Errorf("Cloudinary storage requires CLOUDINARY_CLOUD_NAME, CLOUDINARY_API_KEY and CLOUDINARY_API_SECRET")
+	}
+
+	cld, err := cloudinary.NewFromParams(cfg.CloudName, cfg.APIKey, cfg.APISecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Cloudinary: %v", err)
+	}
+
+	return &CloudinaryBlobStore{
+		cloudName:  cfg.CloudName,
+		cloudinary: cld,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (s *CloudinaryBlobStore) Name() string {
+	return StorageProviderCloudinary
+}
+
+func (s *CloudinaryBlobStore) Put(ctx context.Context, key string, src io.Reader, size int64, contentType string) error {
+	overwrite := false
+	result, err := s.cloudinary.Upload.Upload(ctx, src, uploader.UploadParams{
+		PublicID:     cloudinaryPublicID(key),
+		Overwrite:    &overwrite,
+		ResourceType: cloudinaryResourceType(key),
+		Type:         cloudinaryDeliveryType(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to Cloudinary: %v", err)
+	}
+	if result.Error.Message != "" {
+		return fmt.Errorf("failed to upload to Cloudinary: %s", result.Error.Message)
+	}
+	return nil
+}
+
+func (s *CloudinaryBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	fileURL := s.URL(key)
+	if IsPrivateKey(key) {
+		signed, err := s.SignedURL(key, time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		fileURL = signed
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download from Cloudinary: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download from Cloudinary: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (s *CloudinaryBlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.cloudinary.Upload.Destroy(ctx, uploader.DestroyParams{
+		PublicID:     cloudinaryPublicID(key),
+		Type:         string(cloudinaryDeliveryType(key)),
+		ResourceType: cloudinaryResourceType(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete from Cloudinary: %v", err)
+	}
+	return nil
+}
+
+// URL returns the delivery URL; private assets are not viewable through it without a signature
+func (s *CloudinaryBlobStore) URL(key string) string {
+	return fmt.Sprintf("https://res.cloudinary.com/%s/%s/%s/%s", s.cloudName, cloudinaryResourceType(key), cloudinaryDeliveryType(key), key)
+}
+
+// SignedURL returns a Cloudinary private download URL that expires after ttl
+func (s *CloudinaryBlobStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	expiresAt := time.Now().Add(ttl)
+	params := uploader.PrivateDownloadURLParams{
+		PublicID:     cloudinaryPublicID(key),
+		DeliveryType: string(cloudinaryDeliveryType(key)),
+		ExpiresAt:    &expiresAt,
+		ResourceType: api.AssetType(cloudinaryResourceType(key)),
+	}
+	if params.ResourceType != "raw" {
+		params.Format = strings.TrimPrefix(path.Ext(key), ".")
+	}
+	return s.cloudinary.Upload.PrivateDownloadURL(params)
+}
+
+func (s *CloudinaryBlobStore) KeyForURL(fileURL string) (string, bool) {
+	for _, deliveryType := range []api.DeliveryType{api.Upload, api.Authenticated} {
+		parts := strings.SplitN(fileURL, "/"+string(deliveryType)+"/", 2)
+		if len(parts) == 2 && strings.Contains(parts[0], "res.cloudinary.com/"+s.cloudName+"/") {
+			return cloudinaryVersion.ReplaceAllString(parts[1], ""), true
+		}
+	}
+	return "", false
+}
+
+// cloudinaryDeliveryType uploads private files as authenticated assets, which need a signed URL to view
+func cloudinaryDeliveryType(key string) api.DeliveryType {
+	if IsPrivateKey(key) {
+		return api.Authenticated
+	}
+	return api.Upload
+}
+
+// cloudinaryPublicID drops the extension, which Cloudinary stores as the asset format
+func cloudinaryPublicID(key string) string {
+	if cloudinaryResourceType(key) == "raw" {
+		return key
+	}
+	return strings.TrimSuffix(key, path.Ext(key))
+}
+
+// cloudinaryResourceType serves images and PDFs as images and everything else as raw files
+func cloudinaryResourceType(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic", ".pdf":
+		return "image"
+	default:
+		return "raw"
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// LocalFileRoute is where the API serves files from the local blob store
+const LocalFileRoute = "/api/v1/storage/local/"
+
+// LocalBlobStore keeps files on disk and serves them through signed API routes.
+// It is meant for development and tests.
+type LocalBlobStore struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+func NewLocalBlobStore(cfg *config.StorageConfig) (*LocalBlobStore, error) {
+	if cfg.SigningKey == "" {
+		return nil, fmt.Errorf("local storage requires STORAGE_SIGNING_KEY or JWT_SECRET_KEY")
+	}
+
+	root, err := filepath.Abs(cfg.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid local storage path: %v", err)
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory: %v", err)
+	}
+
+	return &LocalBlobStore{
+		root:       root,
+		baseURL:    strings.TrimSuffix(cfg.PublicBaseURL, "/"),
+		signingKey: []byte(cfg.SigningKey),
+	}, nil
+}
+
+func (s *LocalBlobStore) Name() string {
+	return StorageProviderLocal
+}
+
+// Path maps a key to a file under the storage root, refusing keys that escape it
+func (s *LocalBlobStore) Path(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", fmt.Errorf("invalid storage key: %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, src io.Reader, size int64, contentType string) error {
+	dest, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("short write: wrote %d of %d bytes", written, size)
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
+
+func (s *LocalBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	file, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete file: %v", err)
+	}
+	return nil
+}
+
+// URL returns a signed URL that does not expire. Private files get an unsigned
+// URL, which the file route refuses; they need SignedURL.
+func (s *LocalBlobStore) URL(key string) string {
+	if IsPrivateKey(key) {
+		return s.baseURL + LocalFileRoute + (&url.URL{Path: key}).EscapedPath()
+	}
+	return s.signedURL(key, 0)
+}
+
+func (s *LocalBlobStore) SignedURL(key string, ttl time.Duration) (string, error) {
+	return s.signedURL(key, time.Now().Add(ttl).Unix()), nil
+}
+
+func (s *LocalBlobStore) signedURL(key string, expires int64) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.sign(key, expires))
+	return s.baseURL + LocalFileRoute + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode()
+}
+
+func (s *LocalBlobStore) KeyForURL(fileURL string) (string, bool) {
+	parsed, err := url.Parse(fileURL)
+	if err != nil {
+		return "", false
+	}
+	index := strings.Index(parsed.Path, LocalFileRoute)
+	if index == -1 {
+		return "", false
+	}
+	return parsed.Path[index+len(LocalFileRoute):], true
+}
+
+// Verify checks a signed URL's signature and expiry. Expires 0 never expires,
+// which is only allowed for public files.
+func (s *LocalBlobStore) Verify(key, expires, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return false
+	}
+	if expiresAt == 0 && IsPrivateKey(key) {
+		return false
+	}
+	if expiresAt != 0 && time.Now().Unix() > expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.sign(key, expiresAt)))
+}
+
+func (s *LocalBlobStore) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+func testLocalStore(t *testing.T) *LocalBlobStore {
+	t.Helper()
+	store, err := NewLocalBlobStore(&config.StorageConfig{
+		LocalPath:     t.TempDir(),
+		PublicBaseURL: "https://api.example.com",
+		SigningKey:    "local-signing-key",
+	})
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+	return store
+}
+
+// signedQuery splits a signed URL into the key, expiry and signature the file route checks
+func signedQuery(t *testing.T, store *LocalBlobStore, signed string) (string, string, string) {
+	t.Helper()
+	parsed, err := url.Parse(signed)
+	if err != nil {
+		t.Fatalf("parse %s: %v", signed, err)
+	}
+	key, ok := store.KeyForURL(signed)
+	if !ok {
+		t.Fatalf("KeyForURL(%s) failed", signed)
+	}
+	return key, parsed.Query().Get("expires"), parsed.Query().Get("signature")
+}
+
+func TestLocalBlobStorePutOpenDelete(t *testing.T) {
+	store := testLocalStore(t)
+	ctx := context.Background()
+
+	key := "private/lab_reports/ab/report.pdf"
+	if err := store.Put(ctx, key, strings.NewReader("report"), 6, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	body, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if !bytes.Equal(got, []byte("report")) {
+		t.Errorf("Open returned %q", got)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Open after Delete returned %v, want ErrBlobNotFound", err)
+	}
+
+	if err := store.Put(ctx, "general/short.txt", strings.NewReader("abc"), 10, "text/plain"); err == nil {
+		t.Error("Put accepted fewer bytes than the declared size")
+	}
+	if _, err := store.Path("../outside.txt"); err == nil {
+		t.Error("Path accepted a key outside the storage root")
+	}
+}
+
+func TestLocalBlobStoreVerify(t *testing.T) {
+	store := testLocalStore(t)
+	key := "private/prescriptions/cd/scan.jpg"
+
+	signed, err := store.SignedURL(key, time.Minute)
+	if err != nil {
+		t.Fatalf("SignedURL: %v", err)
+	}
+	urlKey, expires, signature := signedQuery(t, store, signed)
+	if urlKey != key {
+		t.Fatalf("KeyForURL = %q, want %q", urlKey, key)
+	}
+	if !store.Verify(key, expires, signature) {
+		t.Fatal("a fresh signed URL did not verify")
+	}
+
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+	tests := []struct {
+		name      string
+		key       string
+		expires   string
+		signature string
+	}{
+		{"tampered signature", key, expires, string(tampered)},
+		{"other key", "private/prescriptions/cd/other.jpg", expires, signature},
+		{"extended expiry", key, strconv.FormatInt(expiresAt+3600, 10), signature},
+		{"never expiring private file", key, "0", store.sign(key, 0)},
+		{"invalid expiry", key, "soon", signature},
+	}
+	for _, tt := range tests {
+		if store.Verify(tt.key, tt.expires, tt.signature) {
+			t.Errorf("%s: Verify accepted the URL", tt.name)
+		}
+	}
+
+	expired, _ := store.SignedURL(key, -time.Minute)
+	_, expires, signature = signedQuery(t, store, expired)
+	if store.Verify(key, expires, signature) {
+		t.Error("Verify accepted an expired URL")
+	}
+
+	other, err := NewLocalBlobStore(&config.StorageConfig{LocalPath: t.TempDir(), SigningKey: "another-key"})
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore: %v", err)
+	}
+	_, expires, signature = signedQuery(t, store, signed)
+	if other.Verify(key, expires, signature) {
+		t.Error("a URL signed with another key verified")
+	}
+}
+
+func TestLocalBlobStorePublicURL(t *testing.T) {
+	store := testLocalStore(t)
+
+	_, expires, signature := signedQuery(t, store, store.URL("test_kits/ab/kit.jpg"))
+	if expires != "0" || !store.Verify("test_kits/ab/kit.jpg", expires, signature) {
+		t.Errorf("public file URL did not verify without expiry (expires=%s)", expires)
+	}
+	if strings.Contains(store.URL("private/test_results/ab/photo.jpg"), "signature=") {
+		t.Error("private file URL was signed without an expiry")
+	}
+}
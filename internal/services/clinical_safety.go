@@ -0,0 +1,345 @@
+package services
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nyumbanicare/internal/models"
+)
+
+//go:embed data/drug_database.json
+var bundledDrugDatabase []byte
+
+// DrugEntry is a medication known to the drug database, by generic name
+type DrugEntry struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"` // brand names and alternative spellings
+	Classes []string `json:"classes"`
+}
+
+// DrugInteraction is a pair of drugs or drug classes that should not be combined lightly
+type DrugInteraction struct {
+	A           string `json:"a"` // generic name or class
+	B           string `json:"b"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// DrugCaution is the risk of giving a drug or drug class to a patient with an allergy or condition
+type DrugCaution struct {
+	Target      string `json:"target"` // generic name or class
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// AllergyRule lists the drugs an allergy rules out, including cross-reactive classes
+type AllergyRule struct {
+	Allergen  string        `json:"allergen"`
+	Aliases   []string      `json:"aliases"`
+	Reactions []DrugCaution `json:"reactions"`
+}
+
+// ConditionRule lists the drugs to avoid or use with care in a chronic condition
+type ConditionRule struct {
+	Condition string        `json:"condition"`
+	Aliases   []string      `json:"aliases"`
+	Cautions  []DrugCaution `json:"cautions"`
+}
+
+// DrugDatabase is the locally loaded reference used for medication safety checks
+type DrugDatabase struct {
+	Drugs        []DrugEntry       `json:"drugs"`
+	Interactions []DrugInteraction `json:"interactions"`
+	Allergies    []AllergyRule     `json:"allergies"`
+	Conditions   []ConditionRule   `json:"conditions"`
+
+	index   map[string]*DrugEntry // normalised generic names and aliases
+	classes map[string]bool
+}
+
+// SafetyProfile is what the safety check knows about a patient
+type SafetyProfile struct {
+	Allergies   []string
+	Conditions  []string
+	Medications []string // medications the patient is currently taking
+}
+
+// SafetyCheck is the result of checking medications against a patient's profile
+type SafetyCheck struct {
+	Alerts       []models.SafetyAlert `json:"alerts"`
+	Blocking     bool                 `json:"blocking"`     // an alert is serious enough to need an override
+	Unrecognized []string             `json:"unrecognized"` // medications not in the drug database, which were not checked
+}
+
+var safetySeverityRanks = map[string]int{
+	models.SafetySeverityMinor:           1,
+	models.SafetySeverityModerate:        2,
+	models.SafetySeverityMajor:           3,
+	models.SafetySeverityContraindicated: 4,
+}
+
+// SafetySeverityRank orders severities from minor (1) to contraindicated (4); unknown severities rank 0
+func SafetySeverityRank(severity string) int {
+	return safetySeverityRanks[severity]
+}
+
+// IsBlockingSeverity reports whether an alert must be overridden before the medication is added
+func IsBlockingSeverity(severity string) bool {
+	return SafetySeverityRank(severity) >= SafetySeverityRank(models.SafetySeverityMajor)
+}
+
+// LoadDrugDatabase reads the drug database at path, or the bundled one when path is empty
+func LoadDrugDatabase(path string) (*DrugDatabase, error) {
+	data := bundledDrugDatabase
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("failed to read drug database: %v", err)
+		}
+	}
+	return ParseDrugDatabase(data)
+}
+
+// ParseDrugDatabase decodes and indexes a drug database, rejecting rules that refer
+// to unknown drugs or classes so a typo cannot silently disable a check
+func ParseDrugDatabase(data []byte) (*DrugDatabase, error) {
+	var d DrugDatabase
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("invalid drug database: %v", err)
+	}
+
+	d.index = make(map[string]*DrugEntry)
+	d.classes = make(map[string]bool)
+	for i := range d.Drugs {
+		drug := &d.Drugs[i]
+		for _, class := range drug.Classes {
+			d.classes[class] = true
+		}
+		for _, name := range append([]string{drug.Name}, drug.Aliases...) {
+			key := normalizeDrugTerm(name)
+			if existing, ok := d.index[key]; ok && existing != drug {
+				return nil, fmt.Errorf("invalid drug database: %q names both %s and %s", name, existing.Name, drug.Name)
+			}
+			d.index[key] = drug
+		}
+	}
+	for i := range d.Drugs {
+		if d.classes[d.Drugs[i].Name] {
+			return nil, fmt.Errorf("invalid drug database: %s is both a drug and a class", d.Drugs[i].Name)
+		}
+	}
+
+	checkTarget := func(target, severity, rule string) error {
+		if !d.classes[target] && d.index[normalizeDrugTerm(target)] == nil {
+			return fmt.Errorf("invalid drug database: %s refers to unknown drug or class %q", rule, target)
+		}
+		if SafetySeverityRank(severity) == 0 {
+			return fmt.Errorf("invalid drug database: %s has unknown severity %q", rule, severity)
+		}
+		return nil
+	}
+	for _, interaction := range d.Interactions {
+		rule := "interaction " + interaction.A + "/" + interaction.B
+		if err := checkTarget(interaction.A, interaction.Severity, rule); err != nil {
+			return nil, err
+		}
+		if err := checkTarget(interaction.B, interaction.Severity, rule); err != nil {
+			return nil, err
+		}
+	}
+	for _, allergy := range d.Allergies {
+		for _, reaction := range allergy.Reactions {
+			if err := checkTarget(reaction.Target, reaction.Severity, "allergy "+allergy.Allergen); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, condition := range d.Conditions {
+		for _, caution := range condition.Cautions {
+			if err := checkTarget(caution.Target, caution.Severity, "condition "+condition.Condition); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &d, nil
+}
+
+// Resolve finds the drug a free-text medication name refers to, such as
+// "Amoxil 500mg capsules" or "Co-amoxiclav 625", or nil if it is unknown
+func (d *DrugDatabase) Resolve(name string) *DrugEntry {
+	term := normalizeDrugTerm(name)
+	if drug, ok := d.index[term]; ok {
+		return drug
+	}
+
+	words := strings.Fields(term)
+	for i := 0; i+1 < len(words); i++ {
+		if drug, ok := d.index[words[i]+" "+words[i+1]]; ok {
+			return drug
+		}
+	}
+	for _, word := range words {
+		if drug, ok := d.index[word]; ok {
+			return drug
+		}
+	}
+	return nil
+}
+
+// Check looks for allergy, condition, interaction and duplicate therapy problems
+// with adding medications to a patient's profile. The medications being added are
+// also checked against each other.
+func (d *DrugDatabase) Check(adding []string, profile SafetyProfile) *SafetyCheck {
+	check := &SafetyCheck{Alerts: []models.SafetyAlert{}, Unrecognized: []string{}}
+	seen := map[string]int{}
+	add := func(alert models.SafetyAlert) {
+		// Keep the most serious alert for each medication and cause
+		key := alert.Type + "|" + alert.Medication + "|" + alert.Against
+		if i, ok := seen[key]; ok {
+			if SafetySeverityRank(alert.Severity) > SafetySeverityRank(check.Alerts[i].Severity) {
+				check.Alerts[i] = alert
+			}
+			return
+		}
+		seen[key] = len(check.Alerts)
+		check.Alerts = append(check.Alerts, alert)
+	}
+
+	type current struct {
+		name string
+		drug *DrugEntry
+	}
+	var taking []current
+	for _, name := range profile.Medications {
+		if drug := d.Resolve(name); drug != nil {
+			taking = append(taking, current{name, drug})
+		}
+	}
+
+	for _, name := range adding {
+		drug := d.Resolve(name)
+		if drug == nil {
+			check.Unrecognized = append(check.Unrecognized, name)
+			continue
+		}
+
+		for _, allergy := range profile.Allergies {
+			if allergic := d.Resolve(allergy); allergic == drug {
+				add(models.SafetyAlert{
+					Type:        models.SafetyAlertAllergy,
+					Severity:    models.SafetySeverityContraindicated,
+					Medication:  name,
+					Against:     allergy,
+					Description: "Patient is allergic to " + drug.Name,
+				})
+			}
+			for _, rule := range d.Allergies {
+				if !mentionsTerm(allergy, append([]string{rule.Allergen}, rule.Aliases...)) {
+					continue
+				}
+				for _, reaction := range rule.Reactions {
+					if drug.matches(reaction.Target) {
+						add(models.SafetyAlert{
+							Type:        models.SafetyAlertAllergy,
+							Severity:    reaction.Severity,
+							Medication:  name,
+							Against:     allergy,
+							Description: reaction.Description,
+						})
+					}
+				}
+			}
+		}
+
+		for _, condition := range profile.Conditions {
+			for _, rule := range d.Conditions {
+				if !mentionsTerm(condition, append([]string{rule.Condition}, rule.Aliases...)) {
+					continue
+				}
+				for _, caution := range rule.Cautions {
+					if drug.matches(caution.Target) {
+						add(models.SafetyAlert{
+							Type:        models.SafetyAlertCondition,
+							Severity:    caution.Severity,
+							Medication:  name,
+							Against:     condition,
+							Description: caution.Description,
+						})
+					}
+				}
+			}
+		}
+
+		for _, other := range taking {
+			if other.drug == drug {
+				add(models.SafetyAlert{
+					Type:        models.SafetyAlertDuplicate,
+					Severity:    models.SafetySeverityModerate,
+					Medication:  name,
+					Against:     other.name,
+					Description: "Patient is already taking " + drug.Name,
+				})
+				continue
+			}
+			for _, interaction := range d.Interactions {
+				if (drug.matches(interaction.A) && other.drug.matches(interaction.B)) ||
+					(drug.matches(interaction.B) && other.drug.matches(interaction.A)) {
+					add(models.SafetyAlert{
+						Type:        models.SafetyAlertInteraction,
+						Severity:    interaction.Severity,
+						Medication:  name,
+						Against:     other.name,
+						Description: interaction.Description,
+					})
+				}
+			}
+		}
+
+		taking = append(taking, current{name, drug})
+	}
+
+	sort.SliceStable(check.Alerts, func(i, j int) bool {
+		return SafetySeverityRank(check.Alerts[i].Severity) > SafetySeverityRank(check.Alerts[j].Severity)
+	})
+	for _, alert := range check.Alerts {
+		if IsBlockingSeverity(alert.Severity) {
+			check.Blocking = true
+		}
+	}
+	return check
+}
+
+// matches reports whether a rule target names this drug or one of its classes
+func (e *DrugEntry) matches(target string) bool {
+	return normalizeDrugTerm(target) == normalizeDrugTerm(e.Name) || contains(e.Classes, target)
+}
+
+// mentionsTerm reports whether free text, such as an allergy or condition entered
+// by a patient, contains any of the terms as whole words
+func mentionsTerm(text string, terms []string) bool {
+	padded := " " + normalizeDrugTerm(text) + " "
+	for _, term := range terms {
+		if strings.Contains(padded, " "+normalizeDrugTerm(term)+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDrugTerm lowercases a name, joins hyphenated words ("co-amoxiclav")
+// and turns other punctuation into spaces, keeping the slash of combination products
+func normalizeDrugTerm(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '/' {
+			return r
+		}
+		return ' '
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
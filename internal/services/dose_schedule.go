@@ -0,0 +1,148 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DoseSchedule is a medication frequency parsed into the times of day doses are due
+type DoseSchedule struct {
+	Times    []string `json:"times"`     // "15:04" in the dose time zone
+	AsNeeded bool     `json:"as_needed"` // taken when required, so no doses are scheduled
+}
+
+// standardDoseTimes spreads daily doses over waking hours
+var standardDoseTimes = map[int][]string{
+	1: {"08:00"},
+	2: {"08:00", "20:00"},
+	3: {"08:00", "14:00", "20:00"},
+	4: {"06:00", "12:00", "18:00", "22:00"},
+}
+
+var (
+	asNeededPattern       = regexp.MustCompile(`\b(prn|sos|as needed|as required|when needed|when required|if needed)\b`)
+	intervalPattern       = regexp.MustCompile(`\bevery\s+(\d{1,2})\s*(?:hours?|hrs?|h)\b|\b(\d{1,2})\s*(?:-\s*)?hourly\b|\bq\s*(\d{1,2})\s*h\b`)
+	timesPerDayPattern    = regexp.MustCompile(`\b(\d)\s*(?:times|x)\s*(?:a|per|each)?\s*(?:day|daily)\b|\b\d\s*x\s*(\d)\b`)
+	nightlyPattern        = regexp.MustCompile(`\b(nocte|hs|at night|at bedtime|before bed|every night|nightly)\b`)
+	onceDailyPattern      = regexp.MustCompile(`\b(od|qd|daily|once daily|once a day|once per day|mane|every morning|in the morning|every 24 hours)\b`)
+	twiceDailyPattern     = regexp.MustCompile(`\b(bd|bid|twice daily|twice a day|two times a day)\b`)
+	thriceDailyPattern    = regexp.MustCompile(`\b(tds|tid|thrice daily|three times daily|three times a day)\b`)
+	fourTimesDailyPattern = regexp.MustCompile(`\b(qds|qid|four times daily|four times a day)\b`)
+	notDailyPattern       = regexp.MustCompile(`\b(stat|weekly|monthly|alternate days?|every other day|once only)\b`)
+)
+
+// ParseFrequency turns a prescribed frequency such as "bd", "1x3", "every 8 hours"
+// or "nocte" into a dose schedule. It reports false for frequencies that are not
+// a fixed number of doses a day, such as "stat" or "weekly".
+func ParseFrequency(frequency string) (DoseSchedule, bool) {
+	text := strings.ToLower(strings.ReplaceAll(frequency, ".", ""))
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return DoseSchedule{}, false
+	}
+
+	if asNeededPattern.MatchString(text) {
+		return DoseSchedule{Times: []string{}, AsNeeded: true}, true
+	}
+	if notDailyPattern.MatchString(text) {
+		return DoseSchedule{}, false
+	}
+
+	if match := intervalPattern.FindStringSubmatch(text); match != nil {
+		hours, _ := strconv.Atoi(match[1] + match[2] + match[3])
+		if times := intervalDoseTimes(hours); times != nil {
+			return DoseSchedule{Times: times}, true
+		}
+		return DoseSchedule{}, false
+	}
+	if match := timesPerDayPattern.FindStringSubmatch(text); match != nil {
+		count, _ := strconv.Atoi(match[1] + match[2])
+		if times, ok := standardDoseTimes[count]; ok {
+			return DoseSchedule{Times: times}, true
+		}
+		if count > 0 && 24%count == 0 {
+			return DoseSchedule{Times: intervalDoseTimes(24 / count)}, true
+		}
+		return DoseSchedule{}, false
+	}
+
+	switch {
+	case fourTimesDailyPattern.MatchString(text):
+		return DoseSchedule{Times: standardDoseTimes[4]}, true
+	case thriceDailyPattern.MatchString(text):
+		return DoseSchedule{Times: standardDoseTimes[3]}, true
+	case twiceDailyPattern.MatchString(text):
+		return DoseSchedule{Times: standardDoseTimes[2]}, true
+	case nightlyPattern.MatchString(text):
+		return DoseSchedule{Times: []string{"21:00"}}, true
+	case onceDailyPattern.MatchString(text):
+		return DoseSchedule{Times: standardDoseTimes[1]}, true
+	}
+	return DoseSchedule{}, false
+}
+
+// intervalDoseTimes spaces doses evenly through the day, starting early in the
+// morning for short intervals. It returns nil for intervals that do not divide a day.
+func intervalDoseTimes(hours int) []string {
+	if hours <= 0 || hours > 24 || 24%hours != 0 {
+		return nil
+	}
+	start := 6
+	if hours >= 12 {
+		start = 8
+	}
+	var times []string
+	for h := 0; h < 24; h += hours {
+		times = append(times, fmt.Sprintf("%02d:00", (start+h)%24))
+	}
+	sort.Strings(times)
+	return times
+}
+
+// NormalizeDoseTimes validates times of day given as "HH:MM", returning them sorted without duplicates
+func NormalizeDoseTimes(times []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, value := range times {
+		t, err := time.Parse("15:04", strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid dose time %q, expected HH:MM", value)
+		}
+		formatted := t.Format("15:04")
+		if !seen[formatted] {
+			seen[formatted] = true
+			normalized = append(normalized, formatted)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ScheduledDoses lists the times doses are due from (inclusive) to (exclusive),
+// with the times of day read in loc
+func ScheduledDoses(times []string, loc *time.Location, from, to time.Time) []time.Time {
+	var doses []time.Time
+	if len(times) == 0 || !from.Before(to) {
+		return doses
+	}
+
+	start := from.In(loc)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, value := range times {
+			t, err := time.Parse("15:04", value)
+			if err != nil {
+				continue
+			}
+			dose := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+			if !dose.Before(from) && dose.Before(to) {
+				doses = append(doses, dose)
+			}
+		}
+	}
+	return doses
+}
@@ -18,10 +18,11 @@ type EmailService struct {
 }
 
 type EmailData struct {
-	To      string
-	Subject string
-	Content string
-	Type    string // html or text
+	To          string
+	Subject     string
+	Content     string
+	TextContent string // plain text alternative for html emails
+	Type        string // html or text
 }
 
 func NewEmailService(cfg *config.EmailConfig) *EmailService {
@@ -47,7 +48,10 @@ func (es *EmailService) sendViaSMTP(data EmailData) error {
 	m.SetHeader("To", data.To)
 	m.SetHeader("Subject", data.Subject)
 
-	if data.Type == "html" {
+	if data.Type == "html" && data.TextContent != "" {
+		m.SetBody("text/plain", data.TextContent)
+		m.AddAlternative("text/html", data.Content)
+	} else if data.Type == "html" {
 		m.SetBody("text/html", data.Content)
 	} else {
 		m.SetBody("text/plain", data.Content)
@@ -74,6 +78,16 @@ func (es *EmailService) sendViaSendGrid(data EmailData) error {
 		return nil
 	}
 
+	content := []map[string]interface{}{}
+	if data.Type == "html" {
+		if data.TextContent != "" {
+			content = append(content, map[string]interface{}{"type": "text/plain", "value": data.TextContent})
+		}
+		content = append(content, map[string]interface{}{"type": "text/html", "value": data.Content})
+	} else {
+		content = append(content, map[string]interface{}{"type": "text/plain", "value": data.Content})
+	}
+
 	payload := map[string]interface{}{
 		"personalizations": []map[string]interface{}{
 			{
@@ -87,12 +101,7 @@ func (es *EmailService) sendViaSendGrid(data EmailData) error {
 			"name":  es.config.FromName,
 		},
 		"subject": data.Subject,
-		"content": []map[string]interface{}{
-			{
-				"type":  data.Type,
-				"value": data.Content,
-			},
-		},
+		"content": content,
 	}
 
 	jsonPayload, _ := json.Marshal(payload)
@@ -119,101 +128,51 @@ func (es *EmailService) sendViaSendGrid(data EmailData) error {
 	return nil
 }
 
-// Email templates
-func (es *EmailService) SendAppointmentConfirmation(user models.User, session models.TelehealthSession) error {
-	content := fmt.Sprintf(`
-		<h2>Appointment Confirmed</h2>
-		<p>Dear %s,</p>
-		<p>Your telehealth appointment has been confirmed for %s.</p>
-		<p><strong>Session Details:</strong></p>
-		<ul>
-			<li>Date: %s</li>
-			<li>Type: %s</li>
-			<li>Duration: %d minutes</li>
-		</ul>
-		<p>You will receive a link to join the session 15 minutes before the appointment.</p>
-		<p>Best regards,<br>Nyumbani Care Team</p>
-	`, user.FirstName, session.ScheduledAt.Format("January 2, 2006 at 3:04 PM"),
-		session.ScheduledAt.Format("January 2, 2006 at 3:04 PM"),
-		session.SessionType, session.Duration)
+// SendTemplate renders a registered email template in the given locale and sends it
+func (es *EmailService) SendTemplate(to, name, locale string, data interface{}) error {
+	registry, err := EmailTemplates()
+	if err != nil {
+		return err
+	}
+
+	rendered, err := registry.Render(name, locale, data)
+	if err != nil {
+		return err
+	}
 
 	return es.SendEmail(EmailData{
-		To:      user.Email,
-		Subject: "Telehealth Appointment Confirmed",
-		Content: content,
-		Type:    "text/html",
+		To:          to,
+		Subject:     rendered.Subject,
+		Content:     rendered.HTML,
+		TextContent: rendered.Text,
+		Type:        "html",
 	})
 }
 
-func (es *EmailService) SendTestResultsReady(user models.User, testResult models.TestKitResult) error {
-	content := fmt.Sprintf(`
-		<h2>Test Results Ready</h2>
-		<p>Dear %s,</p>
-		<p>Your test results are now available in your Nyumbani Care account.</p>
-		<p><strong>Test Details:</strong></p>
-		<ul>
-			<li>Result ID: %s</li>
-			<li>Date Processed: %s</li>
-		</ul>
-		<p>Please log in to your account to view your complete results.</p>
-		<p>Best regards,<br>Nyumbani Care Team</p>
-	`, user.FirstName, testResult.ID.String(), testResult.UpdatedAt.Format("January 2, 2006"))
+func (es *EmailService) SendAppointmentConfirmation(user models.User, session models.TelehealthSession) error {
+	return es.SendTemplate(user.Email, EmailTemplateAppointmentConfirmation, user.PreferredLanguage, map[string]interface{}{
+		"User":    user,
+		"Session": session,
+	})
+}
 
-	return es.SendEmail(EmailData{
-		To:      user.Email,
-		Subject: "Your Test Results Are Ready",
-		Content: content,
-		Type:    "text/html",
+func (es *EmailService) SendTestResultsReady(user models.User, testResult models.TestKitResult) error {
+	return es.SendTemplate(user.Email, EmailTemplateTestResultsReady, user.PreferredLanguage, map[string]interface{}{
+		"User":   user,
+		"Result": testResult,
 	})
 }
 
 func (es *EmailService) SendPrescriptionUpdate(user models.User, prescription models.Prescription) error {
-	statusMessage := ""
-	switch prescription.Status {
-	case "approved":
-		statusMessage = "Your prescription has been approved and is ready for pickup or delivery."
-	case "rejected":
-		statusMessage = "Your prescription could not be processed. Please contact us for more information."
-	case "dispensed":
-		statusMessage = "Your prescription has been dispensed and is on its way to you."
-	}
-
-	content := fmt.Sprintf(`
-		<h2>Prescription Update</h2>
-		<p>Dear %s,</p>
-		<p>%s</p>
-		<p><strong>Prescription ID:</strong> %s</p>
-		<p>Please log in to your account for more details.</p>
-		<p>Best regards,<br>Nyumbani Care Team</p>
-	`, user.FirstName, statusMessage, prescription.ID.String())
-
-	return es.SendEmail(EmailData{
-		To:      user.Email,
-		Subject: "Prescription Status Update",
-		Content: content,
-		Type:    "text/html",
+	return es.SendTemplate(user.Email, EmailTemplatePrescriptionUpdate, user.PreferredLanguage, map[string]interface{}{
+		"User":         user,
+		"Prescription": prescription,
 	})
 }
 
 func (es *EmailService) SendOrderConfirmation(user models.User, order models.TestKitOrder) error {
-	content := fmt.Sprintf(`
-		<h2>Order Confirmation</h2>
-		<p>Dear %s,</p>
-		<p>Thank you for your order. We have received your request and will process it shortly.</p>
-		<p><strong>Order Details:</strong></p>
-		<ul>
-			<li>Order ID: %s</li>
-			<li>Total: KES %.2f</li>
-			<li>Status: %s</li>
-		</ul>
-		<p>You will receive updates as your order is processed and shipped.</p>
-		<p>Best regards,<br>Nyumbani Care Team</p>
-	`, user.FirstName, order.ID.String(), order.TotalPrice, order.Status)
-
-	return es.SendEmail(EmailData{
-		To:      user.Email,
-		Subject: "Order Confirmation - " + order.ID.String(),
-		Content: content,
-		Type:    "text/html",
+	return es.SendTemplate(user.Email, EmailTemplateOrderConfirmation, user.PreferredLanguage, map[string]interface{}{
+		"User":  user,
+		"Order": order,
 	})
 }
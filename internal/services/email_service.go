@@ -5,7 +5,6 @@ import (
 	"net/smtp"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/nyumbanicare/internal/config"
 )
@@ -30,13 +29,18 @@ func NewExtendedEmailService(config *config.EmailConfig) (*ExtendedEmailService,
 func (s *ExtendedEmailService) SendEmail(to, subject, body string) error {
 	// Email server configuration
 	auth := smtp.PlainAuth("", s.config.SMTPUser, s.config.SMTPPass, s.config.SMTPHost)
+	rendered, err := renderGeneralMessage(subject, body)
+	if err != nil {
+		return err
+	}
+
 	// Prepare email content with headers
 	fromHeader := fmt.Sprintf("From: %s <%s>\r\n", s.config.FromName, s.config.FromEmail)
 	toHeader := fmt.Sprintf("To: %s\r\n", to)
 	subjectHeader := fmt.Sprintf("Subject: %s\r\n", subject)
 	mimeHeader := "MIME-version: 1.0;\r\nContent-Type: text/html; charset=\"UTF-8\";\r\n\r\n"
 
-	message := fromHeader + toHeader + subjectHeader + mimeHeader + formatEmailBody(body)
+	message := fromHeader + toHeader + subjectHeader + mimeHeader + rendered.HTML
 
 	// Convert port string to int
 	port, err := strconv.Atoi(s.config.SMTPPort)
@@ -56,66 +60,15 @@ func (s *ExtendedEmailService) SendEmail(to, subject, body string) error {
 	return err
 }
 
-// Helper function to format email body as HTML
-func formatEmailBody(content string) string {
-	// Replace newlines with <br> for HTML
-	htmlContent := strings.ReplaceAll(content, "\n", "<br>")
-
-	return fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-  <meta charset="UTF-8">
-  <meta name="viewport" content="width=device-width, initial-scale=1.0">
-  <title>Nyumbani Care</title>
-  <style>
-    body {
-      font-family: Arial, sans-serif;
-      line-height: 1.6;
-      color: #333;
-      max-width: 600px;
-      margin: 0 auto;
-      padding: 20px;
-    }
-    .header {
-      background-color: #4f46e5;
-      padding: 20px;
-      text-align: center;
-      color: white;
-      border-radius: 5px 5px 0 0;
-    }
-    .content {
-      padding: 20px;
-      background-color: #f9fafb;
-      border: 1px solid #e5e7eb;
-      border-top: none;
-      border-radius: 0 0 5px 5px;
-    }
-    .footer {
-      margin-top: 20px;
-      text-align: center;
-      font-size: 12px;
-      color: #6b7280;
-    }
-  </style>
-</head>
-<body>
-  <div class="header">
-    <h2>Nyumbani Care</h2>
-  </div>
-  <div class="content">
-    %s
-  </div>
-  <div class="footer">
-    <p>&copy; %d Nyumbani Care. All rights reserved.</p>
-    <p>This is an automated message, please do not reply to this email.</p>
-  </div>
-</body>
-</html>
-`, htmlContent, getCurrentYear())
-}
+// renderGeneralMessage wraps a free-text body in the shared email layout, one paragraph per line
+func renderGeneralMessage(subject, body string) (*RenderedEmail, error) {
+	registry, err := EmailTemplates()
+	if err != nil {
+		return nil, err
+	}
 
-// Get current year for copyright notice
-func getCurrentYear() int {
-	return time.Now().Year()
+	return registry.Render(EmailTemplateGeneralMessage, DefaultLocale, GeneralMessageData{
+		Subject:    subject,
+		Paragraphs: strings.Split(body, "\n"),
+	})
 }
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/nyumbanicare/internal/models"
+)
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+const DefaultLocale = i18n.DefaultLanguage
+
+// SupportedLocales lists the locales every email template is translated into
+var SupportedLocales = i18n.SupportedLanguages
+
+// Email template names
+const (
+	EmailTemplateAppointmentConfirmation = "appointment_confirmation"
+	EmailTemplateTestResultsReady        = "test_results_ready"
+	EmailTemplatePrescriptionUpdate      = "prescription_update"
+	EmailTemplateOrderConfirmation       = "order_confirmation"
+	EmailTemplateGeneralMessage          = "general_message"
+)
+
+var emailTemplateNames = []string{
+	EmailTemplateAppointmentConfirmation,
+	EmailTemplateTestResultsReady,
+	EmailTemplatePrescriptionUpdate,
+	EmailTemplateOrderConfirmation,
+	EmailTemplateGeneralMessage,
+}
+
+// RenderedEmail is a fully rendered email with an HTML body and a plain text fallback
+type RenderedEmail struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// GeneralMessageData is the data for the general_message template
+type GeneralMessageData struct {
+	Subject    string
+	Paragraphs []string
+}
+
+type emailTemplateSet struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// EmailTemplateRegistry holds the parsed email templates for every supported locale
+type EmailTemplateRegistry struct {
+	sets map[string]map[string]*emailTemplateSet
+}
+
+var (
+	emailTemplatesOnce     sync.Once
+	emailTemplatesRegistry *EmailTemplateRegistry
+	emailTemplatesErr      error
+)
+
+// EmailTemplates returns the shared template registry, parsing the embedded templates on first use
+func EmailTemplates() (*EmailTemplateRegistry, error) {
+	emailTemplatesOnce.Do(func() {
+		emailTemplatesRegistry, emailTemplatesErr = NewEmailTemplateRegistry()
+	})
+	return emailTemplatesRegistry, emailTemplatesErr
+}
+
+// NewEmailTemplateRegistry parses the embedded templates for all supported locales
+func NewEmailTemplateRegistry() (*EmailTemplateRegistry, error) {
+	registry := &EmailTemplateRegistry{
+		sets: make(map[string]map[string]*emailTemplateSet),
+	}
+
+	for _, locale := range SupportedLocales {
+		registry.sets[locale] = make(map[string]*emailTemplateSet)
+		funcs := emailTemplateFuncs(locale)
+		common := fmt.Sprintf("templates/email/%s/common.tmpl", locale)
+
+		for _, name := range emailTemplateNames {
+			htmlTmpl, err := htmltemplate.New("layout.html").Funcs(htmltemplate.FuncMap(funcs)).ParseFS(
+				emailTemplateFS,
+				"templates/email/layout.html",
+				common,
+				fmt.Sprintf("templates/email/%s/%s.html", locale, name),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s HTML template for locale %s: %v", name, locale, err)
+			}
+
+			textTmpl, err := texttemplate.New("layout.txt").Funcs(funcs).ParseFS(
+				emailTemplateFS,
+				"templates/email/layout.txt",
+				common,
+				fmt.Sprintf("templates/email/%s/%s.txt", locale, name),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s text template for locale %s: %v", name, locale, err)
+			}
+
+			registry.sets[locale][name] = &emailTemplateSet{html: htmlTmpl, text: textTmpl}
+		}
+	}
+
+	return registry, nil
+}
+
+// Names returns the names of all registered templates
+func (r *EmailTemplateRegistry) Names() []string {
+	return append([]string(nil), emailTemplateNames...)
+}
+
+// Has reports whether a template with the given name exists
+func (r *EmailTemplateRegistry) Has(name string) bool {
+	_, ok := r.sets[DefaultLocale][name]
+	return ok
+}
+
+// Render renders the named template in the given locale, falling back to English
+func (r *EmailTemplateRegistry) Render(name, locale string, data interface{}) (*RenderedEmail, error) {
+	locale = NormalizeLocale(locale)
+	set, ok := r.sets[locale][name]
+	if !ok {
+		return nil, fmt.Errorf("email template not found: %s", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s subject: %v", name, err)
+	}
+	if err := set.text.ExecuteTemplate(&text, "layout.txt", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s text body: %v", name, err)
+	}
+	if err := set.html.ExecuteTemplate(&html, "layout.html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s HTML body: %v", name, err)
+	}
+
+	return &RenderedEmail{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+// RenderPreview renders the named template with built-in sample data
+func (r *EmailTemplateRegistry) RenderPreview(name, locale string) (*RenderedEmail, error) {
+	data, ok := emailTemplateSamples()[name]
+	if !ok {
+		return nil, fmt.Errorf("email template not found: %s", name)
+	}
+	return r.Render(name, locale, data)
+}
+
+// NormalizeLocale maps a language tag such as "sw-KE" to a supported locale
+func NormalizeLocale(locale string) string {
+	return i18n.Normalize(locale)
+}
+
+var swahiliMonths = []string{
+	"Januari", "Februari", "Machi", "Aprili", "Mei", "Juni",
+	"Julai", "Agosti", "Septemba", "Oktoba", "Novemba", "Desemba",
+}
+
+func emailTemplateFuncs(locale string) texttemplate.FuncMap {
+	formatDate := func(t time.Time) string {
+		if locale == "sw" {
+			return fmt.Sprintf("%d %s %d", t.Day(), swahiliMonths[t.Month()-1], t.Year())
+		}
+		return t.Format("January 2, 2006")
+	}
+
+	formatDateTime := func(t time.Time) string {
+		if locale == "sw" {
+			return fmt.Sprintf("%s, saa %s", formatDate(t), t.Format("15:04"))
+		}
+		return t.Format("January 2, 2006 at 3:04 PM")
+	}
+
+	return texttemplate.FuncMap{
+		"date":     formatDate,
+		"datetime": formatDateTime,
+		"money": func(amount float64) string {
+			return fmt.Sprintf("KES %.2f", amount)
+		},
+		"year": func() int {
+			return time.Now().Year()
+		},
+	}
+}
+
+func emailTemplateSamples() map[string]interface{} {
+	user := models.User{
+		ID:        uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		Email:     "wanjiku@example.com",
+		FirstName: "Wanjiku",
+		LastName:  "Kamau",
+	}
+	sampleTime := time.Date(2025, time.June, 14, 10, 30, 0, 0, time.UTC)
+
+	return map[string]interface{}{
+		EmailTemplateAppointmentConfirmation: map[string]interface{}{
+			"User": user,
+			"Session": models.TelehealthSession{
+				ID:          uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+				ScheduledAt: sampleTime,
+				SessionType: "video",
+				Duration:    30,
+			},
+		},
+		EmailTemplateTestResultsReady: map[string]interface{}{
+			"User": user,
+			"Result": models.TestKitResult{
+				ID:        uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+				UpdatedAt: sampleTime,
+			},
+		},
+		EmailTemplatePrescriptionUpdate: map[string]interface{}{
+			"User": user,
+			"Prescription": models.Prescription{
+				ID:     uuid.MustParse("00000000-0000-0000-0000-000000000004"),
+				Status: "approved",
+			},
+		},
+		EmailTemplateOrderConfirmation: map[string]interface{}{
+			"User": user,
+			"Order": models.TestKitOrder{
+				ID:         uuid.MustParse("00000000-0000-0000-0000-000000000005"),
+				TotalPrice: 1999,
+				Status:     "pending",
+			},
+		},
+		EmailTemplateGeneralMessage: GeneralMessageData{
+			Subject:    "A message from Nyumbani Care",
+			Paragraphs: []string{"Hello Wanjiku,", "This is a sample message body."},
+		},
+	}
+}
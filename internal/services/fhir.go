@@ -0,0 +1,740 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+)
+
+// FHIR R4 code systems and identifiers used when exchanging patient records
+const (
+	FHIRPatientIdentifierSystem = "https://nyumbanicare.com/fhir/sid/patient"
+	fhirActCodeSystem           = "http://terminology.hl7.org/CodeSystem/v3-ActCode"
+	fhirAllergyClinicalSystem   = "http://terminology.hl7.org/CodeSystem/allergyintolerance-clinical"
+	fhirConditionClinicalSystem = "http://terminology.hl7.org/CodeSystem/condition-clinical"
+	fhirConditionCategorySystem = "http://terminology.hl7.org/CodeSystem/condition-category"
+	fhirObservationCategory     = "http://terminology.hl7.org/CodeSystem/observation-category"
+	fhirDiagnosticServiceSystem = "http://terminology.hl7.org/CodeSystem/v2-0074"
+	fhirInterpretationSystem    = "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation"
+	fhirLOINCSystem             = "http://loinc.org"
+	FHIRContentType             = "application/fhir+json"
+)
+
+// fhirNamespace derives stable ids for resources that have no row of their own,
+// such as an allergy listed on a medical record
+var fhirNamespace = uuid.MustParse("5b0f4c1e-8f5d-4b8e-9d2a-6a1c0e7f3b21")
+
+type FHIRCoding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+type FHIRCodeableConcept struct {
+	Coding []FHIRCoding `json:"coding,omitempty"`
+	Text   string       `json:"text,omitempty"`
+}
+
+// Label is the concept's text, or the first coding's display or code
+func (cc *FHIRCodeableConcept) Label() string {
+	if cc == nil {
+		return ""
+	}
+	if text := strings.TrimSpace(cc.Text); text != "" {
+		return text
+	}
+	for _, coding := range cc.Coding {
+		if display := strings.TrimSpace(coding.Display); display != "" {
+			return display
+		}
+	}
+	for _, coding := range cc.Coding {
+		if code := strings.TrimSpace(coding.Code); code != "" {
+			return code
+		}
+	}
+	return ""
+}
+
+// Code is the first code in the concept from system, or from any system when system is empty
+func (cc *FHIRCodeableConcept) Code(system string) string {
+	if cc == nil {
+		return ""
+	}
+	for _, coding := range cc.Coding {
+		if system == "" || coding.System == system {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+type FHIRReference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type FHIRIdentifier struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+type FHIRHumanName struct {
+	Text   string   `json:"text,omitempty"`
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+type FHIRContactPoint struct {
+	System string `json:"system,omitempty"` // phone, email
+	Value  string `json:"value,omitempty"`
+}
+
+type FHIRAddress struct {
+	Text string `json:"text,omitempty"`
+}
+
+type FHIRPeriod struct {
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type FHIRQuantity struct {
+	Value *float64 `json:"value,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+}
+
+type FHIRAnnotation struct {
+	Text string `json:"text"`
+}
+
+type FHIRAttachment struct {
+	ContentType string `json:"contentType,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Title       string `json:"title,omitempty"`
+}
+
+type FHIRPatient struct {
+	ResourceType string             `json:"resourceType"`
+	ID           string             `json:"id,omitempty"`
+	Identifier   []FHIRIdentifier   `json:"identifier,omitempty"`
+	Name         []FHIRHumanName    `json:"name,omitempty"`
+	Telecom      []FHIRContactPoint `json:"telecom,omitempty"`
+	Gender       string             `json:"gender,omitempty"`
+	BirthDate    string             `json:"birthDate,omitempty"`
+	Address      []FHIRAddress      `json:"address,omitempty"`
+}
+
+type FHIRAllergyIntolerance struct {
+	ResourceType       string               `json:"resourceType"`
+	ID                 string               `json:"id,omitempty"`
+	ClinicalStatus     *FHIRCodeableConcept `json:"clinicalStatus,omitempty"`
+	VerificationStatus *FHIRCodeableConcept `json:"verificationStatus,omitempty"`
+	Code               *FHIRCodeableConcept `json:"code,omitempty"`
+	Patient            *FHIRReference       `json:"patient,omitempty"`
+}
+
+type FHIRCondition struct {
+	ResourceType       string                `json:"resourceType"`
+	ID                 string                `json:"id,omitempty"`
+	ClinicalStatus     *FHIRCodeableConcept  `json:"clinicalStatus,omitempty"`
+	VerificationStatus *FHIRCodeableConcept  `json:"verificationStatus,omitempty"`
+	Category           []FHIRCodeableConcept `json:"category,omitempty"`
+	Code               *FHIRCodeableConcept  `json:"code,omitempty"`
+	Subject            *FHIRReference        `json:"subject,omitempty"`
+}
+
+type FHIRTimingRepeat struct {
+	TimeOfDay []string `json:"timeOfDay,omitempty"` // "08:00:00"
+}
+
+type FHIRTiming struct {
+	Repeat *FHIRTimingRepeat    `json:"repeat,omitempty"`
+	Code   *FHIRCodeableConcept `json:"code,omitempty"`
+}
+
+type FHIRDosage struct {
+	Text            string      `json:"text,omitempty"`
+	Timing          *FHIRTiming `json:"timing,omitempty"`
+	AsNeededBoolean *bool       `json:"asNeededBoolean,omitempty"`
+}
+
+type FHIRMedicationStatement struct {
+	ResourceType              string               `json:"resourceType"`
+	ID                        string               `json:"id,omitempty"`
+	Status                    string               `json:"status,omitempty"`
+	MedicationCodeableConcept *FHIRCodeableConcept `json:"medicationCodeableConcept,omitempty"`
+	MedicationReference       *FHIRReference       `json:"medicationReference,omitempty"`
+	Subject                   *FHIRReference       `json:"subject,omitempty"`
+	EffectiveDateTime         string               `json:"effectiveDateTime,omitempty"`
+	EffectivePeriod           *FHIRPeriod          `json:"effectivePeriod,omitempty"`
+	InformationSource         *FHIRReference       `json:"informationSource,omitempty"`
+	Note                      []FHIRAnnotation     `json:"note,omitempty"`
+	Dosage                    []FHIRDosage         `json:"dosage,omitempty"`
+}
+
+type FHIRObservation struct {
+	ResourceType         string                `json:"resourceType"`
+	ID                   string                `json:"id,omitempty"`
+	Status               string                `json:"status,omitempty"`
+	Category             []FHIRCodeableConcept `json:"category,omitempty"`
+	Code                 *FHIRCodeableConcept  `json:"code,omitempty"`
+	Subject              *FHIRReference        `json:"subject,omitempty"`
+	EffectiveDateTime    string                `json:"effectiveDateTime,omitempty"`
+	Issued               string                `json:"issued,omitempty"`
+	Performer            []FHIRReference       `json:"performer,omitempty"`
+	ValueQuantity        *FHIRQuantity         `json:"valueQuantity,omitempty"`
+	ValueCodeableConcept *FHIRCodeableConcept  `json:"valueCodeableConcept,omitempty"`
+	ValueString          string                `json:"valueString,omitempty"`
+	Interpretation       []FHIRCodeableConcept `json:"interpretation,omitempty"`
+	Note                 []FHIRAnnotation      `json:"note,omitempty"`
+	ReferenceRange       []FHIRReferenceRange  `json:"referenceRange,omitempty"`
+}
+
+type FHIRReferenceRange struct {
+	Low  *FHIRQuantity `json:"low,omitempty"`
+	High *FHIRQuantity `json:"high,omitempty"`
+	Text string        `json:"text,omitempty"`
+}
+
+type FHIRDiagnosticReport struct {
+	ResourceType      string                `json:"resourceType"`
+	ID                string                `json:"id,omitempty"`
+	Status            string                `json:"status,omitempty"`
+	Category          []FHIRCodeableConcept `json:"category,omitempty"`
+	Code              *FHIRCodeableConcept  `json:"code,omitempty"`
+	Subject           *FHIRReference        `json:"subject,omitempty"`
+	EffectiveDateTime string                `json:"effectiveDateTime,omitempty"`
+	Issued            string                `json:"issued,omitempty"`
+	Result            []FHIRReference       `json:"result,omitempty"`
+	Conclusion        string                `json:"conclusion,omitempty"`
+	PresentedForm     []FHIRAttachment      `json:"presentedForm,omitempty"`
+}
+
+type FHIREncounterParticipant struct {
+	Individual *FHIRReference `json:"individual,omitempty"`
+}
+
+type FHIREncounter struct {
+	ResourceType string                     `json:"resourceType"`
+	ID           string                     `json:"id,omitempty"`
+	Status       string                     `json:"status,omitempty"`
+	Class        *FHIRCoding                `json:"class,omitempty"`
+	Subject      *FHIRReference             `json:"subject,omitempty"`
+	Participant  []FHIREncounterParticipant `json:"participant,omitempty"`
+	Period       *FHIRPeriod                `json:"period,omitempty"`
+	ReasonCode   []FHIRCodeableConcept      `json:"reasonCode,omitempty"` // carries the diagnosis, so it survives without a separate Condition
+}
+
+// FHIRIssue is a problem found in a FHIR resource
+type FHIRIssue struct {
+	Severity    string   `json:"severity"` // fatal, error, warning, information
+	Code        string   `json:"code"`     // required, value, invalid, not-supported, not-found
+	Diagnostics string   `json:"diagnostics,omitempty"`
+	Expression  []string `json:"expression,omitempty"`
+}
+
+type FHIROperationOutcome struct {
+	ResourceType string      `json:"resourceType"`
+	Issue        []FHIRIssue `json:"issue"`
+}
+
+// NewFHIROperationOutcome reports issues as an OperationOutcome
+func NewFHIROperationOutcome(issues ...FHIRIssue) FHIROperationOutcome {
+	return FHIROperationOutcome{ResourceType: "OperationOutcome", Issue: issues}
+}
+
+type FHIRBundleSearch struct {
+	Mode string `json:"mode,omitempty"` // match, include
+}
+
+type FHIRBundleResponse struct {
+	Status   string                `json:"status"`
+	Location string                `json:"location,omitempty"`
+	Outcome  *FHIROperationOutcome `json:"outcome,omitempty"`
+}
+
+type FHIRBundleEntry struct {
+	FullURL  string              `json:"fullUrl,omitempty"`
+	Resource json.RawMessage     `json:"resource,omitempty"`
+	Search   *FHIRBundleSearch   `json:"search,omitempty"`
+	Response *FHIRBundleResponse `json:"response,omitempty"`
+}
+
+type FHIRBundle struct {
+	ResourceType string            `json:"resourceType"`
+	ID           string            `json:"id,omitempty"`
+	Type         string            `json:"type"`
+	Timestamp    string            `json:"timestamp,omitempty"`
+	Total        *int              `json:"total,omitempty"`
+	Entry        []FHIRBundleEntry `json:"entry,omitempty"`
+}
+
+// FHIRPatientData is everything exported for a patient
+type FHIRPatientData struct {
+	User          models.User
+	Record        *models.MedicalRecord // with medications, test results and consultations loaded
+	KitResults    []models.TestKitResult
+	KitNames      map[uuid.UUID]string
+	LabResults    []models.LabResult // with the booking's lab test loaded
+	Sessions      []models.TelehealthSession
+	ProviderNames map[uuid.UUID]string
+}
+
+// FHIRAllergyID is the stable AllergyIntolerance id of an allergy listed on a medical record
+func FHIRAllergyID(recordID uuid.UUID, allergy string) uuid.UUID {
+	return uuid.NewSHA1(fhirNamespace, []byte(recordID.String()+"|allergy|"+strings.ToLower(strings.TrimSpace(allergy))))
+}
+
+// FHIRConditionID is the stable Condition id of a chronic condition listed on a medical record
+func FHIRConditionID(recordID uuid.UUID, condition string) uuid.UUID {
+	return uuid.NewSHA1(fhirNamespace, []byte(recordID.String()+"|condition|"+strings.ToLower(strings.TrimSpace(condition))))
+}
+
+func fhirURN(id uuid.UUID) string {
+	return "urn:uuid:" + id.String()
+}
+
+func fhirDateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func fhirText(text string) *FHIRCodeableConcept {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	return &FHIRCodeableConcept{Text: text}
+}
+
+func fhirCoded(system, code, display string) *FHIRCodeableConcept {
+	return &FHIRCodeableConcept{Coding: []FHIRCoding{{System: system, Code: code, Display: display}}}
+}
+
+func fhirNotes(texts ...string) []FHIRAnnotation {
+	var notes []FHIRAnnotation
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			notes = append(notes, FHIRAnnotation{Text: text})
+		}
+	}
+	return notes
+}
+
+// fhirGender maps a profile gender onto the FHIR administrative gender codes
+func fhirGender(gender string) string {
+	switch strings.ToLower(strings.TrimSpace(gender)) {
+	case "male", "m":
+		return "male"
+	case "female", "f":
+		return "female"
+	case "":
+		return ""
+	case "unknown":
+		return "unknown"
+	}
+	return "other"
+}
+
+var laboratoryCategory = []FHIRCodeableConcept{{Coding: []FHIRCoding{{System: fhirObservationCategory, Code: "laboratory", Display: "Laboratory"}}}}
+
+// FHIRPatientBundle maps a patient's record onto FHIR R4 resources, returned as
+// the searchset Bundle of a Patient $everything operation
+func FHIRPatientBundle(data FHIRPatientData) FHIRBundle {
+	bundle := FHIRBundle{
+		ResourceType: "Bundle",
+		ID:           uuid.New().String(),
+		Type:         "searchset",
+		Timestamp:    fhirDateTime(time.Now()),
+	}
+	add := func(id uuid.UUID, resource interface{}, mode string) {
+		raw, err := json.Marshal(resource)
+		if err != nil {
+			return
+		}
+		bundle.Entry = append(bundle.Entry, FHIRBundleEntry{
+			FullURL:  fhirURN(id),
+			Resource: raw,
+			Search:   &FHIRBundleSearch{Mode: mode},
+		})
+	}
+
+	user := data.User
+	subject := &FHIRReference{Reference: fhirURN(user.ID), Display: strings.TrimSpace(user.FirstName + " " + user.LastName)}
+
+	patient := FHIRPatient{
+		ResourceType: "Patient",
+		ID:           user.ID.String(),
+		Identifier:   []FHIRIdentifier{{System: FHIRPatientIdentifierSystem, Value: user.ID.String()}},
+		Name:         []FHIRHumanName{{Family: user.LastName, Given: nonEmpty(user.FirstName)}},
+		Gender:       fhirGender(user.Gender),
+	}
+	if user.Email != "" {
+		patient.Telecom = append(patient.Telecom, FHIRContactPoint{System: "email", Value: user.Email})
+	}
+	if user.PhoneNumber != "" {
+		patient.Telecom = append(patient.Telecom, FHIRContactPoint{System: "phone", Value: user.PhoneNumber})
+	}
+	if user.DateOfBirth != nil {
+		patient.BirthDate = user.DateOfBirth.Format("2006-01-02")
+	}
+	if user.Address != "" {
+		patient.Address = []FHIRAddress{{Text: user.Address}}
+	}
+	add(user.ID, patient, "match")
+
+	if record := data.Record; record != nil {
+		for _, allergy := range record.Allergies {
+			if strings.TrimSpace(allergy) == "" {
+				continue
+			}
+			id := FHIRAllergyID(record.ID, allergy)
+			add(id, FHIRAllergyIntolerance{
+				ResourceType:   "AllergyIntolerance",
+				ID:             id.String(),
+				ClinicalStatus: fhirCoded(fhirAllergyClinicalSystem, "active", "Active"),
+				Code:           fhirText(allergy),
+				Patient:        subject,
+			}, "include")
+		}
+		for _, condition := range record.ChronicConditions {
+			if strings.TrimSpace(condition) == "" {
+				continue
+			}
+			id := FHIRConditionID(record.ID, condition)
+			add(id, FHIRCondition{
+				ResourceType:   "Condition",
+				ID:             id.String(),
+				ClinicalStatus: fhirCoded(fhirConditionClinicalSystem, "active", "Active"),
+				Category:       []FHIRCodeableConcept{*fhirCoded(fhirConditionCategorySystem, "problem-list-item", "Problem List Item")},
+				Code:           fhirText(condition),
+				Subject:        subject,
+			}, "include")
+		}
+
+		for _, medication := range record.Medications {
+			add(medication.ID, fhirMedicationStatement(medication, subject), "include")
+		}
+		// Entries merged from test kits, lab results and sessions are exported from their source below
+		for _, result := range record.TestResults {
+			if result.SourceType == models.RecordSourceTestKitResult || result.SourceType == models.RecordSourceLabResult {
+				continue
+			}
+			observation := FHIRObservation{
+				ResourceType:      "Observation",
+				ID:                result.ID.String(),
+				Status:            "final",
+				Category:          laboratoryCategory,
+				Code:              fhirText(result.TestType),
+				Subject:           subject,
+				EffectiveDateTime: fhirDateTime(result.TestDate),
+				ValueString:       result.Result,
+				Interpretation:    nonNilConcepts(fhirText(result.Interpretation)),
+				Note:              fhirNotes(result.DoctorNotes),
+			}
+			if result.LabName != "" {
+				observation.Performer = []FHIRReference{{Display: result.LabName}}
+			}
+			add(result.ID, observation, "include")
+		}
+		for _, consultation := range record.Consultations {
+			if consultation.SourceType == models.RecordSourceTelehealth {
+				continue
+			}
+			encounter := FHIREncounter{
+				ResourceType: "Encounter",
+				ID:           consultation.ID.String(),
+				Status:       "finished",
+				Class:        fhirEncounterClass(consultation.Type),
+				Subject:      subject,
+				Period:       &FHIRPeriod{Start: fhirDateTime(consultation.Date)},
+				ReasonCode:   nonNilConcepts(fhirText(consultation.Diagnosis)),
+			}
+			if consultation.DoctorName != "" {
+				encounter.Participant = []FHIREncounterParticipant{{Individual: &FHIRReference{Display: consultation.DoctorName}}}
+			}
+			add(consultation.ID, encounter, "include")
+		}
+	}
+
+	for _, result := range data.KitResults {
+		if result.Result == "" {
+			continue
+		}
+		status := "preliminary"
+		if result.ReviewedAt != nil {
+			status = "final"
+		}
+		name := data.KitNames[result.TestKitID]
+		if name == "" {
+			name = result.KitType
+		}
+		observationID := uuid.NewSHA1(fhirNamespace, []byte(result.ID.String()+"|observation"))
+		add(observationID, FHIRObservation{
+			ResourceType:      "Observation",
+			ID:                observationID.String(),
+			Status:            status,
+			Category:          laboratoryCategory,
+			Code:              fhirText(name),
+			Subject:           subject,
+			EffectiveDateTime: fhirDateTime(result.CreatedAt),
+			ValueString:       result.Result,
+			Note:              fhirNotes(result.Notes),
+		}, "include")
+
+		report := FHIRDiagnosticReport{
+			ResourceType:      "DiagnosticReport",
+			ID:                result.ID.String(),
+			Status:            status,
+			Category:          []FHIRCodeableConcept{*fhirCoded(fhirDiagnosticServiceSystem, "LAB", "Laboratory")},
+			Code:              fhirText(name),
+			Subject:           subject,
+			EffectiveDateTime: fhirDateTime(result.CreatedAt),
+			Result:            []FHIRReference{{Reference: fhirURN(observationID)}},
+			Conclusion:        result.ReviewNotes,
+		}
+		if result.ReviewedAt != nil {
+			report.Issued = fhirDateTime(*result.ReviewedAt)
+		}
+		add(result.ID, report, "include")
+	}
+
+	for _, result := range data.LabResults {
+		status := "preliminary"
+		if result.VerifiedAt != nil {
+			status = "final"
+		}
+		name := result.LabBooking.LabTest.Name
+		if name == "" {
+			name = "Laboratory Test"
+		}
+
+		var references []FHIRReference
+		for _, observation := range labResultObservations(result, name, status, subject) {
+			id := uuid.MustParse(observation.ID)
+			references = append(references, FHIRReference{Reference: fhirURN(id)})
+			add(id, observation, "include")
+		}
+
+		report := FHIRDiagnosticReport{
+			ResourceType:      "DiagnosticReport",
+			ID:                result.ID.String(),
+			Status:            status,
+			Category:          []FHIRCodeableConcept{*fhirCoded(fhirDiagnosticServiceSystem, "LAB", "Laboratory")},
+			Code:              fhirText(name),
+			Subject:           subject,
+			EffectiveDateTime: fhirDateTime(result.ResultDate),
+			Issued:            fhirDateTime(result.UpdatedAt),
+			Result:            references,
+			Conclusion:        strings.TrimSpace(strings.Join(nonEmpty(result.Interpretation, result.DoctorComments), "\n")),
+		}
+		if result.ReportURL != "" {
+			report.PresentedForm = []FHIRAttachment{{ContentType: "application/pdf", URL: result.ReportURL, Title: name}}
+		}
+		add(result.ID, report, "include")
+	}
+
+	for _, session := range data.Sessions {
+		encounter := FHIREncounter{
+			ResourceType: "Encounter",
+			ID:           session.ID.String(),
+			Status:       fhirEncounterStatus(session.Status),
+			Class:        fhirEncounterClass("telehealth"),
+			Subject:      subject,
+			Period:       &FHIRPeriod{Start: fhirDateTime(session.ScheduledAt)},
+			ReasonCode:   nonNilConcepts(fhirText(session.Diagnosis)),
+		}
+		if session.Status == "completed" && session.Duration > 0 {
+			encounter.Period.End = fhirDateTime(session.ScheduledAt.Add(time.Duration(session.Duration) * time.Minute))
+		}
+		if name := data.ProviderNames[session.ProviderID]; name != "" {
+			encounter.Participant = []FHIREncounterParticipant{{Individual: &FHIRReference{Display: name}}}
+		}
+		add(session.ID, encounter, "include")
+	}
+
+	total := len(bundle.Entry)
+	bundle.Total = &total
+	return bundle
+}
+
+func fhirMedicationStatement(medication models.Medication, subject *FHIRReference) FHIRMedicationStatement {
+	status := "active"
+	if medication.EndDate != nil && medication.EndDate.Before(time.Now()) {
+		status = "completed"
+	}
+
+	dosage := FHIRDosage{Text: strings.TrimSpace(medication.Dosage)}
+	if medication.AsNeeded {
+		asNeeded := true
+		dosage.AsNeededBoolean = &asNeeded
+	}
+	if len(medication.DoseTimes) > 0 || medication.Frequency != "" {
+		dosage.Timing = &FHIRTiming{Code: fhirText(medication.Frequency)}
+		if len(medication.DoseTimes) > 0 {
+			times := make([]string, 0, len(medication.DoseTimes))
+			for _, at := range medication.DoseTimes {
+				times = append(times, at+":00")
+			}
+			dosage.Timing.Repeat = &FHIRTimingRepeat{TimeOfDay: times}
+		}
+	}
+
+	statement := FHIRMedicationStatement{
+		ResourceType:              "MedicationStatement",
+		ID:                        medication.ID.String(),
+		Status:                    status,
+		MedicationCodeableConcept: fhirText(medication.Name),
+		Subject:                   subject,
+		EffectivePeriod:           &FHIRPeriod{Start: fhirDateTime(medication.StartDate)},
+		Note:                      fhirNotes(medication.Notes),
+	}
+	if medication.EndDate != nil {
+		statement.EffectivePeriod.End = fhirDateTime(*medication.EndDate)
+	}
+	if medication.PrescribedBy != "" {
+		statement.InformationSource = &FHIRReference{Display: medication.PrescribedBy}
+	}
+	if dosage.Text != "" || dosage.Timing != nil || dosage.AsNeededBoolean != nil {
+		statement.Dosage = []FHIRDosage{dosage}
+	}
+	return statement
+}
+
+// labResultObservations maps each value of a lab result onto an Observation,
+// with its unit, reference range and abnormal flag
+func labResultObservations(result models.LabResult, name, status string, subject *FHIRReference) []FHIRObservation {
+	if len(result.Values) == 0 {
+		id := uuid.NewSHA1(fhirNamespace, []byte(result.ID.String()+"|observation|"))
+		return []FHIRObservation{{
+			ResourceType:      "Observation",
+			ID:                id.String(),
+			Status:            status,
+			Category:          laboratoryCategory,
+			Code:              fhirText(name),
+			Subject:           subject,
+			EffectiveDateTime: fhirDateTime(result.ResultDate),
+			Interpretation:    nonNilConcepts(fhirText(result.Interpretation)),
+		}}
+	}
+
+	values := append([]models.LabResultValue(nil), result.Values...)
+	sort.SliceStable(values, func(i, j int) bool { return values[i].DisplayOrder < values[j].DisplayOrder })
+
+	observations := make([]FHIRObservation, 0, len(values))
+	for _, value := range values {
+		id := uuid.NewSHA1(fhirNamespace, []byte(result.ID.String()+"|observation|"+value.Code))
+		code := &FHIRCodeableConcept{Text: value.Name}
+		if value.LOINCCode != "" {
+			code.Coding = []FHIRCoding{{System: fhirLOINCSystem, Code: value.LOINCCode, Display: value.Name}}
+		}
+		o := FHIRObservation{
+			ResourceType:      "Observation",
+			ID:                id.String(),
+			Status:            status,
+			Category:          laboratoryCategory,
+			Code:              code,
+			Subject:           subject,
+			EffectiveDateTime: fhirDateTime(result.ResultDate),
+		}
+		if value.Value != nil {
+			v := *value.Value
+			o.ValueQuantity = &FHIRQuantity{Value: &v, Unit: value.Unit}
+		} else {
+			o.ValueString = value.ValueText
+		}
+		if interpretation := fhirLabInterpretation(value.Flag); interpretation != nil {
+			o.Interpretation = []FHIRCodeableConcept{*interpretation}
+		}
+		if value.ReferenceLow != nil || value.ReferenceHigh != nil || value.ReferenceText != "" {
+			referenceRange := FHIRReferenceRange{Text: value.ReferenceText}
+			if value.ReferenceLow != nil {
+				referenceRange.Low = &FHIRQuantity{Value: value.ReferenceLow, Unit: value.Unit}
+			}
+			if value.ReferenceHigh != nil {
+				referenceRange.High = &FHIRQuantity{Value: value.ReferenceHigh, Unit: value.Unit}
+			}
+			o.ReferenceRange = []FHIRReferenceRange{referenceRange}
+		}
+		observations = append(observations, o)
+	}
+	return observations
+}
+
+// fhirLabInterpretation maps an abnormal flag onto the v3 observation interpretation codes
+func fhirLabInterpretation(flag string) *FHIRCodeableConcept {
+	displays := map[string]string{
+		models.LabFlagNormal:       "Normal",
+		models.LabFlagLow:          "Low",
+		models.LabFlagHigh:         "High",
+		models.LabFlagCriticalLow:  "Critical low",
+		models.LabFlagCriticalHigh: "Critical high",
+		models.LabFlagAbnormal:     "Abnormal",
+	}
+	display, ok := displays[flag]
+	if !ok {
+		return nil
+	}
+	return fhirCoded(fhirInterpretationSystem, flag, display)
+}
+
+// fhirEncounterClass maps a consultation type onto the v3 ActCode encounter classes
+func fhirEncounterClass(consultationType string) *FHIRCoding {
+	if strings.EqualFold(consultationType, "telehealth") {
+		return &FHIRCoding{System: fhirActCodeSystem, Code: "VR", Display: "virtual"}
+	}
+	if strings.EqualFold(consultationType, "home-visit") {
+		return &FHIRCoding{System: fhirActCodeSystem, Code: "HH", Display: "home health"}
+	}
+	return &FHIRCoding{System: fhirActCodeSystem, Code: "AMB", Display: "ambulatory"}
+}
+
+// fhirEncounterStatus maps a telehealth session status onto the Encounter statuses
+func fhirEncounterStatus(status string) string {
+	switch status {
+	case "scheduled":
+		return "planned"
+	case "active":
+		return "in-progress"
+	case "completed":
+		return "finished"
+	case "cancelled", "no_show":
+		return "cancelled"
+	}
+	return "unknown"
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func nonNilConcepts(concepts ...*FHIRCodeableConcept) []FHIRCodeableConcept {
+	var out []FHIRCodeableConcept
+	for _, concept := range concepts {
+		if concept != nil {
+			out = append(out, *concept)
+		}
+	}
+	return out
+}
+
+// fhirExpression names an element of a bundle entry's resource in issue expressions
+func fhirExpression(index int, element string) string {
+	if element == "" {
+		return fmt.Sprintf("Bundle.entry[%d].resource", index)
+	}
+	return fmt.Sprintf("Bundle.entry[%d].resource.%s", index, element)
+}
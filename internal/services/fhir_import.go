@@ -0,0 +1,534 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nyumbanicare/internal/models"
+)
+
+// FHIRImport is a validated Bundle, mapped onto the entries it adds to a patient's medical record
+type FHIRImport struct {
+	BundleType string
+	Patient    *FHIRPatient // the patient the bundle describes, if it includes one
+	Entries    []FHIRImportEntry
+}
+
+// FHIRImportEntry is one bundle entry and what it maps onto. At most one of the
+// allergy, condition, medication, test result and consultation is set; none is
+// set when the entry is skipped.
+type FHIRImportEntry struct {
+	Index        int
+	ResourceType string
+	Key          string // identifies the sender's resource, so importing it again does not duplicate it
+	Allergy      string
+	Condition    string
+	Medication   *models.Medication
+	TestResult   *models.TestResult
+	Consultation *models.Consultation
+	Skipped      string // why the entry was not imported
+}
+
+// FHIRSourceID is the source id recorded for an imported entry, stable per
+// patient and sender resource
+func FHIRSourceID(patientID uuid.UUID, key string) uuid.UUID {
+	return uuid.NewSHA1(patientID, []byte(key))
+}
+
+var (
+	fhirBundleTypes              = []string{"transaction", "batch", "collection", "document"}
+	fhirMedicationStatuses       = []string{"active", "completed", "entered-in-error", "intended", "stopped", "on-hold", "unknown", "not-taken"}
+	fhirObservationStatuses      = []string{"registered", "preliminary", "final", "amended", "corrected", "cancelled", "entered-in-error", "unknown"}
+	fhirDiagnosticReportStatuses = []string{"registered", "partial", "preliminary", "final", "amended", "corrected", "appended", "cancelled", "entered-in-error", "unknown"}
+	fhirEncounterStatuses        = []string{"planned", "arrived", "triaged", "in-progress", "onleave", "finished", "cancelled", "entered-in-error", "unknown"}
+)
+
+// fhirImportParser collects the issues found while mapping a bundle
+type fhirImportParser struct {
+	issues      []FHIRIssue
+	patientRefs map[string]bool
+	patientIDs  map[string]bool
+	medications map[string]string // Medication resources in the bundle by reference, to their names
+}
+
+func (p *fhirImportParser) fail(code, diagnostics string, expression ...string) {
+	p.issues = append(p.issues, FHIRIssue{Severity: "error", Code: code, Diagnostics: diagnostics, Expression: expression})
+}
+
+func (p *fhirImportParser) required(index int, element string) {
+	p.fail("required", fmt.Sprintf("%s is required", element), fhirExpression(index, element))
+}
+
+// ParseFHIRBundle validates a FHIR R4 Bundle and maps the resources it can import
+// onto medical record entries. Import is all or nothing, so any error-level issue
+// means nothing should be imported.
+func ParseFHIRBundle(body []byte) (*FHIRImport, []FHIRIssue) {
+	var bundle struct {
+		ResourceType string            `json:"resourceType"`
+		Type         string            `json:"type"`
+		Entry        []FHIRBundleEntry `json:"entry"`
+	}
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return nil, []FHIRIssue{{Severity: "fatal", Code: "structure", Diagnostics: "The body is not valid FHIR JSON: " + err.Error()}}
+	}
+	if bundle.ResourceType != "Bundle" {
+		return nil, []FHIRIssue{{Severity: "fatal", Code: "invalid", Diagnostics: "Expected a Bundle resource", Expression: []string{"Bundle.resourceType"}}}
+	}
+
+	p := &fhirImportParser{patientRefs: map[string]bool{}, patientIDs: map[string]bool{}, medications: map[string]string{}}
+	result := &FHIRImport{BundleType: bundle.Type}
+	switch {
+	case bundle.Type == "":
+		p.fail("required", "Bundle.type is required", "Bundle.type")
+	case !containsString(fhirBundleTypes, bundle.Type):
+		p.fail("not-supported", fmt.Sprintf("Bundles of type %q cannot be imported; use one of %s", bundle.Type, strings.Join(fhirBundleTypes, ", ")), "Bundle.type")
+	}
+	if len(bundle.Entry) == 0 {
+		p.fail("required", "The bundle has no entries", "Bundle.entry")
+	}
+
+	// Patients and medications are read first, since other entries refer to them
+	resourceTypes := make([]string, len(bundle.Entry))
+	for i, entry := range bundle.Entry {
+		if len(entry.Resource) == 0 {
+			p.fail("required", "Bundle.entry.resource is required", fmt.Sprintf("Bundle.entry[%d].resource", i))
+			continue
+		}
+		var header struct {
+			ResourceType string `json:"resourceType"`
+			ID           string `json:"id"`
+		}
+		if err := json.Unmarshal(entry.Resource, &header); err != nil || header.ResourceType == "" {
+			p.fail("required", "resourceType is required", fhirExpression(i, "resourceType"))
+			continue
+		}
+		resourceTypes[i] = header.ResourceType
+
+		switch header.ResourceType {
+		case "Patient":
+			if result.Patient != nil {
+				p.fail("not-supported", "A bundle may describe only one patient", fhirExpression(i, ""))
+				continue
+			}
+			var patient FHIRPatient
+			if err := json.Unmarshal(entry.Resource, &patient); err != nil {
+				p.fail("structure", err.Error(), fhirExpression(i, ""))
+				continue
+			}
+			result.Patient = &patient
+			if entry.FullURL != "" {
+				p.patientRefs[entry.FullURL] = true
+			}
+			if patient.ID != "" {
+				p.patientIDs[patient.ID] = true
+			}
+		case "Medication":
+			var medication struct {
+				Code *FHIRCodeableConcept `json:"code"`
+			}
+			if err := json.Unmarshal(entry.Resource, &medication); err == nil && medication.Code.Label() != "" {
+				if entry.FullURL != "" {
+					p.medications[entry.FullURL] = medication.Code.Label()
+				}
+				if header.ID != "" {
+					p.medications["Medication/"+header.ID] = medication.Code.Label()
+				}
+			}
+		}
+	}
+
+	for i, entry := range bundle.Entry {
+		if resourceTypes[i] == "" {
+			continue
+		}
+		item := FHIRImportEntry{Index: i, ResourceType: resourceTypes[i], Key: fhirResourceKey(resourceTypes[i], entry)}
+		var err error
+		switch resourceTypes[i] {
+		case "Patient":
+			item.Skipped = "Used to identify the patient"
+		case "AllergyIntolerance":
+			err = p.allergy(entry.Resource, &item)
+		case "Condition":
+			err = p.condition(entry.Resource, &item)
+		case "MedicationStatement":
+			err = p.medicationStatement(entry.Resource, &item)
+		case "Observation":
+			err = p.observation(entry.Resource, &item)
+		case "DiagnosticReport":
+			err = p.diagnosticReport(entry.Resource, &item)
+		case "Encounter":
+			err = p.encounter(entry.Resource, &item)
+		default:
+			item.Skipped = fmt.Sprintf("%s resources are not imported", resourceTypes[i])
+		}
+		if err != nil {
+			p.fail("structure", err.Error(), fhirExpression(i, ""))
+			continue
+		}
+		result.Entries = append(result.Entries, item)
+	}
+
+	for _, issue := range p.issues {
+		if issue.Severity == "error" || issue.Severity == "fatal" {
+			return nil, p.issues
+		}
+	}
+	return result, p.issues
+}
+
+// fhirResourceKey identifies the sender's resource by its absolute URL, its id,
+// or failing both its content
+func fhirResourceKey(resourceType string, entry FHIRBundleEntry) string {
+	if strings.HasPrefix(entry.FullURL, "http://") || strings.HasPrefix(entry.FullURL, "https://") {
+		return entry.FullURL
+	}
+	var header struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(entry.Resource, &header) == nil && header.ID != "" {
+		return resourceType + "/" + header.ID
+	}
+	sum := sha256.Sum256(entry.Resource)
+	return resourceType + "#" + hex.EncodeToString(sum[:])
+}
+
+// subject checks a reference to the patient is present and, when the bundle
+// includes the patient, that it points at them
+func (p *fhirImportParser) subject(index int, element string, ref *FHIRReference) {
+	if ref == nil || strings.TrimSpace(ref.Reference) == "" {
+		p.required(index, element)
+		return
+	}
+	if len(p.patientRefs) == 0 && len(p.patientIDs) == 0 {
+		return
+	}
+	if p.patientRefs[ref.Reference] {
+		return
+	}
+	for id := range p.patientIDs {
+		if ref.Reference == "Patient/"+id || strings.HasSuffix(ref.Reference, "/Patient/"+id) {
+			return
+		}
+	}
+	p.fail("invalid", fmt.Sprintf("%s does not refer to the patient in the bundle", element), fhirExpression(index, element+".reference"))
+}
+
+// inactiveStatus reports whether clinical and verification statuses mean the
+// allergy or condition no longer applies
+func inactiveStatus(clinical, verification *FHIRCodeableConcept) string {
+	switch verification.Code("") {
+	case "entered-in-error", "refuted":
+		return "Verification status is " + verification.Code("")
+	}
+	switch clinical.Code("") {
+	case "", "active", "recurrence", "relapse":
+		return ""
+	}
+	return "Clinical status is " + clinical.Code("")
+}
+
+func (p *fhirImportParser) allergy(raw json.RawMessage, item *FHIRImportEntry) error {
+	var allergy FHIRAllergyIntolerance
+	if err := json.Unmarshal(raw, &allergy); err != nil {
+		return err
+	}
+	p.subject(item.Index, "patient", allergy.Patient)
+	if allergy.Code.Label() == "" {
+		p.required(item.Index, "code")
+		return nil
+	}
+	if reason := inactiveStatus(allergy.ClinicalStatus, allergy.VerificationStatus); reason != "" {
+		item.Skipped = reason
+		return nil
+	}
+	item.Allergy = allergy.Code.Label()
+	return nil
+}
+
+func (p *fhirImportParser) condition(raw json.RawMessage, item *FHIRImportEntry) error {
+	var condition FHIRCondition
+	if err := json.Unmarshal(raw, &condition); err != nil {
+		return err
+	}
+	p.subject(item.Index, "subject", condition.Subject)
+	if condition.Code.Label() == "" {
+		p.required(item.Index, "code")
+		return nil
+	}
+	if reason := inactiveStatus(condition.ClinicalStatus, condition.VerificationStatus); reason != "" {
+		item.Skipped = reason
+		return nil
+	}
+	item.Condition = condition.Code.Label()
+	return nil
+}
+
+func (p *fhirImportParser) medicationStatement(raw json.RawMessage, item *FHIRImportEntry) error {
+	var statement FHIRMedicationStatement
+	if err := json.Unmarshal(raw, &statement); err != nil {
+		return err
+	}
+	p.status(item.Index, statement.Status, fhirMedicationStatuses)
+	p.subject(item.Index, "subject", statement.Subject)
+
+	name := statement.MedicationCodeableConcept.Label()
+	if name == "" && statement.MedicationReference != nil {
+		name = p.medications[statement.MedicationReference.Reference]
+		if name == "" {
+			name = strings.TrimSpace(statement.MedicationReference.Display)
+		}
+	}
+	if name == "" {
+		p.fail("required", "medication[x] is required, as a concept or a reference to a Medication in the bundle", fhirExpression(item.Index, "medication[x]"))
+	}
+
+	start, startOK := time.Time{}, true
+	var end *time.Time
+	if statement.EffectivePeriod != nil {
+		start, startOK = p.dateTime(item.Index, "effectivePeriod.start", statement.EffectivePeriod.Start)
+		if at, ok := p.dateTime(item.Index, "effectivePeriod.end", statement.EffectivePeriod.End); ok && !at.IsZero() {
+			end = &at
+		}
+	} else {
+		start, startOK = p.dateTime(item.Index, "effectiveDateTime", statement.EffectiveDateTime)
+	}
+	if name == "" || !startOK || !containsString(fhirMedicationStatuses, statement.Status) {
+		return nil
+	}
+	switch statement.Status {
+	case "entered-in-error", "not-taken", "intended":
+		item.Skipped = "Status is " + statement.Status
+		return nil
+	}
+
+	medication := &models.Medication{Name: name, StartDate: start}
+	if medication.StartDate.IsZero() {
+		medication.StartDate = time.Now()
+	}
+	medication.EndDate = end
+	if end == nil && (statement.Status == "completed" || statement.Status == "stopped") {
+		stopped := time.Now()
+		medication.EndDate = &stopped
+	}
+	if statement.InformationSource != nil {
+		medication.PrescribedBy = statement.InformationSource.Display
+	}
+	medication.Notes = joinNotes(statement.Note)
+
+	if len(statement.Dosage) > 0 {
+		dosage := statement.Dosage[0]
+		medication.Dosage = dosage.Text
+		if dosage.AsNeededBoolean != nil && *dosage.AsNeededBoolean {
+			medication.AsNeeded = true
+		}
+		if dosage.Timing != nil {
+			medication.Frequency = dosage.Timing.Code.Label()
+			if dosage.Timing.Repeat != nil {
+				for j, at := range dosage.Timing.Repeat.TimeOfDay {
+					t, err := time.Parse("15:04:05", at)
+					if err != nil {
+						p.fail("value", fmt.Sprintf("%q is not a FHIR time", at), fhirExpression(item.Index, fmt.Sprintf("dosage[0].timing.repeat.timeOfDay[%d]", j)))
+						continue
+					}
+					medication.DoseTimes = append(medication.DoseTimes, t.Format("15:04"))
+				}
+			}
+		}
+		if medication.Frequency == "" {
+			medication.Frequency = dosage.Text
+		}
+	}
+	item.Medication = medication
+	return nil
+}
+
+func (p *fhirImportParser) observation(raw json.RawMessage, item *FHIRImportEntry) error {
+	var observation FHIRObservation
+	if err := json.Unmarshal(raw, &observation); err != nil {
+		return err
+	}
+	p.status(item.Index, observation.Status, fhirObservationStatuses)
+	if observation.Code.Label() == "" {
+		p.required(item.Index, "code")
+	}
+	p.subject(item.Index, "subject", observation.Subject)
+	date, dateOK := p.dateTime(item.Index, "effectiveDateTime", observation.EffectiveDateTime)
+	issued, issuedOK := p.dateTime(item.Index, "issued", observation.Issued)
+	if observation.Code.Label() == "" || !dateOK || !issuedOK || !containsString(fhirObservationStatuses, observation.Status) {
+		return nil
+	}
+	switch observation.Status {
+	case "cancelled", "entered-in-error", "registered":
+		item.Skipped = "Status is " + observation.Status
+		return nil
+	}
+
+	value := observation.ValueString
+	if observation.ValueQuantity != nil && observation.ValueQuantity.Value != nil {
+		value = strings.TrimSpace(strconv.FormatFloat(*observation.ValueQuantity.Value, 'f', -1, 64) + " " + observation.ValueQuantity.Unit)
+	} else if observation.ValueCodeableConcept != nil {
+		value = observation.ValueCodeableConcept.Label()
+	}
+	if value == "" {
+		item.Skipped = "The observation has no value"
+		return nil
+	}
+
+	if date.IsZero() {
+		date = issued
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var interpretations []string
+	for _, concept := range observation.Interpretation {
+		interpretations = append(interpretations, concept.Label())
+	}
+	result := &models.TestResult{
+		TestType:       observation.Code.Label(),
+		TestDate:       date,
+		Result:         value,
+		Interpretation: strings.Join(nonEmpty(interpretations...), ", "),
+		DoctorNotes:    joinNotes(observation.Note),
+	}
+	if len(observation.Performer) > 0 {
+		result.LabName = observation.Performer[0].Display
+	}
+	item.TestResult = result
+	return nil
+}
+
+func (p *fhirImportParser) diagnosticReport(raw json.RawMessage, item *FHIRImportEntry) error {
+	var report FHIRDiagnosticReport
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return err
+	}
+	p.status(item.Index, report.Status, fhirDiagnosticReportStatuses)
+	if report.Code.Label() == "" {
+		p.required(item.Index, "code")
+	}
+	if report.Subject != nil {
+		p.subject(item.Index, "subject", report.Subject)
+	}
+	date, ok := p.dateTime(item.Index, "effectiveDateTime", report.EffectiveDateTime)
+	if report.Code.Label() == "" || !ok {
+		return nil
+	}
+
+	// A report's values arrive as its Observations; only a conclusion on its own is kept
+	if len(report.Result) > 0 || strings.TrimSpace(report.Conclusion) == "" {
+		item.Skipped = "The report's results are imported from its Observations"
+		return nil
+	}
+	switch report.Status {
+	case "cancelled", "entered-in-error", "registered", "partial":
+		item.Skipped = "Status is " + report.Status
+		return nil
+	}
+	if date.IsZero() {
+		date = time.Now()
+	}
+	item.TestResult = &models.TestResult{
+		TestType: report.Code.Label(),
+		TestDate: date,
+		Result:   report.Conclusion,
+	}
+	return nil
+}
+
+func (p *fhirImportParser) encounter(raw json.RawMessage, item *FHIRImportEntry) error {
+	var encounter FHIREncounter
+	if err := json.Unmarshal(raw, &encounter); err != nil {
+		return err
+	}
+	p.status(item.Index, encounter.Status, fhirEncounterStatuses)
+	if encounter.Class == nil || (encounter.Class.Code == "" && encounter.Class.Display == "") {
+		p.required(item.Index, "class")
+	}
+	p.subject(item.Index, "subject", encounter.Subject)
+	var start time.Time
+	ok := true
+	if encounter.Period != nil {
+		start, ok = p.dateTime(item.Index, "period.start", encounter.Period.Start)
+	}
+	if encounter.Class == nil || !ok || !containsString(fhirEncounterStatuses, encounter.Status) {
+		return nil
+	}
+	if encounter.Status != "finished" {
+		item.Skipped = "Only finished encounters are imported"
+		return nil
+	}
+	if start.IsZero() {
+		p.required(item.Index, "period.start")
+		return nil
+	}
+
+	consultation := &models.Consultation{Date: start, Type: "in-person"}
+	switch encounter.Class.Code {
+	case "VR":
+		consultation.Type = "telehealth"
+	case "HH":
+		consultation.Type = "home-visit"
+	}
+	for _, participant := range encounter.Participant {
+		if participant.Individual != nil && participant.Individual.Display != "" {
+			consultation.DoctorName = participant.Individual.Display
+			break
+		}
+	}
+	var reasons []string
+	for _, reason := range encounter.ReasonCode {
+		reasons = append(reasons, reason.Label())
+	}
+	consultation.Diagnosis = strings.Join(nonEmpty(reasons...), ", ")
+	item.Consultation = consultation
+	return nil
+}
+
+func (p *fhirImportParser) status(index int, status string, allowed []string) {
+	if status == "" {
+		p.required(index, "status")
+		return
+	}
+	if !containsString(allowed, status) {
+		p.fail("code-invalid", fmt.Sprintf("%q is not a valid status; use one of %s", status, strings.Join(allowed, ", ")), fhirExpression(index, "status"))
+	}
+}
+
+// dateTime parses a FHIR date or dateTime, which may be partial. Empty values
+// parse as the zero time.
+func (p *fhirImportParser) dateTime(index int, element, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	p.fail("value", fmt.Sprintf("%q is not a FHIR date or dateTime", value), fhirExpression(index, element))
+	return time.Time{}, false
+}
+
+func joinNotes(notes []FHIRAnnotation) string {
+	var texts []string
+	for _, note := range notes {
+		texts = append(texts, note.Text)
+	}
+	return strings.Join(nonEmpty(texts...), "\n")
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/nyumbanicare/internal/i18n"
+)
+
+// Image quality issue codes returned to clients
+const (
+	ImageIssueUnsupportedType = "unsupported_type"
+	ImageIssueTypeMismatch    = "type_mismatch"
+	ImageIssueCorrupt         = "corrupt_image"
+	ImageIssueTooSmall        = "too_small"
+	ImageIssueTooLarge        = "too_large"
+	ImageIssueBlurry          = "blurry"
+	ImageIssueUnderexposed    = "underexposed"
+	ImageIssueOverexposed     = "overexposed"
+)
+
+const (
+	minImageShortSide    = 480   // pixels on the shorter side
+	minImageSharpness    = 40.0  // variance of the Laplacian on a 0-255 scale
+	minImageBrightness   = 50.0  // mean luminance
+	maxImageBrightness   = 225.0 // mean luminance
+	maxClippedFraction   = 0.4   // share of pixels that are fully black or fully white
+	qualitySampleSize    = 512   // longest side of the grid used for blur and exposure checks
+	sanitizedJPEGQuality = 92
+	maxImagePixels       = 40_000_000
+)
+
+var allowedImageTypes = []string{"image/jpeg", "image/png"}
+
+// ImageQualityIssue is a structured reason an image was rejected
+type ImageQualityIssue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImageQualityReport describes the checks run on an uploaded image
+type ImageQualityReport struct {
+	Accepted    bool                `json:"accepted"`
+	Issues      []ImageQualityIssue `json:"issues"`
+	ContentType string              `json:"content_type"`
+	Width       int                 `json:"width"`
+	Height      int                 `json:"height"`
+	Sharpness   float64             `json:"sharpness"`
+	Brightness  float64             `json:"brightness"`
+	Orientation int                 `json:"orientation"` // EXIF orientation that was applied, 1 if none
+	GPSRemoved  bool                `json:"gps_removed"`
+}
+
+// SanitizedImage is an accepted image re-encoded upright and without metadata
+type SanitizedImage struct {
+	Data        []byte
+	ContentType string
+}
+
+// CheckImageQuality verifies the type and magic bytes of an upload, decodes it and
+// rejects images that are too small, blurry or badly exposed. Accepted images are
+// rotated upright from their EXIF orientation and re-encoded, which drops all
+// metadata including GPS location, before they are stored or analysed.
+func CheckImageQuality(data []byte, declaredType, language string) (*ImageQualityReport, *SanitizedImage) {
+	report := &ImageQualityReport{Orientation: 1, Issues: []ImageQualityIssue{}}
+
+	detected := http.DetectContentType(data)
+	report.ContentType = detected
+	if !contains(allowedImageTypes, detected) {
+		report.reject(language, ImageIssueUnsupportedType, "Upload a JPEG or PNG photo.")
+		return report, nil
+	}
+	declared := strings.TrimSpace(strings.Split(declaredType, ";")[0])
+	if declared != "" && declared != "application/octet-stream" && declared != detected {
+		report.reject(language, ImageIssueTypeMismatch, "The file content does not match its type. Upload the original photo from your camera.")
+		return report, nil
+	}
+
+	// Check dimensions before decoding so a crafted header cannot exhaust memory
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		report.reject(language, ImageIssueCorrupt, "The image could not be read. Take a new photo and upload it again.")
+		return report, nil
+	}
+	if imgConfig.Width*imgConfig.Height > maxImagePixels {
+		report.reject(language, ImageIssueTooLarge, "The photo resolution is too high. Upload a photo under 40 megapixels.")
+		return report, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		report.reject(language, ImageIssueCorrupt, "The image could not be read. Take a new photo and upload it again.")
+		return report, nil
+	}
+
+	if detected == "image/jpeg" {
+		orientation, hasGPS := readJPEGExif(data)
+		report.GPSRemoved = hasGPS
+		if orientation > 1 && orientation <= 8 {
+			img = applyOrientation(img, orientation)
+			report.Orientation = orientation
+		}
+	}
+
+	bounds := img.Bounds()
+	report.Width, report.Height = bounds.Dx(), bounds.Dy()
+	if minInt(report.Width, report.Height) < minImageShortSide {
+		report.reject(language, ImageIssueTooSmall, "The photo is too small. Move closer so the test kit fills most of the frame, and use your camera's full resolution.")
+		return report, nil
+	}
+
+	gray, gw, gh := sampleLuminance(img)
+	report.Sharpness = laplacianVariance(gray, gw, gh)
+	brightness, dark, bright := exposureStats(gray)
+	report.Brightness = brightness
+
+	if report.Sharpness < minImageSharpness {
+		report.reject(language, ImageIssueBlurry, "The photo is blurry. Hold the phone steady, tap to focus on the test window and try again.")
+	}
+	if brightness < minImageBrightness || dark > maxClippedFraction {
+		report.reject(language, ImageIssueUnderexposed, "The photo is too dark. Move to a well-lit area or turn on more light, avoiding shadows over the test.")
+	}
+	if brightness > maxImageBrightness || bright > maxClippedFraction {
+		report.reject(language, ImageIssueOverexposed, "The photo is too bright. Avoid direct sunlight or flash glare on the test window.")
+	}
+	if len(report.Issues) > 0 {
+		return report, nil
+	}
+
+	var buf bytes.Buffer
+	if detected == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: sanitizedJPEGQuality})
+	}
+	if err != nil {
+		report.reject(language, ImageIssueCorrupt, "The image could not be read. Take a new photo and upload it again.")
+		return report, nil
+	}
+
+	report.Accepted = true
+	return report, &SanitizedImage{Data: buf.Bytes(), ContentType: detected}
+}
+
+func (r *ImageQualityReport) reject(language, code, message string) {
+	r.Accepted = false
+	r.Issues = append(r.Issues, ImageQualityIssue{Code: code, Message: i18n.T(language, message)})
+}
+
+// sampleLuminance converts the image to a grayscale grid no larger than qualitySampleSize
+func sampleLuminance(img image.Image) ([]float64, int, int) {
+	bounds := img.Bounds()
+	step := int(math.Max(1, math.Ceil(float64(maxInt(bounds.Dx(), bounds.Dy()))/qualitySampleSize)))
+	w, h := bounds.Dx()/step, bounds.Dy()/step
+
+	gray := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x*step, bounds.Min.Y+y*step).RGBA()
+			gray[y*w+x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(b>>8)
+		}
+	}
+	return gray, w, h
+}
+
+// laplacianVariance measures focus: sharp edges give a high variance, blur a low one
+func laplacianVariance(gray []float64, w, h int) float64 {
+	if w < 3 || h < 3 {
+		return 0
+	}
+
+	var sum, sumSq float64
+	var n int
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			lap := gray[i-w] + gray[i+w] + gray[i-1] + gray[i+1] - 4*gray[i]
+			sum += lap
+			sumSq += lap * lap
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
+
+// exposureStats returns the mean luminance and the share of crushed and blown-out pixels
+func exposureStats(gray []float64) (float64, float64, float64) {
+	if len(gray) == 0 {
+		return 0, 0, 0
+	}
+
+	var sum float64
+	var dark, bright int
+	for _, v := range gray {
+		sum += v
+		if v < 10 {
+			dark++
+		}
+		if v > 250 {
+			bright++
+		}
+	}
+	total := float64(len(gray))
+	return sum / total, float64(dark) / total, float64(bright) / total
+}
+
+// readJPEGExif returns the EXIF orientation and whether GPS data is present
+func readJPEGExif(data []byte) (int, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1, false
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1, false
+		}
+		marker := data[pos+1]
+		// Start of scan: no more metadata segments follow
+		if marker == 0xDA || marker == 0xD9 {
+			return 1, false
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 1, false
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return parseExifTIFF(segment[6:])
+		}
+		pos = end
+	}
+	return 1, false
+}
+
+func parseExifTIFF(tiff []byte) (int, bool) {
+	if len(tiff) < 8 {
+		return 1, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1, false
+	}
+
+	orientation, hasGPS := 1, false
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		switch order.Uint16(tiff[entry : entry+2]) {
+		case 0x0112: // Orientation, a SHORT stored inline
+			orientation = int(order.Uint16(tiff[entry+8 : entry+10]))
+		case 0x8825: // GPS IFD pointer
+			hasGPS = true
+		}
+	}
+	return orientation, hasGPS
+}
+
+// applyOrientation rotates or flips an image so that EXIF orientation 1 is upright
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnsupportedKitType is returned when a test kit type is not in the catalog
+var ErrUnsupportedKitType = errors.New("unsupported test kit type")
+
+// Kit type codes stored on TestKit and TestKitResult
+const (
+	KitTypeCOVID19Antigen = "covid19_antigen"
+	KitTypeHIVSelfTest    = "hiv_self_test"
+	KitTypeMalariaRDT     = "malaria_rdt"
+	KitTypePregnancy      = "pregnancy"
+	KitTypeGlucose        = "glucose"
+)
+
+// KitTypeSpec defines how results of one kind of test kit are read and handled
+type KitTypeSpec struct {
+	Code         string   `json:"code"`
+	Name         string   `json:"name"`
+	LateralFlow  bool     `json:"lateral_flow"` // read from lines on a cassette
+	ResultValues []string `json:"result_values"`
+	Markers      []string `json:"markers"`
+	// HighRiskResults are prioritised in the review queue and never auto-confirmed
+	HighRiskResults []string `json:"high_risk_results"`
+	// AutoConfirmConfidence is the model confidence at or above which other
+	// results are confirmed without clinician review; 0 means always review
+	AutoConfirmConfidence float64             `json:"auto_confirm_confidence"`
+	RecommendedSteps      map[string][]string `json:"recommended_steps"` // by result value
+
+	aliases       []string          // lowercase fragments matched against free-text kit names
+	resultAliases map[string]string // model wording mapped onto ResultValues
+}
+
+var lineResultValues = []string{"positive", "negative", "inconclusive"}
+
+var inconclusiveSteps = []string{
+	"Repeat the test with a new kit",
+	"Consult a healthcare professional",
+}
+
+// KitCatalog lists every kit type the analysis pipeline supports
+var KitCatalog = []KitTypeSpec{
+	{
+		Code:                  KitTypeCOVID19Antigen,
+		Name:                  "COVID-19 antigen test",
+		LateralFlow:           true,
+		ResultValues:          lineResultValues,
+		Markers:               []string{"control_line", "test_line"},
+		HighRiskResults:       []string{"positive"},
+		AutoConfirmConfidence: 0.9,
+		RecommendedSteps: map[string][]string{
+			"positive": {
+				"Isolate at home and avoid close contact with others, especially older or vulnerable people",
+				"Seek care immediately if you have difficulty breathing",
+				"Inform people you have been in close contact with",
+			},
+			"negative": {
+				"A negative antigen test does not rule out infection. Test again in 48 hours if you have symptoms",
+			},
+			"inconclusive": inconclusiveSteps,
+		},
+		aliases: []string{"covid", "sars-cov-2", "coronavirus"},
+	},
+	{
+		Code:                  KitTypeHIVSelfTest,
+		Name:                  "HIV self-test",
+		LateralFlow:           true,
+		ResultValues:          lineResultValues,
+		Markers:               []string{"control_line", "test_line"},
+		HighRiskResults:       []string{"positive"},
+		AutoConfirmConfidence: 0.95,
+		RecommendedSteps: map[string][]string{
+			"positive": {
+				"A reactive self-test must be confirmed at a health facility",
+				"Visit a health facility or HIV testing centre for confirmatory testing",
+			},
+			"negative": {
+				"If you may have been exposed in the last 3 months, test again after 3 months",
+				"Consider prevention options such as condoms and PrEP",
+			},
+			"inconclusive": inconclusiveSteps,
+		},
+		aliases: []string{"hiv"},
+	},
+	{
+		Code:                  KitTypeMalariaRDT,
+		Name:                  "Malaria rapid diagnostic test",
+		LateralFlow:           true,
+		ResultValues:          lineResultValues,
+		Markers:               []string{"control_line", "test_line", "pf_line", "pan_line"},
+		HighRiskResults:       []string{"positive"},
+		AutoConfirmConfidence: 0.9,
+		RecommendedSteps: map[string][]string{
+			"positive": {
+				"Visit a health facility today for malaria treatment",
+				"Seek emergency care if you have confusion, convulsions or difficulty breathing",
+			},
+			"negative": {
+				"If your fever continues, visit a health facility for further testing",
+			},
+			"inconclusive": inconclusiveSteps,
+		},
+		aliases: []string{"malaria"},
+	},
+	{
+		Code:                  KitTypePregnancy,
+		Name:                  "Pregnancy test",
+		LateralFlow:           true,
+		ResultValues:          lineResultValues,
+		Markers:               []string{"control_line", "test_line"},
+		AutoConfirmConfidence: 0.85,
+		RecommendedSteps: map[string][]string{
+			"positive": {
+				"Book an antenatal care visit with a healthcare provider",
+				"Start taking folic acid supplements if you are not already",
+			},
+			"negative": {
+				"If your period does not start within a week, repeat the test",
+			},
+			"inconclusive": inconclusiveSteps,
+		},
+		aliases: []string{"pregnancy", "hcg"},
+	},
+	{
+		Code:                  KitTypeGlucose,
+		Name:                  "Blood glucose test",
+		ResultValues:          []string{"low", "normal", "high", "inconclusive"},
+		Markers:               []string{"display_reading", "test_strip"},
+		HighRiskResults:       []string{"low", "high"},
+		AutoConfirmConfidence: 0.9,
+		RecommendedSteps: map[string][]string{
+			"low": {
+				"Eat or drink something sugary now, such as juice or glucose tablets",
+				"Recheck your glucose in 15 minutes and seek care if it stays low",
+			},
+			"normal": {
+				"Continue your usual monitoring schedule",
+			},
+			"high": {
+				"Drink water and recheck your glucose",
+				"Contact a healthcare provider if your glucose stays high or you feel unwell",
+			},
+			"inconclusive": {
+				"Repeat the reading with a new test strip",
+				"Consult a healthcare professional",
+			},
+		},
+		aliases: []string{"glucose", "blood sugar", "diabetes", "glucometer"},
+		resultAliases: map[string]string{
+			"hypoglycemia":   "low",
+			"hypoglycaemia":  "low",
+			"in range":       "normal",
+			"within range":   "normal",
+			"normoglycemia":  "normal",
+			"elevated":       "high",
+			"hyperglycemia":  "high",
+			"hyperglycaemia": "high",
+		},
+	},
+}
+
+// markerAliases maps common model wording onto catalog marker names
+var markerAliases = map[string]string{
+	"c_line":  "control_line",
+	"control": "control_line",
+	"t_line":  "test_line",
+	"test":    "test_line",
+	"reading": "display_reading",
+	"strip":   "test_strip",
+}
+
+// GetKitType returns the catalog entry for a kit type code, or nil
+func GetKitType(code string) *KitTypeSpec {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for i := range KitCatalog {
+		if KitCatalog[i].Code == code {
+			return &KitCatalog[i]
+		}
+	}
+	return nil
+}
+
+// LookupKitType resolves the first value that names a catalog kit type, either by
+// code or by a free-text name such as "Rapid HIV Self Test Kit"
+func LookupKitType(values ...string) *KitTypeSpec {
+	for _, value := range values {
+		if spec := GetKitType(value); spec != nil {
+			return spec
+		}
+	}
+	for _, value := range values {
+		value = strings.ToLower(value)
+		if value == "" {
+			continue
+		}
+		for i := range KitCatalog {
+			if containsAny(value, KitCatalog[i].aliases) {
+				return &KitCatalog[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ValidResult reports whether result is a valid reading for this kit type
+func (k *KitTypeSpec) ValidResult(result string) bool {
+	return contains(k.ResultValues, result)
+}
+
+// NormalizeResult maps model wording onto the kit's result values, leaving unknown values unchanged
+func (k *KitTypeSpec) NormalizeResult(result string) string {
+	lower := strings.ToLower(strings.TrimSpace(result))
+	if mapped, ok := k.resultAliases[lower]; ok {
+		return mapped
+	}
+	if k.ValidResult(lower) {
+		return lower
+	}
+	if normalized := NormalizeTestKitResult(lower); k.ValidResult(normalized) {
+		return normalized
+	}
+	return result
+}
+
+// IsHighRisk reports whether result must always be reviewed first
+func (k *KitTypeSpec) IsHighRisk(result string) bool {
+	return contains(k.HighRiskResults, result)
+}
+
+// RequiresReview reports whether a reading needs a clinician before it is final.
+// Only validated model readings of low-risk results above the kit's confidence
+// threshold are auto-confirmed.
+func (k *KitTypeSpec) RequiresReview(result string, confidence float64, source string) bool {
+	if source != AnalysisSourceModel || k.AutoConfirmConfidence <= 0 {
+		return true
+	}
+	if result == "inconclusive" || k.IsHighRisk(result) {
+		return true
+	}
+	return confidence < k.AutoConfirmConfidence
+}
+
+// StandardSteps returns the catalog recommended steps for a result
+func (k *KitTypeSpec) StandardSteps(result string) []string {
+	return k.RecommendedSteps[result]
+}
+
+// schema returns the test kit response schema restricted to this kit's results and markers
+func (k *KitTypeSpec) schema() *jsonSchema {
+	schema := *testKitJSONSchema
+	schema.Properties = make(map[string]*jsonSchema, len(testKitJSONSchema.Properties))
+	for key, property := range testKitJSONSchema.Properties {
+		schema.Properties[key] = property
+	}
+	schema.Properties["result"] = &jsonSchema{Type: "string", Enum: k.ResultValues}
+	schema.Properties["detected_markers"] = &jsonSchema{
+		Type:  "array",
+		Items: &jsonSchema{Type: "string", Enum: k.Markers},
+	}
+	return &schema
+}
+
+// normalizeResponse applies the generic test kit normalisation with this kit's result and marker names
+func (k *KitTypeSpec) normalizeResponse(obj map[string]interface{}) {
+	normalizeTestKitResponse(obj)
+	if result, ok := obj["result"].(string); ok {
+		obj["result"] = k.NormalizeResult(result)
+	}
+	if markers, ok := obj["detected_markers"].([]interface{}); ok {
+		for i, marker := range markers {
+			if name, ok := marker.(string); ok {
+				markers[i] = normalizeMarker(name)
+			}
+		}
+	}
+}
+
+func normalizeMarker(marker string) string {
+	name := strings.Join(strings.FieldsFunc(strings.ToLower(marker), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_")
+	if mapped, ok := markerAliases[name]; ok {
+		return mapped
+	}
+	return name
+}
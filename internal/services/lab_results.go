@@ -0,0 +1,147 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyumbanicare/internal/models"
+)
+
+// AgeInMonths returns a patient's age in whole months on a date, or -1 when
+// their date of birth is unknown
+func AgeInMonths(dateOfBirth *time.Time, on time.Time) int {
+	if dateOfBirth == nil || on.Before(*dateOfBirth) {
+		return -1
+	}
+	months := (on.Year()-dateOfBirth.Year())*12 + int(on.Month()) - int(dateOfBirth.Month())
+	if on.Day() < dateOfBirth.Day() {
+		months--
+	}
+	return months
+}
+
+// SelectReferenceRange picks the reference range that applies to a patient. A
+// range for the patient's sex is preferred over one for everyone, and a narrower
+// age band over a wider one. When the patient's age or sex is unknown only
+// ranges that do not depend on it apply. Returns nil when no range applies.
+func SelectReferenceRange(ranges []models.LabReferenceRange, ageMonths int, gender string) *models.LabReferenceRange {
+	sex := fhirGender(gender)
+	var best *models.LabReferenceRange
+	bestScore := -1
+	for i := range ranges {
+		r := &ranges[i]
+		score := 0
+		if r.Sex != "" {
+			if fhirGender(r.Sex) != sex || (sex != "male" && sex != "female") {
+				continue
+			}
+			score += 2
+		}
+		ageBound := r.MinAgeMonths > 0 || r.MaxAgeMonths != nil
+		if ageBound {
+			if ageMonths < 0 || ageMonths < r.MinAgeMonths || (r.MaxAgeMonths != nil && ageMonths >= *r.MaxAgeMonths) {
+				continue
+			}
+			score++
+		}
+		if score > bestScore || (score == bestScore && ageBound && narrowerAgeBand(r, best)) {
+			best, bestScore = r, score
+		}
+	}
+	return best
+}
+
+// narrowerAgeBand reports whether a covers fewer ages than b
+func narrowerAgeBand(a, b *models.LabReferenceRange) bool {
+	if b == nil {
+		return true
+	}
+	if a.MaxAgeMonths == nil {
+		return false
+	}
+	if b.MaxAgeMonths == nil {
+		return true
+	}
+	return *a.MaxAgeMonths-a.MinAgeMonths < *b.MaxAgeMonths-b.MinAgeMonths
+}
+
+// FlagLabValue applies a reference range to a value: its low and high limits,
+// the critical limits beyond them, and for text values the expected text. The
+// flag is empty when there is nothing to compare against.
+func FlagLabValue(value *models.LabResultValue, r *models.LabReferenceRange) {
+	value.Flag = ""
+	value.Critical = false
+	value.ReferenceLow = nil
+	value.ReferenceHigh = nil
+	value.ReferenceText = ""
+	if r == nil {
+		return
+	}
+	value.ReferenceLow = r.Low
+	value.ReferenceHigh = r.High
+	value.ReferenceText = referenceRangeText(r)
+
+	if value.Value == nil {
+		if r.NormalText == "" || strings.TrimSpace(value.ValueText) == "" {
+			return
+		}
+		value.Flag = models.LabFlagNormal
+		if !strings.EqualFold(strings.TrimSpace(value.ValueText), strings.TrimSpace(r.NormalText)) {
+			value.Flag = models.LabFlagAbnormal
+		}
+		return
+	}
+
+	v := *value.Value
+	switch {
+	case r.CriticalLow != nil && v < *r.CriticalLow:
+		value.Flag = models.LabFlagCriticalLow
+		value.Critical = true
+	case r.CriticalHigh != nil && v > *r.CriticalHigh:
+		value.Flag = models.LabFlagCriticalHigh
+		value.Critical = true
+	case r.Low != nil && v < *r.Low:
+		value.Flag = models.LabFlagLow
+	case r.High != nil && v > *r.High:
+		value.Flag = models.LabFlagHigh
+	case r.Low != nil || r.High != nil:
+		value.Flag = models.LabFlagNormal
+	}
+}
+
+// referenceRangeText prints a range the way lab reports do
+func referenceRangeText(r *models.LabReferenceRange) string {
+	switch {
+	case r.Low != nil && r.High != nil:
+		return formatLabNumber(*r.Low) + " - " + formatLabNumber(*r.High)
+	case r.Low != nil:
+		return ">= " + formatLabNumber(*r.Low)
+	case r.High != nil:
+		return "<= " + formatLabNumber(*r.High)
+	}
+	return r.NormalText
+}
+
+func formatLabNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// LabResultSummary prints a result's values on one line, such as
+// "Haemoglobin 9.1 g/dL (L); Platelets 250 x10^9/L", for places that show a
+// result as text
+func LabResultSummary(values []models.LabResultValue) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		text := value.ValueText
+		if value.Value != nil {
+			text = formatLabNumber(*value.Value)
+		}
+		part := strings.TrimSpace(value.Name + " " + text + " " + value.Unit)
+		if value.Flag != "" && value.Flag != models.LabFlagNormal {
+			part += " (" + value.Flag + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "; ")
+}
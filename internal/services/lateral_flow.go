@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"sort"
+)
+
+// LineAnalysis is the result of reading control and test lines from a lateral flow cassette photo
+type LineAnalysis struct {
+	Result              string  `json:"result"` // positive, negative, inconclusive
+	Confidence          float64 `json:"confidence"`
+	ControlLineDetected bool    `json:"control_line_detected"`
+	ControlIntensity    float64 `json:"control_intensity"` // 0-1 line prominence over the background
+	TestLineDetected    bool    `json:"test_line_detected"`
+	TestIntensity       float64 `json:"test_intensity"`
+	TestToControlRatio  float64 `json:"test_to_control_ratio"`
+	Axis                string  `json:"axis"` // horizontal, vertical
+}
+
+const (
+	// lineMinProminence is the minimum rise of a line above the strip background, on a 0-255 scale
+	lineMinProminence = 6.0
+	// faintLineRatio is the weakest test line, relative to the control line, still read as positive
+	faintLineRatio = 0.12
+	// lineAnalysisMaxSamples caps the pixels sampled per axis to keep the analysis cheap on large photos
+	lineAnalysisMaxSamples = 600
+)
+
+// AnalyzeLateralFlowImage reads control and test line intensities from the pixel
+// profile of a cassette photo. It is deterministic and needs no external service.
+//
+// Lines absorb green light, so the signal is the green channel deficit. The
+// central region is averaged along each axis; the strip runs along the axis
+// whose profile shows the strongest peaks. The strongest peak is taken as the
+// control line and the next strongest, if any, as the test line.
+func AnalyzeLateralFlowImage(data []byte) (*LineAnalysis, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bounds := img.Bounds()
+	if bounds.Dx() < 20 || bounds.Dy() < 20 {
+		return nil, fmt.Errorf("image too small for line detection")
+	}
+
+	// Use the central 60% to keep the cassette housing and background out of the profile
+	region := image.Rect(
+		bounds.Min.X+bounds.Dx()/5, bounds.Min.Y+bounds.Dy()/5,
+		bounds.Max.X-bounds.Dx()/5, bounds.Max.Y-bounds.Dy()/5,
+	)
+
+	rows, cols := lineProfiles(img, region)
+
+	horizontal := findLinePeaks(cols)
+	vertical := findLinePeaks(rows)
+
+	analysis := &LineAnalysis{Axis: "horizontal"}
+	peaks := horizontal
+	if peakStrength(vertical) > peakStrength(horizontal) {
+		analysis.Axis = "vertical"
+		peaks = vertical
+	}
+
+	if len(peaks) == 0 {
+		analysis.Result = "inconclusive"
+		analysis.Confidence = 0.3
+		return analysis, nil
+	}
+
+	control := peaks[0]
+	analysis.ControlLineDetected = true
+	analysis.ControlIntensity = math.Min(control/255, 1)
+
+	if len(peaks) > 1 && peaks[1]/control >= faintLineRatio {
+		analysis.TestLineDetected = true
+		analysis.TestIntensity = math.Min(peaks[1]/255, 1)
+		analysis.TestToControlRatio = peaks[1] / control
+		analysis.Result = "positive"
+		// Faint test lines are read as positive but with less certainty
+		analysis.Confidence = clamp(0.6+0.4*math.Min(analysis.TestToControlRatio/0.5, 1), 0, 0.95)
+		return analysis, nil
+	}
+
+	analysis.Result = "negative"
+	// Confidence grows with how clearly the control line stands out from the background
+	analysis.Confidence = clamp(0.55+control/100, 0, 0.9)
+	return analysis, nil
+}
+
+// lineProfiles averages the green channel deficit across each row and column of the region
+func lineProfiles(img image.Image, region image.Rectangle) ([]float64, []float64) {
+	stepX := int(math.Max(1, float64(region.Dx())/lineAnalysisMaxSamples))
+	stepY := int(math.Max(1, float64(region.Dy())/lineAnalysisMaxSamples))
+
+	rows := make([]float64, 0, region.Dy()/stepY+1)
+	cols := make([]float64, (region.Dx()+stepX-1)/stepX)
+	var rowCount int
+
+	for y := region.Min.Y; y < region.Max.Y; y += stepY {
+		var sum float64
+		var n int
+		for i, x := 0, region.Min.X; x < region.Max.X; i, x = i+1, x+stepX {
+			_, g, _, _ := img.At(x, y).RGBA()
+			signal := 255 - float64(g>>8)
+			sum += signal
+			cols[i] += signal
+			n++
+		}
+		rows = append(rows, sum/float64(n))
+		rowCount++
+	}
+
+	for i := range cols {
+		cols[i] /= float64(rowCount)
+	}
+
+	return rows, cols
+}
+
+// findLinePeaks returns the prominence of each line in the profile, strongest first
+func findLinePeaks(profile []float64) []float64 {
+	if len(profile) < 5 {
+		return nil
+	}
+
+	smoothed := smoothProfile(profile, int(math.Max(1, float64(len(profile))/100)))
+	baseline := median(smoothed)
+	minSeparation := int(math.Max(2, float64(len(smoothed))/20))
+
+	type peak struct {
+		index      int
+		prominence float64
+	}
+	var candidates []peak
+	for i := 1; i < len(smoothed)-1; i++ {
+		if smoothed[i] < smoothed[i-1] || smoothed[i] < smoothed[i+1] {
+			continue
+		}
+		prominence := smoothed[i] - baseline
+		if prominence < lineMinProminence {
+			continue
+		}
+		candidates = append(candidates, peak{index: i, prominence: prominence})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].prominence > candidates[j].prominence
+	})
+
+	// Keep only the strongest peak within each neighbourhood so a wide line counts once
+	var kept []peak
+	for _, candidate := range candidates {
+		separate := true
+		for _, existing := range kept {
+			if absInt(candidate.index-existing.index) < minSeparation {
+				separate = false
+				break
+			}
+		}
+		if separate {
+			kept = append(kept, candidate)
+		}
+	}
+
+	prominences := make([]float64, len(kept))
+	for i, p := range kept {
+		prominences[i] = p.prominence
+	}
+	return prominences
+}
+
+func peakStrength(peaks []float64) float64 {
+	if len(peaks) == 0 {
+		return 0
+	}
+	return peaks[0]
+}
+
+func smoothProfile(profile []float64, radius int) []float64 {
+	smoothed := make([]float64, len(profile))
+	for i := range profile {
+		start := i - radius
+		if start < 0 {
+			start = 0
+		}
+		end := i + radius + 1
+		if end > len(profile) {
+			end = len(profile)
+		}
+		var sum float64
+		for _, v := range profile[start:end] {
+			sum += v
+		}
+		smoothed[i] = sum / float64(end-start)
+	}
+	return smoothed
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+func absInt(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
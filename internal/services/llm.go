@@ -0,0 +1,479 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+	"github.com/nyumbanicare/internal/i18n"
+	"github.com/sashabaranov/go-openai"
+)
+
+// LLM provider names
+const (
+	LLMProviderOpenAI           = "openai"
+	LLMProviderOpenAICompatible = "openai_compatible"
+	LLMProviderRules            = "rules"
+)
+
+// LLM features, each of which can use its own model
+const (
+	LLMFeatureSymptoms  = "symptoms"
+	LLMFeatureAnalytics = "analytics"
+	LLMFeatureVision    = "test_kit_vision"
+	// LLMFeaturePrescription structures prescription images and uses the vision model
+	LLMFeaturePrescription = "prescription_extraction"
+)
+
+var (
+	ErrCircuitOpen      = errors.New("LLM provider circuit breaker is open")
+	ErrEmptyLLMResponse = errors.New("no response from AI service")
+)
+
+// LLMMessage is a single chat message sent to a provider
+type LLMMessage struct {
+	Role     string
+	Content  string
+	ImageURL string // optional image, as a URL or data URI, for vision models
+}
+
+// LLMRequest is a provider independent completion request. Input carries the
+// structured feature request so the rules provider can answer without a model.
+type LLMRequest struct {
+	Feature     string
+	Model       string
+	Messages    []LLMMessage
+	Temperature float32
+	MaxTokens   int
+	Input       interface{}
+}
+
+// LLMUsage is the token and cost accounting for a single call
+type LLMUsage struct {
+	PromptTokens     int           `json:"prompt_tokens"`
+	CompletionTokens int           `json:"completion_tokens"`
+	TotalTokens      int           `json:"total_tokens"`
+	CostUSD          float64       `json:"cost_usd"`
+	Latency          time.Duration `json:"latency"`
+	Attempts         int           `json:"attempts"`
+}
+
+// LLMResponse is the raw text completion returned by a provider
+type LLMResponse struct {
+	Content  string
+	Model    string
+	Provider string
+	Usage    LLMUsage
+}
+
+// LLMProvider generates completions for the AI features
+type LLMProvider interface {
+	Name() string
+	Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+}
+
+// NewLLMProvider builds the configured provider, wrapped with timeouts, retries
+// and a circuit breaker. Without credentials or a base URL it falls back to rules.
+func NewLLMProvider(cfg *config.ExternalConfig) LLMProvider {
+	var provider *OpenAIProvider
+
+	switch strings.ToLower(strings.TrimSpace(cfg.AIProvider)) {
+	case LLMProviderRules:
+		return NewRulesProvider()
+	case LLMProviderOpenAICompatible:
+		baseURL := chatGPTBaseURL(cfg.ChatGPTEndpoint)
+		if baseURL == "" {
+			return NewRulesProvider()
+		}
+		provider = NewOpenAIProvider(LLMProviderOpenAICompatible, cfg.ChatGPTAPIKey, baseURL)
+	default:
+		if cfg.ChatGPTAPIKey == "" {
+			return NewRulesProvider()
+		}
+		provider = NewOpenAIProvider(LLMProviderOpenAI, cfg.ChatGPTAPIKey, chatGPTBaseURL(cfg.ChatGPTEndpoint))
+	}
+
+	return NewResilientProvider(provider, cfg)
+}
+
+// OpenAIProvider talks to the OpenAI API or any server exposing the same chat completions API
+type OpenAIProvider struct {
+	name    string
+	baseURL string
+	client  *openai.Client
+}
+
+func NewOpenAIProvider(name, apiKey, baseURL string) *OpenAIProvider {
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+	return &OpenAIProvider{
+		name:    name,
+		baseURL: clientConfig.BaseURL,
+		client:  openai.NewClientWithConfig(clientConfig),
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, message := range req.Messages {
+		if message.ImageURL == "" {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    message.Role,
+				Content: message.Content,
+			})
+			continue
+		}
+
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role: message.Role,
+			MultiContent: []openai.ChatMessagePart{
+				{Type: openai.ChatMessagePartTypeText, Text: message.Content},
+				{Type: openai.ChatMessagePartTypeImageURL, ImageURL: &openai.ChatMessageImageURL{
+					URL:    message.ImageURL,
+					Detail: openai.ImageURLDetailHigh,
+				}},
+			},
+		})
+	}
+
+	resp, err := p.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s API error: %w", p.name, err)
+	}
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return nil, ErrEmptyLLMResponse
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = req.Model
+	}
+
+	return &LLMResponse{
+		Content:  resp.Choices[0].Message.Content,
+		Model:    model,
+		Provider: p.name,
+		Usage: LLMUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// RulesProvider answers deterministically from the structured request without
+// calling a model. It is used when no model is configured and as the fallback.
+type RulesProvider struct{}
+
+func NewRulesProvider() *RulesProvider {
+	return &RulesProvider{}
+}
+
+func (p *RulesProvider) Name() string {
+	return LLMProviderRules
+}
+
+func (p *RulesProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	var result interface{}
+	switch input := req.Input.(type) {
+	case SymptomCheckRequest:
+		result = mockSymptomAnalysis(input)
+	case AnalyticsRequest:
+		result = mockHealthAnalytics(input)
+	case TestKitResultRequest:
+		result = rulesTestKitAnalysis(input)
+	case PrescriptionExtractionRequest:
+		result = rulesPrescriptionExtraction(input)
+	default:
+		return nil, fmt.Errorf("rules provider does not support feature %s", req.Feature)
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rules response: %v", err)
+	}
+
+	return &LLMResponse{
+		Content:  string(content),
+		Model:    LLMProviderRules,
+		Provider: LLMProviderRules,
+	}, nil
+}
+
+// rulesTestKitAnalysis cannot read the image, so it always defers to a clinician
+func rulesTestKitAnalysis(req TestKitResultRequest) *TestKitResultResponse {
+	return &TestKitResultResponse{
+		Result:           "inconclusive",
+		Confidence:       0.0,
+		DetectedMarkers:  []string{},
+		RecommendedSteps: []string{i18n.T(req.Language, "Consult a healthcare professional")},
+		Notes:            i18n.T(req.Language, "Automated image analysis is unavailable. A clinician will review this result."),
+	}
+}
+
+// ResilientProvider adds per-call timeouts, retries with backoff and a shared
+// circuit breaker around another provider
+type ResilientProvider struct {
+	provider   LLMProvider
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+	breaker    *circuitBreaker
+}
+
+func NewResilientProvider(provider *OpenAIProvider, cfg *config.ExternalConfig) *ResilientProvider {
+	timeout := time.Duration(cfg.AITimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	maxRetries := cfg.AIMaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	return &ResilientProvider{
+		provider:   provider,
+		timeout:    timeout,
+		maxRetries: maxRetries,
+		backoff:    500 * time.Millisecond,
+		breaker: sharedCircuitBreaker(
+			provider.Name()+"|"+provider.baseURL,
+			cfg.AICircuitBreakerThreshold,
+			time.Duration(cfg.AICircuitBreakerCooldownSeconds)*time.Second,
+		),
+	}
+}
+
+func (p *ResilientProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *ResilientProvider) Complete(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	if !p.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(p.backoff * time.Duration(1<<(attempt-1))):
+			case <-ctx.Done():
+				p.breaker.failure()
+				return nil, ctx.Err()
+			}
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		start := time.Now()
+		resp, err := p.provider.Complete(callCtx, req)
+		cancel()
+
+		if err == nil {
+			resp.Usage.Latency = time.Since(start)
+			resp.Usage.Attempts = attempt + 1
+			p.breaker.success()
+			return resp, nil
+		}
+
+		lastErr = err
+		if !isRetryableLLMError(err) {
+			break
+		}
+	}
+
+	p.breaker.failure()
+	return nil, lastErr
+}
+
+// isRetryableLLMError retries rate limits, server errors and transport failures
+// but not authentication or request errors
+func isRetryableLLMError(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests || apiErr.HTTPStatusCode >= 500
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests || reqErr.HTTPStatusCode >= 500
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// circuitBreaker opens after threshold consecutive failures and lets a single
+// trial call through once the cooldown has passed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+var (
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   = make(map[string]*circuitBreaker)
+)
+
+// sharedCircuitBreaker returns the breaker for a provider endpoint. Services are
+// created per request, so breaker state has to live at package level.
+func sharedCircuitBreaker(key string, threshold int, cooldown time.Duration) *circuitBreaker {
+	circuitBreakersMu.Lock()
+	defer circuitBreakersMu.Unlock()
+
+	if breaker, ok := circuitBreakers[key]; ok {
+		return breaker
+	}
+
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	breaker := &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	circuitBreakers[key] = breaker
+	return breaker
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	// Half-open: allow a trial call and keep others out until it reports back
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// llmModelPricing is the USD price per 1K prompt and completion tokens
+var llmModelPricing = map[string][2]float64{
+	"gpt-4":         {0.03, 0.06},
+	"gpt-4-turbo":   {0.01, 0.03},
+	"gpt-4o":        {0.0025, 0.01},
+	"gpt-4o-mini":   {0.00015, 0.0006},
+	"gpt-3.5-turbo": {0.0005, 0.0015},
+}
+
+// llmCallCost prices a call from the configured rates, then the built-in table.
+// Models that are not listed, such as local models, are free.
+func llmCallCost(cfg *config.ExternalConfig, model string, usage LLMUsage) float64 {
+	inputRate, outputRate := cfg.AIInputCostPer1K, cfg.AIOutputCostPer1K
+	if inputRate == 0 && outputRate == 0 {
+		// Dated snapshots such as gpt-4o-mini-2024-07-18 use the longest matching name
+		matched := ""
+		for name, pricing := range llmModelPricing {
+			if (model == name || strings.HasPrefix(model, name+"-")) && len(name) > len(matched) {
+				matched = name
+				inputRate, outputRate = pricing[0], pricing[1]
+			}
+		}
+	}
+	return float64(usage.PromptTokens)/1000*inputRate + float64(usage.CompletionTokens)/1000*outputRate
+}
+
+// LLMUsageTotals aggregates usage per feature, provider and model
+type LLMUsageTotals struct {
+	Feature          string    `json:"feature"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Calls            int       `json:"calls"`
+	Failures         int       `json:"failures"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CostUSD          float64   `json:"cost_usd"`
+	LastCallAt       time.Time `json:"last_call_at"`
+}
+
+var (
+	llmUsageMu sync.Mutex
+	llmUsage   = make(map[string]*LLMUsageTotals)
+)
+
+// recordLLMUsage adds a call to the usage ledger and logs it
+func recordLLMUsage(feature, provider, model string, resp *LLMResponse, err error) {
+	llmUsageMu.Lock()
+	defer llmUsageMu.Unlock()
+
+	key := feature + "|" + provider + "|" + model
+	totals, ok := llmUsage[key]
+	if !ok {
+		totals = &LLMUsageTotals{Feature: feature, Provider: provider, Model: model}
+		llmUsage[key] = totals
+	}
+
+	totals.Calls++
+	totals.LastCallAt = time.Now()
+	if err != nil {
+		totals.Failures++
+		fmt.Printf("LLM call failed: feature=%s provider=%s model=%s error=%v\n", feature, provider, model, err)
+		return
+	}
+
+	totals.PromptTokens += resp.Usage.PromptTokens
+	totals.CompletionTokens += resp.Usage.CompletionTokens
+	totals.TotalTokens += resp.Usage.TotalTokens
+	totals.CostUSD += resp.Usage.CostUSD
+	fmt.Printf("LLM call: feature=%s provider=%s model=%s tokens=%d/%d cost=$%.5f latency=%s attempts=%d\n",
+		feature, provider, resp.Model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens,
+		resp.Usage.CostUSD, resp.Usage.Latency, resp.Usage.Attempts)
+}
+
+// LLMUsageSummary returns the usage ledger since the process started
+func LLMUsageSummary() []LLMUsageTotals {
+	llmUsageMu.Lock()
+	defer llmUsageMu.Unlock()
+
+	summary := make([]LLMUsageTotals, 0, len(llmUsage))
+	for _, totals := range llmUsage {
+		summary = append(summary, *totals)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Feature != summary[j].Feature {
+			return summary[i].Feature < summary[j].Feature
+		}
+		return summary[i].Model < summary[j].Model
+	})
+	return summary
+}
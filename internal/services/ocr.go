@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// OCR providers
+const (
+	OCRProviderNone      = "none"
+	OCRProviderTesseract = "tesseract"
+)
+
+// OCRLine is a line of recognised text with the mean confidence of its words
+type OCRLine struct {
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence"` // 0-1
+}
+
+// OCRResult is the text an engine read from an image
+type OCRResult struct {
+	Engine     string    `json:"engine"`
+	Text       string    `json:"text"`
+	Lines      []OCRLine `json:"lines"`
+	Confidence float64   `json:"confidence"` // mean word confidence, 0-1
+}
+
+// OCREngine reads text from an image
+type OCREngine interface {
+	Name() string
+	Recognize(ctx context.Context, image []byte) (*OCRResult, error)
+}
+
+// NewOCREngine returns the engine for the configured OCR provider, or nil when OCR is disabled
+func NewOCREngine(cfg *config.ExternalConfig) (OCREngine, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.OCRProvider)) {
+	case "", OCRProviderNone:
+		return nil, nil
+	case OCRProviderTesseract:
+		return NewTesseractEngine(cfg.TesseractPath, cfg.OCRLanguages, time.Duration(cfg.OCRTimeoutSeconds)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("OCR provider not supported: %s", cfg.OCRProvider)
+	}
+}
+
+// TesseractEngine runs the tesseract command line tool
+type TesseractEngine struct {
+	path      string
+	languages string
+	timeout   time.Duration
+}
+
+func NewTesseractEngine(path, languages string, timeout time.Duration) *TesseractEngine {
+	if path == "" {
+		path = OCRProviderTesseract
+	}
+	if languages == "" {
+		languages = "eng"
+	}
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &TesseractEngine{path: path, languages: languages, timeout: timeout}
+}
+
+func (e *TesseractEngine) Name() string {
+	return OCRProviderTesseract
+}
+
+// Recognize writes the image to a temporary file and reads tesseract's TSV
+// output, which carries a confidence for every word
+func (e *TesseractEngine) Recognize(ctx context.Context, image []byte) (*OCRResult, error) {
+	tmp, err := os.CreateTemp("", "nyumbanicare-ocr-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer image for OCR: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(image); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to buffer image for OCR: %v", err)
+	}
+	tmp.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.path, tmp.Name(), "stdout", "-l", e.languages, "tsv")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tesseract failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	result := parseTesseractTSV(stdout.String())
+	result.Engine = e.Name()
+	return result, nil
+}
+
+// parseTesseractTSV groups the word rows of tesseract's TSV output into lines.
+// Columns are level, page_num, block_num, par_num, line_num, word_num, left,
+// top, width, height, conf and text; words have level 5 and a conf of 0-100.
+func parseTesseractTSV(tsv string) *OCRResult {
+	type lineKey struct{ page, block, par, line string }
+
+	result := &OCRResult{Lines: []OCRLine{}}
+	var order []lineKey
+	words := map[lineKey][]string{}
+	confidences := map[lineKey][]float64{}
+	var total float64
+	var count int
+
+	for i, row := range strings.Split(tsv, "\n") {
+		cols := strings.Split(strings.TrimRight(row, "\r"), "\t")
+		if i == 0 || len(cols) < 12 || cols[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(cols[11])
+		conf, err := strconv.ParseFloat(cols[10], 64)
+		if text == "" || err != nil || conf < 0 {
+			continue
+		}
+
+		key := lineKey{cols[1], cols[2], cols[3], cols[4]}
+		if _, seen := words[key]; !seen {
+			order = append(order, key)
+		}
+		words[key] = append(words[key], text)
+		confidences[key] = append(confidences[key], conf/100)
+		total += conf / 100
+		count++
+	}
+
+	texts := make([]string, 0, len(order))
+	for _, key := range order {
+		var sum float64
+		for _, conf := range confidences[key] {
+			sum += conf
+		}
+		line := OCRLine{
+			Text:       strings.Join(words[key], " "),
+			Confidence: sum / float64(len(confidences[key])),
+		}
+		result.Lines = append(result.Lines, line)
+		texts = append(texts, line.Text)
+	}
+
+	result.Text = strings.Join(texts, "\n")
+	if count > 0 {
+		result.Confidence = total / float64(count)
+	}
+	return result
+}
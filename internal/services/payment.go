@@ -58,12 +58,22 @@ type PaystackVerifyResponse struct {
 	} `json:"data"`
 }
 
-func (ps *PaymentService) InitiatePayment(order *models.TestKitOrder, email string, callbackURL string) (*models.Payment, error) { // Create a new payment record
+func (ps *PaymentService) InitiatePayment(order *models.TestKitOrder, email string, callbackURL string) (*models.Payment, error) {
+	return ps.initiate(order.ID, models.PaymentOrderTestKit, order.UserID, order.TotalPrice, email, callbackURL)
+}
+
+// InitiatePrescriptionPayment starts payment of an accepted prescription quote
+func (ps *PaymentService) InitiatePrescriptionPayment(prescription *models.Prescription, email string, callbackURL string) (*models.Payment, error) {
+	return ps.initiate(prescription.ID, models.PaymentOrderPrescription, prescription.UserID, prescription.QuoteTotal, email, callbackURL)
+}
+
+func (ps *PaymentService) initiate(orderID uuid.UUID, orderType string, userID uuid.UUID, amount float64, email string, callbackURL string) (*models.Payment, error) { // Create a new payment record
 	payment := &models.Payment{
 		ID:          uuid.New(),
-		OrderID:     order.ID,
-		UserID:      order.UserID,
-		Amount:      order.TotalPrice,
+		OrderID:     orderID,
+		OrderType:   orderType,
+		UserID:      userID,
+		Amount:      amount,
 		Currency:    "KES",
 		Method:      "paystack",
 		Status:      "pending",
@@ -80,7 +90,8 @@ func (ps *PaymentService) InitiatePayment(order *models.TestKitOrder, email stri
 		Callback:  callbackURL,
 		Currency:  payment.Currency,
 		Metadata: map[string]interface{}{
-			"order_id":   order.ID.String(),
+			"order_id":   orderID.String(),
+			"order_type": orderType,
 			"payment_id": payment.ID.String(),
 		},
 	}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page layout, in points
+const (
+	pdfPageWidth   = 595
+	pdfPageHeight  = 842
+	pdfMargin      = 50
+	pdfWrapColumns = 90
+)
+
+type pdfLine struct {
+	text string
+	font string // F1 regular, F2 bold
+	size int
+}
+
+// TextPDF builds a plain text PDF document, such as the summary included in a
+// patient's data export. It uses the standard Helvetica fonts so nothing has to
+// be embedded; characters outside Latin-1 are replaced.
+type TextPDF struct {
+	pages [][]pdfLine
+	y     int
+}
+
+// NewTextPDF starts an empty document
+func NewTextPDF() *TextPDF {
+	return &TextPDF{}
+}
+
+// Heading adds a large bold line
+func (p *TextPDF) Heading(text string) {
+	p.space(12)
+	p.line(text, "F2", 16)
+}
+
+// Subheading adds a bold line
+func (p *TextPDF) Subheading(text string) {
+	p.space(8)
+	p.line(text, "F2", 12)
+}
+
+// Text adds a paragraph, wrapped to the page width
+func (p *TextPDF) Text(text string) {
+	for _, paragraph := range strings.Split(text, "\n") {
+		for _, line := range wrapText(paragraph, pdfWrapColumns) {
+			p.line(line, "F1", 10)
+		}
+	}
+}
+
+func (p *TextPDF) space(points int) {
+	if p.y > 0 {
+		p.y += points
+	}
+}
+
+func (p *TextPDF) line(text, font string, size int) {
+	height := size + size/2
+	if len(p.pages) == 0 || p.y+height > pdfPageHeight-2*pdfMargin {
+		p.pages = append(p.pages, nil)
+		p.y = 0
+	}
+	p.y += height
+	last := len(p.pages) - 1
+	p.pages[last] = append(p.pages[last], pdfLine{text: text, font: font, size: size})
+}
+
+// Bytes renders the document
+func (p *TextPDF) Bytes() []byte {
+	pages := p.pages
+	if len(pages) == 0 {
+		pages = [][]pdfLine{nil}
+	}
+
+	// Objects 1-4 are the catalog, page tree and fonts; each page adds a page and its content
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, lines := range pages {
+		var content bytes.Buffer
+		y := pdfPageHeight - pdfMargin
+		for _, line := range lines {
+			y -= line.size + line.size/2
+			fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", line.font, line.size, pdfMargin, y, pdfEscape(line.text))
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// pdfEscape encodes text as a Latin-1 PDF string literal body
+func pdfEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || r > 0xff:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// wrapText splits text into lines of at most width characters, breaking at spaces
+func wrapText(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	current := ""
+	for _, word := range words {
+		for len([]rune(word)) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case len([]rune(current))+1+len([]rune(word)) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/nyumbanicare/internal/models"
+)
+
+// prescriptionTransitions lists the statuses each prescription status can move to.
+// A pharmacist transcribes an uploaded prescription (processing), then approves it
+// with a quote or rejects it. The patient accepts and pays the quote, and the
+// pharmacy dispenses it, either directly or after delivery.
+var prescriptionTransitions = map[string][]string{
+	models.PrescriptionStatusUploaded:       {models.PrescriptionStatusProcessing, models.PrescriptionStatusRejected, models.PrescriptionStatusCancelled},
+	models.PrescriptionStatusProcessing:     {models.PrescriptionStatusApproved, models.PrescriptionStatusRejected, models.PrescriptionStatusCancelled},
+	models.PrescriptionStatusApproved:       {models.PrescriptionStatusProcessing, models.PrescriptionStatusAccepted, models.PrescriptionStatusCancelled},
+	models.PrescriptionStatusAccepted:       {models.PrescriptionStatusPaid, models.PrescriptionStatusCancelled},
+	models.PrescriptionStatusPaid:           {models.PrescriptionStatusOutForDelivery, models.PrescriptionStatusDispensed},
+	models.PrescriptionStatusOutForDelivery: {models.PrescriptionStatusDispensed},
+}
+
+// CanTransitionPrescription reports whether a prescription may move from one status to another
+func CanTransitionPrescription(from, to string) bool {
+	for _, next := range prescriptionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PrescriptionQuote totals the available medication lines, rounded to cents
+func PrescriptionQuote(medications []models.PrescriptionMedication) float64 {
+	total := 0.0
+	for _, medication := range medications {
+		if !medication.Available {
+			continue
+		}
+		quantity := medication.Quantity
+		if quantity < 1 {
+			quantity = 1
+		}
+		total += medication.Price * float64(quantity)
+	}
+	return math.Round(total*100) / 100
+}
+
+var supplyDurationPattern = regexp.MustCompile(`(?i)\b(\d{1,3})\s*(?:(days?|d)|(weeks?|wks?|w)|(months?|mo))\b|\b(\d{1,3})\s*/\s*(7|52|12)\b`)
+
+// ParseSupplyDays reads a prescribed duration such as "5 days", "2 weeks",
+// "1 month" or the shorthand "5/7", "2/52" and "3/12" as a number of days
+func ParseSupplyDays(duration string) (int, bool) {
+	match := supplyDurationPattern.FindStringSubmatch(duration)
+	if match == nil {
+		return 0, false
+	}
+
+	if match[1] != "" {
+		n, _ := strconv.Atoi(match[1])
+		switch {
+		case match[3] != "":
+			n *= 7
+		case match[4] != "":
+			n *= 30
+		}
+		return n, n > 0
+	}
+
+	n, _ := strconv.Atoi(match[5])
+	switch match[6] {
+	case "52":
+		n *= 7
+	case "12":
+		n *= 30
+	}
+	return n, n > 0
+}
+
+// SupplyDays estimates how long the available medication lines of a dispensed
+// prescription last, which is until the first of them runs out. Each line lasts
+// its prescribed duration, or else its quantity, counted in doses, divided by the
+// doses a day in its frequency. A quantity of one is the default and is usually a
+// pack, so it is not counted. It reports false when no line can be estimated.
+func SupplyDays(medications []models.PrescriptionMedication) (int, bool) {
+	days := 0
+	for _, medication := range medications {
+		if !medication.Available {
+			continue
+		}
+
+		lasts, ok := ParseSupplyDays(medication.Duration)
+		if !ok {
+			schedule, parsed := ParseFrequency(medication.Frequency)
+			if !parsed || len(schedule.Times) == 0 || medication.Quantity <= 1 || medication.Quantity < len(schedule.Times) {
+				continue
+			}
+			lasts = medication.Quantity / len(schedule.Times)
+		}
+		if days == 0 || lasts < days {
+			days = lasts
+		}
+	}
+	return days, days > 0
+}
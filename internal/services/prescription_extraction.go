@@ -0,0 +1,250 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/nyumbanicare/internal/models"
+	"github.com/sashabaranov/go-openai"
+)
+
+// extractionConfidenceThreshold is the confidence below which a field is flagged as illegible
+const extractionConfidenceThreshold = 0.6
+
+// rulesConfidenceFactor discounts OCR confidence for values found by pattern matching
+const rulesConfidenceFactor = 0.8
+
+type PrescriptionExtractionRequest struct {
+	ImageURL         string     `json:"image_url"`
+	ImageData        []byte     `json:"-"`
+	ImageContentType string     `json:"-"`
+	ImagePrivate     bool       `json:"-"`
+	OCR              *OCRResult `json:"ocr,omitempty"` // local OCR output, used as a hint by the model and by the rules provider
+}
+
+type PrescriptionExtractionResponse struct {
+	PrescriberName         models.ExtractedField        `json:"prescriber_name"`
+	PrescriberRegistration models.ExtractedField        `json:"prescriber_registration"`
+	PrescriptionDate       models.ExtractedField        `json:"prescription_date"`
+	Medications            []models.ExtractedMedication `json:"medications"`
+	Flags                  []models.ExtractionFlag      `json:"flags"`
+	Source                 string                       `json:"source"` // model, rules, fallback
+}
+
+// Prescriptions are transcribed verbatim, so these prompts are not translated
+const (
+	prescriptionSystemPrompt = "You are a pharmacy assistant transcribing medical prescriptions, which may be handwritten. " +
+		"Read the prescriber's name, the prescriber's practitioner registration number, the date of the prescription " +
+		"and every prescribed medication with its dosage, frequency, duration and any instructions. " +
+		"Copy values exactly as written, expanding nothing. Give every value a confidence between 0 and 1. " +
+		"Use an empty value with confidence 0 for anything that is not on the prescription, and never guess a " +
+		"medication or dose you cannot read; report your best reading with a low confidence instead. " +
+		"Respond only with valid JSON following the specified structure."
+	prescriptionPrompt         = "Transcribe the attached prescription."
+	prescriptionOCRPrompt      = "Text recognised from the image by OCR, which may contain errors:\n%s"
+	prescriptionResponseSchema = "Format your response as JSON with the following structure, where every field is " +
+		"{\"value\": string, \"confidence\": float}: " +
+		"{\"prescriber_name\": field, \"prescriber_registration\": field, \"prescription_date\": field, " +
+		"\"medications\": [{\"name\": field, \"dosage\": field, \"frequency\": field, \"duration\": field, \"instructions\": field}]}"
+)
+
+var prescriptionJSONSchema = mustParseJSONSchema(strings.ReplaceAll(`{
+	"type": "object",
+	"required": ["prescriber_name", "prescriber_registration", "prescription_date", "medications"],
+	"properties": {
+		"prescriber_name": $field,
+		"prescriber_registration": $field,
+		"prescription_date": $field,
+		"medications": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["name", "dosage", "frequency", "duration"],
+				"properties": {
+					"name": $field,
+					"dosage": $field,
+					"frequency": $field,
+					"duration": $field,
+					"instructions": $field
+				}
+			}
+		}
+	}
+}`, "$field", `{
+	"type": "object",
+	"required": ["value", "confidence"],
+	"properties": {
+		"value": {"type": "string"},
+		"confidence": {"type": "number", "minimum": 0, "maximum": 1}
+	}
+}`))
+
+// ExtractPrescription reads a prescription image into proposed medication lines.
+// The vision model reads the image with the OCR text as a hint; without a model
+// the rules provider pattern-matches the OCR text. Every result carries flags
+// for missing and illegible fields and must be confirmed by a pharmacist.
+func (ai *AIService) ExtractPrescription(req *PrescriptionExtractionRequest) (*PrescriptionExtractionResponse, error) {
+	parts := []string{prescriptionPrompt, prescriptionResponseSchema}
+	if req.OCR != nil && strings.TrimSpace(req.OCR.Text) != "" {
+		parts = append(parts, fmt.Sprintf(prescriptionOCRPrompt, req.OCR.Text))
+	}
+
+	var response PrescriptionExtractionResponse
+	source, err := ai.structuredCompletion(LLMRequest{
+		Feature: LLMFeaturePrescription,
+		Messages: []LLMMessage{
+			{Role: openai.ChatMessageRoleSystem, Content: prescriptionSystemPrompt},
+			{
+				Role:     openai.ChatMessageRoleUser,
+				Content:  strings.Join(parts, "\n\n"),
+				ImageURL: imageInput(req.ImageURL, req.ImagePrivate, req.ImageData, req.ImageContentType),
+			},
+		},
+		Temperature: 0.1,
+		MaxTokens:   1500,
+		Input:       *req,
+	}, "en", prescriptionJSONSchema, normalizePrescriptionExtraction, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract prescription: %w", err)
+	}
+
+	response.Source = source
+	response.Flags = flagPrescriptionExtraction(&response)
+	return &response, nil
+}
+
+// normalizePrescriptionExtraction fills in absent fields and accepts bare string
+// values, which carry no confidence and are therefore flagged for checking
+func normalizePrescriptionExtraction(obj map[string]interface{}) {
+	for _, key := range []string{"prescriber_name", "prescriber_registration", "prescription_date"} {
+		normalizeExtractedField(obj, key)
+	}
+	defaultArray(obj, "medications")
+	if medications, ok := obj["medications"].([]interface{}); ok {
+		for _, medication := range medications {
+			if medication, ok := medication.(map[string]interface{}); ok {
+				for _, key := range []string{"name", "dosage", "frequency", "duration", "instructions"} {
+					normalizeExtractedField(medication, key)
+				}
+			}
+		}
+	}
+}
+
+func normalizeExtractedField(obj map[string]interface{}, key string) {
+	switch value := obj[key].(type) {
+	case nil:
+		obj[key] = map[string]interface{}{"value": "", "confidence": 0.0}
+	case string:
+		obj[key] = map[string]interface{}{"value": value, "confidence": 0.0}
+	case map[string]interface{}:
+		if value["value"] == nil {
+			value["value"] = ""
+		}
+		if value["confidence"] == nil {
+			value["confidence"] = 0.0
+		}
+		normalizeFraction(value, "confidence")
+	}
+}
+
+// flagPrescriptionExtraction lists the prescriber details and medication fields
+// that are missing or were read with low confidence
+func flagPrescriptionExtraction(response *PrescriptionExtractionResponse) []models.ExtractionFlag {
+	flags := []models.ExtractionFlag{}
+	check := func(field string, value models.ExtractedField, required bool) {
+		switch {
+		case strings.TrimSpace(value.Value) == "":
+			if required {
+				flags = append(flags, models.ExtractionFlag{Field: field, Issue: models.ExtractionIssueMissing})
+			}
+		case value.Confidence < extractionConfidenceThreshold:
+			flags = append(flags, models.ExtractionFlag{Field: field, Issue: models.ExtractionIssueIllegible})
+		}
+	}
+
+	check("prescriber_name", response.PrescriberName, true)
+	check("prescriber_registration", response.PrescriberRegistration, true)
+	check("prescription_date", response.PrescriptionDate, true)
+
+	if len(response.Medications) == 0 {
+		flags = append(flags, models.ExtractionFlag{Field: "medications", Issue: models.ExtractionIssueMissing})
+	}
+	for i, medication := range response.Medications {
+		prefix := fmt.Sprintf("medications[%d].", i)
+		check(prefix+"name", medication.Name, true)
+		check(prefix+"dosage", medication.Dosage, true)
+		check(prefix+"frequency", medication.Frequency, true)
+		check(prefix+"duration", medication.Duration, true)
+		check(prefix+"instructions", medication.Instructions, false)
+	}
+	return flags
+}
+
+var (
+	prescriptionDrugPattern = regexp.MustCompile(`(?i)^\s*(?:\d+\s*[.)]\s*|rx:?\s*)?(?:(?:tabs?|caps?|syr|syrup|susp|inj)\.?\s+)?` +
+		`([a-z][a-z-]{2,}(?:\s+[a-z][a-z-]{2,})?)\s+(\d+(?:\.\d+)?\s*(?:mg|mcg|µg|g|ml|iu|units?|%)(?:/\d*\s*ml)?)`)
+	prescriptionFrequencyPattern = regexp.MustCompile(`(?i)\b(o\.?d\.?|b\.?d\.?|bid|t\.?d\.?s\.?|tid|q\.?i\.?d\.?|qds|nocte|mane|prn|stat|` +
+		`once daily|twice daily|three times (?:a )?daily|every \d+ hours|\d+ times (?:a|per) day|\d\s*x\s*\d)(?:\s|$|[,;])`)
+	prescriptionDurationPattern     = regexp.MustCompile(`(?i)(?:\bfor\s+|\bx\s*)?\b(\d+\s*(?:days?|weeks?|months?)|\d+/(?:7|52))`)
+	prescriptionPrescriberPattern   = regexp.MustCompile(`(?i)\b(dr\.?\s+[a-z][a-z .'-]*[a-z])`)
+	prescriptionRegistrationPattern = regexp.MustCompile(`(?i)\b(?:reg(?:istration)?\.?\s*(?:no\.?|number)?|kmpdc|mpdb|licen[cs]e\s*(?:no\.?)?)\s*[:#.]?\s*([a-z0-9][a-z0-9/-]{2,})`)
+	prescriptionDatePattern         = regexp.MustCompile(`\b(\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4})\b`)
+)
+
+// rulesPrescriptionExtraction pattern-matches the OCR text line by line. It finds
+// common printed and clearly written forms such as "Amoxicillin 500mg tds x 5 days";
+// anything else is left for the pharmacist.
+func rulesPrescriptionExtraction(req PrescriptionExtractionRequest) *PrescriptionExtractionResponse {
+	response := &PrescriptionExtractionResponse{
+		Medications: []models.ExtractedMedication{},
+		Flags:       []models.ExtractionFlag{},
+	}
+	if req.OCR == nil {
+		return response
+	}
+
+	field := func(value string, line OCRLine) models.ExtractedField {
+		return models.ExtractedField{
+			Value:      strings.TrimSpace(value),
+			Confidence: math.Round(line.Confidence*rulesConfidenceFactor*100) / 100,
+		}
+	}
+
+	for _, line := range req.OCR.Lines {
+		if match := prescriptionDrugPattern.FindStringSubmatch(line.Text); match != nil {
+			medication := models.ExtractedMedication{
+				Name:   field(match[1], line),
+				Dosage: field(match[2], line),
+			}
+			rest := line.Text[len(match[0]):]
+			if frequency := prescriptionFrequencyPattern.FindStringSubmatch(rest); frequency != nil {
+				medication.Frequency = field(frequency[1], line)
+			}
+			if duration := prescriptionDurationPattern.FindStringSubmatch(rest); duration != nil {
+				medication.Duration = field(duration[1], line)
+			}
+			response.Medications = append(response.Medications, medication)
+			continue
+		}
+
+		if response.PrescriberName.Value == "" {
+			if match := prescriptionPrescriberPattern.FindStringSubmatch(line.Text); match != nil {
+				response.PrescriberName = field(match[1], line)
+			}
+		}
+		if response.PrescriberRegistration.Value == "" {
+			if match := prescriptionRegistrationPattern.FindStringSubmatch(line.Text); match != nil {
+				response.PrescriberRegistration = field(match[1], line)
+			}
+		}
+		if response.PrescriptionDate.Value == "" {
+			if match := prescriptionDatePattern.FindStringSubmatch(line.Text); match != nil {
+				response.PrescriptionDate = field(match[1], line)
+			}
+		}
+	}
+	return response
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"time"
+
+	"github.com/nyumbanicare/internal/models"
+)
+
+// ReviewClaimTTL is how long a clinician's claim holds before the result returns to the open queue
+const ReviewClaimTTL = 30 * time.Minute
+
+// lowConfidenceThreshold marks AI readings that need a closer look
+const lowConfidenceThreshold = 0.7
+
+// Review SLAs by priority band
+var reviewSLAs = []struct {
+	MinPriority int
+	SLA         time.Duration
+}{
+	{MinPriority: 100, SLA: 4 * time.Hour},
+	{MinPriority: 50, SLA: 12 * time.Hour},
+	{MinPriority: 0, SLA: 24 * time.Hour},
+}
+
+// AssessReviewPriority scores how urgently a clinician should review a result.
+// High-risk results for the kit type (positive results, or abnormal glucose), then
+// inconclusive and low-confidence readings come first, as do results the model
+// could not read itself.
+func AssessReviewPriority(result *models.TestKitResult) int {
+	priority := 0
+
+	highRisk := result.Result == "positive"
+	if spec := GetKitType(result.KitType); spec != nil {
+		highRisk = spec.IsHighRisk(result.Result)
+	}
+
+	switch {
+	case highRisk:
+		priority += 100
+	case result.Result == "inconclusive":
+		priority += 60
+	}
+
+	if result.AIConfidence < lowConfidenceThreshold {
+		priority += 50
+	}
+
+	if result.AnalysisSource != AnalysisSourceModel {
+		priority += 20
+	}
+
+	return priority
+}
+
+// ReviewSLA returns the time allowed to review a result with the given priority
+func ReviewSLA(priority int) time.Duration {
+	for _, band := range reviewSLAs {
+		if priority >= band.MinPriority {
+			return band.SLA
+		}
+	}
+	return reviewSLAs[len(reviewSLAs)-1].SLA
+}
+
+// QueueForReview sets the review priority and SLA deadline on a new result
+func QueueForReview(result *models.TestKitResult, now time.Time) {
+	result.Status = models.TestKitResultStatusPending
+	result.ReviewPriority = AssessReviewPriority(result)
+	due := now.Add(ReviewSLA(result.ReviewPriority))
+	result.ReviewDueAt = &due
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// Scan providers
+const (
+	ScanProviderNone   = "none"
+	ScanProviderClamAV = "clamav"
+)
+
+// ErrScanFailed is returned when an upload could not be scanned, so it is not stored
+var ErrScanFailed = errors.New("file scan failed")
+
+// ScanResult is a scanner's verdict on some content
+type ScanResult struct {
+	Clean     bool
+	Signature string // name of the detected threat when not clean
+}
+
+// Scanner checks uploaded content for malware
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, src io.Reader) (*ScanResult, error)
+}
+
+// InfectedFileError is returned when a scanner rejects an upload
+type InfectedFileError struct {
+	Scanner   string
+	Signature string
+	Checksum  string
+	Size      int64
+}
+
+func (e *InfectedFileError) Error() string {
+	return fmt.Sprintf("file rejected by %s: %s", e.Scanner, e.Signature)
+}
+
+// NewScanner returns the scanner for the configured provider, or nil when scanning is disabled
+func NewScanner(cfg *config.StorageConfig) (Scanner, error) {
+	switch cfg.ScanProvider {
+	case "", ScanProviderNone:
+		return nil, nil
+	case ScanProviderClamAV:
+		return NewClamAVScanner(cfg.ClamAVAddress, time.Duration(cfg.ScanTimeoutSeconds)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("scan provider not supported: %s", cfg.ScanProvider)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of each INSTREAM chunk; clamd's default StreamMaxLength is 25MB overall
+const clamdChunkSize = 64 * 1024
+
+// ClamAVScanner scans content with a clamd daemon over its TCP protocol
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+	dialer  net.Dialer
+}
+
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &ClamAVScanner{address: address, timeout: timeout}
+}
+
+func (s *ClamAVScanner) Name() string {
+	return ScanProviderClamAV
+}
+
+// Ping checks that clamd is reachable
+func (s *ClamAVScanner) Ping(ctx context.Context) error {
+	reply, err := s.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd reply: %q", reply)
+	}
+	return nil
+}
+
+// Scan streams src to clamd with the INSTREAM command
+func (s *ClamAVScanner) Scan(ctx context.Context, src io.Reader) (*ScanResult, error) {
+	reply, err := s.command(ctx, "INSTREAM", src)
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(reply)
+}
+
+// command sends a null-terminated clamd command, then the chunked body if any,
+// and returns the reply without its terminator
+func (s *ClamAVScanner) command(ctx context.Context, name string, body io.Reader) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	conn, err := s.dialer.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to clamd: %v", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("z" + name + "\x00")); err != nil {
+		return "", fmt.Errorf("failed to send clamd command: %v", err)
+	}
+	if body != nil {
+		if err := writeClamdChunks(conn, body); err != nil {
+			// clamd closes the stream when it refuses it, e.g. over its size limit; its reply says why
+			if reply, readErr := readClamdReply(conn); readErr == nil {
+				return "", fmt.Errorf("clamd refused the stream: %s", reply)
+			}
+			return "", err
+		}
+	}
+
+	return readClamdReply(conn)
+}
+
+// writeClamdChunks sends body as length-prefixed chunks followed by a zero-length chunk
+func writeClamdChunks(w io.Writer, body io.Reader) error {
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := body.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, writeErr := w.Write(buf[:4+n]); writeErr != nil {
+				return fmt.Errorf("failed to stream file to clamd: %v", writeErr)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read file for scanning: %v", err)
+		}
+	}
+
+	if _, err := w.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("failed to finish clamd stream: %v", err)
+	}
+	return nil
+}
+
+func readClamdReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadString(0)
+	if err != nil && !(err == io.EOF && reply != "") {
+		return "", fmt.Errorf("failed to read clamd reply: %v", err)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
+
+// parseClamdReply reads replies such as "stream: OK" and "stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (*ScanResult, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return &ScanResult{Clean: true}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &ScanResult{Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("clamd error: %s", reply)
+	}
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// fakeClamd speaks enough of the clamd protocol for the scanner: zPING and
+// zINSTREAM with length-prefixed chunks. It answers each stream with verdict,
+// or refuses streams over limit bytes the way clamd's StreamMaxLength does.
+type fakeClamd struct {
+	listener net.Listener
+	verdict  func(content []byte) string
+	limit    int
+
+	mu       sync.Mutex
+	received [][]byte
+}
+
+func newFakeClamd(t *testing.T, verdict func(content []byte) string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	fake := &fakeClamd{listener: listener, verdict: verdict}
+	t.Cleanup(func() { listener.Close() })
+	go fake.serve()
+	return fake
+}
+
+func (f *fakeClamd) address() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch command {
+	case "zPING\x00":
+		conn.Write([]byte("PONG\x00"))
+		return
+	case "zINSTREAM\x00":
+	default:
+		conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var content []byte
+	refused := false
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return
+		}
+		if refused {
+			continue
+		}
+		content = append(content, chunk...)
+		if f.limit > 0 && len(content) > f.limit {
+			// clamd replies as soon as the limit is passed; keep reading so the
+			// client can finish writing and read the reply
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			refused = true
+		}
+	}
+	if refused {
+		return
+	}
+
+	f.mu.Lock()
+	f.received = append(f.received, content)
+	f.mu.Unlock()
+	conn.Write([]byte(f.verdict(content) + "\x00"))
+}
+
+func TestClamAVScannerVerdicts(t *testing.T) {
+	fake := newFakeClamd(t, func(content []byte) string {
+		switch {
+		case bytes.Contains(content, []byte("EICAR")):
+			return "stream: Eicar-Test-Signature FOUND"
+		case bytes.Contains(content, []byte("broken")):
+			return "stream: Can't allocate memory ERROR"
+		}
+		return "stream: OK"
+	})
+	scanner := NewClamAVScanner(fake.address(), 5*time.Second)
+	ctx := context.Background()
+
+	if err := scanner.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	// Larger than one chunk, to check the chunks are reassembled in order
+	clean := bytes.Repeat([]byte("prescription scan "), 10000)
+	result, err := scanner.Scan(ctx, bytes.NewReader(clean))
+	if err != nil || !result.Clean {
+		t.Fatalf("clean file: result %+v, error %v", result, err)
+	}
+	fake.mu.Lock()
+	received := fake.received[len(fake.received)-1]
+	fake.mu.Unlock()
+	if !bytes.Equal(received, clean) {
+		t.Errorf("clamd received %d bytes, want the %d sent", len(received), len(clean))
+	}
+
+	result, err = scanner.Scan(ctx, strings.NewReader("X5O!P%@AP EICAR test file"))
+	if err != nil || result.Clean || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("infected file: result %+v, error %v", result, err)
+	}
+
+	if result, err := scanner.Scan(ctx, strings.NewReader("broken")); err == nil {
+		t.Errorf("clamd error reply: result %+v, want an error", result)
+	} else if !strings.Contains(err.Error(), "Can't allocate memory") {
+		t.Errorf("error %q does not carry clamd's reply", err)
+	}
+}
+
+func TestClamAVScannerSizeLimit(t *testing.T) {
+	fake := newFakeClamd(t, func([]byte) string { return "stream: OK" })
+	fake.limit = 100 * 1024
+	scanner := NewClamAVScanner(fake.address(), 5*time.Second)
+
+	result, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 512*1024)))
+	if err == nil {
+		t.Fatalf("oversized stream: result %+v, want an error", result)
+	}
+	if !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Errorf("error %q does not report the size limit", err)
+	}
+}
+
+// unreachableAddress returns a local address nothing is listening on
+func unreachableAddress(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestClamAVScannerUnreachable(t *testing.T) {
+	scanner := NewClamAVScanner(unreachableAddress(t), time.Second)
+	if err := scanner.Ping(context.Background()); err == nil {
+		t.Error("Ping succeeded without clamd")
+	}
+	if _, err := scanner.Scan(context.Background(), strings.NewReader("file")); err == nil {
+		t.Error("Scan succeeded without clamd")
+	}
+}
+
+func TestStorageServiceScanFailsClosed(t *testing.T) {
+	store := testLocalStore(t)
+	infected := newFakeClamd(t, func([]byte) string { return "stream: Eicar-Test-Signature FOUND" })
+
+	tests := []struct {
+		name    string
+		address string
+		check   func(err error) bool
+	}{
+		{"scanner unreachable", unreachableAddress(t), func(err error) bool { return errors.Is(err, ErrScanFailed) }},
+		{"infected", infected.address(), func(err error) bool {
+			var infectedErr *InfectedFileError
+			return errors.As(err, &infectedErr) && infectedErr.Signature == "Eicar-Test-Signature"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &StorageService{
+				config:  &config.StorageConfig{UploadFolder: "uploads"},
+				store:   store,
+				scanner: NewClamAVScanner(tt.address, time.Second),
+			}
+			stored, err := service.SavePrivate(strings.NewReader("%PDF-1.4 lab report"), "report.pdf", "lab_reports")
+			if err == nil {
+				t.Fatalf("upload was stored as %s", stored.Key)
+			}
+			if !tt.check(err) {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+
+	// Nothing reached the blob store
+	var stored []string
+	filepath.WalkDir(store.root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			stored = append(stored, path)
+		}
+		return nil
+	})
+	if len(stored) > 0 {
+		t.Errorf("rejected uploads were stored: %v", stored)
+	}
+}
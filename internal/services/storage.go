@@ -2,102 +2,191 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net/http"
+	"os"
 	"path"
-	"strings"
+	"time"
 
-	"github.com/cloudinary/cloudinary-go/v2"
-	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
 	"github.com/nyumbanicare/internal/config"
 )
 
 type StorageService struct {
-	config     *config.StorageConfig
-	cloudinary *cloudinary.Cloudinary
+	config  *config.StorageConfig
+	store   BlobStore
+	scanner Scanner // nil when scanning is disabled
+}
+
+// StoredFile describes content written to the blob store
+type StoredFile struct {
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"` // hex SHA-256 of the content
 }
 
 func NewStorageService(cfg *config.StorageConfig) (*StorageService, error) {
-	service := &StorageService{
-		config: cfg,
+	store, err := NewBlobStore(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.Provider == "cloudinary" && cfg.CloudName != "" && cfg.APIKey != "" && cfg.APISecret != "" {
-		cld, err := cloudinary.NewFromParams(cfg.CloudName, cfg.APIKey, cfg.APISecret)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize Cloudinary: %v", err)
-		}
-		service.cloudinary = cld
+	scanner, err := NewScanner(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	return service, nil
+	return &StorageService{
+		config:  cfg,
+		store:   store,
+		scanner: scanner,
+	}, nil
 }
 
 func (s *StorageService) UploadFile(file *multipart.FileHeader, folder string) (string, error) {
-	if s.config.Provider == "cloudinary" && s.cloudinary != nil {
-		return s.uploadToCloudinary(file, folder)
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %v", err)
 	}
+	defer src.Close()
 
-	return "", fmt.Errorf("storage provider not configured or unsupported: %s", s.config.Provider)
+	return s.UploadReader(src, file.Filename, folder)
 }
 
-func (s *StorageService) uploadToCloudinary(file *multipart.FileHeader, folder string) (string, error) {
-	src, err := file.Open()
+// UploadReader uploads content that has already been read or processed, such as a sanitized image
+func (s *StorageService) UploadReader(src io.Reader, filename string, folder string) (string, error) {
+	stored, err := s.Save(src, filename, folder)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %v", err)
+		return "", err
 	}
-	defer src.Close()
+	return stored.URL, nil
+}
+
+// Save streams src to the blob store under a key derived from its checksum. The
+// content is spooled to a temporary file so its checksum and size are known
+// before the upload starts, without holding the whole file in memory. The spool
+// is also the quarantine: content only reaches the blob store once the scanner
+// has passed it, and infected content returns an *InfectedFileError.
+func (s *StorageService) Save(src io.Reader, filename string, folder string) (*StoredFile, error) {
+	return s.save(src, filename, folder, false)
+}
+
+// SavePrivate stores a medical file that is only reachable through SignedURL.
+// The returned StoredFile has no URL.
+func (s *StorageService) SavePrivate(src io.Reader, filename string, folder string) (*StoredFile, error) {
+	return s.save(src, filename, folder, true)
+}
 
-	uploadFolder := s.config.UploadFolder
-	if folder != "" {
-		uploadFolder = path.Join(uploadFolder, folder)
+// SignedURL returns a temporary download URL for a stored key
+func (s *StorageService) SignedURL(key string, ttl time.Duration) (string, error) {
+	return s.store.SignedURL(key, ttl)
+}
+
+// Open reads a stored object, for server-side processing of private files
+func (s *StorageService) Open(key string) (io.ReadCloser, error) {
+	return s.store.Open(context.Background(), key)
+}
+
+// Scanner returns the name of the upload scanner, or "" when scanning is disabled
+func (s *StorageService) Scanner() string {
+	if s.scanner == nil {
+		return ""
 	}
+	return s.scanner.Name()
+}
+
+// Provider returns the name of the configured blob store
+func (s *StorageService) Provider() string {
+	return s.store.Name()
+}
 
-	ctx := context.Background()
-	uploadParams := uploader.UploadParams{
-		PublicID: strings.TrimSuffix(file.Filename, path.Ext(file.Filename)),
-		Folder:   uploadFolder,
+func (s *StorageService) save(src io.Reader, filename string, folder string, private bool) (*StoredFile, error) {
+	tmp, err := os.CreateTemp("", "nyumbanicare-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer upload: %v", err)
 	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
 
-	uploadResult, err := s.cloudinary.Upload.Upload(ctx, src, uploadParams)
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload to Cloudinary: %v", err)
+		return nil, fmt.Errorf("failed to read upload: %v", err)
 	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
 
-	return uploadResult.SecureURL, nil
-}
+	head := make([]byte, 512)
+	n, _ := tmp.ReadAt(head, 0)
+	contentType := http.DetectContentType(head[:n])
 
-func (s *StorageService) GetFileURL(fileName string, folder string) string {
-	if s.config.Provider == "cloudinary" {
-		uploadFolder := s.config.UploadFolder
-		if folder != "" {
-			uploadFolder = path.Join(uploadFolder, folder)
+	if s.scanner != nil {
+		result, err := s.scanner.Scan(context.Background(), io.NewSectionReader(tmp, 0, size))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrScanFailed, err)
+		}
+		if !result.Clean {
+			return nil, &InfectedFileError{
+				Scanner:   s.scanner.Name(),
+				Signature: result.Signature,
+				Checksum:  checksum,
+				Size:      size,
+			}
 		}
-		return fmt.Sprintf("https://res.cloudinary.com/%s/image/upload/%s/%s",
-			s.config.CloudName, uploadFolder, fileName)
 	}
 
-	return ""
-}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind upload: %v", err)
+	}
 
-func (s *StorageService) DeleteFile(fileURL string) error {
-	if s.config.Provider == "cloudinary" && s.cloudinary != nil {
-		parts := strings.Split(fileURL, "/upload/")
-		if len(parts) != 2 {
-			return fmt.Errorf("invalid Cloudinary URL format")
-		}
+	prefix := s.config.UploadFolder
+	if private {
+		prefix = privateKeyPrefix + prefix
+	}
+	key := contentKey(prefix, folder, checksum, filename, contentType)
+	if err := s.store.Put(context.Background(), key, tmp, size, contentType); err != nil {
+		return nil, err
+	}
 
-		publicIDWithExt := parts[1]
-		publicID := strings.TrimSuffix(publicIDWithExt, path.Ext(publicIDWithExt))
+	stored := &StoredFile{
+		Key:         key,
+		ContentType: contentType,
+		Size:        size,
+		Checksum:    checksum,
+	}
+	if !private {
+		stored.URL = s.store.URL(key)
+	}
+	return stored, nil
+}
 
-		ctx := context.Background()
-		_, err := s.cloudinary.Upload.Destroy(ctx, uploader.DestroyParams{PublicID: publicID})
-		if err != nil {
-			return fmt.Errorf("failed to delete from Cloudinary: %v", err)
-		}
+func (s *StorageService) GetFileURL(fileName string, folder string) string {
+	return s.store.URL(path.Join(s.config.UploadFolder, folder, fileName))
+}
+
+func (s *StorageService) DeleteFile(fileURL string) error {
+	key, ok := s.store.KeyForURL(fileURL)
+	if !ok {
+		return fmt.Errorf("URL does not belong to %s storage: %s", s.store.Name(), fileURL)
+	}
+	return s.DeleteKey(key)
+}
 
+// DeleteKey removes a stored object; an object that is already gone is not an error
+func (s *StorageService) DeleteKey(key string) error {
+	err := s.store.Delete(context.Background(), key)
+	if errors.Is(err, ErrBlobNotFound) {
 		return nil
 	}
+	return err
+}
 
-	return nil
+// KeyForURL recovers the storage key from a URL issued by the configured blob store
+func (s *StorageService) KeyForURL(fileURL string) (string, bool) {
+	return s.store.KeyForURL(fileURL)
 }
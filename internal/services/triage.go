@@ -0,0 +1,292 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/nyumbanicare/internal/i18n"
+)
+
+// Urgency levels, lowest to highest
+const (
+	UrgencyLow       = "low"
+	UrgencyMedium    = "medium"
+	UrgencyHigh      = "high"
+	UrgencyEmergency = "emergency"
+)
+
+var urgencyRank = map[string]int{
+	UrgencyLow:       1,
+	UrgencyMedium:    2,
+	UrgencyHigh:      3,
+	UrgencyEmergency: 4,
+}
+
+// Red flag sources
+const (
+	RedFlagSourceSymptoms = "symptoms"
+	RedFlagSourceAnalysis = "analysis"
+)
+
+// RedFlag is a safety rule that matched a symptom check
+type RedFlag struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Urgency     string `json:"urgency"`
+	Source      string `json:"source"` // symptoms, analysis
+}
+
+// TriageResult is the outcome of running the red flag rules
+type TriageResult struct {
+	Urgency  string    `json:"urgency"`
+	RedFlags []RedFlag `json:"red_flags"`
+}
+
+// redFlagRule matches when any keyword is present and, if AlsoRequires is set,
+// any of those keywords is present as well. MaxAge limits the rule to young patients.
+type redFlagRule struct {
+	Code         string
+	Description  string
+	Urgency      string
+	Keywords     []string
+	AlsoRequires []string
+	MaxAge       int
+}
+
+// symptomRedFlagRules run on the reported symptoms before the LLM is consulted.
+// Keywords are lower case and include common Swahili phrasing.
+var symptomRedFlagRules = []redFlagRule{
+	{
+		Code:        "chest_pain",
+		Description: "Chest pain or pressure",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"chest pain", "chest tightness", "chest pressure", "pain in chest", "maumivu ya kifua"},
+	},
+	{
+		Code:        "breathing_difficulty",
+		Description: "Difficulty breathing",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"difficulty breathing", "shortness of breath", "can't breathe", "cannot breathe", "breathlessness", "struggling to breathe", "kupumua kwa shida", "shida ya kupumua"},
+	},
+	{
+		Code:        "severe_bleeding",
+		Description: "Severe or uncontrolled bleeding",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"severe bleeding", "uncontrolled bleeding", "heavy bleeding", "vomiting blood", "coughing blood", "coughing up blood", "kutokwa na damu nyingi"},
+	},
+	{
+		Code:        "loss_of_consciousness",
+		Description: "Loss of consciousness or unresponsiveness",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"loss of consciousness", "unconscious", "unresponsive", "passed out", "fainting", "kupoteza fahamu", "kuzimia"},
+	},
+	{
+		Code:        "seizure",
+		Description: "Seizure or convulsions",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"seizure", "convulsion", " fits ", "degedege", "kifafa"},
+	},
+	{
+		Code:        "stroke_signs",
+		Description: "Possible stroke signs",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"face drooping", "slurred speech", "weakness on one side", "numbness on one side", "sudden confusion", "sudden vision loss"},
+	},
+	{
+		Code:        "anaphylaxis",
+		Description: "Possible severe allergic reaction",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"swelling of the throat", "throat swelling", "swollen tongue", "anaphylaxis", "severe allergic reaction"},
+	},
+	{
+		Code:        "self_harm",
+		Description: "Thoughts of suicide or self-harm",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"suicidal", "suicide", "self-harm", "self harm", "want to die", "kujiua"},
+	},
+	{
+		Code:        "poisoning",
+		Description: "Poisoning or overdose",
+		Urgency:     UrgencyEmergency,
+		Keywords:    []string{"poisoning", "overdose", "swallowed poison", "sumu"},
+	},
+	{
+		Code:         "meningitis_signs",
+		Description:  "Fever with stiff neck",
+		Urgency:      UrgencyEmergency,
+		Keywords:     []string{"stiff neck", "neck stiffness", "shingo ngumu"},
+		AlsoRequires: []string{"fever", "homa"},
+	},
+	{
+		Code:        "infant_fever",
+		Description: "Fever in an infant",
+		Urgency:     UrgencyHigh,
+		Keywords:    []string{"fever", "homa"},
+		MaxAge:      1,
+	},
+	{
+		Code:        "severe_abdominal_pain",
+		Description: "Severe abdominal pain",
+		Urgency:     UrgencyHigh,
+		Keywords:    []string{"severe abdominal pain", "severe stomach pain", "maumivu makali ya tumbo"},
+	},
+	{
+		Code:        "blood_in_stool",
+		Description: "Blood in stool or urine",
+		Urgency:     UrgencyHigh,
+		Keywords:    []string{"blood in stool", "bloody stool", "black stool", "blood in urine", "damu kwenye choo"},
+	},
+	{
+		Code:        "dehydration",
+		Description: "Signs of severe dehydration",
+		Urgency:     UrgencyHigh,
+		Keywords:    []string{"severe dehydration", "no urine", "not urinating", "sunken eyes"},
+	},
+}
+
+// analysisRedFlagRules run on the conditions suggested by the LLM so that a
+// dangerous differential is never reported with a low urgency.
+var analysisRedFlagRules = []redFlagRule{
+	{Code: "cardiac_event", Description: "Possible heart attack", Urgency: UrgencyEmergency, Keywords: []string{"myocardial infarction", "heart attack", "acute coronary", "cardiac arrest"}},
+	{Code: "stroke", Description: "Possible stroke", Urgency: UrgencyEmergency, Keywords: []string{"stroke", "cerebrovascular"}},
+	{Code: "pulmonary_embolism", Description: "Possible pulmonary embolism", Urgency: UrgencyEmergency, Keywords: []string{"pulmonary embolism"}},
+	{Code: "sepsis", Description: "Possible sepsis", Urgency: UrgencyEmergency, Keywords: []string{"sepsis", "septic"}},
+	{Code: "meningitis", Description: "Possible meningitis", Urgency: UrgencyEmergency, Keywords: []string{"meningitis"}},
+	{Code: "anaphylaxis", Description: "Possible anaphylaxis", Urgency: UrgencyEmergency, Keywords: []string{"anaphylaxis", "anaphylactic"}},
+	{Code: "ectopic_pregnancy", Description: "Possible ectopic pregnancy", Urgency: UrgencyEmergency, Keywords: []string{"ectopic pregnancy"}},
+	{Code: "severe_malaria", Description: "Possible severe malaria", Urgency: UrgencyHigh, Keywords: []string{"severe malaria", "cerebral malaria"}},
+	{Code: "appendicitis", Description: "Possible appendicitis", Urgency: UrgencyHigh, Keywords: []string{"appendicitis"}},
+	{Code: "pneumonia", Description: "Possible pneumonia", Urgency: UrgencyHigh, Keywords: []string{"pneumonia"}},
+}
+
+// minimumConditionProbability ignores long-shot differentials when escalating on the LLM output
+const minimumConditionProbability = 0.2
+
+// emergencyGuidance is shown with every emergency triage result
+var emergencyGuidance = []string{
+	"This may be a medical emergency. Call 999 or 112 now, or go to the nearest hospital emergency department.",
+	"Kenya Red Cross ambulance: call 1199.",
+	"Do not drive yourself. Ask someone to take you or wait for the ambulance.",
+	"Do not wait for online advice or a telehealth appointment.",
+}
+
+// NormalizeUrgency maps model output such as "Moderate" or "critical" onto the supported urgency levels
+func NormalizeUrgency(urgency string) string {
+	switch strings.ToLower(strings.TrimSpace(urgency)) {
+	case "low", "mild", "minor", "routine":
+		return UrgencyLow
+	case "medium", "moderate":
+		return UrgencyMedium
+	case "high", "urgent", "severe":
+		return UrgencyHigh
+	case "emergency", "critical", "immediate":
+		return UrgencyEmergency
+	default:
+		return ""
+	}
+}
+
+// MaxUrgency returns the most urgent of the given levels
+func MaxUrgency(levels ...string) string {
+	max := UrgencyLow
+	for _, level := range levels {
+		if urgencyRank[level] > urgencyRank[max] {
+			max = level
+		}
+	}
+	return max
+}
+
+// EvaluateSymptomRedFlags runs the deterministic red flag rules on the reported symptoms
+func EvaluateSymptomRedFlags(request SymptomCheckRequest, severity string) TriageResult {
+	text := normalizeTriageText(request.Symptoms...)
+	result := TriageResult{Urgency: UrgencyLow}
+
+	for _, rule := range symptomRedFlagRules {
+		if rule.MaxAge > 0 && request.Age > rule.MaxAge {
+			continue
+		}
+		if !rule.matches(text) {
+			continue
+		}
+		result.add(rule, RedFlagSourceSymptoms, request.Language)
+	}
+
+	if strings.EqualFold(severity, "severe") {
+		result.Urgency = MaxUrgency(result.Urgency, UrgencyMedium)
+	}
+
+	return result
+}
+
+// EvaluateAnalysisRedFlags runs the red flag rules on the conditions suggested by the model
+func EvaluateAnalysisRedFlags(response *SymptomCheckResponse, language string) TriageResult {
+	result := TriageResult{Urgency: UrgencyLow}
+	if response == nil {
+		return result
+	}
+
+	var parts []string
+	for _, condition := range response.PossibleConditions {
+		if condition.Probability >= minimumConditionProbability {
+			parts = append(parts, condition.Name, condition.Description)
+		}
+	}
+	text := normalizeTriageText(parts...)
+
+	for _, rule := range analysisRedFlagRules {
+		if rule.matches(text) {
+			result.add(rule, RedFlagSourceAnalysis, language)
+		}
+	}
+
+	return result
+}
+
+// ApplyTriage raises the response urgency to at least the triage level, records the
+// red flags and attaches emergency guidance when the result is an emergency
+func ApplyTriage(response *SymptomCheckResponse, language string, results ...TriageResult) {
+	levels := []string{NormalizeUrgency(response.Urgency)}
+	for _, result := range results {
+		levels = append(levels, result.Urgency)
+		response.RedFlags = append(response.RedFlags, result.RedFlags...)
+	}
+	response.Urgency = MaxUrgency(levels...)
+
+	if response.Urgency == UrgencyEmergency {
+		response.EmergencyGuidance = i18n.TSlice(language, emergencyGuidance)
+		response.Recommendations = append([]string{response.EmergencyGuidance[0]}, response.Recommendations...)
+	}
+}
+
+func (r redFlagRule) matches(text string) bool {
+	if !containsAny(text, r.Keywords) {
+		return false
+	}
+	if len(r.AlsoRequires) > 0 && !containsAny(text, r.AlsoRequires) {
+		return false
+	}
+	return true
+}
+
+func (t *TriageResult) add(rule redFlagRule, source, language string) {
+	t.RedFlags = append(t.RedFlags, RedFlag{
+		Code:        rule.Code,
+		Description: i18n.T(language, rule.Description),
+		Urgency:     rule.Urgency,
+		Source:      source,
+	})
+	t.Urgency = MaxUrgency(t.Urgency, rule.Urgency)
+}
+
+func normalizeTriageText(parts ...string) string {
+	return " " + strings.ToLower(strings.Join(parts, " | ")) + " "
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nyumbanicare/internal/config"
+)
+
+// fakeChatServer is an OpenAI-compatible chat completions server that answers
+// every request with handler and counts the calls it receives
+func fakeChatServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// chatCompletion writes content as a chat completions response
+func chatCompletion(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   "test-model",
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{"prompt_tokens": 10, "completion_tokens": 10, "total_tokens": 20},
+	})
+}
+
+func testAIService(server *httptest.Server, timeoutSeconds int) *AIService {
+	return NewAIService(&config.ExternalConfig{
+		AIProvider:       LLMProviderOpenAICompatible,
+		ChatGPTEndpoint:  server.URL + "/v1/chat/completions",
+		ChatGPTModel:     "test-model",
+		AITimeoutSeconds: timeoutSeconds,
+		AIMaxRetries:     0,
+	})
+}
+
+func hasRedFlag(response *SymptomCheckResponse, code string) bool {
+	for _, flag := range response.RedFlags {
+		if flag.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTriageSymptomsRedFlagSkipsModel(t *testing.T) {
+	server, calls := fakeChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+		chatCompletion(w, `{"possible_conditions": [], "recommendations": ["Rest"], "urgency": "low", "confidence": 0.9}`)
+	})
+
+	response := testAIService(server, 5).TriageSymptoms(SymptomCheckRequest{
+		Symptoms: []string{"Chest pain", "sweating"},
+		Age:      54,
+		Gender:   "male",
+		Language: "en",
+	}, "moderate")
+
+	if got := atomic.LoadInt32(calls); got != 0 {
+		t.Fatalf("model was called %d times for an emergency red flag", got)
+	}
+	if response.Urgency != UrgencyEmergency {
+		t.Errorf("urgency = %q, want %q", response.Urgency, UrgencyEmergency)
+	}
+	if response.Source != AnalysisSourceRules {
+		t.Errorf("source = %q, want %q", response.Source, AnalysisSourceRules)
+	}
+	if !hasRedFlag(response, "chest_pain") {
+		t.Errorf("red flags %+v do not include chest_pain", response.RedFlags)
+	}
+	if len(response.EmergencyGuidance) == 0 {
+		t.Error("emergency guidance missing")
+	}
+}
+
+func TestTriageSymptomsEscalatesMildModelAnswer(t *testing.T) {
+	server, calls := fakeChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+		chatCompletion(w, `{"possible_conditions": [{"name": "Bacterial meningitis", "probability": 0.4, "description": "Infection of the brain lining"}], "recommendations": ["Drink fluids"], "urgency": "low", "confidence": 0.7}`)
+	})
+
+	response := testAIService(server, 5).TriageSymptoms(SymptomCheckRequest{
+		Symptoms: []string{"headache", "vomiting"},
+		Age:      19,
+		Gender:   "female",
+		Language: "en",
+	}, "moderate")
+
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("model was called %d times, want 1", got)
+	}
+	if response.Source != AnalysisSourceModel {
+		t.Errorf("source = %q, want %q", response.Source, AnalysisSourceModel)
+	}
+	if response.Urgency != UrgencyEmergency {
+		t.Errorf("urgency = %q, want the model's low answer raised to %q", response.Urgency, UrgencyEmergency)
+	}
+	if !hasRedFlag(response, "meningitis") {
+		t.Errorf("red flags %+v do not include meningitis", response.RedFlags)
+	}
+}
+
+func TestTriageSymptomsInfantFever(t *testing.T) {
+	server, _ := fakeChatServer(t, func(w http.ResponseWriter, r *http.Request) {
+		chatCompletion(w, `{"possible_conditions": [], "recommendations": ["Rest"], "urgency": "low", "confidence": 0.5}`)
+	})
+
+	response := testAIService(server, 5).TriageSymptoms(SymptomCheckRequest{
+		Symptoms: []string{"fever"},
+		Age:      0,
+		Gender:   "female",
+		Language: "en",
+	}, "mild")
+
+	if response.Urgency != UrgencyHigh || !hasRedFlag(response, "infant_fever") {
+		t.Errorf("urgency = %q with red flags %+v, want high with infant_fever", response.Urgency, response.RedFlags)
+	}
+}
+
+func TestTriageSymptomsFallsBackToRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		timeoutSeconds int
+		handler        func(w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name:           "provider error",
+			timeoutSeconds: 5,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"error": {"message": "model crashed", "type": "server_error"}}`))
+			},
+		},
+		{
+			name:           "provider timeout",
+			timeoutSeconds: 1,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(1500 * time.Millisecond):
+				case <-r.Context().Done():
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, calls := fakeChatServer(t, tt.handler)
+
+			start := time.Now()
+			response := testAIService(server, tt.timeoutSeconds).TriageSymptoms(SymptomCheckRequest{
+				Symptoms: []string{"fever", "cough"},
+				Age:      30,
+				Gender:   "male",
+				Language: "en",
+			}, "moderate")
+
+			if got := atomic.LoadInt32(calls); got == 0 {
+				t.Fatal("model was never called")
+			}
+			if elapsed := time.Since(start); elapsed > 2500*time.Millisecond {
+				t.Errorf("fallback took %s", elapsed)
+			}
+			if response.Source != AnalysisSourceFallback {
+				t.Errorf("source = %q, want %q", response.Source, AnalysisSourceFallback)
+			}
+			if response.Urgency != UrgencyMedium {
+				t.Errorf("urgency = %q, want the rules engine's %q", response.Urgency, UrgencyMedium)
+			}
+			if len(response.PossibleConditions) == 0 {
+				t.Error("rules engine returned no conditions")
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueueFull is returned when a job cannot be queued without blocking
+var ErrQueueFull = errors.New("worker queue is full")
+
+// Job is a unit of background work retried with exponential backoff
+type Job struct {
+	Name        string
+	MaxAttempts int
+	// Run performs one attempt; attempt starts at 1
+	Run func(attempt int) error
+	// OnFailure is called once every attempt has failed
+	OnFailure func(err error)
+}
+
+// WorkerPool runs jobs on a fixed number of goroutines fed by a bounded queue
+type WorkerPool struct {
+	jobs    chan Job
+	backoff time.Duration
+}
+
+// NewWorkerPool starts workers goroutines reading from a queue of queueSize jobs
+func NewWorkerPool(workers, queueSize int, backoff time.Duration) *WorkerPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	pool := &WorkerPool{
+		jobs:    make(chan Job, queueSize),
+		backoff: backoff,
+	}
+	for i := 0; i < workers; i++ {
+		go pool.work()
+	}
+	return pool
+}
+
+// Submit queues a job, returning ErrQueueFull instead of blocking the caller
+func (p *WorkerPool) Submit(job Job) error {
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (p *WorkerPool) work() {
+	for job := range p.jobs {
+		p.runJob(job)
+	}
+}
+
+func (p *WorkerPool) runJob(job Job) {
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = runAttempt(job, attempt); err == nil {
+			return
+		}
+		fmt.Printf("Job %s failed (attempt %d/%d): %v\n", job.Name, attempt, maxAttempts, err)
+		if attempt < maxAttempts {
+			time.Sleep(p.backoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+
+	if job.OnFailure != nil {
+		job.OnFailure(err)
+	}
+}
+
+// runAttempt turns a panic in a job into an error so one bad job cannot stop a worker
+func runAttempt(job Job, attempt int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(attempt)
+}